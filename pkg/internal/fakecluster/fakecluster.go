@@ -0,0 +1,261 @@
+// Package fakecluster provides a single fake Kafka broker for tests that need
+// to control broker responses without a real cluster.
+package fakecluster
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"github.com/twmb/franz-go/pkg/kversion"
+)
+
+// Cluster is a single fake broker speaking the Kafka protocol.
+//
+// ApiVersions requests are always answered with the stable versions. By
+// default, metadata requests are answered with the fake broker as the only
+// broker, the controller, and the leader of every partition of the cluster's
+// topics; find coordinator requests are answered with the fake broker; and
+// init producer ID requests are answered with producer ID 1. Any other request
+// must have a handler set with Control.
+type Cluster struct {
+	t    testing.TB
+	ln   net.Listener
+	host string
+	port int32
+
+	mu       sync.Mutex
+	topics   map[string]int32 // topic => number of partitions
+	handlers map[int16]func(kmsg.Request) (kmsg.Response, error)
+	reqs     map[int16]int
+	conns    []net.Conn
+}
+
+// ErrCloseConn can be returned from a Cluster handler to close the
+// connection rather than reply.
+var ErrCloseConn = errors.New("close connection")
+
+// New returns a fake cluster serving the given topics, each mapped to its
+// number of partitions. The cluster must be closed when the test is done.
+func New(t testing.TB, topics map[string]int32) *Cluster {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	port64, _ := strconv.ParseInt(port, 10, 32)
+	c := &Cluster{
+		t:        t,
+		ln:       ln,
+		host:     host,
+		port:     int32(port64),
+		topics:   make(map[string]int32),
+		handlers: make(map[int16]func(kmsg.Request) (kmsg.Response, error)),
+		reqs:     make(map[int16]int),
+	}
+	for topic, partitions := range topics {
+		c.topics[topic] = partitions
+	}
+	go c.accept()
+	return c
+}
+
+// Addr returns the address of the fake broker.
+func (c *Cluster) Addr() string { return c.ln.Addr().String() }
+
+// Control sets the handler for requests of the given key.
+func (c *Cluster) Control(key int16, fn func(kmsg.Request) (kmsg.Response, error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers[key] = fn
+}
+
+// DeleteTopic removes a topic from the cluster, so that metadata requests no
+// longer return it.
+func (c *Cluster) DeleteTopic(topic string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.topics, topic)
+}
+
+// NumReqs returns how many requests of the given key the cluster received.
+func (c *Cluster) NumReqs(key int16) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reqs[key]
+}
+
+// Close stops the fake broker and closes all of its connections.
+func (c *Cluster) Close() {
+	c.ln.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+}
+
+func (c *Cluster) accept() {
+	for {
+		conn, err := c.ln.Accept()
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		c.conns = append(c.conns, conn)
+		c.mu.Unlock()
+		go c.serve(conn)
+	}
+}
+
+func (c *Cluster) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		buf := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		req, corrID, ok := parseFakeRequest(buf)
+		if !ok {
+			return
+		}
+		resp, err := c.handle(req)
+		if err != nil {
+			return
+		}
+		if resp == nil {
+			continue // e.g., acks=0 produce
+		}
+		resp.SetVersion(req.GetVersion())
+
+		out := make([]byte, 8, 64)
+		binary.BigEndian.PutUint32(out[4:], uint32(corrID))
+		if resp.IsFlexible() && req.Key() != 18 { // api versions never uses a flexible response header
+			out = append(out, 0)
+		}
+		out = resp.AppendTo(out)
+		binary.BigEndian.PutUint32(out, uint32(len(out)-4))
+		if _, err := conn.Write(out); err != nil {
+			return
+		}
+	}
+}
+
+func parseFakeRequest(buf []byte) (kmsg.Request, int32, bool) {
+	if len(buf) < 10 {
+		return nil, 0, false
+	}
+	key := int16(binary.BigEndian.Uint16(buf))
+	version := int16(binary.BigEndian.Uint16(buf[2:]))
+	corrID := int32(binary.BigEndian.Uint32(buf[4:]))
+	clientIDLen := int16(binary.BigEndian.Uint16(buf[8:]))
+	buf = buf[10:]
+	if clientIDLen > 0 {
+		buf = buf[clientIDLen:]
+	}
+	req := kmsg.RequestForKey(key)
+	if req == nil {
+		return nil, 0, false
+	}
+	req.SetVersion(version)
+	if req.IsFlexible() {
+		ntags, n := binary.Uvarint(buf)
+		buf = buf[n:]
+		for ; ntags > 0; ntags-- {
+			_, n := binary.Uvarint(buf)
+			buf = buf[n:]
+			size, n := binary.Uvarint(buf)
+			buf = buf[n+int(size):]
+		}
+	}
+	if err := req.ReadFrom(buf); err != nil {
+		return nil, 0, false
+	}
+	return req, corrID, true
+}
+
+func (c *Cluster) handle(req kmsg.Request) (kmsg.Response, error) {
+	c.mu.Lock()
+	c.reqs[req.Key()]++
+	fn := c.handlers[req.Key()]
+	c.mu.Unlock()
+
+	if req.Key() == 18 {
+		resp := kmsg.NewPtrApiVersionsResponse()
+		kversion.Stable().EachMaxKeyVersion(func(key, version int16) {
+			k := kmsg.NewApiVersionsResponseApiKey()
+			k.ApiKey, k.MaxVersion = key, version
+			resp.ApiKeys = append(resp.ApiKeys, k)
+		})
+		return resp, nil
+	}
+	if fn != nil {
+		return fn(req)
+	}
+
+	switch req := req.(type) {
+	case *kmsg.MetadataRequest:
+		return c.Metadata(req), nil
+	case *kmsg.FindCoordinatorRequest:
+		resp := req.ResponseKind().(*kmsg.FindCoordinatorResponse)
+		resp.Host, resp.Port = c.host, c.port
+		return resp, nil
+	case *kmsg.InitProducerIDRequest:
+		resp := req.ResponseKind().(*kmsg.InitProducerIDResponse)
+		resp.ProducerID = 1
+		return resp, nil
+	}
+	c.t.Errorf("fake cluster: unexpected request key %d", req.Key())
+	return nil, ErrCloseConn
+}
+
+// Metadata returns the default metadata response for req, which handlers
+// can use to modify the default response.
+func (c *Cluster) Metadata(req *kmsg.MetadataRequest) *kmsg.MetadataResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resp := req.ResponseKind().(*kmsg.MetadataResponse)
+	b := kmsg.NewMetadataResponseBroker()
+	b.Host, b.Port = c.host, c.port
+	resp.Brokers = append(resp.Brokers, b)
+
+	addTopic := func(topic string) {
+		t := kmsg.NewMetadataResponseTopic()
+		t.Topic = topic
+		partitions, exists := c.topics[topic]
+		if !exists {
+			t.ErrorCode = kerr.UnknownTopicOrPartition.Code
+		}
+		for p := int32(0); p < partitions; p++ {
+			rp := kmsg.NewMetadataResponseTopicPartition()
+			rp.Partition = p
+			rp.Replicas = []int32{0}
+			rp.ISR = []int32{0}
+			t.Partitions = append(t.Partitions, rp)
+		}
+		resp.Topics = append(resp.Topics, t)
+	}
+	if req.Topics == nil {
+		for topic := range c.topics {
+			addTopic(topic)
+		}
+	} else {
+		for _, t := range req.Topics {
+			if t.Topic != nil {
+				addTopic(*t.Topic)
+			}
+		}
+	}
+	return resp
+}
@@ -0,0 +1,252 @@
+package kadm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// ACLPattern is how an ACL's resource name is matched against resources
+// (KIP-290).
+//
+// This mirrors the wire values Kafka uses, which are offset by one from the
+// names of kmsg.ACLResourcePatternType; converting an ACLPattern directly to
+// that type yields the correct wire value.
+type ACLPattern int8
+
+const (
+	// ACLPatternAny matches ACLs of any pattern type, and is only valid
+	// in filters.
+	ACLPatternAny ACLPattern = 1
+	// ACLPatternMatch matches ACLs whose literal name, wildcard, or
+	// prefix matches the filter's resource name, and is only valid in
+	// filters.
+	ACLPatternMatch ACLPattern = 2
+	// ACLPatternLiteral is an exact resource name, or the wildcard "*".
+	ACLPatternLiteral ACLPattern = 3
+	// ACLPatternPrefixed is a resource name prefix.
+	ACLPatternPrefixed ACLPattern = 4
+)
+
+func (p ACLPattern) String() string {
+	switch p {
+	case ACLPatternAny:
+		return "ANY"
+	case ACLPatternMatch:
+		return "MATCH"
+	case ACLPatternLiteral:
+		return "LITERAL"
+	case ACLPatternPrefixed:
+		return "PREFIXED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ACL is a single access control entry: a principal being allowed or denied
+// an operation from a host on a resource.
+type ACL struct {
+	ResourceType kmsg.ACLResourceType // ResourceType is the type of resource, e.g. TOPIC.
+	ResourceName string               // ResourceName is the resource name, or "*" for all.
+	Pattern      ACLPattern           // Pattern is how ResourceName is matched; zero means LITERAL.
+
+	Principal  string                 // Principal is the principal, e.g. "User:bob".
+	Host       string                 // Host is the host, or "*" for all hosts.
+	Operation  kmsg.ACLOperation      // Operation is the operation, e.g. READ.
+	Permission kmsg.ACLPermissionType // Permission is ALLOW or DENY.
+}
+
+func (a ACL) String() string {
+	pattern := a.Pattern
+	if pattern == 0 {
+		pattern = ACLPatternLiteral
+	}
+	return fmt.Sprintf("%s %s %s from %s on %s %s:%s", a.Principal, a.Permission, a.Operation, a.Host, pattern, a.ResourceType, a.ResourceName)
+}
+
+// ACLFilter filters ACLs to describe or delete. Nil string fields match any
+// value, and zero enum fields match any value (or, for Pattern, any pattern
+// type).
+type ACLFilter struct {
+	ResourceType kmsg.ACLResourceType
+	ResourceName *string
+	Pattern      ACLPattern
+
+	Principal  *string
+	Host       *string
+	Operation  kmsg.ACLOperation
+	Permission kmsg.ACLPermissionType
+}
+
+// withAny returns the filter with zero enum fields set to ANY.
+func (f ACLFilter) withAny() ACLFilter {
+	if f.ResourceType == kmsg.ACLResourceTypeUnknown {
+		f.ResourceType = kmsg.ACLResourceTypeAny
+	}
+	if f.Pattern == 0 {
+		f.Pattern = ACLPatternAny
+	}
+	if f.Operation == kmsg.ACLOperationUnknown {
+		f.Operation = kmsg.ACLOperationAny
+	}
+	if f.Permission == kmsg.ACLPermissionTypeUnknown {
+		f.Permission = kmsg.ACLPermissionTypeAny
+	}
+	return f
+}
+
+// DescribeACLs issues a DescribeACLs request, returning all ACLs matching
+// the filter. A zero filter returns every ACL in the cluster.
+func (cl *Client) DescribeACLs(ctx context.Context, filter ACLFilter) ([]ACL, error) {
+	filter = filter.withAny()
+	req := kmsg.NewPtrDescribeACLsRequest()
+	req.ResourceType = filter.ResourceType
+	req.ResourceName = filter.ResourceName
+	req.ResourcePatternType = kmsg.ACLResourcePatternType(filter.Pattern)
+	req.Principal = filter.Principal
+	req.Host = filter.Host
+	req.Operation = filter.Operation
+	req.PermissionType = filter.Permission
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if err := kerr.ErrorForCodeMessage(resp.ErrorCode, resp.ErrorMessage); err != nil {
+		return nil, err
+	}
+
+	var acls []ACL
+	for _, r := range resp.Resources {
+		for _, a := range r.ACLs {
+			acls = append(acls, ACL{
+				ResourceType: r.ResourceType,
+				ResourceName: r.ResourceName,
+				Pattern:      ACLPattern(r.ResourcePatternType),
+				Principal:    a.Principal,
+				Host:         a.Host,
+				Operation:    a.Operation,
+				Permission:   a.PermissionType,
+			})
+		}
+	}
+	return acls, nil
+}
+
+// CreateACLsResult is the result of creating a single ACL.
+type CreateACLsResult struct {
+	ACL ACL   // ACL is the ACL that was requested to be created.
+	Err error // Err is any error for this ACL, with any broker message (see kerr.MessageError).
+}
+
+// CreateACLs issues a CreateACLs request, returning a result per ACL in the
+// order the ACLs were given.
+//
+// This returns an error only if the request fails; per-ACL errors are in
+// each result.
+func (cl *Client) CreateACLs(ctx context.Context, acls []ACL) ([]CreateACLsResult, error) {
+	req := kmsg.NewPtrCreateACLsRequest()
+	for _, a := range acls {
+		pattern := a.Pattern
+		if pattern == 0 {
+			pattern = ACLPatternLiteral
+		}
+		c := kmsg.NewCreateACLsRequestCreation()
+		c.ResourceType = a.ResourceType
+		c.ResourceName = a.ResourceName
+		c.ResourcePatternType = kmsg.ACLResourcePatternType(pattern)
+		c.Principal = a.Principal
+		c.Host = a.Host
+		c.Operation = a.Operation
+		c.PermissionType = a.Permission
+		req.Creations = append(req.Creations, c)
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(acls) {
+		return nil, fmt.Errorf("broker replied with %d results for %d acl creations", len(resp.Results), len(acls))
+	}
+
+	results := make([]CreateACLsResult, 0, len(acls))
+	for i, r := range resp.Results {
+		results = append(results, CreateACLsResult{
+			ACL: acls[i],
+			Err: kerr.ErrorForCodeMessage(r.ErrorCode, r.ErrorMessage),
+		})
+	}
+	return results, nil
+}
+
+// DeletedACL is a single ACL that matched a delete filter.
+type DeletedACL struct {
+	ACL ACL   // ACL is the matching ACL.
+	Err error // Err is any error deleting this ACL, with any broker message (see kerr.MessageError).
+}
+
+// DeleteACLsResult is the result of deleting ACLs with a single filter.
+type DeleteACLsResult struct {
+	Filter  ACLFilter    // Filter is the filter that was requested.
+	Deleted []DeletedACL // Deleted contains every ACL the filter matched.
+
+	Err error // Err is any error for the filter as a whole, with any broker message (see kerr.MessageError).
+}
+
+// DeleteACLs issues a DeleteACLs request, returning a result per filter in
+// the order the filters were given. Each result contains every ACL the filter
+// matched and whether that ACL was deleted.
+//
+// Note that a zero filter matches, and thus deletes, every ACL.
+//
+// This returns an error only if the request fails; per-filter and per-ACL
+// errors are in each result.
+func (cl *Client) DeleteACLs(ctx context.Context, filters []ACLFilter) ([]DeleteACLsResult, error) {
+	req := kmsg.NewPtrDeleteACLsRequest()
+	for _, f := range filters {
+		f = f.withAny()
+		rf := kmsg.NewDeleteACLsRequestFilter()
+		rf.ResourceType = f.ResourceType
+		rf.ResourceName = f.ResourceName
+		rf.ResourcePatternType = kmsg.ACLResourcePatternType(f.Pattern)
+		rf.Principal = f.Principal
+		rf.Host = f.Host
+		rf.Operation = f.Operation
+		rf.PermissionType = f.Permission
+		req.Filters = append(req.Filters, rf)
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(filters) {
+		return nil, fmt.Errorf("broker replied with %d results for %d acl delete filters", len(resp.Results), len(filters))
+	}
+
+	results := make([]DeleteACLsResult, 0, len(filters))
+	for i, r := range resp.Results {
+		dr := DeleteACLsResult{Filter: filters[i]}
+		dr.Err = kerr.ErrorForCodeMessage(r.ErrorCode, r.ErrorMessage)
+		for _, m := range r.MatchingACLs {
+			d := DeletedACL{
+				ACL: ACL{
+					ResourceType: m.ResourceType,
+					ResourceName: m.ResourceName,
+					Pattern:      ACLPattern(m.ResourcePatternType),
+					Principal:    m.Principal,
+					Host:         m.Host,
+					Operation:    m.Operation,
+					Permission:   m.PermissionType,
+				},
+			}
+			d.Err = kerr.ErrorForCodeMessage(m.ErrorCode, m.ErrorMessage)
+			dr.Deleted = append(dr.Deleted, d)
+		}
+		results = append(results, dr)
+	}
+	return results, nil
+}
@@ -0,0 +1,292 @@
+package kadm
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestDescribeACLs(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	var (
+		mu  sync.Mutex
+		got *kmsg.DescribeACLsRequest
+	)
+	c.Control(29, func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = req.(*kmsg.DescribeACLsRequest)
+		resp := req.ResponseKind().(*kmsg.DescribeACLsResponse)
+		r := kmsg.NewDescribeACLsResponseResource()
+		r.ResourceType = kmsg.ACLResourceTypeTopic
+		r.ResourceName = "foo"
+		r.ResourcePatternType = kmsg.ACLResourcePatternType(ACLPatternPrefixed)
+		a := kmsg.NewDescribeACLsResponseResourceACL()
+		a.Principal = "User:bob"
+		a.Host = "*"
+		a.Operation = kmsg.ACLOperationRead
+		a.PermissionType = kmsg.ACLPermissionTypeAllow
+		r.ACLs = append(r.ACLs, a)
+		resp.Resources = append(resp.Resources, r)
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	principal := "User:bob"
+	acls, err := adm.DescribeACLs(ctx, ACLFilter{Principal: &principal})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Zero enum fields in the filter are sent as ANY.
+	if got.ResourceType != kmsg.ACLResourceTypeAny ||
+		got.ResourcePatternType != kmsg.ACLResourcePatternType(ACLPatternAny) ||
+		got.Operation != kmsg.ACLOperationAny ||
+		got.PermissionType != kmsg.ACLPermissionTypeAny {
+		t.Errorf("got request enums %v %v %v %v, expected all ANY", got.ResourceType, got.ResourcePatternType, got.Operation, got.PermissionType)
+	}
+	if got.Principal == nil || *got.Principal != principal || got.ResourceName != nil || got.Host != nil {
+		t.Errorf("got request principal %v, name %v, host %v; expected only the principal", got.Principal, got.ResourceName, got.Host)
+	}
+
+	exp := []ACL{{
+		ResourceType: kmsg.ACLResourceTypeTopic,
+		ResourceName: "foo",
+		Pattern:      ACLPatternPrefixed,
+		Principal:    "User:bob",
+		Host:         "*",
+		Operation:    kmsg.ACLOperationRead,
+		Permission:   kmsg.ACLPermissionTypeAllow,
+	}}
+	if !reflect.DeepEqual(acls, exp) {
+		t.Errorf("got %v != exp %v", acls, exp)
+	}
+}
+
+func TestDescribeACLsErr(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	c.Control(29, func(req kmsg.Request) (kmsg.Response, error) {
+		resp := req.ResponseKind().(*kmsg.DescribeACLsResponse)
+		resp.ErrorCode = kerr.SecurityDisabled.Code
+		resp.ErrorMessage = kmsg.StringPtr("no authorizer is configured")
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	if _, err := adm.DescribeACLs(ctx, ACLFilter{}); !errors.Is(err, kerr.SecurityDisabled) {
+		t.Errorf("got err %v, expected it to wrap %v", err, kerr.SecurityDisabled)
+	}
+}
+
+func TestCreateACLs(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	var (
+		mu  sync.Mutex
+		got *kmsg.CreateACLsRequest
+	)
+	c.Control(30, func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = req.(*kmsg.CreateACLsRequest)
+		resp := req.ResponseKind().(*kmsg.CreateACLsResponse)
+		for i := range got.Creations {
+			r := kmsg.NewCreateACLsResponseResult()
+			if i == 1 {
+				r.ErrorCode = kerr.InvalidRequest.Code
+				r.ErrorMessage = kmsg.StringPtr("invalid principal")
+			}
+			resp.Results = append(resp.Results, r)
+		}
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	acls := []ACL{
+		{
+			ResourceType: kmsg.ACLResourceTypeTopic,
+			ResourceName: "foo",
+			Principal:    "User:bob",
+			Host:         "*",
+			Operation:    kmsg.ACLOperationWrite,
+			Permission:   kmsg.ACLPermissionTypeAllow,
+		},
+		{
+			ResourceType: kmsg.ACLResourceTypeGroup,
+			ResourceName: "g",
+			Pattern:      ACLPatternPrefixed,
+			Principal:    "bob",
+			Host:         "*",
+			Operation:    kmsg.ACLOperationRead,
+			Permission:   kmsg.ACLPermissionTypeDeny,
+		},
+	}
+	rs, err := adm.CreateACLs(ctx, acls)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got.Creations) != 2 {
+		t.Fatalf("got %d creations != exp 2", len(got.Creations))
+	}
+	// A zero pattern is created as LITERAL.
+	if p := got.Creations[0].ResourcePatternType; p != kmsg.ACLResourcePatternType(ACLPatternLiteral) {
+		t.Errorf("got pattern %v != exp LITERAL", p)
+	}
+	if p := got.Creations[1].ResourcePatternType; p != kmsg.ACLResourcePatternType(ACLPatternPrefixed) {
+		t.Errorf("got pattern %v != exp PREFIXED", p)
+	}
+	if cr := got.Creations[1]; cr.ResourceType != kmsg.ACLResourceTypeGroup || cr.ResourceName != "g" ||
+		cr.Principal != "bob" || cr.Operation != kmsg.ACLOperationRead || cr.PermissionType != kmsg.ACLPermissionTypeDeny {
+		t.Errorf("got unexpected creation %v", cr)
+	}
+
+	if len(rs) != 2 {
+		t.Fatalf("got %d results != exp 2", len(rs))
+	}
+	if !reflect.DeepEqual(rs[0].ACL, acls[0]) || rs[0].Err != nil {
+		t.Errorf("got unexpected first result %v", rs[0])
+	}
+	if !reflect.DeepEqual(rs[1].ACL, acls[1]) || !errors.Is(rs[1].Err, kerr.InvalidRequest) {
+		t.Errorf("got unexpected second result %v", rs[1])
+	}
+}
+
+func TestCreateACLsMismatchedResults(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	c.Control(30, func(req kmsg.Request) (kmsg.Response, error) {
+		return req.ResponseKind(), nil // no results
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	if _, err := adm.CreateACLs(ctx, []ACL{{Principal: "User:bob"}}); err == nil {
+		t.Error("expected an err when the broker replies with fewer results than creations")
+	}
+}
+
+func TestDeleteACLs(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	var (
+		mu  sync.Mutex
+		got *kmsg.DeleteACLsRequest
+	)
+	c.Control(31, func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = req.(*kmsg.DeleteACLsRequest)
+		resp := req.ResponseKind().(*kmsg.DeleteACLsResponse)
+
+		r := kmsg.NewDeleteACLsResponseResult()
+		for _, principal := range []string{"User:a", "User:b"} {
+			m := kmsg.NewDeleteACLsResponseResultMatchingACL()
+			m.ResourceType = kmsg.ACLResourceTypeTopic
+			m.ResourceName = "foo"
+			m.ResourcePatternType = kmsg.ACLResourcePatternType(ACLPatternLiteral)
+			m.Principal = principal
+			m.Host = "*"
+			m.Operation = kmsg.ACLOperationAll
+			m.PermissionType = kmsg.ACLPermissionTypeAllow
+			if principal == "User:b" {
+				m.ErrorCode = kerr.UnknownServerError.Code
+			}
+			r.MatchingACLs = append(r.MatchingACLs, m)
+		}
+		resp.Results = append(resp.Results, r)
+
+		r = kmsg.NewDeleteACLsResponseResult()
+		r.ErrorCode = kerr.InvalidRequest.Code
+		r.ErrorMessage = kmsg.StringPtr("bad filter")
+		resp.Results = append(resp.Results, r)
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	name := "foo"
+	filters := []ACLFilter{
+		{ResourceType: kmsg.ACLResourceTypeTopic, ResourceName: &name},
+		{Pattern: ACLPatternMatch},
+	}
+	rs, err := adm.DeleteACLs(ctx, filters)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got.Filters) != 2 {
+		t.Fatalf("got %d filters != exp 2", len(got.Filters))
+	}
+	f0, f1 := got.Filters[0], got.Filters[1]
+	if f0.ResourceType != kmsg.ACLResourceTypeTopic || f0.ResourceName == nil || *f0.ResourceName != "foo" ||
+		f0.ResourcePatternType != kmsg.ACLResourcePatternType(ACLPatternAny) || f0.Operation != kmsg.ACLOperationAny {
+		t.Errorf("got unexpected first filter %v", f0)
+	}
+	if f1.ResourceType != kmsg.ACLResourceTypeAny || f1.ResourcePatternType != kmsg.ACLResourcePatternType(ACLPatternMatch) {
+		t.Errorf("got unexpected second filter %v", f1)
+	}
+
+	if len(rs) != 2 {
+		t.Fatalf("got %d results != exp 2", len(rs))
+	}
+	if !reflect.DeepEqual(rs[0].Filter, filters[0]) || rs[0].Err != nil || len(rs[0].Deleted) != 2 {
+		t.Fatalf("got unexpected first result %v", rs[0])
+	}
+	d0, d1 := rs[0].Deleted[0], rs[0].Deleted[1]
+	if d0.ACL.Principal != "User:a" || d0.ACL.Pattern != ACLPatternLiteral || d0.ACL.Operation != kmsg.ACLOperationAll || d0.Err != nil {
+		t.Errorf("got unexpected first deleted acl %v", d0)
+	}
+	if d1.ACL.Principal != "User:b" || !errors.Is(d1.Err, kerr.UnknownServerError) {
+		t.Errorf("got unexpected second deleted acl %v", d1)
+	}
+	if !errors.Is(rs[1].Err, kerr.InvalidRequest) || len(rs[1].Deleted) != 0 {
+		t.Errorf("got unexpected second result %v", rs[1])
+	}
+}
@@ -0,0 +1,18 @@
+package kadm
+
+import (
+	"context"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// DescribeCluster returns the brokers, controller, and cluster ID of the
+// cluster, with brokers sorted by node ID.
+//
+// If the cluster supports DescribeCluster (Kafka 2.8.0+, KIP-700), that
+// request is used, which avoids pulling the topic data that a metadata
+// response carries; otherwise, this falls back to a metadata request for no
+// topics. See kgo.Client.DiscoverBrokers, which this wraps, for more details.
+func (cl *Client) DescribeCluster(ctx context.Context) (kgo.ClusterInfo, error) {
+	return cl.cl.DiscoverBrokers(ctx)
+}
@@ -0,0 +1,103 @@
+package kadm
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestDescribeCluster(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"t": 1})
+	defer c.Close()
+
+	rack := "r"
+	c.Control(60, func(req kmsg.Request) (kmsg.Response, error) {
+		resp := req.ResponseKind().(*kmsg.DescribeClusterResponse)
+		resp.ClusterID = "cluster"
+		resp.ControllerID = 2
+		for _, id := range []int32{2, 1} {
+			b := kmsg.NewDescribeClusterResponseBroker()
+			b.NodeID, b.Host, b.Port, b.Rack = id, "host", 9092+id, &rack
+			resp.Brokers = append(resp.Brokers, b)
+		}
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	info, err := adm.DescribeCluster(ctx)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	clusterID := "cluster"
+	exp := kgo.ClusterInfo{
+		Brokers: []kgo.BrokerMetadata{
+			{NodeID: 1, Host: "host", Port: 9093, Rack: &rack},
+			{NodeID: 2, Host: "host", Port: 9094, Rack: &rack},
+		},
+		Controller: 2,
+		ClusterID:  &clusterID,
+	}
+	if !reflect.DeepEqual(info, exp) {
+		t.Errorf("got %+v != exp %+v", info, exp)
+	}
+	if n := c.NumReqs(3); n != 0 {
+		t.Errorf("got %d metadata requests, expected 0", n)
+	}
+}
+
+func TestDescribeClusterFallback(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"t": 1})
+	defer c.Close()
+
+	c.Control(60, func(req kmsg.Request) (kmsg.Response, error) {
+		resp := req.ResponseKind().(*kmsg.DescribeClusterResponse)
+		resp.ErrorCode = kerr.UnknownServerError.Code
+		return resp, nil
+	})
+	var (
+		mu          sync.Mutex
+		noTopicReqs int
+	)
+	c.Control(3, func(req kmsg.Request) (kmsg.Response, error) {
+		meta := req.(*kmsg.MetadataRequest)
+		mu.Lock()
+		if meta.Topics != nil && len(meta.Topics) == 0 {
+			noTopicReqs++
+		}
+		mu.Unlock()
+		resp := c.Metadata(meta)
+		resp.ControllerID = 0
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	info, err := adm.DescribeCluster(ctx)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(info.Brokers) != 1 || info.Brokers[0].NodeID != 0 || info.Controller != 0 {
+		t.Errorf("got %+v, expected the fake broker as the only broker and controller", info)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if noTopicReqs != 1 {
+		t.Errorf("got %d metadata requests for no topics, expected 1", noTopicReqs)
+	}
+}
@@ -0,0 +1,137 @@
+package kadm
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// ConfigResource is a resource to describe the configs of.
+type ConfigResource struct {
+	Type kmsg.ConfigResourceType // Type is TOPIC, BROKER, or BROKER_LOGGER.
+
+	// Name is the topic name, or the broker ID for broker resources. An
+	// empty name for a broker resource describes the cluster wide dynamic
+	// broker defaults.
+	Name string
+
+	// ConfigNames are the config keys to describe; nil describes all keys.
+	ConfigNames []string
+}
+
+// TopicConfigResource returns a resource to describe all configs of topic.
+func TopicConfigResource(topic string) ConfigResource {
+	return ConfigResource{Type: kmsg.ConfigResourceTypeTopic, Name: topic}
+}
+
+// BrokerConfigResource returns a resource to describe all configs of the
+// broker with the given ID.
+func BrokerConfigResource(id int32) ConfigResource {
+	return ConfigResource{Type: kmsg.ConfigResourceTypeBroker, Name: strconv.Itoa(int(id))}
+}
+
+// ConfigSynonym is a value a config key is set to at one source, in order of
+// precedence. Synonyms show, for example, a dynamic topic value overriding a
+// static broker value which itself overrides the default.
+type ConfigSynonym struct {
+	Name   string            // Name is the config key at this source, which may differ from the config's key.
+	Value  *string           // Value is the value at this source, if any.
+	Source kmsg.ConfigSource // Source is where this value is set.
+}
+
+// Config is a single config entry of a resource.
+type Config struct {
+	Name  string  // Name is the config key.
+	Value *string // Value is the config value; nil if sensitive or unset.
+
+	// Source is where the value comes from: a dynamic topic or broker
+	// config, a static broker config, or the default. Brokers before
+	// Kafka 1.1 do not report a source; in that case this is
+	// DEFAULT_CONFIG if the value is a default and UNKNOWN otherwise.
+	Source kmsg.ConfigSource
+
+	IsDefault   bool // IsDefault is whether the value is the default.
+	ReadOnly    bool // ReadOnly is whether the config cannot be altered.
+	IsSensitive bool // IsSensitive is whether the value is sensitive, in which case Value is nil.
+
+	// Synonyms are the values this config has at each source, in order
+	// of precedence, if the broker supports returning synonyms.
+	Synonyms []ConfigSynonym
+
+	Type          kmsg.ConfigType // Type is the config's value type, if the broker returns types.
+	Documentation *string         // Documentation is the config's documentation, if the broker returns it.
+}
+
+// ResourceConfigs is the configs of a single described resource.
+type ResourceConfigs struct {
+	Type    kmsg.ConfigResourceType // Type is the resource type.
+	Name    string                  // Name is the resource name.
+	Configs []Config                // Configs are the described configs.
+
+	Err error // Err is any error describing this resource, with any broker message (see kerr.MessageError).
+}
+
+// DescribeConfigs issues a DescribeConfigs request for the given resources,
+// returning each resource's configs, including where each value is set and
+// whether values are defaults, read only, or sensitive.
+//
+// This returns an error only if the request fails; per-resource errors are in
+// each result.
+func (cl *Client) DescribeConfigs(ctx context.Context, resources ...ConfigResource) ([]ResourceConfigs, error) {
+	req := kmsg.NewPtrDescribeConfigsRequest()
+	req.IncludeSynonyms = true
+	req.IncludeDocumentation = true
+	for _, r := range resources {
+		rr := kmsg.NewDescribeConfigsRequestResource()
+		rr.ResourceType = r.Type
+		rr.ResourceName = r.Name
+		rr.ConfigNames = r.ConfigNames
+		req.Resources = append(req.Resources, rr)
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ResourceConfigs, 0, len(resp.Resources))
+	for _, r := range resp.Resources {
+		rc := ResourceConfigs{
+			Type: r.ResourceType,
+			Name: r.ResourceName,
+		}
+		rc.Err = kerr.ErrorForCodeMessage(r.ErrorCode, r.ErrorMessage)
+		for _, c := range r.Configs {
+			config := Config{
+				Name:          c.Name,
+				Value:         c.Value,
+				Source:        c.Source,
+				IsDefault:     c.IsDefault,
+				ReadOnly:      c.ReadOnly,
+				IsSensitive:   c.IsSensitive,
+				Type:          c.ConfigType,
+				Documentation: c.Documentation,
+			}
+			if resp.Version >= 1 {
+				// v1+ replaced IsDefault with Source.
+				config.IsDefault = c.Source == kmsg.ConfigSourceDefaultConfig
+			} else if c.IsDefault {
+				config.Source = kmsg.ConfigSourceDefaultConfig
+			} else {
+				config.Source = kmsg.ConfigSourceUnknown
+			}
+			for _, s := range c.ConfigSynonyms {
+				config.Synonyms = append(config.Synonyms, ConfigSynonym{
+					Name:   s.Name,
+					Value:  s.Value,
+					Source: s.Source,
+				})
+			}
+			rc.Configs = append(rc.Configs, config)
+		}
+		results = append(results, rc)
+	}
+	return results, nil
+}
@@ -0,0 +1,176 @@
+package kadm
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"github.com/twmb/franz-go/pkg/kversion"
+)
+
+// describeConfigsHandler replies to every resource with a default
+// cleanup.policy and a sensitive, dynamically set password, or with an error
+// for the topic "unknown".
+func describeConfigsHandler(mu *sync.Mutex, got **kmsg.DescribeConfigsRequest) func(kmsg.Request) (kmsg.Response, error) {
+	return func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		*got = req.(*kmsg.DescribeConfigsRequest)
+		resp := req.ResponseKind().(*kmsg.DescribeConfigsResponse)
+		for _, rr := range (*got).Resources {
+			sr := kmsg.NewDescribeConfigsResponseResource()
+			sr.ResourceType = rr.ResourceType
+			sr.ResourceName = rr.ResourceName
+			if rr.ResourceName == "unknown" {
+				sr.ErrorCode = kerr.UnknownTopicOrPartition.Code
+				sr.ErrorMessage = kmsg.StringPtr("topic 'unknown' does not exist")
+				resp.Resources = append(resp.Resources, sr)
+				continue
+			}
+
+			def := kmsg.NewDescribeConfigsResponseResourceConfig()
+			def.Name = "cleanup.policy"
+			def.Value = kmsg.StringPtr("delete")
+			def.IsDefault = true
+			def.Source = kmsg.ConfigSourceDefaultConfig
+			def.ConfigType = kmsg.ConfigTypeList
+			def.Documentation = kmsg.StringPtr("docs")
+
+			pw := kmsg.NewDescribeConfigsResponseResourceConfig()
+			pw.Name = "sasl.password"
+			pw.IsSensitive = true
+			pw.Source = kmsg.ConfigSourceDynamicTopicConfig
+			syn := kmsg.NewDescribeConfigsResponseResourceConfigConfigSynonym()
+			syn.Name = "sasl.password"
+			syn.Source = kmsg.ConfigSourceDynamicTopicConfig
+			pw.ConfigSynonyms = append(pw.ConfigSynonyms, syn)
+
+			sr.Configs = append(sr.Configs, def, pw)
+			resp.Resources = append(resp.Resources, sr)
+		}
+		return resp, nil
+	}
+}
+
+func TestDescribeConfigs(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	var (
+		mu  sync.Mutex
+		got *kmsg.DescribeConfigsRequest
+	)
+	c.Control(32, describeConfigsHandler(&mu, &got))
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	topic := TopicConfigResource("t")
+	topic.ConfigNames = []string{"cleanup.policy", "sasl.password"}
+	rs, err := adm.DescribeConfigs(ctx, topic, TopicConfigResource("unknown"))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	mu.Lock()
+	if !got.IncludeSynonyms || !got.IncludeDocumentation {
+		t.Error("request does not include synonyms and documentation")
+	}
+	var names [][]string
+	for _, rr := range got.Resources {
+		if rr.ResourceType != kmsg.ConfigResourceTypeTopic {
+			t.Errorf("got resource type %v != exp TOPIC", rr.ResourceType)
+		}
+		names = append(names, rr.ConfigNames)
+	}
+	if exp := [][]string{{"cleanup.policy", "sasl.password"}, nil}; !reflect.DeepEqual(names, exp) {
+		t.Errorf("got config names %v != exp %v", names, exp)
+	}
+	mu.Unlock()
+
+	if len(rs) != 2 {
+		t.Fatalf("got %d results != exp 2", len(rs))
+	}
+	var tr, ur ResourceConfigs
+	for _, r := range rs {
+		if r.Name == "t" {
+			tr = r
+		} else {
+			ur = r
+		}
+	}
+	if !errors.Is(ur.Err, kerr.UnknownTopicOrPartition) || len(ur.Configs) != 0 {
+		t.Errorf("got unexpected unknown topic result %v", ur)
+	}
+	if tr.Err != nil || tr.Type != kmsg.ConfigResourceTypeTopic || len(tr.Configs) != 2 {
+		t.Fatalf("got unexpected topic result %v", tr)
+	}
+
+	def, pw := tr.Configs[0], tr.Configs[1]
+	if def.Name != "cleanup.policy" || def.Value == nil || *def.Value != "delete" ||
+		!def.IsDefault || def.Source != kmsg.ConfigSourceDefaultConfig ||
+		def.Type != kmsg.ConfigTypeList || def.Documentation == nil || *def.Documentation != "docs" {
+		t.Errorf("got unexpected default config %+v", def)
+	}
+	expSyns := []ConfigSynonym{{Name: "sasl.password", Source: kmsg.ConfigSourceDynamicTopicConfig}}
+	if pw.Value != nil || !pw.IsSensitive || pw.IsDefault || pw.Source != kmsg.ConfigSourceDynamicTopicConfig ||
+		!reflect.DeepEqual(pw.Synonyms, expSyns) {
+		t.Errorf("got unexpected sensitive config %+v", pw)
+	}
+}
+
+func TestDescribeConfigsV0(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	var (
+		mu  sync.Mutex
+		got *kmsg.DescribeConfigsRequest
+	)
+	c.Control(32, describeConfigsHandler(&mu, &got))
+
+	cl, err := kgo.NewClient(kgo.SeedBrokers(c.Addr()), kgo.MaxVersions(kversion.V0_11_0()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adm := NewClient(cl)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	rs, err := adm.DescribeConfigs(ctx, BrokerConfigResource(0))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	mu.Lock()
+	if v := got.GetVersion(); v != 0 {
+		t.Errorf("got request version %d != exp 0", v)
+	}
+	if rr := got.Resources[0]; rr.ResourceType != kmsg.ConfigResourceTypeBroker || rr.ResourceName != "0" {
+		t.Errorf("got unexpected broker resource %v", rr)
+	}
+	mu.Unlock()
+
+	// v0 has no sources; the source is derived from IsDefault.
+	if len(rs) != 1 || len(rs[0].Configs) != 2 {
+		t.Fatalf("got unexpected results %v", rs)
+	}
+	if def := rs[0].Configs[0]; !def.IsDefault || def.Source != kmsg.ConfigSourceDefaultConfig {
+		t.Errorf("got default config is default %v, source %v; exp true, DEFAULT_CONFIG", def.IsDefault, def.Source)
+	}
+	if pw := rs[0].Configs[1]; pw.IsDefault || pw.Source != kmsg.ConfigSourceUnknown {
+		t.Errorf("got sensitive config is default %v, source %v; exp false, UNKNOWN", pw.IsDefault, pw.Source)
+	}
+}
@@ -0,0 +1,235 @@
+package kadm
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// CommittedOffset is a group's committed offset for a single partition.
+type CommittedOffset struct {
+	Partition   int32  // Partition is the partition the offset is committed for.
+	Offset      int64  // Offset is the committed offset: the next offset to consume.
+	LeaderEpoch int32  // LeaderEpoch is the leader epoch of the committed offset, or -1 if unknown.
+	Metadata    string // Metadata is any metadata committed with the offset.
+}
+
+// TopicConsumer is a group that has committed offsets for a topic.
+type TopicConsumer struct {
+	Group string // Group is the group name.
+	State string // State is the group state, if the brokers are new enough to return it (Kafka 2.6+).
+
+	// Offsets are the group's committed offsets for the topic, sorted by
+	// partition. Partitions the group has not committed to are omitted.
+	Offsets []CommittedOffset
+
+	// Err is any error fetching the group's offsets, in which case the
+	// group may or may not be consuming the topic.
+	Err error
+}
+
+// topicConsumersConcurrency is how many groups TopicConsumers fetches
+// offsets for at once.
+const topicConsumersConcurrency = 16
+
+// TopicConsumers returns every group that has committed offsets for topic,
+// along with the group's committed offsets, sorted by group.
+//
+// This lists all groups in the cluster and then fetches each group's
+// committed offsets for the topic's partitions, a bounded number of groups
+// at a time. Groups with no committed offsets for the topic are omitted,
+// unless fetching the group's offsets failed, in which case the group is
+// returned with its error.
+//
+// This returns an error if the topic's partitions cannot be loaded or if
+// listing groups fails on any broker, since the result would otherwise be
+// silently incomplete.
+func (cl *Client) TopicConsumers(ctx context.Context, topic string) ([]TopicConsumer, error) {
+	partitions, err := cl.topicPartitions(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []kmsg.ListGroupsResponseGroup
+	for _, shard := range cl.cl.RequestSharded(ctx, kmsg.NewPtrListGroupsRequest()) {
+		if shard.Err != nil {
+			return nil, shard.Err
+		}
+		resp := shard.Resp.(*kmsg.ListGroupsResponse)
+		if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+			return nil, err
+		}
+		groups = append(groups, resp.Groups...)
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		sem       = make(chan struct{}, topicConsumersConcurrency)
+		consumers []TopicConsumer
+	)
+	for _, g := range groups {
+		g := g
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			c := TopicConsumer{
+				Group: g.Group,
+				State: g.GroupState,
+			}
+			c.Offsets, c.Err = cl.fetchGroupTopicOffsets(ctx, g.Group, topic, partitions)
+			if c.Err == nil && len(c.Offsets) == 0 {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			consumers = append(consumers, c)
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(consumers, func(i, j int) bool { return consumers[i].Group < consumers[j].Group })
+	return consumers, nil
+}
+
+// topicPartitions returns the partitions of topic.
+func (cl *Client) topicPartitions(ctx context.Context, topic string) ([]int32, error) {
+	req := kmsg.NewPtrMetadataRequest()
+	rt := kmsg.NewMetadataRequestTopic()
+	rt.Topic = &topic
+	req.Topics = append(req.Topics, rt)
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Topics) != 1 {
+		return nil, kerr.UnknownTopicOrPartition
+	}
+	t := resp.Topics[0]
+	if err := kerr.ErrorForCode(t.ErrorCode); err != nil {
+		return nil, err
+	}
+	partitions := make([]int32, 0, len(t.Partitions))
+	for _, p := range t.Partitions {
+		partitions = append(partitions, p.Partition)
+	}
+	return partitions, nil
+}
+
+// fetchGroupTopicOffsets returns group's committed offsets for the given
+// partitions of topic, sorted by partition.
+func (cl *Client) fetchGroupTopicOffsets(ctx context.Context, group, topic string, partitions []int32) ([]CommittedOffset, error) {
+	req := kmsg.NewPtrOffsetFetchRequest()
+	req.Group = group
+	rt := kmsg.NewOffsetFetchRequestTopic()
+	rt.Topic = topic
+	rt.Partitions = partitions
+	req.Topics = append(req.Topics, rt)
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return nil, err
+	}
+
+	var offsets []CommittedOffset
+	for _, t := range resp.Topics {
+		if t.Topic != topic {
+			continue
+		}
+		for _, p := range t.Partitions {
+			if err := kerr.ErrorForCode(p.ErrorCode); err != nil {
+				return nil, err
+			}
+			if p.Offset < 0 {
+				continue // not committed
+			}
+			o := CommittedOffset{
+				Partition:   p.Partition,
+				Offset:      p.Offset,
+				LeaderEpoch: p.LeaderEpoch,
+			}
+			if resp.Version < 5 {
+				o.LeaderEpoch = -1
+			}
+			if p.Metadata != nil {
+				o.Metadata = *p.Metadata
+			}
+			offsets = append(offsets, o)
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i].Partition < offsets[j].Partition })
+	return offsets, nil
+}
+
+// DeleteOffsetsResult is the result of deleting a group's committed offset
+// for a single partition.
+type DeleteOffsetsResult struct {
+	Topic     string // Topic is the topic the offset was deleted for.
+	Partition int32  // Partition is the partition the offset was deleted for.
+	Err       error  // Err is any error for this partition.
+}
+
+// DeleteOffsets issues an OffsetDelete request (KIP-496) to the group's
+// coordinator, deleting the group's committed offsets for the given topics
+// and partitions, and returns the per-partition results sorted by topic and
+// partition.
+//
+// Offsets can only be deleted for partitions the group is not actively
+// consuming; deleting an offset for a subscribed topic fails with
+// GROUP_SUBSCRIBED_TO_TOPIC for that partition. This is useful for pruning
+// stale offsets that would otherwise linger until the offsets retention
+// period and confuse lag monitoring.
+//
+// This returns an error only if the request fails or the response has a top
+// level error, such as the group being unknown; per-partition errors are in
+// each result.
+func (cl *Client) DeleteOffsets(
+	ctx context.Context,
+	group string,
+	topicPartitions map[string][]int32,
+) ([]DeleteOffsetsResult, error) {
+	req := kmsg.NewPtrOffsetDeleteRequest()
+	req.Group = group
+	for topic, partitions := range topicPartitions {
+		rt := kmsg.NewOffsetDeleteRequestTopic()
+		rt.Topic = topic
+		for _, partition := range partitions {
+			rp := kmsg.NewOffsetDeleteRequestTopicPartition()
+			rp.Partition = partition
+			rt.Partitions = append(rt.Partitions, rp)
+		}
+		req.Topics = append(req.Topics, rt)
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return nil, err
+	}
+
+	var results []DeleteOffsetsResult
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			results = append(results, DeleteOffsetsResult{
+				Topic:     t.Topic,
+				Partition: p.Partition,
+				Err:       kerr.ErrorForCode(p.ErrorCode),
+			})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		l, r := results[i], results[j]
+		return l.Topic < r.Topic || l.Topic == r.Topic && l.Partition < r.Partition
+	})
+	return results, nil
+}
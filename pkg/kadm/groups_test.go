@@ -0,0 +1,213 @@
+package kadm
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestTopicConsumers(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"t": 3})
+	defer c.Close()
+
+	c.Control(16, func(req kmsg.Request) (kmsg.Response, error) {
+		resp := req.ResponseKind().(*kmsg.ListGroupsResponse)
+		for _, group := range []string{"uncommitted", "failing", "consuming"} {
+			g := kmsg.NewListGroupsResponseGroup()
+			g.Group = group
+			g.GroupState = "Stable"
+			resp.Groups = append(resp.Groups, g)
+		}
+		return resp, nil
+	})
+
+	var (
+		mu   sync.Mutex
+		reqs = make(map[string]*kmsg.OffsetFetchRequest)
+	)
+	c.Control(9, func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		r := req.(*kmsg.OffsetFetchRequest)
+		reqs[r.Group] = r
+		resp := req.ResponseKind().(*kmsg.OffsetFetchResponse)
+		if r.Group == "failing" {
+			resp.ErrorCode = kerr.GroupAuthorizationFailed.Code
+			return resp, nil
+		}
+		for _, rt := range r.Topics {
+			st := kmsg.NewOffsetFetchResponseTopic()
+			st.Topic = rt.Topic
+			for _, p := range rt.Partitions {
+				sp := kmsg.NewOffsetFetchResponseTopicPartition()
+				sp.Partition = p
+				sp.Offset = -1
+				if r.Group == "consuming" && p != 1 {
+					sp.Offset = 10 + int64(p)
+					sp.LeaderEpoch = 4
+					sp.Metadata = kmsg.StringPtr("meta")
+				}
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	consumers, err := adm.TopicConsumers(ctx, "t")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	// The group without commits is omitted, while the group we could not
+	// fetch offsets for is returned with its error.
+	if len(consumers) != 2 || consumers[0].Group != "consuming" || consumers[1].Group != "failing" {
+		t.Fatalf("got unexpected consumers %v, expected consuming and failing in order", consumers)
+	}
+	exp := TopicConsumer{
+		Group: "consuming",
+		State: "Stable",
+		Offsets: []CommittedOffset{
+			{Partition: 0, Offset: 10, LeaderEpoch: 4, Metadata: "meta"},
+			{Partition: 2, Offset: 12, LeaderEpoch: 4, Metadata: "meta"},
+		},
+	}
+	if !reflect.DeepEqual(consumers[0], exp) {
+		t.Errorf("got %v != exp %v", consumers[0], exp)
+	}
+	if !errors.Is(consumers[1].Err, kerr.GroupAuthorizationFailed) || consumers[1].Offsets != nil {
+		t.Errorf("got unexpected failing consumer %v", consumers[1])
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reqs) != 3 {
+		t.Errorf("got offset fetches for %d groups != exp 3", len(reqs))
+	}
+	for group, r := range reqs {
+		if len(r.Topics) != 1 || r.Topics[0].Topic != "t" {
+			t.Errorf("%s: got unexpected request topics %v", group, r.Topics)
+			continue
+		}
+		ps := append([]int32(nil), r.Topics[0].Partitions...)
+		sort.Slice(ps, func(i, j int) bool { return ps[i] < ps[j] })
+		if !reflect.DeepEqual(ps, []int32{0, 1, 2}) {
+			t.Errorf("%s: got requested partitions %v != exp [0 1 2]", group, ps)
+		}
+	}
+}
+
+func TestTopicConsumersListGroupsErr(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"t": 1})
+	defer c.Close()
+
+	c.Control(16, func(req kmsg.Request) (kmsg.Response, error) {
+		resp := req.ResponseKind().(*kmsg.ListGroupsResponse)
+		resp.ErrorCode = kerr.CoordinatorNotAvailable.Code
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	if _, err := adm.TopicConsumers(ctx, "t"); !errors.Is(err, kerr.CoordinatorNotAvailable) {
+		t.Errorf("got err %v, expected it to wrap %v", err, kerr.CoordinatorNotAvailable)
+	}
+}
+
+func TestDeleteOffsets(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	var (
+		mu  sync.Mutex
+		got *kmsg.OffsetDeleteRequest
+	)
+	c.Control(47, func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = req.(*kmsg.OffsetDeleteRequest)
+		resp := req.ResponseKind().(*kmsg.OffsetDeleteResponse)
+		if got.Group == "unknown" {
+			resp.ErrorCode = kerr.GroupIDNotFound.Code
+			return resp, nil
+		}
+		for _, rt := range got.Topics {
+			st := kmsg.NewOffsetDeleteResponseTopic()
+			st.Topic = rt.Topic
+			for _, rp := range rt.Partitions {
+				sp := kmsg.NewOffsetDeleteResponseTopicPartition()
+				sp.Partition = rp.Partition
+				if rt.Topic == "subscribed" {
+					sp.ErrorCode = kerr.GroupSubscribedToTopic.Code
+				}
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	rs, err := adm.DeleteOffsets(ctx, "g", map[string][]int32{
+		"subscribed": {0},
+		"stale":      {1, 0},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	mu.Lock()
+	if got.Group != "g" {
+		t.Errorf("got group %s != exp g", got.Group)
+	}
+	requested := make(map[string][]int32)
+	for _, rt := range got.Topics {
+		for _, rp := range rt.Partitions {
+			requested[rt.Topic] = append(requested[rt.Topic], rp.Partition)
+		}
+	}
+	if exp := map[string][]int32{"subscribed": {0}, "stale": {1, 0}}; !reflect.DeepEqual(requested, exp) {
+		t.Errorf("got requested %v != exp %v", requested, exp)
+	}
+	mu.Unlock()
+
+	if len(rs) != 3 {
+		t.Fatalf("got %d results != exp 3", len(rs))
+	}
+	exp := []DeleteOffsetsResult{
+		{Topic: "stale", Partition: 0},
+		{Topic: "stale", Partition: 1},
+		{Topic: "subscribed", Partition: 0, Err: kerr.GroupSubscribedToTopic},
+	}
+	if !reflect.DeepEqual(rs, exp) {
+		t.Errorf("got %v != exp %v", rs, exp)
+	}
+
+	if _, err := adm.DeleteOffsets(ctx, "unknown", map[string][]int32{"t": {0}}); !errors.Is(err, kerr.GroupIDNotFound) {
+		t.Errorf("got err %v, expected it to wrap %v", err, kerr.GroupIDNotFound)
+	}
+}
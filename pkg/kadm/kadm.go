@@ -0,0 +1,73 @@
+// Package kadm provides a helper Kafka admin client around a *kgo.Client.
+//
+// This package is meant to cover the common administrative requests that
+// otherwise require hand-rolling the raw kmsg requests and responses. All
+// requests are issued through the wrapped client, which routes admin requests
+// to the cluster controller and retries retriable errors.
+//
+// Errors in results that correspond to Kafka error codes are wrapped with any
+// message the broker returned (see kerr.MessageError). These errors cannot be
+// compared directly to Kafka errors; use errors.Is, i.e.
+// errors.Is(r.Err, kerr.TopicAlreadyExists) rather than r.Err ==
+// kerr.TopicAlreadyExists.
+package kadm
+
+import (
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Client is an admin client.
+//
+// This is a simple wrapper around a *kgo.Client to provide helper admin
+// methods.
+type Client struct {
+	cl *kgo.Client
+
+	timeoutMillis int32
+	idempotent    bool
+}
+
+// NewClient returns an admin client.
+func NewClient(cl *kgo.Client) *Client {
+	return &Client{cl: cl, timeoutMillis: 60000} // 60s timeout default, matching kmsg
+}
+
+// SetTimeoutMillis sets the timeout to use for requests that have a timeout,
+// overriding the default of 60,000 (60s).
+//
+// Not all requests have timeouts. Most requests are expected to return
+// immediately or are expected to deliberately hang. The following requests
+// have timeout fields:
+//
+//     AlterPartitionAssignments
+//     CreateTopics
+//     DeleteTopics
+//     ListPartitionReassignments
+//
+// Timeouts are the broker side timeout for the request to complete; the
+// request context should still be used to bound the client side wait.
+func (cl *Client) SetTimeoutMillis(millis int32) {
+	cl.timeoutMillis = millis
+}
+
+// SetIdempotent sets whether creating and deleting topics is idempotent,
+// overriding the default of false.
+//
+// The underlying client retries requests that fail from transient errors,
+// such as a connection being cut while waiting for a response. If the broker
+// actually processed the first attempt, the retry fails with
+// TOPIC_ALREADY_EXISTS when creating or UNKNOWN_TOPIC_OR_PARTITION when
+// deleting, even though the operation succeeded. If idempotent, these errors
+// are treated as success when, and only when, the request was retried.
+//
+// Note that a retry cannot distinguish the first attempt succeeding from a
+// concurrent operation by another client: a topic created by someone else
+// between the attempts is reported as created.
+func (cl *Client) SetIdempotent(idempotent bool) {
+	cl.idempotent = idempotent
+}
+
+// Close closes the underlying *kgo.Client.
+func (cl *Client) Close() {
+	cl.cl.Close()
+}
@@ -0,0 +1,119 @@
+package kadm
+
+import (
+	"context"
+	"sort"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// LogDirPartition is a partition replica stored in a log directory.
+type LogDirPartition struct {
+	Topic     string // Topic is the partition's topic.
+	Partition int32  // Partition is the partition number.
+
+	// Size is the size of the partition's log segments in bytes.
+	Size int64
+	// OffsetLag is how far the replica's log end offset lags behind the
+	// partition's high watermark, or, if IsFuture, behind the current
+	// replica's log end offset.
+	OffsetLag int64
+	// IsFuture is whether this replica is the future replica of an
+	// ongoing move between log directories.
+	IsFuture bool
+}
+
+// LogDir is a single log directory on a broker.
+type LogDir struct {
+	Dir string // Dir is the absolute path of the log directory.
+	Err error  // Err is any error for this directory, such as KafkaStorageError if the disk is offline.
+
+	// Partitions are the described partitions stored in this directory,
+	// sorted by topic and partition.
+	Partitions []LogDirPartition
+}
+
+// Size returns the sum of the sizes of all partitions in the directory.
+func (d LogDir) Size() int64 {
+	var size int64
+	for _, p := range d.Partitions {
+		size += p.Size
+	}
+	return size
+}
+
+// BrokerLogDirs is the log directories of a single broker.
+type BrokerLogDirs struct {
+	Broker int32    // Broker is the broker ID, or -1 if the request could not be issued.
+	Dirs   []LogDir // Dirs are the broker's log directories, sorted by path.
+	Err    error    // Err is any error issuing the request to this broker.
+}
+
+// DescribeLogDirs issues a DescribeLogDirs request to every broker hosting
+// any of the given partitions, returning each broker's log directories and
+// the sizes of the partitions stored in each directory, sorted by broker.
+//
+// A nil topicPartitions describes every partition on every broker. An empty,
+// non-nil topicPartitions describes only the directories themselves.
+//
+// Note that the total and usable bytes of each directory (KIP-827) are not
+// returned, as they require a newer request version than this client
+// supports; Size can be used to sum the partition sizes in a directory.
+//
+// This returns an error only if the request could not be sharded; errors
+// issuing to individual brokers are in each result.
+func (cl *Client) DescribeLogDirs(ctx context.Context, topicPartitions map[string][]int32) ([]BrokerLogDirs, error) {
+	req := kmsg.NewPtrDescribeLogDirsRequest()
+	if topicPartitions != nil {
+		req.Topics = []kmsg.DescribeLogDirsRequestTopic{} // non-nil: only what we ask for
+		for topic, partitions := range topicPartitions {
+			rt := kmsg.NewDescribeLogDirsRequestTopic()
+			rt.Topic = topic
+			rt.Partitions = partitions
+			req.Topics = append(req.Topics, rt)
+		}
+	}
+
+	shards := cl.cl.RequestSharded(ctx, req)
+	if len(shards) == 1 && shards[0].Meta.NodeID < 0 && shards[0].Err != nil {
+		return nil, shards[0].Err
+	}
+
+	var results []BrokerLogDirs
+	for _, shard := range shards {
+		b := BrokerLogDirs{
+			Broker: shard.Meta.NodeID,
+			Err:    shard.Err,
+		}
+		if shard.Err == nil {
+			resp := shard.Resp.(*kmsg.DescribeLogDirsResponse)
+			for _, d := range resp.Dirs {
+				dir := LogDir{
+					Dir: d.Dir,
+					Err: kerr.ErrorForCode(d.ErrorCode),
+				}
+				for _, t := range d.Topics {
+					for _, p := range t.Partitions {
+						dir.Partitions = append(dir.Partitions, LogDirPartition{
+							Topic:     t.Topic,
+							Partition: p.Partition,
+							Size:      p.Size,
+							OffsetLag: p.OffsetLag,
+							IsFuture:  p.IsFuture,
+						})
+					}
+				}
+				sort.Slice(dir.Partitions, func(i, j int) bool {
+					l, r := dir.Partitions[i], dir.Partitions[j]
+					return l.Topic < r.Topic || l.Topic == r.Topic && l.Partition < r.Partition
+				})
+				b.Dirs = append(b.Dirs, dir)
+			}
+			sort.Slice(b.Dirs, func(i, j int) bool { return b.Dirs[i].Dir < b.Dirs[j].Dir })
+		}
+		results = append(results, b)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Broker < results[j].Broker })
+	return results, nil
+}
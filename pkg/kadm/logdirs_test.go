@@ -0,0 +1,134 @@
+package kadm
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestDescribeLogDirs(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"t": 2})
+	defer c.Close()
+
+	var (
+		mu   sync.Mutex
+		reqs []*kmsg.DescribeLogDirsRequest
+	)
+	c.Control(35, func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reqs = append(reqs, req.(*kmsg.DescribeLogDirsRequest))
+		resp := req.ResponseKind().(*kmsg.DescribeLogDirsResponse)
+
+		d := kmsg.NewDescribeLogDirsResponseDir()
+		d.Dir = "/b"
+		rt := kmsg.NewDescribeLogDirsResponseDirTopic()
+		rt.Topic = "t"
+		for _, p := range []int32{1, 0} {
+			rp := kmsg.NewDescribeLogDirsResponseDirTopicPartition()
+			rp.Partition = p
+			rp.Size = 100 * int64(p+1)
+			rp.OffsetLag = int64(p)
+			rp.IsFuture = p == 1
+			rt.Partitions = append(rt.Partitions, rp)
+		}
+		d.Topics = append(d.Topics, rt)
+		resp.Dirs = append(resp.Dirs, d)
+
+		d = kmsg.NewDescribeLogDirsResponseDir()
+		d.Dir = "/a"
+		d.ErrorCode = kerr.KafkaStorageError.Code
+		resp.Dirs = append(resp.Dirs, d)
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	rs, err := adm.DescribeLogDirs(ctx, map[string][]int32{"t": {0, 1}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	if len(rs) != 1 || rs[0].Broker != 0 || rs[0].Err != nil {
+		t.Fatalf("got unexpected broker results %v", rs)
+	}
+	dirs := rs[0].Dirs
+	if len(dirs) != 2 || dirs[0].Dir != "/a" || dirs[1].Dir != "/b" {
+		t.Fatalf("got unexpected dirs %v, expected /a and /b in order", dirs)
+	}
+	if !errors.Is(dirs[0].Err, kerr.KafkaStorageError) || len(dirs[0].Partitions) != 0 {
+		t.Errorf("got unexpected offline dir %v", dirs[0])
+	}
+	exp := []LogDirPartition{
+		{Topic: "t", Partition: 0, Size: 100},
+		{Topic: "t", Partition: 1, Size: 200, OffsetLag: 1, IsFuture: true},
+	}
+	if dirs[1].Err != nil || !reflect.DeepEqual(dirs[1].Partitions, exp) {
+		t.Errorf("got dir %v != exp partitions %v", dirs[1], exp)
+	}
+	if size := dirs[1].Size(); size != 300 {
+		t.Errorf("got dir size %d != exp 300", size)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var requested []int32
+	for _, req := range reqs {
+		for _, rt := range req.Topics {
+			if rt.Topic != "t" {
+				t.Errorf("got unexpected requested topic %s", rt.Topic)
+			}
+			requested = append(requested, rt.Partitions...)
+		}
+	}
+	if len(requested) != 2 {
+		t.Errorf("got requested partitions %v != exp 0 and 1", requested)
+	}
+}
+
+func TestDescribeLogDirsAll(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	var (
+		mu  sync.Mutex
+		got *kmsg.DescribeLogDirsRequest
+	)
+	c.Control(35, func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = req.(*kmsg.DescribeLogDirsRequest)
+		return req.ResponseKind(), nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	rs, err := adm.DescribeLogDirs(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(rs) != 1 || rs[0].Broker != 0 || rs[0].Err != nil || len(rs[0].Dirs) != 0 {
+		t.Errorf("got unexpected results %v", rs)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got.Topics != nil {
+		t.Errorf("got request topics %v != exp nil to describe everything", got.Topics)
+	}
+}
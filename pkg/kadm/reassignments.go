@@ -0,0 +1,134 @@
+package kadm
+
+import (
+	"context"
+	"sort"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// AlterPartitionAssignmentsResult is the result of altering the replica
+// assignment of a single partition.
+type AlterPartitionAssignmentsResult struct {
+	Topic     string // Topic is the topic that was altered.
+	Partition int32  // Partition is the partition that was altered.
+	Err       error  // Err is any error for this partition, with any broker message (see kerr.MessageError).
+}
+
+// AlterPartitionReassignments issues an AlterPartitionAssignments request
+// (KIP-455) to start or cancel reassignments for the given partitions,
+// returning the per-partition results sorted by topic and partition.
+//
+// The assignments map topics to partitions to the desired replicas for each
+// partition. A nil replica slice cancels any ongoing reassignment for the
+// partition.
+//
+// This returns an error only if the request fails or the response has a top
+// level error; per-partition errors are in each result.
+func (cl *Client) AlterPartitionReassignments(
+	ctx context.Context,
+	assignments map[string]map[int32][]int32,
+) ([]AlterPartitionAssignmentsResult, error) {
+	req := kmsg.NewPtrAlterPartitionAssignmentsRequest()
+	req.TimeoutMillis = cl.timeoutMillis
+	for topic, partitions := range assignments {
+		rt := kmsg.NewAlterPartitionAssignmentsRequestTopic()
+		rt.Topic = topic
+		for partition, replicas := range partitions {
+			rp := kmsg.NewAlterPartitionAssignmentsRequestTopicPartition()
+			rp.Partition = partition
+			rp.Replicas = replicas
+			rt.Partitions = append(rt.Partitions, rp)
+		}
+		req.Topics = append(req.Topics, rt)
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if err := kerr.ErrorForCodeMessage(resp.ErrorCode, resp.ErrorMessage); err != nil {
+		return nil, err
+	}
+
+	var results []AlterPartitionAssignmentsResult
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			r := AlterPartitionAssignmentsResult{
+				Topic:     t.Topic,
+				Partition: p.Partition,
+				Err:       kerr.ErrorForCodeMessage(p.ErrorCode, p.ErrorMessage),
+			}
+			results = append(results, r)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		l, r := results[i], results[j]
+		return l.Topic < r.Topic || l.Topic == r.Topic && l.Partition < r.Partition
+	})
+	return results, nil
+}
+
+// PartitionReassignment is an ongoing reassignment of a single partition.
+type PartitionReassignment struct {
+	Topic     string // Topic is the topic being reassigned.
+	Partition int32  // Partition is the partition being reassigned.
+
+	// Replicas is the current replica set, which includes both adding and
+	// removing replicas while the reassignment is ongoing.
+	Replicas []int32
+	// AddingReplicas are the replicas being added.
+	AddingReplicas []int32
+	// RemovingReplicas are the replicas being removed.
+	RemovingReplicas []int32
+}
+
+// ListPartitionReassignments issues a ListPartitionReassignments request
+// (KIP-455), returning all ongoing reassignments sorted by topic and
+// partition.
+//
+// The filter maps topics to the partitions to list. A nil filter lists every
+// ongoing reassignment in the cluster.
+func (cl *Client) ListPartitionReassignments(
+	ctx context.Context,
+	filter map[string][]int32,
+) ([]PartitionReassignment, error) {
+	req := kmsg.NewPtrListPartitionReassignmentsRequest()
+	req.TimeoutMillis = cl.timeoutMillis
+	if filter != nil {
+		req.Topics = []kmsg.ListPartitionReassignmentsRequestTopic{} // non-nil: only what we ask for
+		for topic, partitions := range filter {
+			rt := kmsg.NewListPartitionReassignmentsRequestTopic()
+			rt.Topic = topic
+			rt.Partitions = partitions
+			req.Topics = append(req.Topics, rt)
+		}
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if err := kerr.ErrorForCodeMessage(resp.ErrorCode, resp.ErrorMessage); err != nil {
+		return nil, err
+	}
+
+	var reassignments []PartitionReassignment
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			reassignments = append(reassignments, PartitionReassignment{
+				Topic:            t.Topic,
+				Partition:        p.Partition,
+				Replicas:         p.Replicas,
+				AddingReplicas:   p.AddingReplicas,
+				RemovingReplicas: p.RemovingReplicas,
+			})
+		}
+	}
+	sort.Slice(reassignments, func(i, j int) bool {
+		l, r := reassignments[i], reassignments[j]
+		return l.Topic < r.Topic || l.Topic == r.Topic && l.Partition < r.Partition
+	})
+	return reassignments, nil
+}
@@ -0,0 +1,185 @@
+package kadm
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestAlterPartitionReassignments(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	var (
+		mu  sync.Mutex
+		got *kmsg.AlterPartitionAssignmentsRequest
+	)
+	c.Control(45, func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = req.(*kmsg.AlterPartitionAssignmentsRequest)
+		resp := req.ResponseKind().(*kmsg.AlterPartitionAssignmentsResponse)
+		for _, rt := range got.Topics {
+			st := kmsg.NewAlterPartitionAssignmentsResponseTopic()
+			st.Topic = rt.Topic
+			for _, rp := range rt.Partitions {
+				sp := kmsg.NewAlterPartitionAssignmentsResponseTopicPartition()
+				sp.Partition = rp.Partition
+				if rp.Partition == 1 {
+					sp.ErrorCode = kerr.NoReassignmentInProgress.Code
+					sp.ErrorMessage = kmsg.StringPtr("no reassignment for partition 1")
+				}
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+	adm.SetTimeoutMillis(1234)
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	rs, err := adm.AlterPartitionReassignments(ctx, map[string]map[int32][]int32{
+		"t": {
+			0: {1, 2, 3},
+			1: nil, // cancel
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got.TimeoutMillis != 1234 {
+		t.Errorf("got timeout %d != exp 1234", got.TimeoutMillis)
+	}
+	if len(got.Topics) != 1 || got.Topics[0].Topic != "t" || len(got.Topics[0].Partitions) != 2 {
+		t.Fatalf("got unexpected request topics %v", got.Topics)
+	}
+	for _, rp := range got.Topics[0].Partitions {
+		switch rp.Partition {
+		case 0:
+			if !reflect.DeepEqual(rp.Replicas, []int32{1, 2, 3}) {
+				t.Errorf("partition 0: got replicas %v != exp [1 2 3]", rp.Replicas)
+			}
+		case 1:
+			if rp.Replicas != nil {
+				t.Errorf("partition 1: got replicas %v != exp nil to cancel", rp.Replicas)
+			}
+		}
+	}
+
+	if len(rs) != 2 || rs[0].Partition != 0 || rs[1].Partition != 1 {
+		t.Fatalf("got unexpected results %v, expected partitions 0 and 1 in order", rs)
+	}
+	if rs[0].Topic != "t" || rs[0].Err != nil {
+		t.Errorf("partition 0: got unexpected result %v", rs[0])
+	}
+	if !errors.Is(rs[1].Err, kerr.NoReassignmentInProgress) {
+		t.Errorf("partition 1: got err %v, expected it to wrap %v", rs[1].Err, kerr.NoReassignmentInProgress)
+	}
+}
+
+func TestAlterPartitionReassignmentsTopLevelErr(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	c.Control(45, func(req kmsg.Request) (kmsg.Response, error) {
+		resp := req.ResponseKind().(*kmsg.AlterPartitionAssignmentsResponse)
+		resp.ErrorCode = kerr.ClusterAuthorizationFailed.Code
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	rs, err := adm.AlterPartitionReassignments(ctx, map[string]map[int32][]int32{"t": {0: {1}}})
+	if !errors.Is(err, kerr.ClusterAuthorizationFailed) || rs != nil {
+		t.Errorf("got %v, %v; expected no results and %v", rs, err, kerr.ClusterAuthorizationFailed)
+	}
+}
+
+func TestListPartitionReassignments(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	var (
+		mu   sync.Mutex
+		reqs []*kmsg.ListPartitionReassignmentsRequest
+	)
+	c.Control(46, func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reqs = append(reqs, req.(*kmsg.ListPartitionReassignmentsRequest))
+		resp := req.ResponseKind().(*kmsg.ListPartitionReassignmentsResponse)
+		for _, topic := range []string{"b", "a"} {
+			st := kmsg.NewListPartitionReassignmentsResponseTopic()
+			st.Topic = topic
+			for _, p := range []int32{1, 0} {
+				sp := kmsg.NewListPartitionReassignmentsResponseTopicPartition()
+				sp.Partition = p
+				sp.Replicas = []int32{1, 2, 3}
+				sp.AddingReplicas = []int32{3}
+				sp.RemovingReplicas = []int32{1}
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	rs, err := adm.ListPartitionReassignments(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	var order []string
+	for _, r := range rs {
+		order = append(order, r.Topic+string(rune('0'+r.Partition)))
+		if !reflect.DeepEqual(r.Replicas, []int32{1, 2, 3}) ||
+			!reflect.DeepEqual(r.AddingReplicas, []int32{3}) ||
+			!reflect.DeepEqual(r.RemovingReplicas, []int32{1}) {
+			t.Errorf("got unexpected reassignment %v", r)
+		}
+	}
+	if exp := []string{"a0", "a1", "b0", "b1"}; !reflect.DeepEqual(order, exp) {
+		t.Errorf("got order %v != exp %v", order, exp)
+	}
+
+	if _, err := adm.ListPartitionReassignments(ctx, map[string][]int32{"a": {0}}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reqs) != 2 {
+		t.Fatalf("got %d requests != exp 2", len(reqs))
+	}
+	if reqs[0].Topics != nil {
+		t.Errorf("nil filter: got request topics %v != exp nil to list everything", reqs[0].Topics)
+	}
+	if len(reqs[1].Topics) != 1 || reqs[1].Topics[0].Topic != "a" || !reflect.DeepEqual(reqs[1].Topics[0].Partitions, []int32{0}) {
+		t.Errorf("filter: got unexpected request topics %v", reqs[1].Topics)
+	}
+}
@@ -0,0 +1,136 @@
+package kadm
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// CreateTopicResult is the result of creating a single topic.
+type CreateTopicResult struct {
+	Topic string // Topic is the topic that was created.
+	Err   error  // Err is any error creating this topic, with any broker message (see kerr.MessageError).
+
+	// Retried is whether the create was retried and the topic already
+	// existed on the retry, which, if the client is idempotent, is treated
+	// as success. See SetIdempotent.
+	Retried bool
+}
+
+// CreateTopics issues a CreateTopics request to create the given topics, each
+// with the given number of partitions, replication factor, and configs,
+// returning the per-topic results sorted by topic. A partition count or
+// replication factor of -1 uses the broker default (Kafka 2.4+).
+//
+// This returns an error only if the request fails; per-topic errors are in
+// each result.
+func (cl *Client) CreateTopics(
+	ctx context.Context,
+	partitions int32,
+	replicationFactor int16,
+	configs map[string]*string,
+	topics ...string,
+) ([]CreateTopicResult, error) {
+	req := kmsg.NewPtrCreateTopicsRequest()
+	req.TimeoutMillis = cl.timeoutMillis
+	for _, topic := range topics {
+		rt := kmsg.NewCreateTopicsRequestTopic()
+		rt.Topic = topic
+		rt.NumPartitions = partitions
+		rt.ReplicationFactor = replicationFactor
+		for name, value := range configs {
+			rc := kmsg.NewCreateTopicsRequestTopicConfig()
+			rc.Name = name
+			rc.Value = value
+			rt.Configs = append(rt.Configs, rc)
+		}
+		req.Topics = append(req.Topics, rt)
+	}
+
+	resp, retried, err := cl.requestOnce(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []CreateTopicResult
+	for _, t := range resp.(*kmsg.CreateTopicsResponse).Topics {
+		r := CreateTopicResult{Topic: t.Topic}
+		r.Err = kerr.ErrorForCodeMessage(t.ErrorCode, t.ErrorMessage)
+		if retried && errors.Is(r.Err, kerr.TopicAlreadyExists) {
+			r.Retried = true
+			if cl.idempotent {
+				r.Err = nil
+			}
+		}
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Topic < results[j].Topic })
+	return results, nil
+}
+
+// DeleteTopicResult is the result of deleting a single topic.
+type DeleteTopicResult struct {
+	Topic string // Topic is the topic that was deleted.
+	Err   error  // Err is any error deleting this topic, with any broker message (see kerr.MessageError).
+
+	// Retried is whether the delete was retried and the topic no longer
+	// existed on the retry, which, if the client is idempotent, is
+	// treated as success. See SetIdempotent.
+	Retried bool
+}
+
+// DeleteTopics issues a DeleteTopics request to delete the given topics,
+// returning the per-topic results sorted by topic.
+//
+// This returns an error only if the request fails; per-topic errors are in
+// each result.
+func (cl *Client) DeleteTopics(ctx context.Context, topics ...string) ([]DeleteTopicResult, error) {
+	req := kmsg.NewPtrDeleteTopicsRequest()
+	req.TimeoutMillis = cl.timeoutMillis
+	req.TopicNames = topics
+	for i := range topics {
+		rt := kmsg.NewDeleteTopicsRequestTopic()
+		rt.Topic = &topics[i]
+		req.Topics = append(req.Topics, rt)
+	}
+
+	resp, retried, err := cl.requestOnce(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []DeleteTopicResult
+	for _, t := range resp.(*kmsg.DeleteTopicsResponse).Topics {
+		var r DeleteTopicResult
+		if t.Topic != nil {
+			r.Topic = *t.Topic
+		}
+		r.Err = kerr.ErrorForCodeMessage(t.ErrorCode, t.ErrorMessage)
+		if retried && errors.Is(r.Err, kerr.UnknownTopicOrPartition) {
+			r.Retried = true
+			if cl.idempotent {
+				r.Err = nil
+			}
+		}
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Topic < results[j].Topic })
+	return results, nil
+}
+
+// requestOnce issues a request that is not split across brokers, returning
+// the response and whether the client internally retried the request.
+func (cl *Client) requestOnce(ctx context.Context, req kmsg.Request) (kmsg.Response, bool, error) {
+	shards := cl.cl.RequestSharded(ctx, req)
+	if len(shards) != 1 {
+		return nil, false, errors.New("unexpectedly received multiple response shards for an unsplit request")
+	}
+	shard := shards[0]
+	if shard.Err != nil {
+		return nil, false, shard.Err
+	}
+	return shard.Resp, shard.Attempts > 1, nil
+}
@@ -0,0 +1,253 @@
+package kadm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// newFakeAdm returns an admin client talking to the fake cluster.
+func newFakeAdm(t *testing.T, c *fakecluster.Cluster) *Client {
+	cl, err := kgo.NewClient(kgo.SeedBrokers(c.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewClient(cl)
+}
+
+func testCtx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 10*time.Second)
+}
+
+func TestCreateTopics(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	var (
+		mu  sync.Mutex
+		got *kmsg.CreateTopicsRequest
+	)
+	c.Control(19, func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = req.(*kmsg.CreateTopicsRequest)
+		resp := req.ResponseKind().(*kmsg.CreateTopicsResponse)
+		for _, rt := range got.Topics {
+			st := kmsg.NewCreateTopicsResponseTopic()
+			st.Topic = rt.Topic
+			if rt.Topic == "exists" {
+				st.ErrorCode = kerr.TopicAlreadyExists.Code
+				st.ErrorMessage = kmsg.StringPtr("topic 'exists' already exists")
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+	adm.SetTimeoutMillis(1234)
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	v := "delete"
+	rs, err := adm.CreateTopics(ctx, 3, 2, map[string]*string{"cleanup.policy": &v}, "new", "exists")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got.TimeoutMillis != 1234 {
+		t.Errorf("got timeout %d != exp 1234", got.TimeoutMillis)
+	}
+	if len(got.Topics) != 2 {
+		t.Fatalf("got %d request topics != exp 2", len(got.Topics))
+	}
+	for _, rt := range got.Topics {
+		if rt.NumPartitions != 3 || rt.ReplicationFactor != 2 {
+			t.Errorf("%s: got partitions %d, replication %d != exp 3, 2", rt.Topic, rt.NumPartitions, rt.ReplicationFactor)
+		}
+		if len(rt.Configs) != 1 || rt.Configs[0].Name != "cleanup.policy" || *rt.Configs[0].Value != "delete" {
+			t.Errorf("%s: got unexpected configs %v", rt.Topic, rt.Configs)
+		}
+	}
+
+	if len(rs) != 2 {
+		t.Fatalf("got %d results != exp 2", len(rs))
+	}
+	if rs[0].Topic != "exists" || rs[1].Topic != "new" {
+		t.Errorf("results not sorted by topic: %v", rs)
+	}
+	if rs[1].Err != nil {
+		t.Errorf("got unexpected err for new topic: %v", rs[1].Err)
+	}
+	if !errors.Is(rs[0].Err, kerr.TopicAlreadyExists) {
+		t.Errorf("got err %v, expected it to wrap %v", rs[0].Err, kerr.TopicAlreadyExists)
+	}
+	var me *kerr.MessageError
+	if !errors.As(rs[0].Err, &me) || me.Message != "topic 'exists' already exists" {
+		t.Errorf("got err %v, expected a message error with the broker message", rs[0].Err)
+	}
+	if rs[0].Retried {
+		t.Error("result unexpectedly marked as retried")
+	}
+}
+
+func TestCreateTopicsIdempotentRetry(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	// The first attempt is processed but the connection is cut before
+	// the response; the retry sees the topic as already existing.
+	c.Control(19, func(req kmsg.Request) (kmsg.Response, error) {
+		if c.NumReqs(19) == 1 {
+			return nil, fakecluster.ErrCloseConn
+		}
+		resp := req.ResponseKind().(*kmsg.CreateTopicsResponse)
+		st := kmsg.NewCreateTopicsResponseTopic()
+		st.Topic = "t"
+		st.ErrorCode = kerr.TopicAlreadyExists.Code
+		st.ErrorMessage = kmsg.StringPtr("topic 't' already exists")
+		resp.Topics = append(resp.Topics, st)
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+	adm.SetIdempotent(true)
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	rs, err := adm.CreateTopics(ctx, 1, 1, nil, "t")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	exp := []CreateTopicResult{{Topic: "t", Retried: true}}
+	if !reflect.DeepEqual(rs, exp) {
+		t.Errorf("got %v != exp %v", rs, exp)
+	}
+}
+
+func TestDeleteTopics(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	var (
+		mu  sync.Mutex
+		got *kmsg.DeleteTopicsRequest
+	)
+	c.Control(20, func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = req.(*kmsg.DeleteTopicsRequest)
+		resp := req.ResponseKind().(*kmsg.DeleteTopicsResponse)
+		for _, topic := range []string{"z", "a"} {
+			st := kmsg.NewDeleteTopicsResponseTopic()
+			st.Topic = kmsg.StringPtr(topic)
+			if topic == "z" {
+				st.ErrorCode = kerr.UnknownTopicOrPartition.Code
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil
+	})
+
+	adm := newFakeAdm(t, c)
+	defer adm.Close()
+	adm.SetTimeoutMillis(1234)
+	adm.SetIdempotent(true)
+
+	ctx, cancel := testCtx()
+	defer cancel()
+	rs, err := adm.DeleteTopics(ctx, "z", "a")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got.TimeoutMillis != 1234 {
+		t.Errorf("got timeout %d != exp 1234", got.TimeoutMillis)
+	}
+	var requested []string
+	if got.GetVersion() >= 6 {
+		for _, rt := range got.Topics {
+			requested = append(requested, *rt.Topic)
+		}
+	} else {
+		requested = got.TopicNames
+	}
+	if exp := []string{"z", "a"}; !reflect.DeepEqual(requested, exp) {
+		t.Errorf("got requested topics %v != exp %v", requested, exp)
+	}
+
+	// An unknown topic on a first attempt is an error even if
+	// idempotent: only a retry can have deleted the topic already.
+	if len(rs) != 2 || rs[0].Topic != "a" || rs[1].Topic != "z" {
+		t.Fatalf("got unexpected results %v, expected a and z in order", rs)
+	}
+	if rs[0].Err != nil || rs[0].Retried {
+		t.Errorf("got unexpected result %v", rs[0])
+	}
+	if !errors.Is(rs[1].Err, kerr.UnknownTopicOrPartition) || rs[1].Retried {
+		t.Errorf("got unexpected result %v", rs[1])
+	}
+}
+
+func TestDeleteTopicsIdempotentRetry(t *testing.T) {
+	t.Parallel()
+
+	for _, idempotent := range []bool{false, true} {
+		c := fakecluster.New(t, nil)
+		defer c.Close()
+
+		// The first attempt is processed but the connection is cut
+		// before the response; the retry sees the topic as unknown.
+		c.Control(20, func(req kmsg.Request) (kmsg.Response, error) {
+			if c.NumReqs(20) == 1 {
+				return nil, fakecluster.ErrCloseConn
+			}
+			resp := req.ResponseKind().(*kmsg.DeleteTopicsResponse)
+			st := kmsg.NewDeleteTopicsResponseTopic()
+			st.Topic = kmsg.StringPtr("t")
+			st.ErrorCode = kerr.UnknownTopicOrPartition.Code
+			resp.Topics = append(resp.Topics, st)
+			return resp, nil
+		})
+
+		adm := newFakeAdm(t, c)
+		defer adm.Close()
+		adm.SetIdempotent(idempotent)
+
+		ctx, cancel := testCtx()
+		defer cancel()
+		rs, err := adm.DeleteTopics(ctx, "t")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(rs) != 1 || rs[0].Topic != "t" || !rs[0].Retried {
+			t.Fatalf("idempotent %v: got unexpected results %v", idempotent, rs)
+		}
+		if idempotent && rs[0].Err != nil {
+			t.Errorf("idempotent: got err %v != exp nil", rs[0].Err)
+		}
+		if !idempotent && !errors.Is(rs[0].Err, kerr.UnknownTopicOrPartition) {
+			t.Errorf("not idempotent: got err %v, expected it to wrap %v", rs[0].Err, kerr.UnknownTopicOrPartition)
+		}
+	}
+}
@@ -7,7 +7,10 @@
 // all errors elide the standard "Err" prefix.
 package kerr
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // Error is a Kafka error.
 type Error struct {
@@ -61,10 +64,50 @@ func TypedErrorForCode(code int16) *Error {
 	return err.(*Error)
 }
 
-// IsRetriable returns whether a Kafka error is considered retriable.
+// MessageError is a Kafka error along with the human readable message a
+// broker returned alongside the error code, such as the details of why a
+// policy rejected a request.
+type MessageError struct {
+	// Err is the Kafka error for the response's error code.
+	Err error
+	// Message is the broker supplied error message.
+	Message string
+}
+
+func (e *MessageError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Err, e.Message)
+}
+
+// Unwrap returns the underlying Kafka error.
+func (e *MessageError) Unwrap() error { return e.Err }
+
+// WithMessage returns err wrapped in a *MessageError if msg is non-nil and
+// non-empty, and otherwise returns err unchanged. If err is nil, this returns
+// nil.
+//
+// NOTE: the returned error is no longer equal to the Kafka error, meaning
+// direct comparisons such as err == kerr.TopicAlreadyExists no longer work.
+// Compare with errors.Is, or unwrap with errors.As.
+func WithMessage(err error, msg *string) error {
+	if err == nil || msg == nil || *msg == "" {
+		return err
+	}
+	return &MessageError{Err: err, Message: *msg}
+}
+
+// ErrorForCodeMessage returns the error corresponding to the given error code,
+// wrapped with the broker supplied message if the message is non-empty.
+//
+// If the code is 0, this returns nil.
+func ErrorForCodeMessage(code int16, msg *string) error {
+	return WithMessage(ErrorForCode(code), msg)
+}
+
+// IsRetriable returns whether a Kafka error, or a Kafka error wrapped by err,
+// is considered retriable.
 func IsRetriable(err error) bool {
-	kerr, ok := err.(*Error)
-	return ok && kerr.Retriable
+	var kerr *Error
+	return errors.As(err, &kerr) && kerr.Retriable
 }
 
 var (
@@ -25,6 +25,7 @@ type promisedReq struct {
 	req     kmsg.Request
 	promise func(kmsg.Response, error)
 	enqueue time.Time // used to calculate writeWait
+	trace   *requestTrace
 }
 
 type promisedResp struct {
@@ -57,6 +58,33 @@ type promisedResp struct {
 	promise func(kmsg.Response, error)
 
 	enqueue time.Time // used to calculate readWait
+	trace   *requestTrace
+
+	// dropResp is whether the response is discarded, from an injected
+	// fault; see UnsafeFaultInjector.
+	dropResp bool
+}
+
+// requestTrace tracks the result of a request for BrokerRequestHooks, and is
+// only created if the client has any BrokerRequestHook.
+//
+// The result is updated serially as the request moves from being written in
+// handleReqs to being read in handleResps, and is only read once the request
+// promise is called.
+type requestTrace struct {
+	finishers []func(BrokerRequestResult)
+	result    BrokerRequestResult
+}
+
+// wrap returns a promise that finishes all hooks before calling promise.
+func (t *requestTrace) wrap(promise func(kmsg.Response, error)) func(kmsg.Response, error) {
+	return func(resp kmsg.Response, err error) {
+		t.result.Resp, t.result.Err = resp, err
+		for _, fn := range t.finishers {
+			fn(t.result)
+		}
+		promise(resp, err)
+	}
 }
 
 var unknownMetadata = BrokerMetadata{
@@ -124,6 +152,24 @@ type broker struct {
 	reqs chan promisedReq
 	// dead is an atomic so a backed up reqs cannot block broker stoppage.
 	dead int32
+
+	// unreachable is an atomic that is 1 if the last dial to this broker
+	// failed and 0 if it succeeded (or if we have not dialed yet).
+	unreachable int32
+
+	// drainingUntil is an atomic unix nanosecond timestamp until which
+	// the broker is avoided, if BrokerDrainCooldown is set; see
+	// markDraining.
+	drainingUntil int64
+
+	// circuitFails and circuitFailStart track consecutive connection
+	// failures for BrokerCircuitBreaker. These are only used in
+	// handleReqs, through loadConnection.
+	circuitFails     int
+	circuitFailStart time.Time
+	// circuitOpenUntil is an atomic unix nanosecond timestamp until which
+	// the broker is not dialed, or 0 if the breaker is closed.
+	circuitOpenUntil int64
 }
 
 const unknownControllerID = -1
@@ -186,12 +232,46 @@ func (b *broker) do(
 ) {
 	dead := false
 
+	if recent := b.cl.recentErrs; recent != nil {
+		finish := promise
+		promise = func(resp kmsg.Response, err error) {
+			if err != nil {
+				recent.add(ErrorEvent{
+					Time:      b.cl.cfg.clock.Now(),
+					Broker:    b.meta.NodeID,
+					Key:       req.Key(),
+					Partition: -1,
+					Err:       err,
+				})
+			}
+			finish(resp, err)
+		}
+	}
+
+	var trace *requestTrace
+	b.cl.cfg.hooks.each(func(h Hook) {
+		if h, ok := h.(BrokerRequestHook); ok {
+			if fn := h.OnBrokerRequest(ctx, b.meta, req); fn != nil {
+				if trace == nil {
+					trace = &requestTrace{result: BrokerRequestResult{
+						Version:       -1,
+						CorrelationID: -1,
+					}}
+				}
+				trace.finishers = append(trace.finishers, fn)
+			}
+		}
+	})
+	if trace != nil {
+		promise = trace.wrap(promise)
+	}
+
 	enqueue := time.Now()
 	b.dieMu.RLock()
 	if atomic.LoadInt32(&b.dead) == 1 {
 		dead = true
 	} else {
-		b.reqs <- promisedReq{ctx, req, promise, enqueue}
+		b.reqs <- promisedReq{ctx, req, promise, enqueue, trace}
 	}
 	b.dieMu.RUnlock()
 
@@ -222,12 +302,21 @@ func (b *broker) waitResp(ctx context.Context, req kmsg.Request) (kmsg.Response,
 // If any of these steps fail, the promise is called with the relevant error.
 func (b *broker) handleReqs() {
 	defer func() {
-		b.cxnNormal.die()
-		b.cxnProduce.die()
-		b.cxnFetch.die()
+		reason := DisconnectBrokerStopped
+		if b.cl.ctx.Err() != nil {
+			reason = DisconnectClientClose
+		}
+		for _, cxn := range b.cxns() {
+			cxn.die(reason)
+		}
 	}()
 
-	for pr := range b.reqs {
+	reqs := (<-chan promisedReq)(b.reqs)
+	if newScheduler := b.cl.cfg.newRequestScheduler; newScheduler != nil {
+		reqs = b.scheduleReqs(newScheduler(b.meta))
+	}
+
+	for pr := range reqs {
 		req := pr.req
 		cxn, err := b.loadConnection(pr.ctx, req.Key())
 		if err != nil {
@@ -278,18 +367,31 @@ func (b *broker) handleReqs() {
 
 		req.SetVersion(version) // always go for highest version
 
-		if !cxn.expiry.IsZero() && time.Now().After(cxn.expiry) {
+		if !cxn.expiry.IsZero() && cxn.cl.cfg.clock.Now().After(cxn.expiry) {
 			// If we are after the reauth time, try to reauth. We
 			// can only have an expiry if we went the authenticate
 			// flow, so we know we are authenticating again.
 			// For KIP-368.
-			if err = cxn.sasl(); err != nil {
+			start := cxn.cl.cfg.clock.Now()
+			err = cxn.sasl()
+			dur := cxn.cl.cfg.clock.Now().Sub(start)
+			cxn.cl.cfg.hooks.each(func(h Hook) {
+				if h, ok := h.(BrokerReauthHook); ok {
+					h.OnReauth(b.meta, dur, err)
+				}
+			})
+			if err != nil {
 				pr.promise(nil, err)
-				cxn.die()
+				cxn.die(DisconnectReauthError)
 				continue
 			}
 		}
 
+		write, dropResp := b.injectFault(pr)
+		if !write {
+			continue
+		}
+
 		// Juuuust before we issue the request, we check if it was
 		// canceled. We could have previously tried this request, which
 		// then failed and retried due to the error being errDeadConn.
@@ -331,11 +433,23 @@ func (b *broker) handleReqs() {
 			noResp = &kmsg.ProduceResponse{Version: req.GetVersion()}
 		}
 
-		corrID, err := cxn.writeRequest(pr.ctx, pr.enqueue, req)
+		corrID, bytesWritten, err := cxn.writeRequest(pr.ctx, pr.enqueue, req)
+		if pr.trace != nil {
+			pr.trace.result.Version = req.GetVersion()
+			pr.trace.result.BytesWritten = bytesWritten
+			if err == nil {
+				pr.trace.result.CorrelationID = corrID
+			}
+		}
 
 		if err != nil {
 			pr.promise(nil, err)
-			cxn.die()
+			// A rejected request was never written, so our
+			// connection is still fine.
+			var rejected *errWriteRejected
+			if !errors.As(err, &rejected) {
+				cxn.die(DisconnectWriteError)
+			}
 			continue
 		}
 
@@ -344,8 +458,9 @@ func (b *broker) handleReqs() {
 			continue
 		}
 
-		rt, _ := cxn.cl.connTimeoutFn(req)
+		rt, _ := cxn.cl.connTimeouts(pr.ctx, req)
 
+		cxn.trackInflight(req.Key(), corrID)
 		cxn.waitResp(promisedResp{
 			pr.ctx,
 			corrID,
@@ -354,6 +469,8 @@ func (b *broker) handleReqs() {
 			req.ResponseKind(),
 			pr.promise,
 			time.Now(),
+			pr.trace,
+			dropResp,
 		})
 	}
 }
@@ -386,8 +503,21 @@ func (b *broker) loadConnection(ctx context.Context, reqKey int16) (*brokerCxn,
 		return *pcxn, nil
 	}
 
+	if b.isDraining() {
+		return nil, errBrokerDraining
+	}
+	if b.isCircuitOpen() {
+		return nil, &ErrBrokerCircuitOpen{
+			Broker: b.meta.NodeID,
+			Until:  time.Unix(0, atomic.LoadInt64(&b.circuitOpenUntil)),
+		}
+	}
+
 	conn, err := b.connect(ctx)
 	if err != nil {
+		if ctx.Err() == nil {
+			b.circuitFailure(err)
+		}
 		return nil, err
 	}
 
@@ -401,10 +531,14 @@ func (b *broker) loadConnection(ctx context.Context, reqKey int16) (*brokerCxn,
 	}
 	if err = cxn.init(isProduceCxn); err != nil {
 		b.cl.cfg.logger.Log(LogLevelDebug, "connection initialization failed", "addr", b.addr, "broker", b.meta.NodeID, "err", err)
-		cxn.closeConn()
+		cxn.closeConn(DisconnectInitError)
+		if ctx.Err() == nil {
+			b.circuitFailure(err)
+		}
 		return nil, err
 	}
 	b.cl.cfg.logger.Log(LogLevelDebug, "connection initialized successfully", "addr", b.addr, "broker", b.meta.NodeID)
+	b.circuitReset()
 
 	b.reapMu.Lock()
 	defer b.reapMu.Unlock()
@@ -412,23 +546,45 @@ func (b *broker) loadConnection(ctx context.Context, reqKey int16) (*brokerCxn,
 	return cxn, nil
 }
 
+// loadedVersions returns the max versions the broker supports, as loaded
+// from the ApiVersions response of any live connection. This returns false
+// if the broker has no live connection or if the client is pinned to a
+// version that does not issue ApiVersions.
+func (b *broker) loadedVersions() ([kmsg.MaxKey + 1]int16, bool) {
+	b.reapMu.Lock()
+	defer b.reapMu.Unlock()
+	for _, cxn := range b.cxns() {
+		if cxn == nil || atomic.LoadInt32(&cxn.dead) == 1 || cxn.versions[0] < 0 {
+			continue
+		}
+		return cxn.versions, true
+	}
+	return [kmsg.MaxKey + 1]int16{}, false
+}
+
+// cxns returns all of the broker's connections, some of which may be nil.
+// This must be called in handleReqs or with reapMu held.
+func (b *broker) cxns() []*brokerCxn {
+	return []*brokerCxn{b.cxnNormal, b.cxnProduce, b.cxnFetch}
+}
+
 func (cl *Client) reapConnectionsLoop() {
 	idleTimeout := cl.cfg.connIdleTimeout
 	if idleTimeout < 0 { // impossible due to cfg.validate, but just in case
 		return
 	}
 
-	ticker := time.NewTicker(idleTimeout)
+	ticker := cl.cfg.clock.NewTicker(idleTimeout)
 	defer ticker.Stop()
-	last := time.Now()
+	last := cl.cfg.clock.Now()
 	for {
 		select {
 		case <-cl.ctx.Done():
 			return
-		case tick := <-ticker.C:
-			start := time.Now()
+		case tick := <-ticker.C():
+			start := cl.cfg.clock.Now()
 			reaped := cl.reapConnections(idleTimeout)
-			dur := time.Since(start)
+			dur := cl.since(start)
 			if reaped > 0 {
 				cl.cfg.logger.Log(LogLevelDebug, "reaped connections", "time_since_last_reap", tick.Sub(last), "reap_dur", dur, "num_reaped", reaped)
 			}
@@ -455,11 +611,7 @@ func (b *broker) reapConnections(idleTimeout time.Duration) (total int) {
 	b.reapMu.Lock()
 	defer b.reapMu.Unlock()
 
-	for _, cxn := range []*brokerCxn{
-		b.cxnNormal,
-		b.cxnProduce,
-		b.cxnFetch,
-	} {
+	for _, cxn := range b.cxns() {
 		if cxn == nil || atomic.LoadInt32(&cxn.dead) == 1 {
 			continue
 		}
@@ -474,37 +626,450 @@ func (b *broker) reapConnections(idleTimeout time.Duration) (total int) {
 		lastWrite := time.Unix(0, atomic.LoadInt64(&cxn.lastWrite))
 		lastRead := time.Unix(0, atomic.LoadInt64(&cxn.lastRead))
 
-		writeIdle := time.Since(lastWrite) > idleTimeout && atomic.LoadUint32(&cxn.writing) == 0
-		readIdle := time.Since(lastRead) > idleTimeout && atomic.LoadUint32(&cxn.reading) == 0
+		writeIdle := b.cl.since(lastWrite) > idleTimeout && atomic.LoadUint32(&cxn.writing) == 0
+		readIdle := b.cl.since(lastRead) > idleTimeout && atomic.LoadUint32(&cxn.reading) == 0
 
 		if writeIdle && readIdle {
-			cxn.die()
+			cxn.die(DisconnectIdle)
 			total++
 		}
 	}
 	return total
 }
 
+func (cl *Client) stuckRequestsLoop() {
+	threshold := cl.cfg.stuckThreshold
+	ticker := cl.cfg.clock.NewTicker(threshold / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cl.ctx.Done():
+			return
+		case <-ticker.C():
+			cl.brokersMu.Lock()
+			brokers := make([]*broker, 0, len(cl.brokers))
+			for _, broker := range cl.brokers {
+				brokers = append(brokers, broker)
+			}
+			cl.brokersMu.Unlock()
+
+			for _, broker := range brokers {
+				broker.checkStuckRequests(threshold)
+			}
+		}
+	}
+}
+
+// checkStuckRequests calls BrokerStuckRequestHooks for the oldest request
+// on each connection if it has been awaiting its response for longer than
+// threshold and has not yet been reported.
+func (b *broker) checkStuckRequests(threshold time.Duration) {
+	b.reapMu.Lock()
+	cxns := b.cxns()
+	b.reapMu.Unlock()
+
+	for _, cxn := range cxns {
+		if cxn == nil || atomic.LoadInt32(&cxn.dead) == 1 {
+			continue
+		}
+
+		cxn.inflightMu.Lock()
+		var stuck inflightReq
+		var age time.Duration
+		if len(cxn.inflight) > 0 && !cxn.inflight[0].reported {
+			if age = b.cl.since(cxn.inflight[0].written); age > threshold {
+				cxn.inflight[0].reported = true
+				stuck = cxn.inflight[0]
+			}
+		}
+		cxn.inflightMu.Unlock()
+
+		if !stuck.reported {
+			continue
+		}
+		b.cl.cfg.logger.Log(LogLevelWarn, "request is stuck awaiting a response", "broker", b.meta.NodeID, "key", kmsg.NameForKey(stuck.key), "corr_id", stuck.corrID, "age", age)
+		b.cl.cfg.hooks.each(func(h Hook) {
+			if h, ok := h.(BrokerStuckRequestHook); ok {
+				h.OnStuckRequest(b.meta, stuck.key, stuck.corrID, age)
+			}
+		})
+	}
+}
+
+// markDraining marks the broker as draining for the BrokerDrainCooldown after
+// the broker closed a connection with a request in flight, which is what a
+// broker does when it shuts down, and triggers a metadata update to learn
+// where its partitions moved.
+func (b *broker) markDraining(err error) {
+	cooldown := b.cl.cfg.drainCooldown
+	if cooldown <= 0 {
+		return
+	}
+	until := b.cl.cfg.clock.Now().Add(cooldown)
+	atomic.StoreInt64(&b.drainingUntil, until.UnixNano())
+	b.cl.cfg.logger.Log(LogLevelWarn, "broker appears to be shutting down, draining it", "addr", b.addr, "broker", b.meta.NodeID, "until", until, "err", err)
+	b.cl.triggerUpdateMetadataNow()
+}
+
+// undrain clears the broker's draining state once metadata shows it is back;
+// see undrainLeaders.
+func (b *broker) undrain() {
+	if atomic.SwapInt64(&b.drainingUntil, 0) != 0 {
+		b.cl.cfg.logger.Log(LogLevelInfo, "metadata shows broker leading partitions again, no longer draining", "addr", b.addr, "broker", b.meta.NodeID)
+	}
+}
+
+// isDraining returns whether the broker is within its drain cooldown.
+func (b *broker) isDraining() bool {
+	until := atomic.LoadInt64(&b.drainingUntil)
+	return until != 0 && b.cl.cfg.clock.Now().UnixNano() < until
+}
+
+// circuitFailure records a connection failure for BrokerCircuitBreaker,
+// opening the breaker once there are enough consecutive failures within the
+// reset window. A failure while the breaker is half open (that is, a failed
+// probe) opens the breaker again immediately.
+func (b *broker) circuitFailure(err error) {
+	maxFails, resetAfter := b.cl.cfg.circuitMaxFails, b.cl.cfg.circuitResetAfter
+	if maxFails <= 0 {
+		return
+	}
+	now := b.cl.cfg.clock.Now()
+	if atomic.LoadInt64(&b.circuitOpenUntil) == 0 {
+		if b.circuitFails == 0 || now.Sub(b.circuitFailStart) > resetAfter {
+			b.circuitFails, b.circuitFailStart = 0, now
+		}
+		b.circuitFails++
+		if b.circuitFails < maxFails {
+			return
+		}
+	}
+	until := now.Add(resetAfter)
+	atomic.StoreInt64(&b.circuitOpenUntil, until.UnixNano())
+	b.cl.cfg.logger.Log(LogLevelWarn, "opening broker circuit breaker after repeated connection failures", "addr", b.addr, "broker", b.meta.NodeID, "failures", b.circuitFails, "until", until, "err", err)
+}
+
+// circuitReset closes the circuit breaker after a connection is successfully
+// initialized.
+func (b *broker) circuitReset() {
+	b.circuitFails = 0
+	if atomic.SwapInt64(&b.circuitOpenUntil, 0) != 0 {
+		b.cl.cfg.logger.Log(LogLevelInfo, "closing broker circuit breaker after successful probe", "addr", b.addr, "broker", b.meta.NodeID)
+	}
+}
+
+// isCircuitOpen returns whether the broker's circuit breaker is open and not
+// yet allowing a probe dial.
+func (b *broker) isCircuitOpen() bool {
+	until := atomic.LoadInt64(&b.circuitOpenUntil)
+	return until != 0 && b.cl.cfg.clock.Now().UnixNano() < until
+}
+
 // connect connects to the broker's addr, returning the new connection.
 func (b *broker) connect(ctx context.Context) (net.Conn, error) {
 	b.cl.cfg.logger.Log(LogLevelDebug, "opening connection to broker", "addr", b.addr, "broker", b.meta.NodeID)
+	parentCtx := ctx
+	if timeout := b.cl.cfg.dialTimeout; timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 	start := time.Now()
-	conn, err := b.cl.cfg.dialFn(ctx, "tcp", b.addr)
+	conn, addr, err := b.dial(ctx)
+	if err == nil {
+		conn, err = b.maybeTLS(ctx, conn)
+	}
 	since := time.Since(start)
 	b.cl.cfg.hooks.each(func(h Hook) {
 		if h, ok := h.(BrokerConnectHook); ok {
 			h.OnConnect(b.meta, since, conn, err)
 		}
+		if h, ok := h.(BrokerConnectAddrHook); ok {
+			h.OnConnectAddr(b.meta, addr, err)
+		}
 	})
+	if err == nil && isTLSConn(conn) {
+		atomic.StoreUint32(&b.cl.dialedTLS, 1)
+	}
 	if err != nil {
+		// A canceled request does not mean the broker is unreachable,
+		// but a dial timeout does.
+		if parentCtx.Err() == nil {
+			atomic.StoreInt32(&b.unreachable, 1)
+		}
 		b.cl.cfg.logger.Log(LogLevelWarn, "unable to open connection to broker", "addr", b.addr, "broker", b.meta.NodeID, "err", err)
 		return nil, fmt.Errorf("unable to dial: %w", err)
 	} else {
-		b.cl.cfg.logger.Log(LogLevelDebug, "connection opened to broker", "addr", b.addr, "broker", b.meta.NodeID)
+		atomic.StoreInt32(&b.unreachable, 0)
+		b.cl.cfg.logger.Log(LogLevelDebug, "connection opened to broker", "addr", b.addr, "dialed_addr", addr, "broker", b.meta.NodeID)
 	}
 	return conn, nil
 }
 
+// isTLSConn returns whether conn is a TLS connection, such as one from
+// TLSConfigFn or from a Dialer that dials TLS.
+func isTLSConn(conn net.Conn) bool {
+	_, ok := conn.(interface{ ConnectionState() tls.ConnectionState })
+	return ok
+}
+
+// maybeTLS performs a TLS handshake over conn if the client has a TLSConfigFn
+// that returns a config for this broker. The handshake is bounded by ctx; on
+// error, conn is closed.
+func (b *broker) maybeTLS(ctx context.Context, conn net.Conn) (net.Conn, error) {
+	fn := b.cl.cfg.tlsCfgFn
+	if fn == nil {
+		return conn, nil
+	}
+	tlsCfg := fn(b.meta)
+	if tlsCfg == nil {
+		return conn, nil
+	}
+	if tlsCfg.ServerName == "" {
+		tlsCfg = tlsCfg.Clone()
+		tlsCfg.ServerName = b.meta.Host
+	}
+
+	tlsConn := tls.Client(conn, tlsCfg)
+	if deadline, ok := ctx.Deadline(); ok {
+		tlsConn.SetDeadline(deadline)
+	}
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			tlsConn.SetDeadline(time.Now()) // interrupt the handshake
+		case <-done:
+		}
+	}()
+	err := tlsConn.Handshake()
+	close(done)
+	<-exited
+	if err != nil {
+		conn.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		return nil, fmt.Errorf("unable to tls handshake: %w", err)
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// dial dials the broker's address, returning the connection and the address
+// that was dialed. If the client is configured with a dns cache or a dial
+// fallback delay, this resolves the broker's host itself and races the
+// resolved addresses.
+func (b *broker) dial(ctx context.Context) (net.Conn, string, error) {
+	var (
+		dialFn = b.cl.cfg.dialFn
+		cache  = b.cl.dnsCache
+		delay  = b.cl.cfg.dialFallbackDelay
+	)
+	if pool := b.cl.cfg.connPool; pool != nil {
+		conn, err := pool.dial(ctx, b.addr)
+		return conn, b.addr, err
+	}
+	if cache == nil && delay == 0 {
+		conn, err := dialFn(ctx, "tcp", b.addr)
+		return conn, b.addr, err
+	}
+	host, port, err := net.SplitHostPort(b.addr)
+	if err != nil || net.ParseIP(host) != nil {
+		conn, err := dialFn(ctx, "tcp", b.addr)
+		return conn, b.addr, err
+	}
+
+	var ips []string
+	if cache != nil {
+		ips, err = cache.lookup(ctx, host)
+	} else {
+		ips, err = net.DefaultResolver.LookupHost(ctx, host)
+	}
+	if err != nil {
+		return nil, b.addr, err
+	}
+
+	if delay == 0 {
+		// Only a dns cache is configured; we still race the cached
+		// addresses, as documented on DNSCacheTTL.
+		delay = 300 * time.Millisecond // same as the net package default
+	}
+
+	conn, addr, err := dialStaggered(ctx, dialFn, interleaveFamilies(ips), port, delay)
+	if err != nil {
+		if cache != nil {
+			cache.evict(host)
+		}
+		addr = b.addr
+	}
+	return conn, addr, err
+}
+
+// interleaveFamilies returns ips reordered to alternate address families,
+// starting with the family of the first ip and otherwise keeping the
+// resolver's order, as described in RFC 8305 section 4.
+func interleaveFamilies(ips []string) []string {
+	var primaries, fallbacks []string
+	for _, ip := range ips {
+		if isIPv4(ip) == isIPv4(ips[0]) {
+			primaries = append(primaries, ip)
+		} else {
+			fallbacks = append(fallbacks, ip)
+		}
+	}
+	interleaved := make([]string, 0, len(ips))
+	for len(primaries) > 0 || len(fallbacks) > 0 {
+		if len(primaries) > 0 {
+			interleaved = append(interleaved, primaries[0])
+			primaries = primaries[1:]
+		}
+		if len(fallbacks) > 0 {
+			interleaved = append(interleaved, fallbacks[0])
+			fallbacks = fallbacks[1:]
+		}
+	}
+	return interleaved
+}
+
+func isIPv4(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && parsed.To4() != nil
+}
+
+// dialStaggered dials ips in order, starting the next dial after delay or as
+// soon as the prior dial fails, whichever is first. The first successful
+// connection and its address are returned; any later successful connection
+// is closed.
+func dialStaggered(
+	ctx context.Context,
+	dialFn func(context.Context, string, string) (net.Conn, error),
+	ips []string,
+	port string,
+	delay time.Duration,
+) (net.Conn, string, error) {
+	if len(ips) == 0 {
+		return nil, "", errors.New("no addresses to dial")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		addr string
+		err  error
+	}
+	results := make(chan result) // unbuffered: we always drain started dials
+	var (
+		next    int
+		started int
+		timer   = time.NewTimer(delay)
+	)
+	defer timer.Stop()
+	startNext := func() {
+		addr := net.JoinHostPort(ips[next], port)
+		next++
+		started++
+		go func() {
+			conn, err := dialFn(ctx, "tcp", addr)
+			results <- result{conn, addr, err}
+		}()
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if next < len(ips) {
+			timer.Reset(delay)
+		}
+	}
+	startNext()
+
+	var (
+		winner   result
+		firstErr error
+	)
+	for started > 0 {
+		select {
+		case <-timer.C:
+			if winner.conn == nil && next < len(ips) && ctx.Err() == nil {
+				startNext()
+			}
+		case r := <-results:
+			started--
+			switch {
+			case r.err == nil && winner.conn == nil:
+				winner = r
+				cancel() // stop any other in flight dial
+			case r.err == nil:
+				r.conn.Close()
+			case firstErr == nil:
+				firstErr = r.err
+			}
+			if winner.conn == nil && next < len(ips) && ctx.Err() == nil {
+				startNext() // the prior dial failed before the delay
+			}
+		}
+	}
+	if winner.conn != nil {
+		return winner.conn, winner.addr, nil
+	}
+	return nil, "", firstErr
+}
+
+// dnsCache caches host lookups for a configured ttl.
+type dnsCache struct {
+	ttl   time.Duration
+	clock clock
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	ips     []string
+	expires time.Time
+}
+
+func newDNSCache(ttl time.Duration, clock clock) *dnsCache {
+	return &dnsCache{
+		ttl:     ttl,
+		clock:   clock,
+		entries: make(map[string]dnsEntry),
+	}
+}
+
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && c.clock.Now().Before(entry.expires) {
+		return entry.ips, nil
+	}
+
+	ips, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses found for host %s", host)
+	}
+
+	c.mu.Lock()
+	c.entries[host] = dnsEntry{ips, c.clock.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return ips, nil
+}
+
+func (c *dnsCache) evict(host string) {
+	c.mu.Lock()
+	delete(c.entries, host)
+	c.mu.Unlock()
+}
+
 // brokerCxn manages an actual connection to a Kafka broker. This is separate
 // the broker struct to allow lazy connection (re)creation.
 type brokerCxn struct {
@@ -531,6 +1096,15 @@ type brokerCxn struct {
 	writing   uint32
 	reading   uint32
 
+	// readBudgetHeld is the number of bytes of the client's read budget
+	// held for the last response read, if MaxConcurrentReadBytes is set.
+	readBudgetHeld int64 // atomic
+
+	// respBuf is the pooled buffer of the last response read in
+	// handleResps, if PooledResponseBuffers is set; only used in
+	// handleResps.
+	respBuf []byte
+
 	// dieMu guards sending to resps in case the connection has died.
 	dieMu sync.RWMutex
 	// resps manages reading kafka responses.
@@ -539,6 +1113,46 @@ type brokerCxn struct {
 	dead int32
 	// closed in cloneConn; allows throttle waiting to quit
 	deadCh chan struct{}
+
+	// inflight tracks requests awaiting responses, oldest first, if the
+	// stuck request watchdog is enabled.
+	inflightMu sync.Mutex
+	inflight   []inflightReq
+}
+
+type inflightReq struct {
+	key      int16
+	corrID   int32
+	written  time.Time
+	reported bool
+}
+
+// trackInflight records a written request that is awaiting a response.
+func (cxn *brokerCxn) trackInflight(key int16, corrID int32) {
+	if cxn.cl.cfg.stuckThreshold <= 0 {
+		return
+	}
+	cxn.inflightMu.Lock()
+	defer cxn.inflightMu.Unlock()
+	cxn.inflight = append(cxn.inflight, inflightReq{
+		key:     key,
+		corrID:  corrID,
+		written: cxn.cl.cfg.clock.Now(),
+	})
+}
+
+// untrackInflight removes the oldest request awaiting a response, which is
+// always the next response read.
+func (cxn *brokerCxn) untrackInflight() {
+	if cxn.cl.cfg.stuckThreshold <= 0 {
+		return
+	}
+	cxn.inflightMu.Lock()
+	defer cxn.inflightMu.Unlock()
+	if len(cxn.inflight) > 0 {
+		cxn.inflight[0] = inflightReq{}
+		cxn.inflight = cxn.inflight[1:]
+	}
 }
 
 func (cxn *brokerCxn) init(isProduceCxn bool) error {
@@ -588,18 +1202,18 @@ start:
 		ClientSoftwareVersion: cxn.cl.cfg.softwareVersion,
 	}
 	cxn.cl.cfg.logger.Log(LogLevelDebug, "issuing api versions request", "broker", cxn.b.meta.NodeID, "version", maxVersion)
-	corrID, err := cxn.writeRequest(nil, time.Now(), req)
+	corrID, _, err := cxn.writeRequest(nil, time.Now(), req)
 	if err != nil {
 		return err
 	}
 
 	rt, _ := cxn.cl.connTimeoutFn(req)
-	rawResp, err := cxn.readResponse(nil, rt, time.Now(), req.Key(), req.GetVersion(), corrID, false) // api versions does *not* use flexible response headers; see comment in promisedResp
+	rawResp, _, err := cxn.readResponse(nil, rt, time.Now(), req.Key(), req.GetVersion(), corrID, false, false) // api versions does *not* use flexible response headers; see comment in promisedResp
 	if err != nil {
 		return err
 	}
 	if len(rawResp) < 2 {
-		return fmt.Errorf("invalid length %d short response from ApiVersions request", len(rawResp))
+		return cxn.maybeAssumeAPIVersions(fmt.Errorf("invalid length %d short response from ApiVersions request", len(rawResp)))
 	}
 
 	resp := req.ResponseKind().(*kmsg.ApiVersionsResponse)
@@ -626,10 +1240,10 @@ start:
 	}
 
 	if err = resp.ReadFrom(rawResp); err != nil {
-		return fmt.Errorf("unable to read ApiVersions response: %w", err)
+		return cxn.maybeAssumeAPIVersions(fmt.Errorf("unable to read ApiVersions response: %w", err))
 	}
 	if len(resp.ApiKeys) == 0 {
-		return errors.New("ApiVersions response invalidly contained no ApiKeys")
+		return cxn.maybeAssumeAPIVersions(errors.New("ApiVersions response invalidly contained no ApiKeys"))
 	}
 
 	for _, key := range resp.ApiKeys {
@@ -641,6 +1255,28 @@ start:
 	return nil
 }
 
+// maybeAssumeAPIVersions is called when an ApiVersions response is unusable.
+// If the user configured OnEmptyAPIVersions, this uses the returned versions
+// and returns nil, otherwise this returns err.
+func (cxn *brokerCxn) maybeAssumeAPIVersions(err error) error {
+	fn := cxn.cl.cfg.onEmptyAPIVersions
+	if fn == nil {
+		return err
+	}
+	assumed := fn()
+	if len(assumed) == 0 {
+		return err
+	}
+	cxn.cl.cfg.logger.Log(LogLevelWarn, "ApiVersions response is unusable, using assumed versions", "broker", cxn.b.meta.NodeID, "err", err)
+	for key, max := range assumed {
+		if key < 0 || key > kmsg.MaxKey {
+			continue
+		}
+		cxn.versions[key] = max
+	}
+	return nil
+}
+
 func (cxn *brokerCxn) sasl() error {
 	if len(cxn.cl.cfg.sasls) == 0 {
 		return nil
@@ -655,13 +1291,13 @@ start:
 		req.Mechanism = mechanism.Name()
 		req.Version = cxn.versions[req.Key()]
 		cxn.cl.cfg.logger.Log(LogLevelDebug, "issuing SASLHandshakeRequest", "broker", cxn.b.meta.NodeID)
-		corrID, err := cxn.writeRequest(nil, time.Now(), req)
+		corrID, _, err := cxn.writeRequest(nil, time.Now(), req)
 		if err != nil {
 			return err
 		}
 
 		rt, _ := cxn.cl.connTimeoutFn(req)
-		rawResp, err := cxn.readResponse(nil, rt, time.Now(), req.Key(), req.GetVersion(), corrID, req.IsFlexible())
+		rawResp, _, err := cxn.readResponse(nil, rt, time.Now(), req.Key(), req.GetVersion(), corrID, req.IsFlexible(), false)
 		if err != nil {
 			return err
 		}
@@ -731,7 +1367,7 @@ func (cxn *brokerCxn) doSasl(authenticate bool) error {
 				return err
 			}
 			if !done {
-				if _, challenge, err, _, _ = cxn.readConn(context.Background(), rt, time.Now()); err != nil {
+				if _, challenge, err, _, _ = cxn.readConn(context.Background(), rt, time.Now(), false); err != nil {
 					return err
 				}
 			}
@@ -743,12 +1379,12 @@ func (cxn *brokerCxn) doSasl(authenticate bool) error {
 			req.Version = cxn.versions[req.Key()]
 			cxn.cl.cfg.logger.Log(LogLevelDebug, "issuing SASLAuthenticate", "broker", cxn.b.meta.NodeID, "version", req.Version, "step", step)
 
-			corrID, err := cxn.writeRequest(nil, time.Now(), req)
+			corrID, _, err := cxn.writeRequest(nil, time.Now(), req)
 			if err != nil {
 				return err
 			}
 			if !done {
-				rawResp, err := cxn.readResponse(nil, rt, time.Now(), req.Key(), req.GetVersion(), corrID, req.IsFlexible())
+				rawResp, _, err := cxn.readResponse(nil, rt, time.Now(), req.Key(), req.GetVersion(), corrID, req.IsFlexible(), false)
 				if err != nil {
 					return err
 				}
@@ -785,31 +1421,42 @@ func (cxn *brokerCxn) doSasl(authenticate bool) error {
 		if lifetimeMillis < 5000 {
 			return fmt.Errorf("invalid short sasl lifetime millis %d", lifetimeMillis)
 		}
-		cxn.expiry = time.Now().Add(time.Duration(lifetimeMillis)*time.Millisecond - time.Second)
+		cxn.expiry = cxn.cl.cfg.clock.Now().Add(time.Duration(lifetimeMillis)*time.Millisecond - time.Second)
 		cxn.cl.cfg.logger.Log(LogLevelDebug, "connection has a limited lifetime", "broker", cxn.b.meta.NodeID, "reauthenticate_at", cxn.expiry)
 	}
 	return nil
 }
 
 // writeRequest writes a message request to the broker connection, bumping the
-// connection's correlation ID as appropriate for the next write.
-func (cxn *brokerCxn) writeRequest(ctx context.Context, enqueuedForWritingAt time.Time, req kmsg.Request) (int32, error) {
+// connection's correlation ID as appropriate for the next write. This returns
+// the correlation ID used and the number of bytes written.
+func (cxn *brokerCxn) writeRequest(ctx context.Context, enqueuedForWritingAt time.Time, req kmsg.Request) (int32, int, error) {
 	// A nil ctx means we cannot be throttled.
 	if ctx != nil {
 		throttleUntil := time.Unix(0, atomic.LoadInt64(&cxn.throttleUntil))
-		if sleep := throttleUntil.Sub(time.Now()); sleep > 0 {
-			after := time.NewTimer(sleep)
+		now := cxn.cl.cfg.clock.Now()
+		if sleep := throttleUntil.Sub(now); sleep > 0 {
+			// If we would sleep past the request's deadline, we
+			// fail now rather than sleep only to fail anyway.
+			if deadline, ok := ctx.Deadline(); ok && now.Add(sleep).After(deadline) {
+				return 0, 0, &ErrThrottleExceedsDeadline{
+					Broker:    cxn.b.meta.NodeID,
+					Throttle:  sleep,
+					Remaining: deadline.Sub(now),
+				}
+			}
+			after := cxn.cl.cfg.clock.NewTimer(sleep)
 			select {
-			case <-after.C:
+			case <-after.C():
 			case <-ctx.Done():
 				after.Stop()
-				return 0, ctx.Err()
+				return 0, 0, ctx.Err()
 			case <-cxn.cl.ctx.Done():
 				after.Stop()
-				return 0, errClientClosing
+				return 0, 0, errClientClosing
 			case <-cxn.deadCh:
 				after.Stop()
-				return 0, errChosenBrokerDead
+				return 0, 0, errChosenBrokerDead
 			}
 		}
 	}
@@ -822,7 +1469,21 @@ func (cxn *brokerCxn) writeRequest(ctx context.Context, enqueuedForWritingAt tim
 		cxn.corrID,
 	)
 
-	_, wt := cxn.cl.connTimeoutFn(req)
+	// A nil ctx means this is a connection initialization request, which
+	// cannot be rejected.
+	if ctx != nil {
+		var rejectErr error
+		cxn.cl.cfg.hooks.each(func(h Hook) {
+			if h, ok := h.(BrokerWriteSizeHook); ok && rejectErr == nil {
+				rejectErr = h.OnWriteSize(cxn.b.meta, req.Key(), len(buf))
+			}
+		})
+		if rejectErr != nil {
+			return 0, 0, &errWriteRejected{rejectErr}
+		}
+	}
+
+	_, wt := cxn.cl.connTimeouts(ctx, req)
 	bytesWritten, writeErr, writeWait, timeToWrite := cxn.writeConn(ctx, buf, wt, enqueuedForWritingAt)
 
 	cxn.cl.cfg.hooks.each(func(h Hook) {
@@ -835,23 +1496,34 @@ func (cxn *brokerCxn) writeRequest(ctx context.Context, enqueuedForWritingAt tim
 	}
 
 	if writeErr != nil {
-		return 0, writeErr
+		return 0, bytesWritten, writeErr
 	}
 	id := cxn.corrID
 	cxn.corrID++
-	return id, nil
+	return id, bytesWritten, nil
 }
 
 func (cxn *brokerCxn) writeConn(ctx context.Context, buf []byte, timeout time.Duration, enqueuedForWritingAt time.Time) (bytesWritten int, writeErr error, writeWait, timeToWrite time.Duration) {
 	atomic.SwapUint32(&cxn.writing, 1)
 	defer func() {
-		atomic.StoreInt64(&cxn.lastWrite, time.Now().UnixNano())
+		atomic.StoreInt64(&cxn.lastWrite, cxn.cl.cfg.clock.Now().UnixNano())
 		atomic.SwapUint32(&cxn.writing, 0)
 	}()
 
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	if codec := cxn.cl.cfg.frameCodec; codec != nil {
+		encoded := cxn.cl.bufPool.get()
+		defer func() { cxn.cl.bufPool.put(encoded) }()
+		encoded = append(encoded[:0], 0, 0, 0, 0)
+		if encoded, writeErr = codec.EncodeFrame(encoded, buf[4:]); writeErr != nil {
+			writeErr = fmt.Errorf("unable to encode frame: %w", writeErr)
+			return
+		}
+		binary.BigEndian.PutUint32(encoded, uint32(len(encoded)-4))
+		buf = encoded
+	}
 	if timeout > 0 {
 		cxn.conn.SetWriteDeadline(time.Now().Add(timeout))
 	}
@@ -885,10 +1557,10 @@ func (cxn *brokerCxn) writeConn(ctx context.Context, buf []byte, timeout time.Du
 	return
 }
 
-func (cxn *brokerCxn) readConn(ctx context.Context, timeout time.Duration, enqueuedForReadingAt time.Time) (nread int, buf []byte, err error, readWait, timeToRead time.Duration) {
+func (cxn *brokerCxn) readConn(ctx context.Context, timeout time.Duration, enqueuedForReadingAt time.Time, pooled bool) (nread int, buf []byte, err error, readWait, timeToRead time.Duration) {
 	atomic.SwapUint32(&cxn.reading, 1)
 	defer func() {
-		atomic.StoreInt64(&cxn.lastRead, time.Now().UnixNano())
+		atomic.StoreInt64(&cxn.lastRead, cxn.cl.cfg.clock.Now().UnixNano())
 		atomic.SwapUint32(&cxn.reading, 0)
 	}()
 
@@ -916,15 +1588,44 @@ func (cxn *brokerCxn) readConn(ctx context.Context, timeout time.Duration, enque
 		if size, err = cxn.parseReadSize(sizeBuf); err != nil {
 			return
 		}
-		buf = make([]byte, size)
+		// If we cannot acquire the read budget, we have already
+		// read the size of a response we will not read; the
+		// connection must die, as it does on any read error.
+		if err = cxn.acquireReadBudget(ctx, size); err != nil {
+			return
+		}
+		if pooled {
+			buf = cxn.cl.respBufs.get(size)
+			cxn.respBuf = buf
+		} else {
+			buf = make([]byte, size)
+		}
 		var nread2 int
 		nread2, err = io.ReadFull(cxn.conn, buf)
 		nread += nread2
 		buf = buf[:nread2]
 		if err != nil {
+			cxn.releaseReadBudget()
 			err = &errDeadConn{err}
 			return
 		}
+		if codec := cxn.cl.cfg.frameCodec; codec != nil {
+			maxSize := cxn.cl.cfg.maxBrokerReadBytes
+			if buf, err = codec.DecodeFrame(buf, int(maxSize)); err != nil {
+				err = fmt.Errorf("unable to decode frame: %w", err)
+				return
+			}
+			if int64(len(buf)) > int64(maxSize) {
+				err = fmt.Errorf("unable to decode frame: decoded size %d: %w", len(buf), ErrFrameTooLarge)
+				return
+			}
+			// The decoded frame is what we hold on to from here;
+			// the read budget is for the decoded size.
+			cxn.releaseReadBudget()
+			if err = cxn.acquireReadBudget(ctx, int32(len(buf))); err != nil {
+				return
+			}
+		}
 	}()
 	select {
 	case <-readDone:
@@ -981,9 +1682,10 @@ func (cxn *brokerCxn) parseReadSize(sizeBuf []byte) (int32, error) {
 }
 
 // readResponse reads a response from conn, ensures the correlation ID is
-// correct, and returns a newly allocated slice on success.
-func (cxn *brokerCxn) readResponse(ctx context.Context, timeout time.Duration, enqueuedForReadingAt time.Time, key, version int16, corrID int32, flexibleHeader bool) ([]byte, error) {
-	nread, buf, err, readWait, timeToRead := cxn.readConn(ctx, timeout, enqueuedForReadingAt)
+// correct, and returns a newly allocated slice on success as well as the
+// number of bytes read.
+func (cxn *brokerCxn) readResponse(ctx context.Context, timeout time.Duration, enqueuedForReadingAt time.Time, key, version int16, corrID int32, flexibleHeader, pooled bool) ([]byte, int, error) {
+	nread, buf, err, readWait, timeToRead := cxn.readConn(ctx, timeout, enqueuedForReadingAt, pooled)
 
 	cxn.cl.cfg.hooks.each(func(h Hook) {
 		if h, ok := h.(BrokerReadHook); ok {
@@ -995,41 +1697,64 @@ func (cxn *brokerCxn) readResponse(ctx context.Context, timeout time.Duration, e
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, nread, err
 	}
 	if len(buf) < 4 {
-		return nil, kbin.ErrNotEnoughData
+		return nil, nread, kbin.ErrNotEnoughData
 	}
 	gotID := int32(binary.BigEndian.Uint32(buf))
 	if gotID != corrID {
-		return nil, errCorrelationIDMismatch
+		return nil, nread, &ErrCorrelationIDMismatch{Expected: corrID, Got: gotID}
 	}
 	// If the response header is flexible, we skip the tags at the end of
 	// it. They are currently unused.
 	if flexibleHeader {
 		b := kbin.Reader{Src: buf[4:]}
 		kmsg.SkipTags(&b)
-		return b.Src, b.Complete()
+		return b.Src, nread, b.Complete()
 	}
-	return buf[4:], nil
+	return buf[4:], nread, nil
 }
 
 // closeConn is the one place we close broker connections. This is always done
 // in either die, which is called when handleResps returns, or if init fails,
 // which means we did not succeed enough to start handleResps.
-func (cxn *brokerCxn) closeConn() {
+func (cxn *brokerCxn) closeConn(reason DisconnectReason) {
 	cxn.cl.cfg.hooks.each(func(h Hook) {
 		if h, ok := h.(BrokerDisconnectHook); ok {
 			h.OnDisconnect(cxn.b.meta, cxn.conn)
 		}
+		if h, ok := h.(BrokerDisconnectReasonHook); ok {
+			h.OnDisconnectReason(cxn.b.meta, cxn.conn, reason)
+		}
 	})
+	if linger := cxn.cl.cfg.connCloseLinger; linger >= 0 {
+		setLinger(cxn.conn, linger)
+	}
 	cxn.conn.Close()
 	close(cxn.deadCh)
 }
 
+// setLinger sets SO_LINGER on conn if conn is, or wraps, a connection that
+// supports it (such as a *net.TCPConn or a *tls.Conn of one).
+func setLinger(conn net.Conn, sec int) {
+	for conn != nil {
+		if l, ok := conn.(interface{ SetLinger(int) error }); ok {
+			l.SetLinger(sec)
+			return
+		}
+		wrapper, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			return
+		}
+		conn = wrapper.NetConn()
+	}
+}
+
 // die kills a broker connection (which could be dead already) and replies to
-// all requests awaiting responses appropriately.
-func (cxn *brokerCxn) die() {
+// all requests awaiting responses appropriately. Only the reason for the
+// first die is used.
+func (cxn *brokerCxn) die(reason DisconnectReason) {
 	if cxn == nil {
 		return
 	}
@@ -1037,7 +1762,8 @@ func (cxn *brokerCxn) die() {
 		return
 	}
 
-	cxn.closeConn()
+	cxn.closeConn(reason)
+	cxn.releaseReadBudget()
 
 	go func() {
 		for pr := range cxn.resps {
@@ -1086,9 +1812,9 @@ func (cxn *brokerCxn) waitResp(pr promisedResp) {
 // correctly).
 //
 // (2) rather than creating a slice for the response, we discard the entire
-// response into a reusable small slice. The small size is because produce
-// responses are relatively small to begin with, so we expect only a few reads
-// per response.
+// response into a reusable small slice (DiscardBufferBytes). The small size is
+// because produce responses are relatively small to begin with, so we expect
+// only a few reads per response.
 //
 // (3) we have no time for when the read was enqueued, so we miss that in the
 // hook.
@@ -1097,12 +1823,16 @@ func (cxn *brokerCxn) waitResp(pr promisedResp) {
 // since we have no idea when a read actually should start, since we should not
 // receive responses to begin with.
 //
-// (5) we set a read deadline *after* the size bytes are read, and only if the
-// client has not yet closed.
+// (5) we set a read deadline (DiscardReadTimeout, or the produce timeout)
+// *after* the size bytes are read, and only if the client has not yet closed.
 func (cxn *brokerCxn) discard() {
-	defer cxn.die()
+	defer cxn.die(DisconnectReadError)
 
-	discardBuf := make([]byte, 256)
+	readTimeout := cxn.cl.cfg.discardReadTimeout
+	if readTimeout == 0 {
+		readTimeout = cxn.cl.cfg.produceTimeout
+	}
+	discardBuf := make([]byte, cxn.cl.cfg.discardBufBytes)
 	for {
 		var (
 			nread      int
@@ -1123,13 +1853,13 @@ func (cxn *brokerCxn) discard() {
 			}
 			deadlineMu.Lock()
 			if !deadlineSet {
-				cxn.conn.SetReadDeadline(time.Now().Add(cxn.cl.cfg.produceTimeout))
+				cxn.conn.SetReadDeadline(time.Now().Add(readTimeout))
 			}
 			deadlineMu.Unlock()
 
 			atomic.SwapUint32(&cxn.reading, 1)
 			defer func() {
-				atomic.StoreInt64(&cxn.lastRead, time.Now().UnixNano())
+				atomic.StoreInt64(&cxn.lastRead, cxn.cl.cfg.clock.Now().UnixNano())
 				atomic.SwapUint32(&cxn.reading, 0)
 			}()
 
@@ -1148,7 +1878,7 @@ func (cxn *brokerCxn) discard() {
 				}
 				nread2, err = cxn.conn.Read(discard)
 				nread += nread2
-				size -= int32(nread2) // nread2 max is 128
+				size -= int32(nread2) // nread2 max is len(discardBuf)
 			}
 			if err != nil {
 				err = &errDeadConn{err}
@@ -1177,48 +1907,84 @@ func (cxn *brokerCxn) discard() {
 	}
 }
 
+// maxUndecodedThrottleMillis is the largest throttle we honor from a response
+// that failed to decode. Real throttles are bounded by the broker's quota
+// window, which by default is far below this.
+const maxUndecodedThrottleMillis = 5 * 60 * 1000
+
 // handleResps serially handles all broker responses for an single connection.
 func (cxn *brokerCxn) handleResps() {
-	defer cxn.die() // always track our death
+	// Always track our death. If we are exiting because resps was closed,
+	// the connection is already dead and this reason is unused.
+	defer cxn.die(DisconnectReadError)
 
 	var successes uint64
 	for pr := range cxn.resps {
-		raw, err := cxn.readResponse(pr.ctx, pr.readTimeout, pr.enqueue, pr.resp.Key(), pr.resp.GetVersion(), pr.corrID, pr.flexibleHeader)
+		raw, nread, err := cxn.readResponse(pr.ctx, pr.readTimeout, pr.enqueue, pr.resp.Key(), pr.resp.GetVersion(), pr.corrID, pr.flexibleHeader, cxn.cl.poolsResp(pr.resp.Key()))
+		cxn.untrackInflight()
+		if pr.trace != nil {
+			pr.trace.result.BytesRead = nread
+		}
 		if err != nil {
 			if successes > 0 || len(cxn.b.cl.cfg.sasls) > 0 {
 				cxn.b.cl.cfg.logger.Log(LogLevelDebug, "read from broker errored, killing connection", "addr", cxn.b.addr, "id", cxn.b.meta.NodeID, "successful_reads", successes, "err", err)
 			} else {
 				cxn.b.cl.cfg.logger.Log(LogLevelWarn, "read from broker errored, killing connection after 0 successful responses (is sasl missing?)", "addr", cxn.b.addr, "id", cxn.b.meta.NodeID, "err", err)
 			}
+			// A clean EOF before any byte of the response means
+			// the broker closed the connection with our request in
+			// flight; brokers do not close connections that have
+			// pending requests unless they are shutting down.
+			var dead *errDeadConn
+			if errors.As(err, &dead) && dead.err == io.EOF {
+				cxn.b.markDraining(err)
+			}
 			pr.promise(nil, err)
 			return
 		}
 		successes++
 		readErr := pr.resp.ReadFrom(raw)
+		cxn.releaseReadBudget()
+		cxn.releaseRespBuf()
 
-		// If we had no error, we read the response successfully.
-		//
 		// Any response that can cause throttling satisfies the
 		// kmsg.ThrottleResponse interface. We check that here.
-		if readErr == nil {
-			if throttleResponse, ok := pr.resp.(kmsg.ThrottleResponse); ok {
-				millis, throttlesAfterResp := throttleResponse.Throttle()
-				if millis > 0 {
-					if throttlesAfterResp {
-						throttleUntil := time.Now().Add(time.Millisecond * time.Duration(millis)).UnixNano()
-						if throttleUntil > cxn.throttleUntil {
-							atomic.StoreInt64(&cxn.throttleUntil, throttleUntil)
-						}
+		//
+		// Responses decode in field order directly into the response,
+		// so if a response failed to decode after its throttle field,
+		// we still have the throttle and should honor it rather than
+		// hammer a throttling broker. Where the throttle field sits
+		// varies (for example, produce responses have it after the
+		// topics), so a failed decode may leave it unset. Since a
+		// failed decode could also mean garbage, we only honor a sane
+		// throttle in that case.
+		if throttleResponse, ok := pr.resp.(kmsg.ThrottleResponse); ok {
+			millis, throttlesAfterResp := throttleResponse.Throttle()
+			if readErr != nil && millis > maxUndecodedThrottleMillis {
+				millis = 0
+			}
+			if millis > 0 {
+				if readErr != nil {
+					cxn.cl.cfg.logger.Log(LogLevelDebug, "honoring throttle from response that failed to decode", "broker", cxn.b.meta.NodeID, "throttle_millis", millis, "err", readErr)
+				}
+				if throttlesAfterResp {
+					throttleUntil := cxn.cl.cfg.clock.Now().Add(time.Millisecond * time.Duration(millis)).UnixNano()
+					if throttleUntil > cxn.throttleUntil {
+						atomic.StoreInt64(&cxn.throttleUntil, throttleUntil)
 					}
-					cxn.cl.cfg.hooks.each(func(h Hook) {
-						if h, ok := h.(BrokerThrottleHook); ok {
-							h.OnThrottle(cxn.b.meta, time.Duration(millis)*time.Millisecond, throttlesAfterResp)
-						}
-					})
 				}
+				cxn.cl.cfg.hooks.each(func(h Hook) {
+					if h, ok := h.(BrokerThrottleHook); ok {
+						h.OnThrottle(cxn.b.meta, time.Duration(millis)*time.Millisecond, throttlesAfterResp)
+					}
+				})
 			}
 		}
 
+		if pr.dropResp {
+			cxn.dropResponse(pr)
+			continue
+		}
 		pr.promise(pr.resp, readErr)
 	}
 }
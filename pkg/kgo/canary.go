@@ -0,0 +1,154 @@
+package kgo
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// CanaryHeader is the record header key that Canary uses to tag the records
+// it produces.
+const CanaryHeader = "kgo-canary"
+
+// Canary produces a uniquely tagged record to topic and consumes it back,
+// returning the round trip time from just before producing to when the
+// record was fetched. This is meant for synthetic monitoring: a successful
+// canary verifies that the topic is writable and readable end to end.
+//
+// The record is partitioned with the client's partitioner. Once produced,
+// the record is fetched directly from the partition leader at the offset it
+// was written to; this does not use nor affect the client's consumer, so a
+// client can run canaries while consuming. The fetched record's value is
+// compared against what was produced, and a mismatch returns an error.
+//
+// Canary fetches until the record is seen or ctx is done; use a context with
+// a deadline to bound how long a canary can take. Producing uses the client's
+// producer as normal, so a transactional client must be in a transaction.
+func (cl *Client) Canary(ctx context.Context, topic string) (time.Duration, error) {
+	tag := make([]byte, 16)
+	if _, err := rand.Read(tag); err != nil {
+		return 0, fmt.Errorf("unable to generate canary tag: %v", err)
+	}
+	r := &Record{
+		Topic:   topic,
+		Value:   tag,
+		Headers: []RecordHeader{{Key: CanaryHeader, Value: tag}},
+	}
+
+	if cl.cfg.acks.val == 0 {
+		// With no acks, we do not know the offset the broker wrote
+		// to; we would have to scan the partition for the record.
+		return 0, errors.New("cannot run a canary when producing with no acks")
+	}
+
+	start := time.Now()
+	if err := cl.ProduceSync(ctx, r).FirstErr(); err != nil {
+		return 0, err
+	}
+
+	for tries := 0; ; tries++ {
+		fp, err := cl.fetchCanary(ctx, r)
+		if err == nil {
+			err = fp.Err
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			// Most errors are the leader moving or the broker
+			// not yet having the record replicated; we refresh
+			// metadata and try again until ctx is done.
+			cl.triggerUpdateMetadataNow()
+			if !cl.waitTries(ctx, tries) {
+				return 0, fmt.Errorf("canary record at offset %d on partition %d was not consumed: %w", r.Offset, r.Partition, err)
+			}
+			continue
+		}
+		for _, got := range fp.Records {
+			if got.Offset != r.Offset {
+				continue
+			}
+			if !bytes.Equal(got.Value, tag) {
+				return 0, fmt.Errorf("canary record at offset %d on partition %d was corrupt: value %x != produced %x", r.Offset, r.Partition, got.Value, tag)
+			}
+			return time.Since(start), nil
+		}
+		end := fp.HighWatermark
+		if cl.cfg.isolationLevel == 1 {
+			end = fp.LastStableOffset
+		}
+		if end > r.Offset && len(fp.Records) == 0 {
+			// The record is committed but the fetch skipped past it,
+			// which only happens if it was compacted or deleted.
+			return 0, fmt.Errorf("canary record at offset %d on partition %d is no longer in the partition", r.Offset, r.Partition)
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// fetchCanary issues one fetch for the canary record's offset to the leader
+// of the record's partition.
+func (cl *Client) fetchCanary(ctx context.Context, r *Record) (FetchPartition, error) {
+	parts, exists := cl.producer.topics.load()[r.Topic]
+	if !exists {
+		return FetchPartition{}, kerr.UnknownTopicOrPartition
+	}
+	partsData := parts.load()
+	if int(r.Partition) >= len(partsData.partitions) {
+		return FetchPartition{}, kerr.UnknownTopicOrPartition
+	}
+	tp := partsData.partitions[r.Partition]
+	if tp.loadErr != nil {
+		return FetchPartition{}, tp.loadErr
+	}
+
+	req := kmsg.NewPtrFetchRequest()
+	req.ReplicaID = -1
+	req.MaxWaitMillis = 500
+	req.MinBytes = 1
+	req.MaxBytes = cl.cfg.maxPartBytes
+	req.IsolationLevel = cl.cfg.isolationLevel
+	reqTopic := kmsg.NewFetchRequestTopic()
+	reqTopic.Topic = r.Topic
+	reqPartition := kmsg.NewFetchRequestTopicPartition()
+	reqPartition.Partition = r.Partition
+	reqPartition.FetchOffset = r.Offset
+	reqPartition.PartitionMaxBytes = cl.cfg.maxPartBytes
+	reqTopic.Partitions = append(reqTopic.Partitions, reqPartition)
+	req.Topics = append(req.Topics, reqTopic)
+
+	kresp, err := cl.Broker(int(tp.leader)).Request(ctx, req)
+	if err != nil {
+		return FetchPartition{}, err
+	}
+	resp := kresp.(*kmsg.FetchResponse)
+	if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return FetchPartition{}, err
+	}
+	for i := range resp.Topics {
+		rt := &resp.Topics[i]
+		if rt.Topic != r.Topic {
+			continue
+		}
+		for j := range rt.Partitions {
+			rp := &rt.Partitions[j]
+			if rp.Partition != r.Partition {
+				continue
+			}
+			o := &cursorOffsetNext{
+				cursorOffset: cursorOffset{offset: r.Offset},
+				from:         &cursor{topic: r.Topic, partition: r.Partition},
+			}
+			return o.processRespPartition(resp.Version, rp, cl.decompressor), nil
+		}
+	}
+	return FetchPartition{}, errors.New("canary fetch response did not contain the canary partition")
+}
@@ -0,0 +1,159 @@
+package kgo
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// canaryCluster returns a fake cluster that writes produced batches at
+// offset 5, and answers fetches with whatever fetch returns given the last
+// produced batch.
+func canaryCluster(t *testing.T, fetch func(batch []byte) (records []byte, hwm int64)) *fakecluster.Cluster {
+	const baseOffset = 5
+
+	c := fakecluster.New(t, map[string]int32{"t": 1})
+
+	var (
+		mu       sync.Mutex
+		produced []byte
+	)
+	c.Control(0, func(req kmsg.Request) (kmsg.Response, error) {
+		resp := req.ResponseKind().(*kmsg.ProduceResponse)
+		for _, rt := range req.(*kmsg.ProduceRequest).Topics {
+			st := kmsg.NewProduceResponseTopic()
+			st.Topic = rt.Topic
+			for _, rp := range rt.Partitions {
+				mu.Lock()
+				produced = append([]byte(nil), rp.Records...)
+				binary.BigEndian.PutUint64(produced, baseOffset)
+				mu.Unlock()
+
+				sp := kmsg.NewProduceResponseTopicPartition()
+				sp.Partition = rp.Partition
+				sp.BaseOffset = baseOffset
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil
+	})
+	c.Control(1, func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		batch := produced
+		mu.Unlock()
+
+		resp := req.ResponseKind().(*kmsg.FetchResponse)
+		for _, rt := range req.(*kmsg.FetchRequest).Topics {
+			st := kmsg.NewFetchResponseTopic()
+			st.Topic = rt.Topic
+			for _, rp := range rt.Partitions {
+				if rp.FetchOffset != baseOffset {
+					t.Errorf("canary fetched offset %d != exp %d", rp.FetchOffset, baseOffset)
+				}
+				sp := kmsg.NewFetchResponseTopicPartition()
+				sp.Partition = rp.Partition
+				sp.RecordBatches, sp.HighWatermark = fetch(batch)
+				sp.LastStableOffset = sp.HighWatermark
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		time.Sleep(5 * time.Millisecond) // avoid spinning on empty fetches
+		return resp, nil
+	})
+	return c
+}
+
+func TestCanary(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name   string
+		fetch  func(batch []byte) ([]byte, int64)
+		expErr string // empty for success, "ctx" for a context error
+	}{
+		{
+			name:  "success",
+			fetch: func(batch []byte) ([]byte, int64) { return batch, 6 },
+		},
+		{
+			name: "corrupt",
+			fetch: func(batch []byte) ([]byte, int64) {
+				// The value is the first copy of the tag;
+				// we flip a byte and fix the crc.
+				batch = append([]byte(nil), batch...)
+				tag := batch[len(batch)-16:]
+				at := bytes.Index(batch[21:], tag) + 21
+				batch[at] ^= 0xff
+				binary.BigEndian.PutUint32(batch[17:], crc32.Checksum(batch[21:], crc32.MakeTable(crc32.Castagnoli)))
+				return batch, 6
+			},
+			expErr: "was corrupt",
+		},
+		{
+			name:   "compacted",
+			fetch:  func([]byte) ([]byte, int64) { return nil, 10 },
+			expErr: "no longer in the partition",
+		},
+		{
+			name:   "not yet replicated",
+			fetch:  func([]byte) ([]byte, int64) { return nil, 5 },
+			expErr: "ctx",
+		},
+	} {
+		c := canaryCluster(t, test.fetch)
+		defer c.Close()
+
+		cl, err := NewClient(
+			SeedBrokers(c.Addr()),
+			BatchCompression(NoCompression()),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cl.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		rtt, err := cl.Canary(ctx, "t")
+		switch {
+		case test.expErr == "":
+			if err != nil || rtt <= 0 {
+				t.Errorf("%s: got rtt %v err %v, want positive rtt and no err", test.name, rtt, err)
+			}
+		case test.expErr == "ctx":
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("%s: got err %v, want %v", test.name, err, context.DeadlineExceeded)
+			}
+		default:
+			if err == nil || !strings.Contains(err.Error(), test.expErr) {
+				t.Errorf("%s: got err %v, want one containing %q", test.name, err, test.expErr)
+			}
+		}
+	}
+}
+
+func TestCanaryNoAcks(t *testing.T) {
+	t.Parallel()
+
+	cl, err := NewClient(RequiredAcks(NoAck()), DisableIdempotentWrite())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if _, err := cl.Canary(context.Background(), "t"); err == nil {
+		t.Error("expected error running a canary with no acks")
+	}
+}
@@ -0,0 +1,51 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBrokerCircuitBreaker(t *testing.T) {
+	clock := newFakeClock()
+	cl, err := NewClient(
+		SeedBrokers("127.0.0.1:1"), // nothing listens; dials are refused
+		BrokerCircuitBreaker(2, time.Minute),
+		withClock(clock),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	b := cl.brokers[unknownSeedID(0)]
+	ctx := context.Background()
+	isOpen := func(err error) bool {
+		var open *ErrBrokerCircuitOpen
+		return errors.As(err, &open)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := b.loadConnection(ctx, 3); err == nil || isOpen(err) {
+			t.Fatalf("dial %d: expected dial err, got %v", i, err)
+		}
+	}
+	if _, err := b.loadConnection(ctx, 3); !isOpen(err) {
+		t.Fatalf("expected open circuit err after max failures, got %v", err)
+	}
+
+	// Once the breaker allows a probe, the failed probe reopens it.
+	clock.advance(time.Minute)
+	if _, err := b.loadConnection(ctx, 3); err == nil || isOpen(err) {
+		t.Fatalf("expected probe dial err, got %v", err)
+	}
+	if _, err := b.loadConnection(ctx, 3); !isOpen(err) {
+		t.Fatalf("expected open circuit err after failed probe, got %v", err)
+	}
+
+	b.circuitReset()
+	if b.isCircuitOpen() {
+		t.Error("circuit still open after reset")
+	}
+}
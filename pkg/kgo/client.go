@@ -28,6 +28,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/twmb/franz-go/pkg/kerr"
@@ -62,6 +63,12 @@ type Client struct {
 
 	reqFormatter  *kmsg.RequestFormatter
 	connTimeoutFn func(kmsg.Request) (time.Duration, time.Duration)
+	dnsCache      *dnsCache     // non-nil if DNSCacheTTL is positive
+	readBudget    *readBudget   // non-nil if MaxConcurrentReadBytes is positive
+	recentErrs    *recentErrors // non-nil if RecentErrorsLimit is positive
+	respBufs      *respBufPool  // non-nil if PooledResponseBuffers is set
+
+	dialedTLS uint32 // atomic; 1 once any dialed connection is TLS, for Opts
 
 	bufPool bufPool // for to brokers to share underlying reusable request buffers
 
@@ -84,10 +91,13 @@ type Client struct {
 	decompressor *decompressor
 
 	coordinatorsMu sync.Mutex
-	coordinators   map[coordinatorKey]int32
+	coordinators   map[coordinatorKey]cachedCoordinator
 
 	updateMetadataCh     chan struct{}
 	updateMetadataNowCh  chan struct{} // like above, but with high priority
+	updateMetaTopicsCh   chan struct{} // like updateMetadataCh, but only for metaTopics
+	metaTopicsMu         sync.Mutex
+	metaTopics           map[string]struct{}
 	blockingMetadataFnCh chan func()
 	metawait             metawait
 	metadone             chan struct{}
@@ -121,6 +131,7 @@ func NewClient(opts ...Opt) (*Client, error) {
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
+	cfg.wrapSOCKS5()
 
 	type hostport struct {
 		host string
@@ -160,23 +171,40 @@ func NewClient(opts ...Opt) (*Client, error) {
 		sinksAndSources: make(map[int32]sinkAndSource),
 
 		reqFormatter:  new(kmsg.RequestFormatter),
-		connTimeoutFn: connTimeoutBuilder(cfg.connTimeoutOverhead),
+		connTimeoutFn: connTimeoutBuilder(cfg.keyTimeoutOverhead),
 
 		bufPool: newBufPool(),
 
 		decompressor: newDecompressor(),
 
-		coordinators: make(map[coordinatorKey]int32),
+		coordinators: make(map[coordinatorKey]cachedCoordinator),
 
 		updateMetadataCh:     make(chan struct{}, 1),
 		updateMetadataNowCh:  make(chan struct{}, 1),
+		updateMetaTopicsCh:   make(chan struct{}, 1),
 		blockingMetadataFnCh: make(chan func()),
 		metadone:             make(chan struct{}),
 	}
 	cl.producer.init()
+	if cfg.produceRateLimit > 0 {
+		cl.producer.rateLimiter = newByteRateLimiter(cfg.produceRateLimit)
+	}
 	cl.consumer.init(cl)
 	cl.metawait.init()
 
+	if cfg.dnsCacheTTL > 0 {
+		cl.dnsCache = newDNSCache(cfg.dnsCacheTTL, cfg.clock)
+	}
+	if cfg.maxConcurrentReadBytes > 0 {
+		cl.readBudget = newReadBudget(cfg.maxConcurrentReadBytes)
+	}
+	if cfg.recentErrorsLimit > 0 {
+		cl.recentErrs = newRecentErrors(cfg.recentErrorsLimit)
+	}
+	if cfg.pooledRespBufs {
+		cl.respBufs = newRespBufPool()
+	}
+
 	if cfg.id != nil {
 		cl.reqFormatter = kmsg.NewRequestFormatter(kmsg.FormatterClientID(*cfg.id))
 	}
@@ -193,15 +221,62 @@ func NewClient(opts ...Opt) (*Client, error) {
 	}
 	go cl.updateMetadataLoop()
 	go cl.reapConnectionsLoop()
+	if cfg.stuckThreshold > 0 {
+		go cl.stuckRequestsLoop()
+	}
+
+	if cfg.metadataEager {
+		if err := cl.loadMetadataEagerly(); err != nil {
+			cl.Close()
+			return nil, err
+		}
+	}
 
 	return cl, nil
 }
 
-func connTimeoutBuilder(def time.Duration) func(kmsg.Request) (time.Duration, time.Duration) {
+// loadMetadataEagerly runs one metadata update in the metadata loop and waits
+// for it, returning an error if the update fails or does not finish in time;
+// see MetadataEagerLoad.
+func (cl *Client) loadMetadataEagerly() error {
+	timeout := cl.cfg.keyTimeoutOverhead(3) + cl.cfg.dialTimeout
+
+	var (
+		done       = make(chan struct{})
+		needsRetry bool
+		err        error
+	)
+	go cl.blockingMetadataFn(func() {
+		defer close(done)
+		needsRetry, err = cl.updateMetadata(MetadataRefreshUrgent)
+	})
+
+	timer := cl.cfg.clock.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C():
+		return fmt.Errorf("unable to load initial metadata within %v", timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to load initial metadata: %w", err)
+	}
+	if needsRetry {
+		cl.triggerUpdateMetadata(true)
+	}
+	return nil
+}
+
+// connTimeoutBuilder returns a function that returns the read and write
+// timeouts for a request, using keyOverhead to get the timeout overhead for
+// the request's key.
+func connTimeoutBuilder(keyOverhead func(int16) time.Duration) func(kmsg.Request) (time.Duration, time.Duration) {
 	var joinMu sync.Mutex
 	var lastRebalanceTimeout time.Duration
 
 	return func(req kmsg.Request) (read, write time.Duration) {
+		def := keyOverhead(req.Key())
+
 		millis := func(m int32) time.Duration { return time.Duration(m) * time.Millisecond }
 		switch t := req.(type) {
 		default:
@@ -265,6 +340,35 @@ func (cl *Client) broker() *broker {
 	cl.brokersMu.Lock() // full lock needed for anyBrokerIdx below
 	defer cl.brokersMu.Unlock()
 
+	// We avoid draining brokers and brokers with an open circuit breaker.
+	// If every broker we could choose is avoided, we fall back to any
+	// seed that is not avoided (seeds are separate from discovered
+	// brokers, so this allows metadata to be refreshed to learn that a
+	// broker is back), and otherwise use the last broker chosen.
+	avoided := func(b *broker) bool { return b.isDraining() || b.isCircuitOpen() }
+	var b *broker
+	for range cl.brokers {
+		if b = cl.anyBrokerLocked(); !avoided(b) {
+			return b
+		}
+	}
+	if b == nil {
+		return cl.anyBrokerLocked()
+	}
+	for i := 0; ; i++ {
+		seed, exists := cl.brokers[unknownSeedID(i)]
+		if !exists {
+			return b
+		}
+		if !avoided(seed) {
+			return seed
+		}
+	}
+}
+
+// anyBrokerLocked returns the next broker to use for requests that can go to
+// any broker, called with brokersMu held.
+func (cl *Client) anyBrokerLocked() *broker {
 	b, exists := cl.brokers[cl.anyBrokerIdx]
 	if !exists && cl.anyBrokerIdx != 0 {
 		cl.anyBrokerIdx = 0
@@ -285,15 +389,37 @@ func (cl *Client) broker() *broker {
 	return b
 }
 
+// anyBrokerReachable returns whether any broker may be reachable, which is
+// false only if the last dial to every broker failed. If brokers have been
+// discovered, seed brokers are not considered.
+func (cl *Client) anyBrokerReachable() bool {
+	cl.brokersMu.RLock()
+	defer cl.brokersMu.RUnlock()
+
+	var discovered, seedReachable bool
+	for id, b := range cl.brokers {
+		reachable := atomic.LoadInt32(&b.unreachable) == 0
+		if id >= 0 {
+			discovered = true
+			if reachable {
+				return true
+			}
+		} else if reachable {
+			seedReachable = true
+		}
+	}
+	return !discovered && seedReachable
+}
+
 func (cl *Client) waitTries(ctx context.Context, tries int) bool {
-	after := time.NewTimer(cl.cfg.retryBackoff(tries))
+	after := cl.cfg.clock.NewTimer(cl.cfg.retryBackoff(tries))
 	defer after.Stop()
 	select {
 	case <-ctx.Done():
 		return false
 	case <-cl.ctx.Done():
 		return false
-	case <-after.C:
+	case <-after.C():
 		return true
 	}
 }
@@ -325,7 +451,7 @@ func (cl *Client) fetchBrokerMetadata(ctx context.Context) error {
 	return wait.err
 }
 
-func (cl *Client) fetchMetadataForTopics(ctx context.Context, all bool, topics []string) (*broker, *kmsg.MetadataResponse, error) {
+func (cl *Client) fetchMetadataForTopics(ctx context.Context, all bool, topics []string) (*retriable, *kmsg.MetadataResponse, error) {
 	req := &kmsg.MetadataRequest{
 		AllowAutoTopicCreation: cl.cfg.allowAutoTopicCreation,
 	}
@@ -342,7 +468,7 @@ func (cl *Client) fetchMetadataForTopics(ctx context.Context, all bool, topics [
 	return cl.fetchMetadata(ctx, req)
 }
 
-func (cl *Client) fetchMetadata(ctx context.Context, req *kmsg.MetadataRequest) (*broker, *kmsg.MetadataResponse, error) {
+func (cl *Client) fetchMetadata(ctx context.Context, req *kmsg.MetadataRequest) (*retriable, *kmsg.MetadataResponse, error) {
 	r := cl.retriable()
 	meta, err := req.RequestWith(ctx, r)
 	if err == nil {
@@ -352,8 +478,9 @@ func (cl *Client) fetchMetadata(ctx context.Context, req *kmsg.MetadataRequest)
 			cl.controllerIDMu.Unlock()
 		}
 		cl.updateBrokers(meta.Brokers)
+		cl.undrainLeaders(meta)
 	}
-	return r.last, meta, err
+	return r, meta, err
 }
 
 // updateBrokers is called with the broker portion of every metadata response.
@@ -396,6 +523,27 @@ func (cl *Client) updateBrokers(brokers []kmsg.MetadataResponseBroker) {
 	cl.brokers = newBrokers
 }
 
+// undrainLeaders clears the draining state of any broker that metadata shows
+// leading a partition. A broker that is shutting down has its leadership
+// moved to other brokers, so leading again means the broker is back; brokers
+// that are gone from metadata are removed in updateBrokers.
+func (cl *Client) undrainLeaders(meta *kmsg.MetadataResponse) {
+	leaders := make(map[int32]bool)
+	for _, t := range meta.Topics {
+		for _, p := range t.Partitions {
+			leaders[p.Leader] = true
+		}
+	}
+
+	cl.brokersMu.RLock()
+	defer cl.brokersMu.RUnlock()
+	for id, b := range cl.brokers {
+		if leaders[id] {
+			b.undrain()
+		}
+	}
+}
+
 // Close leaves any group and closes all connections and goroutines.
 func (cl *Client) Close() {
 	// First, kill the consumer. This waits for the consumer to unset
@@ -470,6 +618,11 @@ func (cl *Client) Close() {
 // In short, this method tries to do the correct thing depending on what type
 // of request is being issued.
 //
+// This method does not return how many times a request was internally retried,
+// nor which broker the final attempt went to. If you need that information,
+// use RequestSharded and inspect the Meta and Attempts fields of the returned
+// shards; for requests that are not split, exactly one shard is returned.
+//
 // The passed context can be used to cancel a request and return early. Note
 // that if the request was written to Kafka but the context canceled before a
 // response is received, Kafka may still operate on the received request.
@@ -491,6 +644,35 @@ func (cl *Client) Request(ctx context.Context, req kmsg.Request) (kmsg.Response,
 	return merge(resps)
 }
 
+type requestTimeoutKey struct{}
+
+// RequestWithTimeout issues a request as Request does, but uses timeout as the
+// read and write timeout for every broker request issued, overriding the
+// timeouts that would otherwise be derived from the client configuration and
+// the request's own TimeoutMillis field.
+//
+// Unlike a context deadline, which cancels the request entirely, the timeout
+// is applied as the connection's read and write deadlines: a request that
+// times out fails the same way a request hitting the configured timeouts
+// does, and may be retried per the client's retry configuration.
+//
+// This is useful for one-off admin requests that need a longer (or shorter)
+// timeout than the client is configured with.
+func (cl *Client) RequestWithTimeout(ctx context.Context, timeout time.Duration, req kmsg.Request) (kmsg.Response, error) {
+	return cl.Request(context.WithValue(ctx, requestTimeoutKey{}, timeout), req)
+}
+
+// connTimeouts returns the read and write timeouts for a request, using any
+// timeout override carried in ctx before the client's connTimeoutFn.
+func (cl *Client) connTimeouts(ctx context.Context, req kmsg.Request) (read, write time.Duration) {
+	if ctx != nil {
+		if timeout, ok := ctx.Value(requestTimeoutKey{}).(time.Duration); ok && timeout > 0 {
+			return timeout, timeout
+		}
+	}
+	return cl.connTimeoutFn(req)
+}
+
 func (cl *Client) retriable() *retriable {
 	return cl.retriableBrokerFn(func() (*broker, error) { return cl.broker(), nil })
 }
@@ -513,6 +695,10 @@ type retriable struct {
 	br   func() (*broker, error)
 	last *broker
 
+	// tries is the number of times the request was attempted, which is
+	// returned to users in ResponseShard.Attempts.
+	tries int
+
 	// parseRetryErr, if non-nil, can parse a retriable error out of the
 	// response and return it. This error is *not* returned from the
 	// request if the req cannot be retried due to timeout or retry limits,
@@ -526,6 +712,7 @@ func (r *retriable) Request(ctx context.Context, req kmsg.Request) (kmsg.Respons
 	retryTimeout := r.cl.cfg.retryTimeout(req.Key())
 start:
 	tries++
+	r.tries = tries
 	br, err := r.br()
 	r.last = br
 	if err != nil {
@@ -533,12 +720,26 @@ start:
 	}
 	resp, err := r.last.waitResp(ctx, req)
 	var retryErr error
+	var forceRetry bool
 	if err == nil && r.parseRetryErr != nil {
 		retryErr = r.parseRetryErr(resp)
 	}
+	if err == nil && r.cl.cfg.errorPolicy != nil {
+		code := responseErrorCode(resp)
+		switch r.cl.cfg.errorAction(req.Key(), code) {
+		case ErrorRefreshMetadata():
+			r.cl.triggerUpdateMetadataNow()
+			fallthrough
+		case ErrorRetry():
+			retryErr = kerr.ErrorForCode(code)
+			forceRetry = int64(tries) < r.cl.cfg.retries
+		case ErrorFail():
+			retryErr = nil
+		}
+	}
 	if err != nil || retryErr != nil {
 		if retryTimeout == 0 || time.Since(tryStart) <= retryTimeout {
-			if (r.cl.shouldRetry(tries, err) || r.cl.shouldRetry(tries, retryErr)) && r.cl.waitTries(ctx, tries) {
+			if (forceRetry || r.cl.shouldRetry(tries, err) || r.cl.shouldRetry(tries, retryErr)) && r.cl.waitTries(ctx, tries) {
 				goto start
 			}
 		}
@@ -546,6 +747,21 @@ start:
 	return resp, err
 }
 
+// responseErrorCode returns the top level ErrorCode field of a response, or
+// zero if the response has no such field.
+func responseErrorCode(resp kmsg.Response) int16 {
+	v := reflect.Indirect(reflect.ValueOf(resp))
+	if v.Kind() != reflect.Struct {
+		return 0
+	}
+	v = v.FieldByName("ErrorCode")
+	if !v.IsValid() {
+		return 0
+	}
+	code, _ := v.Interface().(int16)
+	return code
+}
+
 // ResponseShard ties together a request with either the response it received
 // or an error that prevented a response from being received.
 type ResponseShard struct {
@@ -565,6 +781,13 @@ type ResponseShard struct {
 	// Err, if non-nil, is the error that prevented a response from being
 	// received or the request from being issued.
 	Err error
+
+	// Attempts is the number of times this request was tried before the
+	// response or error was returned. This is more than one if the client
+	// internally retried the request, in which case Meta is the broker
+	// that the final attempt was issued to. This is zero if the request
+	// could not be issued at all.
+	Attempts int
 }
 
 // RequestSharded performs the same logic as Request, but returns all responses
@@ -583,7 +806,10 @@ type ResponseShard struct {
 // request to the first seed broker. This will show up as a seed broker node ID
 // (min int32) and the response will likely contain purely errors.
 //
-// The response shards are ordered by broker metadata.
+// The response shards are ordered by broker metadata. Each shard's Resp, if
+// non-nil, is of the same type as req.ResponseKind() and can be type asserted
+// to that type; each shard's Req is the piece of the original request that
+// was issued to that shard's broker.
 func (cl *Client) RequestSharded(ctx context.Context, req kmsg.Request) []ResponseShard {
 	resps, _ := cl.shardedRequest(ctx, req)
 	sort.Slice(resps, func(i, j int) bool {
@@ -658,8 +884,8 @@ func (cl *Client) shardedRequest(ctx context.Context, req kmsg.Request) ([]Respo
 	if metaReq, isMetaReq := req.(*kmsg.MetadataRequest); isMetaReq {
 		// We hijack any metadata request so as to populate our
 		// own brokers and controller ID.
-		br, resp, err := cl.fetchMetadata(ctx, metaReq)
-		return shards(shard(br, req, resp, err)), nil
+		r, resp, err := cl.fetchMetadata(ctx, metaReq)
+		return shards(shard(r.last, r.tries, req, resp, err)), nil
 
 	} else if adminReq, admin := req.(kmsg.AdminRequest); admin {
 		return shards(cl.handleAdminReq(ctx, adminReq)), nil
@@ -685,14 +911,14 @@ func (cl *Client) shardedRequest(ctx context.Context, req kmsg.Request) ([]Respo
 	// with the default retriable logic.
 	r := cl.retriable()
 	resp, err := r.Request(ctx, req)
-	return shards(shard(r.last, req, resp, err)), nil
+	return shards(shard(r.last, r.tries, req, resp, err)), nil
 }
 
-func shard(br *broker, req kmsg.Request, resp kmsg.Response, err error) ResponseShard {
+func shard(br *broker, tries int, req kmsg.Request, resp kmsg.Response, err error) ResponseShard {
 	if br == nil { // the broker could be nil if loading the broker failed.
-		return ResponseShard{unknownMetadata, req, resp, err}
+		return ResponseShard{unknownMetadata, req, resp, err, tries}
 	}
-	return ResponseShard{br.meta, req, resp, err}
+	return ResponseShard{br.meta, req, resp, err, tries}
 }
 
 func shards(shard ...ResponseShard) []ResponseShard {
@@ -773,34 +999,93 @@ type coordinatorKey struct {
 	typ  int8
 }
 
+type cachedCoordinator struct {
+	node   int32
+	loaded time.Time
+}
+
 // loadController returns the group/txn coordinator for the given key, retrying
 // as necessary. If reload is true, this does not used a cache coordinator.
+//
+// A cached coordinator older than the CoordinatorCacheTTL is reloaded. If
+// FindCoordinator returns a retriable error (the coordinator is not yet
+// available or is loading), the find is retried with the client's retry
+// backoff, up to the client's request retries.
 func (cl *Client) loadCoordinator(reload bool, ctx context.Context, key coordinatorKey) (*broker, error) {
 	cl.coordinatorsMu.Lock()
-	coordinator, ok := cl.coordinators[key]
+	cached, ok := cl.coordinators[key]
 	cl.coordinatorsMu.Unlock()
 
+	if ok && cl.cfg.coordinatorTTL > 0 && cl.cfg.clock.Now().Sub(cached.loaded) > cl.cfg.coordinatorTTL {
+		ok = false
+	}
 	if !reload && ok {
-		return cl.brokerOrErr(nil, coordinator, &errUnknownCoordinator{coordinator, key})
+		return cl.brokerOrErr(nil, cached.node, &errUnknownCoordinator{cached.node, key})
 	}
 
-	resp, err := (&kmsg.FindCoordinatorRequest{
-		CoordinatorKey:  key.name,
-		CoordinatorType: key.typ,
-	}).RequestWith(ctx, cl.retriable())
-
-	if err != nil {
-		return nil, err
+	var coordinator int32
+	for tries := 0; ; tries++ {
+		resp, err := (&kmsg.FindCoordinatorRequest{
+			CoordinatorKey:  key.name,
+			CoordinatorType: key.typ,
+		}).RequestWith(ctx, cl.retriable())
+		if err != nil {
+			return nil, err
+		}
+		if err = kerr.ErrorForCode(resp.ErrorCode); err != nil {
+			if cl.shouldRetry(tries, err) && cl.waitTries(ctx, tries) {
+				continue
+			}
+			return nil, err
+		}
+		coordinator = resp.NodeID
+		break
 	}
 
-	coordinator = resp.NodeID
 	cl.coordinatorsMu.Lock()
-	cl.coordinators[key] = coordinator
+	cl.coordinators[key] = cachedCoordinator{
+		node:   coordinator,
+		loaded: cl.cfg.clock.Now(),
+	}
 	cl.coordinatorsMu.Unlock()
 
 	return cl.brokerOrErr(ctx, coordinator, &errUnknownCoordinator{coordinator, key})
 }
 
+// GroupCoordinator returns the broker that is the coordinator for the given
+// group, using the client's cached coordinator if present and issuing a
+// FindCoordinator request otherwise.
+//
+// This is useful for tooling that inspects where groups are placed, for
+// example to detect coordinators being unevenly spread across brokers.
+func (cl *Client) GroupCoordinator(ctx context.Context, group string) (BrokerMetadata, error) {
+	b, err := cl.loadCoordinator(false, ctx, coordinatorKey{
+		name: group,
+		typ:  coordinatorTypeGroup,
+	})
+	if err != nil {
+		return BrokerMetadata{}, err
+	}
+	return b.meta, nil
+}
+
+// InvalidateCoordinatorCache drops the cached coordinator for the given
+// group, causing the next request for the group to look up the coordinator
+// again.
+//
+// The client already invalidates its cache when a coordinator responds with
+// NOT_COORDINATOR or COORDINATOR_NOT_AVAILABLE. This function is for when you
+// know out of band that a coordinator moved, for example after a broker was
+// decommissioned, and want to avoid the request that would discover it.
+func (cl *Client) InvalidateCoordinatorCache(group string) {
+	cl.coordinatorsMu.Lock()
+	defer cl.coordinatorsMu.Unlock()
+	delete(cl.coordinators, coordinatorKey{
+		name: group,
+		typ:  coordinatorTypeGroup,
+	})
+}
+
 func (cl *Client) maybeDeleteStaleCoordinator(name string, typ int8, err error) bool {
 	switch err {
 	case kerr.CoordinatorNotAvailable,
@@ -916,7 +1201,7 @@ func (cl *Client) handleAdminReq(ctx context.Context, req kmsg.Request) Response
 	}
 
 	resp, err := r.Request(ctx, req)
-	return shard(r.last, req, resp, err)
+	return shard(r.last, r.tries, req, resp, err)
 }
 
 // handleCoordinatorReq issues simple (non-shardable) group or txn requests.
@@ -925,7 +1210,7 @@ func (cl *Client) handleCoordinatorReq(ctx context.Context, req kmsg.Request, ty
 	default:
 		// All group requests should be listed below, so if it isn't,
 		// then we do not know what this request is.
-		return shard(nil, req, nil, errors.New("client is too old; this client does not know what to do with this request"))
+		return shard(nil, 0, req, nil, errors.New("client is too old; this client does not know what to do with this request"))
 
 	/////////
 	// TXN // -- all txn reqs are simple
@@ -940,8 +1225,8 @@ func (cl *Client) handleCoordinatorReq(ctx context.Context, req kmsg.Request, ty
 		// retriable-error parsing, even though we are not actually
 		// using a defined txn coordinator. This is fine; by passing no
 		// names, we delete no coordinator.
-		coordinator, resp, err := cl.handleReqWithCoordinator(ctx, func() (*broker, error) { return cl.broker(), nil }, coordinatorTypeTxn, "", req)
-		return shard(coordinator, req, resp, err)
+		r, resp, err := cl.handleReqWithCoordinator(ctx, func() (*broker, error) { return cl.broker(), nil }, coordinatorTypeTxn, "", req)
+		return shard(r.last, r.tries, req, resp, err)
 	case *kmsg.AddPartitionsToTxnRequest:
 		return cl.handleCoordinatorReqSimple(ctx, coordinatorTypeTxn, t.TransactionalID, req)
 	case *kmsg.AddOffsetsToTxnRequest:
@@ -978,13 +1263,13 @@ func (cl *Client) handleCoordinatorReq(ctx context.Context, req kmsg.Request, ty
 // The error is inspected to see if it is a retriable error and, if so, the
 // coordinator is deleted.
 func (cl *Client) handleCoordinatorReqSimple(ctx context.Context, typ int8, name string, req kmsg.Request) ResponseShard {
-	coordinator, resp, err := cl.handleReqWithCoordinator(ctx, func() (*broker, error) {
+	r, resp, err := cl.handleReqWithCoordinator(ctx, func() (*broker, error) {
 		return cl.loadCoordinator(false, ctx, coordinatorKey{
 			name: name,
 			typ:  typ,
 		})
 	}, typ, name, req)
-	return shard(coordinator, req, resp, err)
+	return shard(r.last, r.tries, req, resp, err)
 }
 
 // handleReqWithCoordinator actually issues a request to a coordinator and
@@ -997,7 +1282,7 @@ func (cl *Client) handleReqWithCoordinator(
 	typ int8,
 	name string, // group ID or the transactional id
 	req kmsg.Request,
-) (*broker, kmsg.Response, error) {
+) (*retriable, kmsg.Response, error) {
 
 	r := cl.retriableBrokerFn(coordinator)
 	r.parseRetryErr = func(resp kmsg.Response) error {
@@ -1050,7 +1335,7 @@ func (cl *Client) handleReqWithCoordinator(
 	}
 
 	resp, err := r.Request(ctx, req)
-	return r.last, resp, err
+	return r, resp, err
 }
 
 // Broker returns a handle to a specific broker to directly issue requests to.
@@ -1083,6 +1368,195 @@ func (cl *Client) DiscoveredBrokers() []*Broker {
 	return bs
 }
 
+// EffectiveVersions returns, per request key, the highest version the client
+// will use with every broker it currently has a connection to: the minimum of
+// each broker's max version and the client's own max version (which accounts
+// for MaxVersions). A key is omitted if any connected broker does not support
+// it, or if the client itself does not support it.
+//
+// Only brokers the client has connected to are considered, since versions are
+// learned from ApiVersions when connecting. If the client has not connected
+// to any broker, this returns nil. This is meant for diagnostics, such as
+// checking which features are available across a mixed-version cluster.
+func (cl *Client) EffectiveVersions() map[int16]int16 {
+	cl.brokersMu.RLock()
+	brokers := make([]*broker, 0, len(cl.brokers))
+	for _, broker := range cl.brokers {
+		brokers = append(brokers, broker)
+	}
+	cl.brokersMu.RUnlock()
+
+	var (
+		mins   [kmsg.MaxKey + 1]int16
+		loaded bool
+	)
+	for _, broker := range brokers {
+		versions, ok := broker.loadedVersions()
+		if !ok {
+			continue
+		}
+		if !loaded {
+			mins, loaded = versions, true
+			continue
+		}
+		for key, v := range versions {
+			if v < mins[key] {
+				mins[key] = v
+			}
+		}
+	}
+	if !loaded {
+		return nil
+	}
+
+	effective := make(map[int16]int16)
+	for i, brokerMax := range mins {
+		key := int16(i)
+		req := kmsg.RequestForKey(key)
+		if req == nil || brokerMax < 0 {
+			continue
+		}
+		ourMax := req.MaxVersion()
+		if cl.cfg.maxVersions != nil {
+			userMax, exists := cl.cfg.maxVersions.LookupMaxKeyVersion(key)
+			if !exists {
+				continue
+			}
+			if userMax < ourMax {
+				ourMax = userMax
+			}
+		}
+		if brokerMax < ourMax {
+			ourMax = brokerMax
+		}
+		effective[key] = ourMax
+	}
+	return effective
+}
+
+// TrackedTopics returns a sorted snapshot of the topics the client is tracking
+// metadata for: topics that have been produced to, and topics that are being
+// consumed, including topics resolved from regex consuming. Topics that have
+// not yet loaded metadata (for example, a topic that does not exist being
+// produced to) are included.
+//
+// This is meant for diagnostics, such as verifying that a regex subscription
+// resolved to the expected topics.
+func (cl *Client) TrackedTopics() []string {
+	var tpsConsumer *topicsPartitions
+	switch v := cl.consumer.loadKind().(type) {
+	case *groupConsumer:
+		tpsConsumer = v.tps
+	case *directConsumer:
+		tpsConsumer = v.tps
+	}
+
+	set := make(map[string]struct{})
+	for _, m := range []topicsPartitionsData{
+		cl.producer.topics.load(),
+		tpsConsumer.load(),
+	} {
+		for topic := range m {
+			set[topic] = struct{}{}
+		}
+	}
+	topics := make([]string, 0, len(set))
+	for topic := range set {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// WarmConnections opens connections to all brokers in the cluster, returning
+// once all connections are open or the context is canceled. Connections are
+// otherwise opened lazily when the first request for a broker is issued.
+//
+// If prioritize is true, connections to the controller and to the
+// coordinators for the client's group and transactional ID are opened first,
+// and all other connections are opened once those are ready. These brokers
+// are on the critical path for control plane operations (joining a group,
+// beginning a transaction, and admin requests), so prioritizing them gets
+// those operations going soonest in large clusters. If prioritize is false,
+// all connections are opened at once.
+//
+// This only warms the connection used for non-produce and non-fetch
+// requests; produce and fetch connections are still opened on first use.
+// The first error encountered is returned, but all connections are still
+// attempted.
+func (cl *Client) WarmConnections(ctx context.Context, prioritize bool) error {
+	var (
+		mu       sync.Mutex
+		firstErr error
+		warmed   = make(map[int32]bool)
+	)
+	setErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	warm := func(brokers []*broker) {
+		var wg sync.WaitGroup
+		for _, b := range brokers {
+			mu.Lock()
+			skip := warmed[b.meta.NodeID]
+			warmed[b.meta.NodeID] = true
+			mu.Unlock()
+			if skip {
+				continue
+			}
+			wg.Add(1)
+			go func(b *broker) {
+				defer wg.Done()
+				if _, err := b.waitResp(ctx, kmsg.NewPtrApiVersionsRequest()); err != nil {
+					setErr(err)
+				}
+			}(b)
+		}
+		wg.Wait()
+	}
+
+	if prioritize {
+		var first []*broker
+		if b, err := cl.controller(ctx); err != nil {
+			setErr(err)
+		} else {
+			first = append(first, b)
+		}
+		var keys []coordinatorKey
+		if g, ok := cl.consumer.loadGroup(); ok {
+			keys = append(keys, coordinatorKey{g.id, coordinatorTypeGroup})
+		}
+		if cl.cfg.txnID != nil {
+			keys = append(keys, coordinatorKey{*cl.cfg.txnID, coordinatorTypeTxn})
+		}
+		for _, key := range keys {
+			if b, err := cl.loadCoordinator(false, ctx, key); err != nil {
+				setErr(err)
+			} else {
+				first = append(first, b)
+			}
+		}
+		warm(first)
+	} else if err := cl.fetchBrokerMetadata(ctx); err != nil {
+		return err
+	}
+
+	cl.brokersMu.RLock()
+	rest := make([]*broker, 0, len(cl.brokers))
+	for id, b := range cl.brokers {
+		if id >= 0 { // skip seeds, which are the same brokers
+			rest = append(rest, b)
+		}
+	}
+	cl.brokersMu.RUnlock()
+	warm(rest)
+
+	return firstErr
+}
+
 // SeedBrokers returns the all seed brokers.
 func (cl *Client) SeedBrokers() []*Broker {
 	cl.brokersMu.RLock()
@@ -1098,6 +1572,98 @@ func (cl *Client) SeedBrokers() []*Broker {
 	}
 }
 
+// ClusterInfo contains the brokers, controller, and cluster ID of a cluster, as
+// returned from DiscoverBrokers.
+type ClusterInfo struct {
+	// Brokers are the brokers in the cluster, sorted by node ID.
+	Brokers []BrokerMetadata
+
+	// Controller is the node ID of the controller broker, or -1 if the
+	// controller is unknown or the broker is too old to say.
+	Controller int32
+
+	// ClusterID is the ID of the cluster, if the broker supports cluster
+	// IDs (Kafka 0.10.1+).
+	ClusterID *string
+}
+
+// DiscoverBrokers describes the cluster through a seed broker, trying each
+// seed in order until one succeeds, and returns the cluster brokers,
+// controller, and cluster ID.
+//
+// If the seed supports DescribeCluster (Kafka 2.8.0+, KIP-700), that request
+// is used, which avoids the topic data a metadata response carries. Otherwise,
+// or if DescribeCluster fails with an error code, this falls back to a
+// metadata request for no topics.
+//
+// Unlike issuing either request through Request, this does not update the
+// brokers the client knows of nor the client's cached controller; the only
+// side effect is opening a connection to a seed broker, which is reaped once
+// idle. This is useful for tooling that only needs to describe a cluster.
+func (cl *Client) DiscoverBrokers(ctx context.Context) (ClusterInfo, error) {
+	var err error
+	for _, seed := range cl.SeedBrokers() {
+		var info ClusterInfo
+		info, err = describeCluster(ctx, seed)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		sort.Slice(info.Brokers, func(i, j int) bool { return info.Brokers[i].NodeID < info.Brokers[j].NodeID })
+		return info, nil
+	}
+	return ClusterInfo{}, err
+}
+
+// describeCluster issues a DescribeCluster request to b, falling back to a
+// metadata request if b is too old or replies with an error code.
+func describeCluster(ctx context.Context, b *Broker) (ClusterInfo, error) {
+	resp, err := b.Request(ctx, kmsg.NewPtrDescribeClusterRequest())
+	if err == nil {
+		describe := resp.(*kmsg.DescribeClusterResponse)
+		if describe.ErrorCode == 0 {
+			info := ClusterInfo{
+				Controller: describe.ControllerID,
+				ClusterID:  &describe.ClusterID,
+			}
+			for _, b := range describe.Brokers {
+				info.Brokers = append(info.Brokers, BrokerMetadata{
+					NodeID: b.NodeID,
+					Host:   b.Host,
+					Port:   b.Port,
+					Rack:   b.Rack,
+				})
+			}
+			return info, nil
+		}
+	} else if err != errBrokerTooOld && err != errUnknownRequestKey {
+		return ClusterInfo{}, err
+	}
+
+	req := kmsg.NewPtrMetadataRequest()
+	req.Topics = []kmsg.MetadataRequestTopic{} // no topics, only brokers
+	resp, err = b.Request(ctx, req)
+	if err != nil {
+		return ClusterInfo{}, err
+	}
+	meta := resp.(*kmsg.MetadataResponse)
+	info := ClusterInfo{
+		Controller: meta.ControllerID,
+		ClusterID:  meta.ClusterID,
+	}
+	for _, b := range meta.Brokers {
+		info.Brokers = append(info.Brokers, BrokerMetadata{
+			NodeID: b.NodeID,
+			Host:   b.Host,
+			Port:   b.Port,
+			Rack:   b.Rack,
+		})
+	}
+	return info, nil
+}
+
 // Broker pairs a broker ID with a client to directly issue requests to a
 // specific broker.
 type Broker struct {
@@ -1287,7 +1853,7 @@ func (cl *Client) handleShardedReq(ctx context.Context, req kmsg.Request) ([]Res
 		issues, reshardable, err := sharder.shard(ctx, try.req)
 		if err != nil {
 			l.Log(LogLevelDebug, "unable to shard request", "previous_tries", try.tries, "err", err)
-			addShard(shard(nil, try.req, nil, err)) // failure to shard means data loading failed; this request is failed
+			addShard(shard(nil, try.tries, try.req, nil, err)) // failure to shard means data loading failed; this request is failed
 			return
 		}
 
@@ -1329,7 +1895,7 @@ func (cl *Client) handleShardedReq(ctx context.Context, req kmsg.Request) ([]Res
 					broker, err = cl.brokerOrErr(ctx, myIssue.broker, errUnknownBroker)
 				}
 				if err != nil {
-					addShard(shard(nil, myIssue.req, nil, err)) // failure to load a broker is a failure to issue a request
+					addShard(shard(nil, tries, myIssue.req, nil, err)) // failure to load a broker is a failure to issue a request
 					return
 				}
 
@@ -1339,7 +1905,7 @@ func (cl *Client) handleShardedReq(ctx context.Context, req kmsg.Request) ([]Res
 					// response internal error checking cleanup.
 					// So, we call onResp, then keep the response.
 					sharder.onResp(resp)
-					addShard(shard(broker, myIssue.req, resp, nil))
+					addShard(shard(broker, tries, myIssue.req, resp, nil))
 					return
 				}
 
@@ -1360,7 +1926,7 @@ func (cl *Client) handleShardedReq(ctx context.Context, req kmsg.Request) ([]Res
 					return
 				}
 
-				addShard(shard(broker, myIssue.req, nil, err)) // the error was not retriable
+				addShard(shard(broker, tries, myIssue.req, nil, err)) // the error was not retriable
 			}()
 		}
 	}
@@ -0,0 +1,43 @@
+package kgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestConnTimeouts(t *testing.T) {
+	cfg := defaultCfg()
+	for _, opt := range []Opt{
+		RequestTimeoutOverhead(10 * time.Second),
+		RequestTimeout(func(key int16) time.Duration {
+			if key == 3 {
+				return time.Second
+			}
+			return 0
+		}),
+	} {
+		opt.apply(&cfg)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	fn := connTimeoutBuilder(cfg.keyTimeoutOverhead)
+
+	for _, test := range []struct {
+		name        string
+		req         kmsg.Request
+		read, write time.Duration
+	}{
+		{"metadata override", kmsg.NewPtrMetadataRequest(), time.Second, time.Second},
+		{"fetch default overhead plus max wait", &kmsg.FetchRequest{MaxWaitMillis: 5000}, 15 * time.Second, 10 * time.Second},
+		{"default overhead plus timeout millis", &kmsg.CreateTopicsRequest{TimeoutMillis: 2000}, 12 * time.Second, 10 * time.Second},
+		{"default overhead", kmsg.NewPtrListGroupsRequest(), 10 * time.Second, 10 * time.Second},
+	} {
+		read, write := fn(test.req)
+		if read != test.read || write != test.write {
+			t.Errorf("%s: got read %v write %v != exp read %v write %v", test.name, read, write, test.read, test.write)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package kgo
+
+import "time"
+
+// clock abstracts the time functions the client uses for throttling, sasl
+// reauthentication, connection reaping, and retry backoff. This allows tests
+// to control time rather than sleep.
+//
+// Timestamps that are handed to the standard library, such as connection
+// deadlines, always use the real time.
+type clock interface {
+	Now() time.Time
+	NewTimer(time.Duration) clockTimer
+	NewTicker(time.Duration) clockTimer
+}
+
+// clockTimer is the subset of time.Timer and time.Ticker that we use.
+type clockTimer interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) clockTimer { return realTimer{time.NewTimer(d)} }
+
+func (realClock) NewTicker(d time.Duration) clockTimer { return realTicker{time.NewTicker(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+func (t realTimer) Stop()               { t.t.Stop() }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// withClock is an unexported option to override the client's clock, for
+// tests.
+func withClock(c clock) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.clock = c }}
+}
+
+// since returns the time elapsed since t according to the client's clock.
+func (cl *Client) since(t time.Time) time.Duration { return cl.cfg.clock.Now().Sub(t) }
@@ -0,0 +1,137 @@
+package kgo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only advances when told to.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock  *fakeClock
+	c      chan time.Time
+	at     time.Time
+	every  time.Duration // non-zero for tickers
+	closed bool
+}
+
+func newFakeClock() *fakeClock { return &fakeClock{now: time.Unix(0, 0)} }
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) clockTimer  { return c.add(d, 0) }
+func (c *fakeClock) NewTicker(d time.Duration) clockTimer { return c.add(d, d) }
+
+func (c *fakeClock) add(d, every time.Duration) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1), at: c.now.Add(d), every: every}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// numTimers returns the number of timers that have not been stopped or fired.
+func (c *fakeClock) numTimers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int
+	for _, t := range c.timers {
+		if !t.closed {
+			n++
+		}
+	}
+	return n
+}
+
+// advance moves the clock forward by d, firing any timers that expire.
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		for !t.closed && !t.at.After(c.now) {
+			select {
+			case t.c <- t.at:
+			default:
+			}
+			if t.every == 0 {
+				t.closed = true
+			} else {
+				t.at = t.at.Add(t.every)
+			}
+		}
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+func (t *fakeTimer) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.closed = true
+}
+
+func TestWaitTriesFakeClock(t *testing.T) {
+	t.Parallel()
+	clock := newFakeClock()
+	cl, err := NewClient(
+		SeedBrokers("127.0.0.1:1"),
+		RetryBackoff(func(int) time.Duration { return time.Hour }),
+		withClock(clock),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	done := make(chan bool)
+	go func() { done <- cl.waitTries(context.Background(), 1) }()
+
+	// The reaper ticker always exists; wait for the backoff timer.
+	for clock.numTimers() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-done:
+		t.Fatal("backoff finished before the clock advanced")
+	default:
+	}
+
+	clock.advance(time.Hour)
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Error("waitTries returned false, expected true")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("backoff did not finish after the clock advanced")
+	}
+}
+
+func TestDNSCacheFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	c := newDNSCache(time.Minute, clock)
+	c.entries["example.invalid"] = dnsEntry{[]string{"10.0.0.1"}, clock.Now().Add(time.Minute)}
+
+	ips, err := c.lookup(context.Background(), "example.invalid")
+	if err != nil || len(ips) != 1 || ips[0] != "10.0.0.1" {
+		t.Fatalf("got %v, %v != exp cached [10.0.0.1], nil", ips, err)
+	}
+
+	// Once the fake clock passes the ttl, the entry expires and the
+	// unresolvable host fails to look up.
+	clock.advance(time.Minute)
+	if _, err := c.lookup(context.Background(), "example.invalid"); err == nil {
+		t.Error("expected lookup err after the cached entry expired")
+	}
+}
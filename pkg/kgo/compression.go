@@ -5,6 +5,7 @@ import (
 	"compress/gzip"
 	"encoding/binary"
 	"errors"
+	"io"
 	"io/ioutil"
 	"runtime"
 	"sync"
@@ -311,3 +312,164 @@ func xerialDecode(src []byte) ([]byte, error) {
 	}
 	return dst, nil
 }
+
+// FrameCodec encodes and decodes entire wire frames as they are written to and
+// read from broker connections.
+//
+// This is NOT part of the Kafka protocol. Kafka brokers do not understand
+// encoded frames; a frame codec is only useful if you control both ends of a
+// connection, such as when speaking to a proxy that decodes frames before
+// forwarding them to Kafka. Both ends must agree on the codec.
+//
+// EncodeFrame is given a full request frame without its four byte size
+// prefix, and DecodeFrame is given a full response frame without its four
+// byte size prefix. The client handles writing and reading the size prefix of
+// the encoded frame.
+type FrameCodec interface {
+	// EncodeFrame appends the encoded form of frame to dst and returns the
+	// updated dst slice.
+	EncodeFrame(dst, frame []byte) ([]byte, error)
+
+	// DecodeFrame returns the decoded form of frame. The returned slice
+	// must not alias any memory the codec reuses.
+	//
+	// maxSize is the client's BrokerMaxReadBytes. The codec should return
+	// an error as soon as it knows the decoded frame would be larger than
+	// maxSize, rather than decoding the entire frame; the client fails
+	// any decoded frame larger than maxSize regardless.
+	DecodeFrame(frame []byte, maxSize int) ([]byte, error)
+}
+
+// ErrFrameTooLarge is returned from the client's FrameCodec when a frame
+// decodes to more than BrokerMaxReadBytes.
+var ErrFrameTooLarge = errors.New("decoded frame is larger than the max broker read bytes")
+
+// CompressionFrameCodec returns a FrameCodec that compresses entire frames
+// with the given compression codec. See the FrameCodec documentation for why
+// this is non-standard.
+//
+// Compressing with NoCompression results in a passthrough codec.
+func CompressionFrameCodec(codec CompressionCodec) (FrameCodec, error) {
+	c, err := newCompressor(codec)
+	if err != nil {
+		return nil, err
+	}
+	return &compressionFrameCodec{
+		codec: codec.codec,
+		c:     c,
+		d:     newDecompressor(),
+		unzstdPool: sync.Pool{
+			New: func() interface{} {
+				zstdDec, _ := zstd.NewReader(nil,
+					zstd.WithDecoderLowmem(true),
+					zstd.WithDecoderConcurrency(1),
+				)
+				r := &zstdDecoder{zstdDec}
+				runtime.SetFinalizer(r, func(r *zstdDecoder) {
+					r.inner.Close()
+				})
+				return r
+			},
+		},
+	}, nil
+}
+
+type compressionFrameCodec struct {
+	codec int8
+	c     *compressor
+	d     *decompressor
+
+	// Frames are zstd decoded by streaming so that decoding can stop at
+	// the max size; these decoders are not mixed with the DecodeAll
+	// decoders of the decompressor.
+	unzstdPool sync.Pool
+}
+
+func (f *compressionFrameCodec) EncodeFrame(dst, frame []byte) ([]byte, error) {
+	if f.c == nil {
+		return append(dst, frame...), nil
+	}
+	w := sliceWriters.Get().(*sliceWriter)
+	defer sliceWriters.Put(w)
+
+	// We use a produce request version that allows any codec, since frame
+	// compression is not tied to produce request versions.
+	compressed, codec := f.c.compress(w, frame, 99)
+	if compressed == nil || codec != f.codec {
+		return nil, errors.New("unable to compress frame")
+	}
+	return append(dst, compressed...), nil
+}
+
+func (f *compressionFrameCodec) DecodeFrame(frame []byte, maxSize int) ([]byte, error) {
+	var decoded []byte
+	var err error
+	switch f.codec {
+	case 0:
+		decoded = frame
+	case 1:
+		ungz := f.d.ungzPool.Get().(*gzip.Reader)
+		defer f.d.ungzPool.Put(ungz)
+		if err := ungz.Reset(bytes.NewReader(frame)); err != nil {
+			return nil, err
+		}
+		decoded, err = ioutil.ReadAll(io.LimitReader(ungz, int64(maxSize)+1))
+	case 2:
+		var n int
+		if n, err = snappyDecodedLen(frame); err != nil {
+			return nil, err
+		}
+		if n > maxSize {
+			return nil, ErrFrameTooLarge
+		}
+		decoded, err = f.d.decompress(frame, 2)
+	case 3:
+		unlz4 := f.d.unlz4Pool.Get().(*lz4.Reader)
+		defer f.d.unlz4Pool.Put(unlz4)
+		unlz4.Reset(bytes.NewReader(frame))
+		decoded, err = ioutil.ReadAll(io.LimitReader(unlz4, int64(maxSize)+1))
+	case 4:
+		unzstd := f.unzstdPool.Get().(*zstdDecoder)
+		defer f.unzstdPool.Put(unzstd)
+		if err := unzstd.inner.Reset(bytes.NewReader(frame)); err != nil {
+			return nil, err
+		}
+		decoded, err = ioutil.ReadAll(io.LimitReader(unzstd.inner, int64(maxSize)+1))
+	default:
+		return nil, errors.New("unknown compression codec")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) > maxSize {
+		return nil, ErrFrameTooLarge
+	}
+	return decoded, nil
+}
+
+// snappyDecodedLen returns the decoded length of snappy or xerial framed
+// snappy input without decoding it.
+func snappyDecodedLen(src []byte) (int, error) {
+	if !(len(src) > 16 && bytes.HasPrefix(src, xerialPfx)) {
+		return snappy.DecodedLen(src)
+	}
+	src = src[16:]
+	var total int
+	for len(src) > 0 {
+		if len(src) < 4 {
+			return 0, errMalformedXerial
+		}
+		size := int32(binary.BigEndian.Uint32(src))
+		src = src[4:]
+		if size < 0 || len(src) < int(size) {
+			return 0, errMalformedXerial
+		}
+		n, err := snappy.DecodedLen(src[:size])
+		if err != nil {
+			return 0, err
+		}
+		total += n
+		src = src[size:]
+	}
+	return total, nil
+}
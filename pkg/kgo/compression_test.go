@@ -2,8 +2,14 @@ package kgo
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kmsg"
 )
 
 func TestNewCompressor(t *testing.T) {
@@ -93,6 +99,107 @@ func TestCompressDecompress(t *testing.T) {
 	wg.Wait()
 }
 
+func TestCompressionFrameCodec(t *testing.T) {
+	t.Parallel()
+	in := []byte("foo bar biz baz")
+	for _, codec := range []CompressionCodec{
+		NoCompression(),
+		GzipCompression(),
+		SnappyCompression(),
+		Lz4Compression(),
+		ZstdCompression(),
+	} {
+		fc, err := CompressionFrameCodec(codec)
+		if err != nil {
+			t.Errorf("codec %d: unexpected err: %v", codec.codec, err)
+			continue
+		}
+		encoded, err := fc.EncodeFrame([]byte("pfx"), in)
+		if err != nil {
+			t.Errorf("codec %d: unexpected encode err: %v", codec.codec, err)
+			continue
+		}
+		if !bytes.HasPrefix(encoded, []byte("pfx")) {
+			t.Errorf("codec %d: encode did not append to dst", codec.codec)
+			continue
+		}
+		decoded, err := fc.DecodeFrame(encoded[3:], len(in))
+		if err != nil {
+			t.Errorf("codec %d: unexpected decode err: %v", codec.codec, err)
+			continue
+		}
+		if !bytes.Equal(decoded, in) {
+			t.Errorf("codec %d: got decoded %s != exp %s", codec.codec, decoded, in)
+		}
+	}
+}
+
+func TestCompressionFrameCodecMaxSize(t *testing.T) {
+	t.Parallel()
+	in := bytes.Repeat([]byte("foo bar biz baz "), 64)
+	for _, codec := range []CompressionCodec{
+		NoCompression(),
+		GzipCompression(),
+		SnappyCompression(),
+		Lz4Compression(),
+		ZstdCompression(),
+	} {
+		fc, err := CompressionFrameCodec(codec)
+		if err != nil {
+			t.Errorf("codec %d: unexpected err: %v", codec.codec, err)
+			continue
+		}
+		encoded, err := fc.EncodeFrame(nil, in)
+		if err != nil {
+			t.Errorf("codec %d: unexpected encode err: %v", codec.codec, err)
+			continue
+		}
+		if _, err := fc.DecodeFrame(encoded, len(in)-1); err != ErrFrameTooLarge {
+			t.Errorf("codec %d: got decode err %v != exp %v", codec.codec, err, ErrFrameTooLarge)
+		}
+		if decoded, err := fc.DecodeFrame(encoded, len(in)); err != nil || !bytes.Equal(decoded, in) {
+			t.Errorf("codec %d: got decoded len %d, err %v != exp len %d, no err", codec.codec, len(decoded), err, len(in))
+		}
+	}
+}
+
+// inflatingFrameCodec decodes every frame to one byte more than it is
+// allowed to, ignoring maxSize.
+type inflatingFrameCodec struct{}
+
+func (inflatingFrameCodec) EncodeFrame(dst, frame []byte) ([]byte, error) {
+	return append(dst, frame...), nil
+}
+
+func (inflatingFrameCodec) DecodeFrame(frame []byte, maxSize int) ([]byte, error) {
+	return append(frame, make([]byte, maxSize+1-len(frame))...), nil
+}
+
+func TestFrameCodecDecodedSizeEnforced(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	cl, err := NewClient(
+		SeedBrokers(c.Addr()),
+		WithFrameCodec(inflatingFrameCodec{}),
+		BrokerMaxReadBytes(1<<10),
+		FetchMaxBytes(1<<10),
+		RequestRetries(0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := cl.Request(ctx, kmsg.NewPtrMetadataRequest()); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("got err %v != exp %v", err, ErrFrameTooLarge)
+	}
+}
+
 func BenchmarkCompress(b *testing.B) {
 	c, _ := newCompressor(CompressionCodec{codec: 2}) // snappy
 	in := []byte("foo")
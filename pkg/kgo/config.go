@@ -2,11 +2,13 @@ package kgo
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"math"
 	"math/rand"
 	"net"
+	"os"
 	"sync"
 	"time"
 
@@ -45,10 +47,28 @@ func (consumerOpt) consumerOpt()       {}
 
 type cfg struct {
 	// ***GENERAL SECTION***
-	id                  *string
-	dialFn              func(context.Context, string, string) (net.Conn, error)
+	id                *string
+	clientHost        *string
+	dialFn            func(context.Context, string, string) (net.Conn, error)
+	socks5            *socks5Dialer // if non-nil, wraps dialFn in NewClient
+	dialTimeout       time.Duration
+	dnsCacheTTL       time.Duration
+	dialFallbackDelay time.Duration
+	drainCooldown     time.Duration
+	circuitMaxFails   int
+	circuitResetAfter time.Duration
+	connCloseLinger   int
+	connPool          *ConnPool
+	tlsCfgFn          func(BrokerMetadata) *tls.Config
+
+	onEmptyAPIVersions func() map[int16]int16
+
+	clock               clock
 	connTimeoutOverhead time.Duration
 	connIdleTimeout     time.Duration
+	stuckThreshold      time.Duration
+
+	requestTimeout func(int16) time.Duration
 
 	softwareName    string // KIP-511
 	softwareVersion string // KIP-511
@@ -63,19 +83,36 @@ type cfg struct {
 	retries               int64
 	retryTimeout          func(int16) time.Duration
 	brokerConnDeadRetries int
+	errorPolicy           func(int16, int16) ErrorAction
 
 	maxBrokerWriteBytes int32
 	maxBrokerReadBytes  int32
 
+	maxConcurrentReadBytes int64
+
+	recentErrorsLimit int
+	pooledRespBufs    bool
+
 	allowAutoTopicCreation bool
 
-	metadataMaxAge time.Duration
-	metadataMinAge time.Duration
+	metadataMaxAge   time.Duration
+	metadataMinAge   time.Duration
+	metadataDebounce time.Duration
+	metadataEager    bool
+
+	onTopicMetadataErr func(string, error)
+
+	coordinatorTTL time.Duration
 
 	sasls []sasl.Mechanism
 
 	hooks hooks
 
+	newRequestScheduler func(BrokerMetadata) RequestScheduler
+	faultInjector       FaultInjector
+
+	frameCodec FrameCodec
+
 	// ***PRODUCER SECTION***
 	txnID              *string
 	txnTimeout         time.Duration
@@ -84,6 +121,12 @@ type cfg struct {
 	compression        []CompressionCodec // order of preference
 
 	maxRecordBatchBytes int32
+	maxProduceReqBytes  int32
+	shrinkProduceReqs   bool
+	produceRateLimit    int64
+	discardBufBytes     int
+	discardReadTimeout  time.Duration
+	maxRecordSize       int32
 	maxBufferedRecords  int64
 	produceTimeout      time.Duration
 	produceRetries      int64
@@ -91,11 +134,26 @@ type cfg struct {
 	recordTimeout       time.Duration
 	manualFlushing      bool
 
-	partitioner Partitioner
+	maxInflightPerPartition int
+	requireOrdering         bool
+
+	partitioner        Partitioner
+	expectedPartitions func(string) int
 
 	stopOnDataLoss bool
 	onDataLoss     func(string, int32)
 
+	onUnknownTopic func(string) UnknownTopicAction
+
+	targetedMetadataRefresh bool
+	failFastIfNoBroker      bool
+
+	overflowPolicy OverflowPolicy
+	onDropped      func(*Record)
+
+	minISR     int32
+	minISRFail bool
+
 	// ***CONSUMER SECTION***
 	maxWait        int32
 	minBytes       int32
@@ -106,7 +164,12 @@ type cfg struct {
 	keepControl    bool
 	rack           string
 
+	onOffsetOutOfRange func(string, int32, int64, int64, int64) int64
+
 	allowedConcurrentFetches int
+
+	adaptiveFetchMinBytes int32
+	adaptiveFetchMaxBytes int32
 }
 
 func (cfg *cfg) validate() error {
@@ -127,6 +190,61 @@ func (cfg *cfg) validate() error {
 		return errors.New("idempotency requires acks=all")
 	}
 
+	if cfg.requireOrdering && cfg.disableIdempotency {
+		// Without idempotency, a retried batch can be written after
+		// a later batch that was in flight at the same time.
+		switch {
+		case cfg.maxInflightPerPartition == 0:
+			cfg.maxInflightPerPartition = 1
+		case cfg.maxInflightPerPartition > 1:
+			return fmt.Errorf("ordered non-idempotent producing requires at most one in flight produce per partition, but %d was configured", cfg.maxInflightPerPartition)
+		}
+	}
+
+	if cfg.maxProduceReqBytes != 0 {
+		switch {
+		case cfg.maxProduceReqBytes < cfg.maxRecordBatchBytes:
+			return fmt.Errorf("max produce request bytes %v is erroneously less than max record batch bytes %v", cfg.maxProduceReqBytes, cfg.maxRecordBatchBytes)
+		case cfg.maxProduceReqBytes > cfg.maxBrokerWriteBytes:
+			return fmt.Errorf("max produce request bytes %v is erroneously more than max broker write bytes %v", cfg.maxProduceReqBytes, cfg.maxBrokerWriteBytes)
+		}
+	}
+
+	if cfg.connPool != nil {
+		// Pooled connections are authenticated once for every client
+		// on them, and they route responses by correlation ID, which
+		// does not work for requests the broker does not reply to.
+		if len(cfg.sasls) > 0 {
+			return errors.New("cannot use a shared connection pool with sasl")
+		}
+		if cfg.acks.val == 0 {
+			return errors.New("cannot use a shared connection pool with no acks")
+		}
+		if cfg.tlsCfgFn != nil {
+			return errors.New("cannot use a shared connection pool with a per-broker tls config; configure tls in the pool's dial function")
+		}
+	}
+
+	if cfg.circuitMaxFails > 0 && cfg.circuitResetAfter <= 0 {
+		return errors.New("broker circuit breaker reset after must be positive when max failures is positive")
+	}
+
+	if cfg.clientHost != nil {
+		if cfg.id == nil {
+			return errors.New("cannot set a client host with a disabled client id")
+		}
+		host := *cfg.clientHost
+		if host == "" {
+			var err error
+			if host, err = os.Hostname(); err != nil {
+				return fmt.Errorf("unable to determine hostname for client host: %v", err)
+			}
+		}
+		id := *cfg.id + ";host=" + host
+		cfg.id = &id
+		cfg.clientHost = nil // only append once if validated twice
+	}
+
 	for _, limit := range []struct {
 		name    string
 		sp      **string // if field is a *string, we take addr to it
@@ -174,6 +292,12 @@ func (cfg *cfg) validate() error {
 		{name: "max broker read bytes", v: int64(cfg.maxBrokerReadBytes), allowed: 1 << 10, badcmp: i64lt},
 		{name: "max broker read bytes", v: int64(cfg.maxBrokerReadBytes), allowed: 1 << 30, badcmp: i64gt},
 
+		// 0 <= recent errors limit
+		{name: "recent errors limit", v: int64(cfg.recentErrorsLimit), allowed: 0, badcmp: i64lt},
+
+		// 0 <= max concurrent read bytes
+		{name: "max concurrent read bytes", v: cfg.maxConcurrentReadBytes, allowed: 0, badcmp: i64lt},
+
 		// For batches, we want at least 512 (reasonable), and the
 		// upper limit is the max num when a uvarint transitions from 4
 		// to 5 bytes. The upper limit is also more than reasoanble
@@ -189,6 +313,8 @@ func (cfg *cfg) validate() error {
 		// fetch bytes limit, but hopefully we do not run into that.
 		{v: int64(cfg.maxBrokerWriteBytes), allowed: int64(cfg.maxRecordBatchBytes), badcmp: i64lt, fmt: "max broker write bytes %v is erroneously less than max record batch bytes %v"},
 		{v: int64(cfg.maxBrokerReadBytes), allowed: int64(cfg.maxBytes), badcmp: i64lt, fmt: "max broker read bytes %v is erroneously less than max fetch bytes %v"},
+		{v: int64(cfg.maxBrokerReadBytes), allowed: int64(cfg.adaptiveFetchMaxBytes), badcmp: i64lt, fmt: "max broker read bytes %v is erroneously less than adaptive max fetch bytes %v"},
+		{v: int64(cfg.adaptiveFetchMaxBytes), allowed: int64(cfg.adaptiveFetchMinBytes), badcmp: i64lt, fmt: "adaptive max fetch bytes %v is erroneously less than adaptive min fetch bytes %v"},
 
 		// 0 <= allowed concurrency
 		{name: "allowed concurrency", v: int64(cfg.allowedConcurrentFetches), allowed: 0, badcmp: i64lt},
@@ -197,6 +323,15 @@ func (cfg *cfg) validate() error {
 		{name: "conn timeout max overhead", v: int64(cfg.connTimeoutOverhead), allowed: int64(15 * time.Minute), badcmp: i64gt, durs: true},
 		{name: "conn timeout min overhead", v: int64(cfg.connTimeoutOverhead), allowed: int64(time.Second), badcmp: i64lt, durs: true},
 
+		// 0 <= dial timeout, metadata request timeout; 0 disables
+		{name: "dial timeout", v: int64(cfg.dialTimeout), allowed: 0, badcmp: i64lt, durs: true},
+		{name: "dns cache ttl", v: int64(cfg.dnsCacheTTL), allowed: 0, badcmp: i64lt, durs: true},
+		{name: "dial fallback delay", v: int64(cfg.dialFallbackDelay), allowed: 0, badcmp: i64lt, durs: true},
+		{name: "broker drain cooldown", v: int64(cfg.drainCooldown), allowed: 0, badcmp: i64lt, durs: true},
+
+		// 0 <= circuit breaker max failures; 0 disables
+		{name: "broker circuit breaker max failures", v: int64(cfg.circuitMaxFails), allowed: 0, badcmp: i64lt},
+
 		// 1s <= conn idle <= 15m
 		{name: "conn min idle timeout", v: int64(cfg.connIdleTimeout), allowed: int64(time.Second), badcmp: i64lt, durs: true},
 		{name: "conn max idle timeout", v: int64(cfg.connIdleTimeout), allowed: int64(15 * time.Minute), badcmp: i64gt, durs: true},
@@ -205,9 +340,13 @@ func (cfg *cfg) validate() error {
 		{name: "metadata max age", v: int64(cfg.metadataMaxAge), allowed: int64(time.Hour), badcmp: i64gt, durs: true},
 		{name: "metadata min age", v: int64(cfg.metadataMinAge), allowed: int64(10 * time.Millisecond), badcmp: i64lt, durs: true},
 		{v: int64(cfg.metadataMaxAge), allowed: int64(cfg.metadataMinAge), badcmp: i64lt, fmt: "metadata max age %v is erroneously less than metadata min age %v", durs: true},
+		{name: "metadata debounce", v: int64(cfg.metadataDebounce), allowed: 0, badcmp: i64lt, durs: true},
 
 		// Some random producer settings.
 		{name: "max buffered records", v: int64(cfg.maxBufferedRecords), allowed: 1, badcmp: i64lt},
+		{name: "produce rate limit", v: cfg.produceRateLimit, allowed: 0, badcmp: i64lt},
+		{name: "discard buffer bytes", v: int64(cfg.discardBufBytes), allowed: 4, badcmp: i64lt},
+		{name: "discard read timeout", v: int64(cfg.discardReadTimeout), allowed: 0, badcmp: i64lt, durs: true},
 		{name: "linger", v: int64(cfg.linger), allowed: int64(time.Minute), badcmp: i64gt, durs: true},
 		{name: "produce timeout", v: int64(cfg.produceTimeout), allowed: int64(time.Second), badcmp: i64lt, durs: true},
 		{name: "record timeout", v: int64(cfg.recordTimeout), allowed: int64(time.Second), badcmp: func(l, r int64) (bool, string) {
@@ -244,8 +383,12 @@ func defaultCfg() cfg {
 	defaultID := "kgo"
 	return cfg{
 		id:     &defaultID,
-		dialFn: (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
+		dialFn: new(net.Dialer).DialContext,
+		clock:  realClock{},
+
+		connCloseLinger: -1,
 
+		dialTimeout:         10 * time.Second,
 		connTimeoutOverhead: 20 * time.Second,
 		connIdleTimeout:     20 * time.Second,
 
@@ -305,6 +448,7 @@ func defaultCfg() cfg {
 		acks:                AllISRAcks(),
 		compression:         []CompressionCodec{SnappyCompression(), NoCompression()},
 		maxRecordBatchBytes: 1000000, // Kafka max.message.bytes default is 1000012
+		discardBufBytes:     256,
 		maxBufferedRecords:  math.MaxInt64,
 		produceTimeout:      30 * time.Second,
 		produceRetries:      math.MaxInt64,             // effectively unbounded
@@ -329,6 +473,24 @@ func ClientID(id string) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.id = &id }}
 }
 
+// ClientHost appends host to the client ID as ";host=<host>", such that the
+// client ID sent in every request is "<id>;host=<host>". If host is empty,
+// the machine's hostname is used.
+//
+// The Kafka protocol has no request header field for the client's host;
+// brokers log the address of the connection, which in containerized or NATed
+// environments is often not useful for attributing requests. The client ID is
+// logged in broker request logs, so encoding the host in it allows operators
+// to attribute requests to the right instance. The key=value form keeps the
+// client ID parseable should more fields be added.
+//
+// Note that client IDs are used for client quotas; clients with different
+// hosts have different client IDs and thus different default quotas. The
+// resulting client ID must be no longer than 256 bytes.
+func ClientHost(host string) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.clientHost = &host }}
+}
+
 // DisableClientID sets the client ID to null for all requests sent to Kafka
 // brokers, overriding the default "kgo".
 func DisableClientID() Opt {
@@ -360,21 +522,33 @@ func WithLogger(l Logger) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.logger = &wrappedLogger{l} }}
 }
 
-// ConnTimeoutOverhead uses the given time as overhead while deadlining
+// RequestTimeoutOverhead uses the given time as overhead while deadlining
 // requests, overriding the default overhead of 20s.
 //
 // For most requests, the overhead will simply be this timeout. However, for any
 // request with a TimeoutMillis field, the overhead is added on top of the
 // request's TimeoutMillis. This ensures that we give Kafka enough time to
 // actually process the request given the timeout, while still having a
-// deadline on the connection as a whole to ensure it does not hang.
+// deadline on the connection as a whole to ensure it does not hang. Produce
+// requests add this overhead to the ProduceRequestTimeout.
 //
 // For writes, the timeout is always the overhead. We buffer writes in our
 // client before one quick flush, so we always expect the write to be fast.
-func ConnTimeoutOverhead(overhead time.Duration) Opt {
+//
+// This overhead does not apply to dialing (see DialTimeout), nor to requests
+// whose key has its own overhead set through RequestTimeout.
+func RequestTimeoutOverhead(overhead time.Duration) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.connTimeoutOverhead = overhead }}
 }
 
+// ConnTimeoutOverhead is the same as RequestTimeoutOverhead.
+//
+// Deprecated: Use RequestTimeoutOverhead, which better describes that the
+// overhead applies per request rather than per connection.
+func ConnTimeoutOverhead(overhead time.Duration) Opt {
+	return RequestTimeoutOverhead(overhead)
+}
+
 // ConnIdleTimeout is a rough amount of time to allow connections to idle
 // before they are closed, overriding the default 20.
 //
@@ -388,12 +562,195 @@ func ConnTimeoutOverhead(overhead time.Duration) Opt {
 // Connections are not reaped if they are actively being written to or read
 // from; thus, a request can take a really long time itself and not be reaped
 // (however, this may lead to the ConnTimeoutOverhead).
+//
+// This timeout is only used by the connection reaper; it does not affect
+// dialing or how long individual requests may take.
 func ConnIdleTimeout(timeout time.Duration) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.connIdleTimeout = timeout }}
 }
 
-// Dialer uses fn to dial addresses, overriding the default dialer that uses a
-// 10s dial timeout and no TLS.
+// StuckRequestThreshold enables a background watchdog that calls any
+// BrokerStuckRequestHook for requests that have been waiting for a response
+// longer than threshold, overriding the default of no watchdog.
+//
+// A stalled broker that keeps its TCP connection alive otherwise only shows
+// up once a request hits its read timeout, which for fetch and produce
+// requests includes the broker side wait. Setting the threshold below the
+// read timeout gives early warning of a stalled broker. The watchdog checks
+// every quarter threshold, and reports each stuck request once.
+//
+// Note that fetch requests deliberately wait up to FetchMaxWait for data,
+// so the threshold should be comfortably above that.
+func StuckRequestThreshold(threshold time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.stuckThreshold = threshold }}
+}
+
+// ConnCloseLinger sets SO_LINGER to sec seconds on broker connections right
+// before they are closed, overriding the default of leaving the operating
+// system's linger behavior alone. A negative sec keeps the default.
+//
+// Setting zero makes closing a connection discard any unsent data and reset
+// the connection immediately rather than going through a normal close, which
+// avoids leaving sockets in TIME_WAIT. At very high reconnect rates, this can
+// avoid exhausting ephemeral ports. Since this changes TCP close semantics,
+// only use this if you need it.
+//
+// This only applies to connections that are (or wrap, as with TLS) a
+// *net.TCPConn or any connection with a SetLinger method.
+func ConnCloseLinger(sec int) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.connCloseLinger = sec }}
+}
+
+// DialTimeout sets the maximum amount of time a dial to a broker can take,
+// overriding the default 10s. This applies to the default dialer as well as
+// to any dial function set with Dialer, through the context passed to the
+// dial function.
+//
+// A zero timeout disables the dial timeout, leaving only the dial function's
+// own timeout (if any) and the context of the request that caused the dial.
+func DialTimeout(timeout time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.dialTimeout = timeout }}
+}
+
+// DNSCacheTTL sets the client to resolve broker hostnames itself and cache the
+// resolved addresses for ttl, overriding the default of leaving resolution to
+// the dial function on every dial.
+//
+// With a cache, the dial function is called with the resolved IP addresses
+// rather than the broker hostname, and the addresses are raced as described
+// in DialFallbackDelay until one dial succeeds. Racing is always enabled with
+// a cache: if DialFallbackDelay is unset, a new dial is started every 300ms
+// (the net package default). If every cached address fails to dial, the cache
+// entry for the host is dropped so that the next dial resolves the host
+// again. This allows cutting repeated lookups when reconnecting often, while
+// still moving off of stale addresses when a host behind a rotating virtual
+// IP changes.
+//
+// Since the dial function is given IP addresses, if you use TLS with this
+// option, your TLS config must set ServerName (or otherwise verify the
+// broker certificate without relying on the dialed address).
+func DNSCacheTTL(ttl time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.dnsCacheTTL = ttl }}
+}
+
+// DialFallbackDelay sets the client to resolve broker hostnames itself and
+// race dials to the resolved addresses as described in RFC 8305 ("happy
+// eyeballs v2"): addresses are interleaved by family, starting with the
+// family of the first resolved address, and a new dial is started every delay
+// or as soon as the prior dial fails. The first connection to succeed is used
+// and all other dials are canceled. The address that connected is passed to
+// any BrokerConnectAddrHook.
+//
+// By default, hostname resolution is left to the dial function. Go's
+// net.Dialer already races families, but custom dial functions may not, and
+// a broker hostname with both A and AAAA records can stall on a broken family
+// or an unreachable address until the dial times out. This option moves
+// racing into the client so that it applies to any dial function. If
+// DNSCacheTTL is also used, cached addresses are raced with this delay, or
+// with 300ms if this option is unset.
+//
+// As with DNSCacheTTL, the dial function is given IP addresses rather than
+// the broker hostname, so a TLS config must set ServerName.
+func DialFallbackDelay(delay time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.dialFallbackDelay = delay }}
+}
+
+// BrokerDrainCooldown sets the client to avoid a broker that appears to be
+// shutting down for the given cooldown, overriding the default of 0, which
+// disables draining.
+//
+// A broker is marked draining when it closes a connection while a request is
+// in flight, which brokers do when shutting down. While a broker is draining,
+// requests that can go to any broker (such as metadata requests) are sent to
+// other brokers, and the client does not open new connections to the broker:
+// requests that must go to it fail with a retriable error rather than
+// repeatedly reconnecting to it mid shutdown.
+//
+// Marking a broker draining triggers a metadata refresh, so that partition
+// leadership moves off of the broker are discovered quickly. If metadata no
+// longer lists the broker, the broker is removed entirely, and if metadata
+// shows the broker leading partitions again, it is no longer draining.
+// Otherwise, once the cooldown passes, the broker is used as normal.
+//
+// Existing connections to a draining broker are left open, so that in flight
+// requests can finish.
+func BrokerDrainCooldown(cooldown time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.drainCooldown = cooldown }}
+}
+
+// BrokerCircuitBreaker sets the client to stop dialing a broker after
+// maxFailures consecutive connection failures within resetAfter, overriding
+// the default of 0 max failures, which disables the breaker.
+//
+// By default, every request to an unreachable broker dials it and waits out
+// the dial timeout before failing, and during an outage, every queued request
+// fails slowly one after another. Once a broker's breaker opens, requests
+// that need a new connection to the broker fail immediately with
+// ErrBrokerCircuitOpen, and requests that can go to any broker (such as
+// metadata requests) are sent to other brokers. After resetAfter, the next
+// request that needs a connection is allowed to dial as a probe: if the probe
+// fails, the breaker opens again for another resetAfter, and if the
+// connection is successfully initialized, the breaker closes.
+//
+// A connection failure is a failed dial or a failed connection
+// initialization (for example, a failed TLS handshake or SASL exchange), but
+// not a dial that is abandoned because its request was canceled. Any
+// successfully initialized connection resets the count of failures.
+func BrokerCircuitBreaker(maxFailures int, resetAfter time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.circuitMaxFails, cfg.circuitResetAfter = maxFailures, resetAfter }}
+}
+
+// OnEmptyAPIVersions sets a function that returns the max versions to assume
+// per request key when a broker's ApiVersions response is unusable, overriding
+// the default of failing the connection.
+//
+// An ApiVersions response is unusable if it is too short to contain an error
+// code, if it cannot be parsed, or if it contains no keys. Such responses are
+// not sent by Kafka, but may be sent by test doubles or proxies that do not
+// fully implement the protocol. If the function returns a nil or empty map,
+// the connection fails as normal.
+//
+// If the returned map contains the produce key (0), keys missing from the map
+// are treated as unsupported by the broker; otherwise, missing keys are
+// issued at the client's own max version. Be conservative: the client issues
+// requests at the returned versions without any further negotiation.
+func OnEmptyAPIVersions(fn func() map[int16]int16) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.onEmptyAPIVersions = fn }}
+}
+
+// RequestTimeout sets a function that returns the timeout overhead to use for
+// requests of a given key, overriding the RequestTimeoutOverhead for that key.
+// This is the one place to tune timeouts per request type.
+//
+// If the function returns a non-positive timeout for a key, the
+// RequestTimeoutOverhead is used. Otherwise, the returned timeout is used
+// exactly as the RequestTimeoutOverhead would be: it is the write timeout,
+// and the read timeout is it plus any time the request itself asks Kafka to
+// wait (for example, a produce request timeout, a fetch max wait, or a
+// TimeoutMillis field). For requests with no such field, such as metadata
+// requests, the returned timeout is the whole read timeout.
+//
+// This is useful to bound only specific requests, such as ensuring metadata
+// or admin requests fail quickly, or giving large fetches more time to be
+// read, without loosening or tightening the deadline on every request.
+func RequestTimeout(fn func(key int16) time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.requestTimeout = fn }}
+}
+
+// keyTimeoutOverhead returns the timeout overhead for requests of the given
+// key: the RequestTimeout for the key if set, otherwise the
+// RequestTimeoutOverhead.
+func (cfg *cfg) keyTimeoutOverhead(key int16) time.Duration {
+	if cfg.requestTimeout != nil {
+		if timeout := cfg.requestTimeout(key); timeout > 0 {
+			return timeout
+		}
+	}
+	return cfg.connTimeoutOverhead
+}
+
+// Dialer uses fn to dial addresses, overriding the default dialer that uses
+// no TLS. Dials are always bounded by the DialTimeout.
 //
 // The context passed to the dial function is the context used in the request
 // that caused the dial. If the request is a client-internal request, the
@@ -413,6 +770,40 @@ func Dialer(fn func(ctx context.Context, network, host string) (net.Conn, error)
 	return clientOpt{func(cfg *cfg) { cfg.dialFn = fn }}
 }
 
+// TLSConfigFn sets a function that returns the TLS config to use for each
+// broker, which is useful for clusters that present different certificates
+// per broker listener, such as clusters fronted by per-broker ingresses.
+//
+// The function is called every time the client opens a connection to a
+// broker. The connection is dialed with the dial function as normal, and then
+// if the returned config is non-nil, a TLS handshake is performed over the
+// dialed connection; a nil config leaves the connection to that broker
+// unencrypted. If the returned config has no ServerName, the client uses a
+// clone of the config with ServerName set to the broker's host for SNI and
+// certificate verification.
+//
+// Seed brokers have negative node IDs and no rack. The dial function should
+// not also perform a TLS handshake when using this option.
+func TLSConfigFn(fn func(meta BrokerMetadata) *tls.Config) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.tlsCfgFn = fn }}
+}
+
+// SharedConnPool sets the client to dial brokers through the given connection
+// pool, which multiplexes the connections of every client using the pool onto
+// one connection per broker. This is useful when running many clients in one
+// process against the same cluster, where one connection per broker per
+// client may run into the broker's max.connections limit. See ConnPool for
+// more details.
+//
+// The pool's dial function is used rather than any Dialer on this client,
+// and options that change how the client dials (DNSCacheTTL,
+// DialFallbackDelay) have no effect. Connections in a pool cannot be
+// authenticated per client, so this option cannot be used with SASL, nor can
+// it be used when producing with no acks.
+func SharedConnPool(pool *ConnPool) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.connPool = pool }}
+}
+
 // SeedBrokers sets the seed brokers for the client to use, overriding the
 // default 127.0.0.1:9092.
 //
@@ -467,6 +858,48 @@ func RequestRetries(n int) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.retries = int64(n) }}
 }
 
+// ErrorAction is what the client should do when a response contains an error
+// code, as decided by an ErrorPolicy.
+type ErrorAction struct {
+	action int8
+}
+
+// ErrorDefault handles the error code as the client normally would.
+func ErrorDefault() ErrorAction { return ErrorAction{0} }
+
+// ErrorRetry retries the request (or for produce requests, the batch) as if
+// the error code were retriable, even if it normally is not. Retries are
+// still bounded by RequestRetries or ProduceRetries.
+func ErrorRetry() ErrorAction { return ErrorAction{1} }
+
+// ErrorFail fails the request (or for produce requests, the batch) with the
+// error, even if the error code is normally retriable.
+func ErrorFail() ErrorAction { return ErrorAction{2} }
+
+// ErrorRefreshMetadata triggers a metadata refresh and then retries as with
+// ErrorRetry. This is useful for error codes that a broker returns when its
+// view of the cluster is stale, such as after a leader moves.
+func ErrorRefreshMetadata() ErrorAction { return ErrorAction{3} }
+
+// ErrorPolicy sets a function that decides how the client handles error codes
+// in responses, overriding the default of handling all error codes per the
+// Kafka protocol. The function is called with the request key and the non-zero
+// error code, and can return ErrorDefault to keep the client's normal
+// behavior.
+//
+// This is an escape hatch for broker specific quirks: some error codes, such
+// as UNKNOWN_SERVER_ERROR, are emitted by different broker versions for
+// different underlying causes, some of which are worth retrying.
+//
+// The policy is consulted for the top level error code of requests issued
+// with the client's request functions (including the client's own internal
+// requests), and for per partition error codes in produce responses. Error
+// codes in fetch responses and nested error codes in other responses are not
+// passed to the policy.
+func ErrorPolicy(fn func(key, code int16) ErrorAction) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.errorPolicy = fn }}
+}
+
 // RetryTimeout sets the upper limit on how long we allow requests to retry,
 // overriding the default of 5m for EndTxn requests, 1m for all others.
 //
@@ -535,6 +968,53 @@ func BrokerMaxReadBytes(v int32) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.maxBrokerReadBytes = v }}
 }
 
+// MaxConcurrentReadBytes bounds the total size of large responses (64KiB or
+// more) that the client reads from all brokers at once, overriding the
+// default of 0, which is unbounded.
+//
+// The client allocates a buffer the size of every response it reads, and a
+// burst of large fetch responses from many brokers at once can spike memory.
+// With this option, a read that would exceed the bound waits, before
+// allocating its buffer, until earlier responses are decoded and their bytes
+// are released. The wait respects the request context; if the request is
+// canceled while waiting, the connection is closed, as with any other read
+// error. A single response larger than the bound is read once nothing else
+// is being read.
+//
+// This bounds peak memory on the read path in memory constrained deployments,
+// at the cost of serializing reads when the bound is reached. Under normal
+// load, with the bound well above the size of the responses being read at
+// once, reads never wait.
+func MaxConcurrentReadBytes(n int64) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.maxConcurrentReadBytes = n }}
+}
+
+// RecentErrorsLimit sets the client to remember the latest n request and
+// produce errors in memory, overriding the default of 0, which remembers
+// none. The errors can be read with Client.RecentErrors.
+func RecentErrorsLimit(n int) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.recentErrorsLimit = n }}
+}
+
+// PooledResponseBuffers sets the client to read responses into buffers drawn
+// from a size classed pool, returning each buffer to the pool once its
+// response is decoded, rather than allocating a new buffer for every
+// response.
+//
+// Decoding a response copies strings and numbers out of the buffer, but
+// byte slices in the response alias the buffer, so a buffer cannot be reused
+// if its response has any byte slice field. Thus, only responses without byte
+// slice fields are read into pooled buffers; for example, produce, metadata,
+// list offsets, and offset commit responses are pooled. Fetch responses
+// (whose record batches and thus record keys and values alias the buffer),
+// SASL authenticate responses, and any other response with a byte slice field
+// always use a new buffer. Which responses are pooled is determined from the
+// response types themselves, so responses returned from Request are always
+// safe to retain.
+func PooledResponseBuffers() Opt {
+	return clientOpt{func(cfg *cfg) { cfg.pooledRespBufs = true }}
+}
+
 // MetadataMaxAge sets the maximum age for the client's cached metadata,
 // overriding the default 5m, to allow detection of new topics, partitions,
 // etc.
@@ -544,6 +1024,35 @@ func MetadataMaxAge(age time.Duration) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.metadataMaxAge = age }}
 }
 
+// CoordinatorCacheTTL sets how long a group or transaction coordinator is
+// cached before being looked up again, overriding the default of caching
+// coordinators until they are invalidated.
+//
+// Coordinators are always invalidated when a request to them fails with
+// NOT_COORDINATOR, COORDINATOR_NOT_AVAILABLE, or COORDINATOR_LOAD_IN_PROGRESS,
+// so this is only useful to bound how long a client can use a coordinator
+// that moved without the client noticing. See also
+// Client.InvalidateCoordinatorCache.
+func CoordinatorCacheTTL(ttl time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.coordinatorTTL = ttl }}
+}
+
+// OnTopicMetadataError sets a function to call for every topic that has an
+// error in a metadata response, such as TOPIC_AUTHORIZATION_FAILED or
+// UNKNOWN_TOPIC_OR_PARTITION. The function is called once per errored topic
+// per metadata response, and must not block.
+//
+// A topic erroring does not affect any other topic: healthy topics in the
+// same metadata response are updated as normal. Topics with retriable errors
+// cause metadata to be refreshed again soon, while topics with non-retriable
+// errors keep their last known partitions until the next regular refresh.
+// Records produced to a topic with a non-retriable error are failed with that
+// error. This option allows surfacing errors for consumed topics, which
+// otherwise are not surfaced.
+func OnTopicMetadataError(fn func(topic string, err error)) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.onTopicMetadataErr = fn }}
+}
+
 // MetadataMinAge sets the minimum time between metadata queries,
 // overriding the default 10s. You may want to raise or lower this to reduce
 // the number of metadata queries the client will make. Notably, if metadata
@@ -555,6 +1064,37 @@ func MetadataMinAge(age time.Duration) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.metadataMinAge = age }}
 }
 
+// MetadataDebounce sets the minimum time between the start of any two
+// metadata refreshes, overriding the default of no debounce.
+//
+// MetadataMinAge does not apply to urgent refreshes, such as when a consumer
+// or producer needs metadata for a partition that has moved, nor to the
+// internal retries of urgent refreshes. During incidents, many urgent
+// triggers can amplify load on brokers. With a debounce, every refresh waits
+// until the debounce has passed since the prior refresh began, and all
+// triggers that arrive while waiting are coalesced into one refresh.
+func MetadataDebounce(debounce time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.metadataDebounce = debounce }}
+}
+
+// MetadataEagerLoad sets whether NewClient loads metadata before returning,
+// overriding the default of false, which loads metadata lazily in the
+// background once the client first needs it (for example, on the first
+// produce or when consuming starts).
+//
+// With eager loading, NewClient issues one metadata refresh for all
+// configured topics and returns an error if the refresh fails, closing the
+// client. The refresh is bounded by the dial timeout plus the metadata
+// request timeout overhead (see RequestTimeout and RequestTimeoutOverhead).
+// Topic level errors, such as a topic not existing yet, do not fail NewClient;
+// they are retried in the background as usual.
+//
+// This is useful for services that should fail fast at startup if the
+// cluster is unreachable, rather than discovering it on their first request.
+func MetadataEagerLoad(eager bool) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.metadataEager = eager }}
+}
+
 // SASL appends sasl authentication options to use for all connections.
 //
 // SASL is tried in order; if the broker supports the first mechanism, all
@@ -576,6 +1116,20 @@ func WithHooks(hooks ...Hook) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.hooks = append(cfg.hooks, hooks...) }}
 }
 
+// WithFrameCodec encodes every request frame written to brokers and decodes
+// every response frame read from brokers with the given codec, overriding the
+// default of writing and reading frames as is.
+//
+// This is non-standard and only works if the other end of every connection
+// understands the codec; see the FrameCodec documentation for more details.
+//
+// Both the encoded and decoded size of a response frame are bounded by
+// BrokerMaxReadBytes, and the decoded size is what is counted against
+// MaxConcurrentReadBytes.
+func WithFrameCodec(codec FrameCodec) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.frameCodec = codec }}
+}
+
 // ********** PRODUCER CONFIGURATION **********
 
 // Acks represents the number of acks a broker leader must have before
@@ -621,6 +1175,36 @@ func DisableIdempotentWrite() ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.disableIdempotency = true }}
 }
 
+// MaxInflightProducePerPartition sets the maximum number of batches a single
+// partition can have in flight at once, overriding the default of no
+// per-partition limit (a partition can have a batch in every in flight
+// produce request to its broker).
+//
+// A limit of one means a partition's next batch is not sent until its
+// current batch is acknowledged, which trades throughput for ordering: see
+// RequireProduceOrdering. Values less than one are treated as no limit.
+func MaxInflightProducePerPartition(n int) ProducerOpt {
+	return producerOpt{func(cfg *cfg) {
+		if n < 0 {
+			n = 0
+		}
+		cfg.maxInflightPerPartition = n
+	}}
+}
+
+// RequireProduceOrdering requires that records produced to a partition are
+// written in the order they were produced, even across retries.
+//
+// The idempotent producer (the default) always guarantees ordering, so this
+// option only has an effect if idempotency is disabled. Without idempotency,
+// a failed batch that is retried can be written after a later batch that was
+// in flight at the same time. To avoid this, this option pins the
+// MaxInflightProducePerPartition to one, and client construction fails if
+// the max in flight was explicitly configured higher.
+func RequireProduceOrdering() ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.requireOrdering = true }}
+}
+
 // BatchCompression sets the compression codec to use for producing records.
 //
 // Compression is chosen in the order preferred based on broker support.
@@ -648,6 +1232,12 @@ func BatchCompression(preference ...CompressionCodec) ProducerOpt {
 // If a single record encodes larger than this number (before compression), it
 // will will not be written and a callback will have the appropriate error.
 //
+// A partition's batch is sent as soon as it reaches this size, regardless of
+// linger, while the produce request it is sent in can still aggregate batches
+// for many partitions up to ProduceRequestMaxBytes. A small batch limit thus
+// bounds per partition latency without limiting how much a fan-out producer
+// sends in one request.
+//
 // Note that this is the maximum size of a record batch before compression.
 // If a batch compresses poorly and actually grows the batch, the uncompressed
 // form will be used.
@@ -655,9 +1245,90 @@ func BatchMaxBytes(v int32) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.maxRecordBatchBytes = v }}
 }
 
+// ProduceRequestMaxBytes upper bounds the size of a produce request, which
+// contains batches for many partitions, overriding the default of
+// BrokerMaxWriteBytes.
+//
+// This limit is separate from BatchMaxBytes, which bounds each partition's
+// batch: batches are sized per partition, and then as many ready batches as
+// fit within this limit are sent together in one request. This must be at
+// least BatchMaxBytes and at most BrokerMaxWriteBytes.
+func ProduceRequestMaxBytes(v int32) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.maxProduceReqBytes = v }}
+}
+
+// ShrinkProduceRequestsOnDeadConn opts in to halving a broker's produce
+// request size limit whenever the broker kills the connection while the client
+// is producing a request of many batches. The limit is doubled back after
+// every successful response.
+//
+// Kafka closes the connection, rather than replying with an error, when a
+// request is larger than the broker's socket.request.max.bytes. If that is
+// lower than ProduceRequestMaxBytes, this option lets the client recover by
+// splitting batches across more requests. A dead connection is not evidence
+// of a size rejection on its own, though, which is why this is not the
+// default: connections also die to broker restarts or network blips, and
+// shrinking requests then only adds requests. Prefer setting
+// ProduceRequestMaxBytes to at most the broker's limit if it is known.
+func ShrinkProduceRequestsOnDeadConn() ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.shrinkProduceReqs = true }}
+}
+
+// ProduceRateLimit caps the client's total produce throughput across all
+// brokers to bytesPerSec, overriding the default of no limit. Zero disables
+// the limit.
+//
+// Produce requests are paced with a token bucket that allows up to one
+// second of burst: once the budget is spent, the next produce request to any
+// broker waits until the budget allows it before being sent. Records keep
+// buffering while a request waits, so a limit below the rate records are
+// produced at eventually causes buffering to block or records to time out,
+// as with a slow broker.
+//
+// This allows coexisting with other clients under a shared broker quota by
+// pacing client side, avoiding the latency spikes of being throttled.
+func ProduceRateLimit(bytesPerSec int64) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.produceRateLimit = bytesPerSec }}
+}
+
+// DiscardBufferBytes sets the size of the buffer used to read and discard
+// responses to acks=0 produce requests, overriding the default 256.
+//
+// Kafka never replies to acks=0 produce requests, but some Kafka compatible
+// endpoints (such as Microsoft EventHubs) do. The client reads and discards
+// these responses so that the endpoint does not block; if an endpoint replies
+// with large responses, a larger buffer avoids many small reads. This must be
+// at least 4. This has no effect unless using RequiredAcks(NoAck()).
+func DiscardBufferBytes(n int) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.discardBufBytes = n }}
+}
+
+// DiscardReadTimeout sets the read timeout for reading a discarded acks=0
+// produce response once the response size has been read, overriding the
+// default of the ProduceRequestTimeout. See DiscardBufferBytes for why
+// responses to acks=0 produce requests are read at all.
+func DiscardReadTimeout(timeout time.Duration) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.discardReadTimeout = timeout }}
+}
+
+// MaxRecordSize sets the maximum encoded size of a single record, overriding
+// the default of no limit. The size includes the record's key, value, and
+// headers, as the record would be encoded in a record batch (before
+// compression).
+//
+// Records larger than this are failed immediately with ErrRecordTooLarge
+// before being buffered, rather than being sent and rejected by the broker.
+// This is most useful when the broker's message.max.bytes is lower than
+// BatchMaxBytes, in which case an oversized record would otherwise cause the
+// broker to reject the entire batch containing it.
+func MaxRecordSize(v int32) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.maxRecordSize = v }}
+}
+
 // MaxBufferedRecords sets the max amount of records the client will buffer,
 // blocking produces until records are finished if this limit is reached.
-// This overrides the unbounded default.
+// This overrides the unbounded default. What happens when this limit is
+// reached can be changed with ProduceOverflowPolicy.
 func MaxBufferedRecords(n int) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.maxBufferedRecords = int64(n) }}
 }
@@ -668,6 +1339,24 @@ func RecordPartitioner(partitioner Partitioner) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.partitioner = partitioner }}
 }
 
+// PartitionCountAssertion sets the producer to verify, before partitioning
+// each record, that the record's topic has the number of partitions the
+// partitioner assumes, as returned from expected. If the counts differ, the
+// record is failed with an *ErrPartitionCountMismatch rather than being
+// partitioned. This catches records silently routing to different (or no
+// longer intended) partitions after a topic's partition count changes, which
+// matters for partitioners that map records to partitions assuming a fixed
+// count.
+//
+// The expected function is called for every record and must be safe for
+// concurrent use. If expected returns a non-positive number, the topic is not
+// checked. After handling a mismatch, such as by recomputing a partitioner's
+// mapping for the new count, expected can return the new count to resume
+// producing.
+func PartitionCountAssertion(expected func(topic string) int) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.expectedPartitions = expected }}
+}
+
 // ProduceRequestTimeout sets how long Kafka broker's are allowed to respond to
 // produce requests, overriding the default 30s. If a broker exceeds this
 // duration, it will reply with a request timeout error.
@@ -714,6 +1403,148 @@ func OnDataLoss(fn func(string, int32)) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.onDataLoss = fn }}
 }
 
+// UnknownTopicAction is what the client should do with records produced to a
+// topic when Kafka replies that the topic does not exist.
+type UnknownTopicAction struct {
+	action int8
+}
+
+// UnknownTopicRetry retries records as normal, failing them once the
+// configured retries (RequestRetries when loading an unknown topic,
+// ProduceRetries when producing) or RecordTimeout is reached.
+func UnknownTopicRetry() UnknownTopicAction { return UnknownTopicAction{0} }
+
+// UnknownTopicFail fails records immediately with the unknown topic error.
+func UnknownTopicFail() UnknownTopicAction { return UnknownTopicAction{1} }
+
+// UnknownTopicWait keeps records buffered in case the topic is (re)created,
+// without counting unknown topic errors against the configured retries: a
+// batch whose latest attempt failed because the topic is unknown is never
+// failed for reaching ProduceRetries. Records are still failed if a
+// RecordTimeout is configured and reached, or if their context is canceled.
+func UnknownTopicWait() UnknownTopicAction { return UnknownTopicAction{2} }
+
+// OnUnknownTopic sets a function to call whenever the client encounters
+// UNKNOWN_TOPIC_OR_PARTITION while producing to a topic, overriding the
+// default of always retrying (UnknownTopicRetry).
+//
+// This is consulted both when the client is loading metadata for a topic that
+// it has not yet produced to, and when a produce request for a topic that
+// previously existed fails because the topic was deleted. The returned action
+// controls whether records for the topic fail fast, continue retrying as
+// normal, or wait for the topic to be recreated.
+//
+// The function is called with the topic and may be called concurrently and
+// while internal locks are held; it must be fast and must not produce.
+func OnUnknownTopic(fn func(topic string) UnknownTopicAction) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.onUnknownTopic = fn }}
+}
+
+// TargetedMetadataRefresh sets the client to refresh metadata for only the
+// affected topics when producing fails with a topic or partition specific
+// error (such as NOT_LEADER_FOR_PARTITION), overriding the default of
+// refreshing metadata for every topic the client knows of.
+//
+// For clients that produce to thousands of topics, a partial failure can
+// otherwise cause many full metadata requests. Targeted refreshes still obey
+// MetadataMinAge, and the periodic MetadataMaxAge refresh is still a full
+// refresh. If a full refresh is triggered while a targeted refresh is
+// pending, the full refresh wins.
+func TargetedMetadataRefresh() ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.targetedMetadataRefresh = true }}
+}
+
+// FailFastIfNoBroker sets Produce to immediately return ErrNoReachableBrokers
+// if no broker is reachable, overriding the default of buffering records and
+// retrying until the record timeout or retry limit.
+//
+// A broker is considered unreachable once a dial to it fails, and reachable
+// again once a dial succeeds. Brokers that have not been dialed yet are
+// assumed reachable. Once brokers are discovered from metadata, seed brokers
+// are no longer considered. Records that are already buffered are not failed
+// when brokers become unreachable; this only sheds new load.
+func FailFastIfNoBroker() ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.failFastIfNoBroker = true }}
+}
+
+// OverflowPolicy is what Produce does when MaxBufferedRecords are already
+// buffered.
+type OverflowPolicy struct {
+	policy int8
+}
+
+// OverflowBlock blocks Produce until a buffered record finishes, or until
+// the produce context or client is canceled. This is the default. With
+// ManualFlushing, this instead returns ErrMaxBuffered.
+func OverflowBlock() OverflowPolicy { return OverflowPolicy{0} }
+
+// OverflowDropOldest drops the oldest batch of records that has not yet been
+// sent to Kafka to make room for the new record. Batches that have been sent
+// once cannot be dropped, so if every buffered record is in flight (or is
+// waiting on an unknown topic to load), Produce blocks as with OverflowBlock.
+//
+// Records are dropped a batch at a time, so one overflowing Produce may drop
+// many records.
+func OverflowDropOldest() OverflowPolicy { return OverflowPolicy{1} }
+
+// OverflowDropNewest drops the record being produced, finishing its promise
+// with ErrRecordDropped, and Produce returns nil.
+func OverflowDropNewest() OverflowPolicy { return OverflowPolicy{2} }
+
+// OverflowError returns ErrMaxBuffered from Produce without buffering the
+// record.
+func OverflowError() OverflowPolicy { return OverflowPolicy{3} }
+
+// ProduceOverflowPolicy sets what Produce does when MaxBufferedRecords are
+// already buffered, overriding the default of blocking (OverflowBlock). This
+// is useful for telemetry-like topics where freshness matters more than
+// completeness, and a producer must never block.
+//
+// Dropped records have their promise called with ErrRecordDropped, and if
+// onDropped is non-nil, it is called with each dropped record before the
+// promise. onDropped can be called concurrently, but is never called while
+// internal locks are held. Because it may be called from within Produce, it
+// must not produce.
+func ProduceOverflowPolicy(policy OverflowPolicy, onDropped func(*Record)) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.overflowPolicy, cfg.onDropped = policy, onDropped }}
+}
+
+// MinInSyncReplicas sets the client to check the number of in sync replicas of
+// a partition whenever records are successfully produced to it, warning (or
+// failing the records, if fail is true) when fewer than min replicas are in
+// sync. Failed records have their promise called with *ErrUnderReplicated,
+// even though Kafka did accept the records; only the records in the under
+// replicated batch fail, and later batches to the partition are unaffected.
+//
+// Produce responses do not include the in sync replica count, so the client
+// uses the count from its latest metadata update. Under replicated
+// partitions usually have retriable produce errors that cause the client to
+// refresh metadata, but the count may still be stale by up to
+// MetadataMaxAge. This is best used alongside acks=all and the topic's
+// min.insync.replicas, which the broker enforces itself, to detect writes
+// that succeeded with less redundancy than you want.
+func MinInSyncReplicas(min int, fail bool) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.minISR, cfg.minISRFail = int32(min), fail }}
+}
+
+// errorAction returns the ErrorPolicy action for a response error code,
+// defaulting to the client's normal handling if there is no policy.
+func (cfg *cfg) errorAction(key, code int16) ErrorAction {
+	if cfg.errorPolicy == nil || code == 0 {
+		return ErrorDefault()
+	}
+	return cfg.errorPolicy(key, code)
+}
+
+// unknownTopicAction returns what to do for a topic that Kafka replied is
+// unknown, defaulting to retrying.
+func (cfg *cfg) unknownTopicAction(topic string) UnknownTopicAction {
+	if cfg.onUnknownTopic == nil {
+		return UnknownTopicRetry()
+	}
+	return cfg.onUnknownTopic(topic)
+}
+
 // Linger sets how long individual topic partitions will linger
 // waiting for more records before triggering a request to be built.
 //
@@ -875,6 +1706,28 @@ func AllowedConcurrentFetches(n int) ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.allowedConcurrentFetches = n }}
 }
 
+// AdaptiveFetchMaxBytes opts in to the client adjusting the fetch max bytes
+// (see FetchMaxBytes) on its own, between the given min and max, overriding
+// the default of always using the configured fetch max bytes.
+//
+// The fetch max bytes is grown when fetch responses consistently come back
+// nearly full, meaning the consumer could consume more per fetch, and it is
+// shrunk when fetch responses come back mostly empty or when buffered fetches
+// wait longer than FetchMaxWait to be polled, meaning processing is lagging.
+// The fetch max bytes begins at FetchMaxBytes, bounded to the given range.
+//
+// Using SetFetchMaxBytes while adaptive sizing is enabled sets the current
+// value, which is then adjusted from as usual.
+func AdaptiveFetchMaxBytes(min, max int32) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) {
+		if min < 1 {
+			min = 1
+		}
+		cfg.adaptiveFetchMinBytes = min
+		cfg.adaptiveFetchMaxBytes = max
+	}}
+}
+
 // ConsumeResetOffset sets the offset to restart consuming from when a
 // partition has no commits (for groups) or when a fetch sees an
 // OffsetOutOfRange error, overriding the default ConsumeStartOffset.
@@ -882,6 +1735,23 @@ func ConsumeResetOffset(offset Offset) ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.resetOffset = offset }}
 }
 
+// OnOffsetOutOfRange sets a function to decide where to resume consuming when
+// a fetch sees an OffsetOutOfRange error, overriding the ConsumeResetOffset
+// for that case. This is most commonly hit when a lagging group's committed
+// offset has been deleted by retention.
+//
+// The function is passed the topic, partition, the offset the client tried
+// to consume (for example, the committed offset), and the partition's current
+// log start offset and log end offset, and returns the offset to resume at.
+// The returned offset is bounded to within the log start and end offsets.
+//
+// Using this option requires an additional ListOffsets request to load both
+// the start and end of the partition whenever an offset is out of range.
+// ConsumeResetOffset still applies when a group has no committed offsets.
+func OnOffsetOutOfRange(fn func(topic string, partition int32, committed, logStart, logEnd int64) int64) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.onOffsetOutOfRange = fn }}
+}
+
 // Rack specifies where the client is physically located and changes fetch
 // requests to consume from the closest replica as opposed to the leader
 // replica.
@@ -914,7 +1784,11 @@ func FetchIsolationLevel(level IsolationLevel) ConsumerOpt {
 // KeepControlRecords sets the client to keep control messages and return
 // them with fetches, overriding the default that discards them.
 //
-// Generally, control messages are not useful.
+// Generally, control messages are not useful, but they can be used to trace
+// transaction boundaries in a topic: Record.ControlType returns whether a
+// marker commits or aborts a transaction, and the record's ProducerID and
+// ProducerEpoch identify the transaction. Markers ending aborted transactions
+// are kept even when reading committed.
 func KeepControlRecords() ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.keepControl = true }}
 }
@@ -0,0 +1,227 @@
+package kgo
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"github.com/twmb/franz-go/pkg/kversion"
+)
+
+// ConfigSnapshot is a read-only snapshot of a client's effective
+// configuration, after defaults and options are applied, as returned from
+// Client.Opts.
+//
+// Only plain values are included: functions, hooks, dialers, and loggers are
+// not, other than whether TLS is in use. SASL mechanisms are included by name
+// only and SOCKS5 proxies by address only, so no credentials are ever
+// exposed.
+type ConfigSnapshot struct {
+	// ***GENERAL SECTION***
+
+	ClientID                string                  // ClientID is the client ID sent in requests, including any ClientHost, if any.
+	SeedBrokers             []string                // SeedBrokers are the configured seed brokers.
+	DialTimeout             time.Duration           // DialTimeout is the timeout for dialing brokers.
+	DNSCacheTTL             time.Duration           // DNSCacheTTL is how long resolved broker addresses are cached, or 0 if not cached.
+	DialFallbackDelay       time.Duration           // DialFallbackDelay is the delay between staggered dials to a broker's addresses, or 0 for the default.
+	SOCKS5Proxy             string                  // SOCKS5Proxy is the address of the SOCKS5 proxy brokers are dialed through, if any.
+	SharedConnPool          bool                    // SharedConnPool is whether connections come from a shared ConnPool.
+	TLS                     bool                    // TLS is whether a TLS config is set, or whether any connection dialed so far (for example, through a Dialer or shared pool) is TLS.
+	ConnCloseLinger         int                     // ConnCloseLinger is the SO_LINGER seconds set on connections, or -1 if unset.
+	BrokerDrainCooldown     time.Duration           // BrokerDrainCooldown is how long a shutting down broker is avoided, or 0 if disabled.
+	BrokerCircuitMaxFails   int                     // BrokerCircuitMaxFails is the consecutive dial failures that open a broker's circuit, or 0 if disabled.
+	BrokerCircuitResetAfter time.Duration           // BrokerCircuitResetAfter is how long a broker's circuit stays open.
+	ConnIdleTimeout         time.Duration           // ConnIdleTimeout is how long a connection can be idle before it is reaped.
+	StuckRequestThreshold   time.Duration           // StuckRequestThreshold is how long a request can be in flight before it is logged as stuck, or 0 if disabled.
+	RequestOverhead         time.Duration           // RequestOverhead is the read and write timeout overhead on every request.
+	RequestTimeouts         map[int16]time.Duration // RequestTimeouts are the per request key overrides of RequestOverhead, from RequestTimeout.
+	SoftwareName            string                  // SoftwareName is the client software name sent in ApiVersions (KIP-511).
+	SoftwareVersion         string                  // SoftwareVersion is the client software version sent in ApiVersions (KIP-511).
+	MaxVersions             *kversion.Versions      // MaxVersions is a copy of the max request versions, or nil if unpinned.
+	MinVersions             *kversion.Versions      // MinVersions is a copy of the min request versions, or nil if unset.
+	RequestRetries          int64                   // RequestRetries is how many times retriable requests are retried.
+	BrokerConnDeadRetries   int                     // BrokerConnDeadRetries is how many times a request is retried on dead connections.
+	BrokerMaxWrite          int32                   // BrokerMaxWrite is the max bytes that can be written to a broker in one request.
+	BrokerMaxRead           int32                   // BrokerMaxRead is the max bytes that can be read from a broker in one response.
+	MaxConcurrentRead       int64                   // MaxConcurrentRead is the max bytes of large responses read at once, or 0 for unbounded.
+	RecentErrorsLimit       int                     // RecentErrorsLimit is how many recent errors are kept, or 0 if disabled.
+	PooledResponseBuffers   bool                    // PooledResponseBuffers is whether response buffers are pooled.
+	AutoTopicCreation       bool                    // AutoTopicCreation is whether metadata requests allow auto topic creation.
+	MetadataMaxAge          time.Duration           // MetadataMaxAge is the max age of metadata before it is refreshed.
+	MetadataMinAge          time.Duration           // MetadataMinAge is the min age of metadata before it can be refreshed.
+	MetadataDebounce        time.Duration           // MetadataDebounce is how long metadata refresh triggers are coalesced, or 0 if disabled.
+	MetadataEagerLoad       bool                    // MetadataEagerLoad is whether NewClient loads metadata before returning.
+	CoordinatorCacheTTL     time.Duration           // CoordinatorCacheTTL is how long group and transaction coordinators are cached, or 0 for no expiry.
+	SASLMechanisms          []string                // SASLMechanisms are the names of the configured SASL mechanisms, in order.
+
+	// ***PRODUCER SECTION***
+
+	TransactionalID         string             // TransactionalID is the transactional ID, if any.
+	TransactionTimeout      time.Duration      // TransactionTimeout is the transaction timeout.
+	RequiredAcks            int16              // RequiredAcks is the acks: 0 (none), 1 (leader), or -1 (all).
+	Idempotent              bool               // Idempotent is whether idempotent writes are enabled.
+	MaxInflightPerPartition int                // MaxInflightPerPartition is the max in flight batches per partition, or 0 for no limit.
+	RequireOrdering         bool               // RequireOrdering is whether producing requires strict ordering.
+	Compression             []CompressionCodec // Compression is the compression codecs, in order of preference.
+	BatchMaxBytes           int32              // BatchMaxBytes is the max size of a record batch.
+	ProduceRequestMaxBytes  int32              // ProduceRequestMaxBytes is the max size of a produce request, or 0 for BrokerMaxWrite.
+	ShrinkProduceRequests   bool               // ShrinkProduceRequests is whether produce requests shrink when connections die producing them.
+	ProduceRateLimit        int64              // ProduceRateLimit is the max bytes produced per second, or 0 for unlimited.
+	DiscardBufferBytes      int                // DiscardBufferBytes is the buffer size for discarding no-ack responses.
+	DiscardReadTimeout      time.Duration      // DiscardReadTimeout is the read timeout for discarding no-ack responses, or 0 for ProduceRequestTimeout.
+	MaxRecordSize           int32              // MaxRecordSize is the max size of a single record, or 0 for no limit.
+	MaxBufferedRecords      int64              // MaxBufferedRecords is the max number of buffered records.
+	OverflowPolicy          OverflowPolicy     // OverflowPolicy is what happens when producing with a full buffer.
+	ProduceRequestTimeout   time.Duration      // ProduceRequestTimeout is the broker side produce timeout.
+	RecordRetries           int64              // RecordRetries is how many times a record can be retried.
+	StopOnDataLoss          bool               // StopOnDataLoss is whether producing stops on data loss.
+	TargetedMetadataRefresh bool               // TargetedMetadataRefresh is whether produce errors refresh only the affected topics.
+	FailFastIfNoBroker      bool               // FailFastIfNoBroker is whether records fail immediately if no broker is reachable.
+	MinInSyncReplicas       int32              // MinInSyncReplicas is the min ISR required to produce to a partition, or 0 if unchecked.
+	MinInSyncReplicasFail   bool               // MinInSyncReplicasFail is whether records produced under MinInSyncReplicas fail with ErrUnderReplicated.
+	Linger                  time.Duration      // Linger is how long to linger partitions for more records.
+	RecordDeliveryTimeout   time.Duration      // RecordDeliveryTimeout is how long a record can wait to be produced, or 0 for no limit.
+	ManualFlushing          bool               // ManualFlushing is whether records are only sent on Flush.
+
+	// ***CONSUMER SECTION***
+
+	FetchMaxWait           time.Duration // FetchMaxWait is how long a broker can wait to fill a fetch, including any SetFetchMaxWait.
+	FetchMinBytes          int32         // FetchMinBytes is the min bytes a broker waits for in a fetch, including any SetFetchMinBytes.
+	FetchMaxBytes          int32         // FetchMaxBytes is the max bytes in a fetch response, including any SetFetchMaxBytes or adaptive resizing.
+	FetchMaxPartitionBytes int32         // FetchMaxPartitionBytes is the max bytes per partition in a fetch response, including any SetFetchPartitionMaxBytes.
+	AdaptiveFetchMinBytes  int32         // AdaptiveFetchMinBytes is the lower bound of adaptive FetchMaxBytes, or 0 if disabled.
+	AdaptiveFetchMaxBytes  int32         // AdaptiveFetchMaxBytes is the upper bound of adaptive FetchMaxBytes, or 0 if disabled.
+	ResetOffset            Offset        // ResetOffset is where consuming starts or resets to.
+	ReadCommitted          bool          // ReadCommitted is whether only committed transactional records are consumed.
+	KeepControlRecords     bool          // KeepControlRecords is whether control records are returned from polls.
+	Rack                   string        // Rack is the client's rack for fetching from the closest replica (KIP-392).
+	MaxConcurrentFetches   int           // MaxConcurrentFetches is the max number of concurrent fetches, or 0 for unlimited.
+	Group                  string        // Group is the group being consumed in, if any.
+}
+
+// Opts returns a snapshot of the client's effective configuration, for
+// diagnostics and for confirming that options were applied as intended.
+// Modifying the snapshot does not modify the client.
+//
+// Fetch sizes and waits reflect any changes made since the client was
+// created, through the SetFetch functions or adaptive fetch sizing.
+func (cl *Client) Opts() ConfigSnapshot {
+	s := cl.cfg.snapshot()
+	if atomic.LoadUint32(&cl.dialedTLS) == 1 ||
+		cl.cfg.connPool != nil && atomic.LoadUint32(&cl.cfg.connPool.dialedTLS) == 1 {
+		s.TLS = true
+	}
+	s.FetchMaxWait = time.Duration(atomic.LoadInt32(&cl.consumer.maxWait)) * time.Millisecond
+	s.FetchMinBytes = atomic.LoadInt32(&cl.consumer.minBytes)
+	s.FetchMaxBytes = atomic.LoadInt32(&cl.consumer.maxBytes)
+	s.FetchMaxPartitionBytes = atomic.LoadInt32(&cl.consumer.maxPartBytes)
+	if g, ok := cl.consumer.loadGroup(); ok {
+		s.Group = g.id
+	}
+	return s
+}
+
+// snapshot returns the snapshot of the configuration alone, without any
+// state that changes while the client runs.
+func (cfg *cfg) snapshot() ConfigSnapshot {
+	s := ConfigSnapshot{
+		SeedBrokers:             append([]string(nil), cfg.seedBrokers...),
+		DialTimeout:             cfg.dialTimeout,
+		DNSCacheTTL:             cfg.dnsCacheTTL,
+		DialFallbackDelay:       cfg.dialFallbackDelay,
+		SharedConnPool:          cfg.connPool != nil,
+		TLS:                     cfg.tlsCfgFn != nil,
+		ConnCloseLinger:         cfg.connCloseLinger,
+		BrokerDrainCooldown:     cfg.drainCooldown,
+		BrokerCircuitMaxFails:   cfg.circuitMaxFails,
+		BrokerCircuitResetAfter: cfg.circuitResetAfter,
+		ConnIdleTimeout:         cfg.connIdleTimeout,
+		StuckRequestThreshold:   cfg.stuckThreshold,
+		RequestOverhead:         cfg.connTimeoutOverhead,
+		SoftwareName:            cfg.softwareName,
+		SoftwareVersion:         cfg.softwareVersion,
+		MaxVersions:             copyVersions(cfg.maxVersions),
+		MinVersions:             copyVersions(cfg.minVersions),
+		RequestRetries:          cfg.retries,
+		BrokerConnDeadRetries:   cfg.brokerConnDeadRetries,
+		BrokerMaxWrite:          cfg.maxBrokerWriteBytes,
+		BrokerMaxRead:           cfg.maxBrokerReadBytes,
+		MaxConcurrentRead:       cfg.maxConcurrentReadBytes,
+		RecentErrorsLimit:       cfg.recentErrorsLimit,
+		PooledResponseBuffers:   cfg.pooledRespBufs,
+		AutoTopicCreation:       cfg.allowAutoTopicCreation,
+		MetadataMaxAge:          cfg.metadataMaxAge,
+		MetadataMinAge:          cfg.metadataMinAge,
+		MetadataDebounce:        cfg.metadataDebounce,
+		MetadataEagerLoad:       cfg.metadataEager,
+		CoordinatorCacheTTL:     cfg.coordinatorTTL,
+
+		TransactionTimeout:      cfg.txnTimeout,
+		RequiredAcks:            cfg.acks.val,
+		Idempotent:              !cfg.disableIdempotency,
+		MaxInflightPerPartition: cfg.maxInflightPerPartition,
+		RequireOrdering:         cfg.requireOrdering,
+		Compression:             append([]CompressionCodec(nil), cfg.compression...),
+		BatchMaxBytes:           cfg.maxRecordBatchBytes,
+		ProduceRequestMaxBytes:  cfg.maxProduceReqBytes,
+		ShrinkProduceRequests:   cfg.shrinkProduceReqs,
+		ProduceRateLimit:        cfg.produceRateLimit,
+		DiscardBufferBytes:      cfg.discardBufBytes,
+		DiscardReadTimeout:      cfg.discardReadTimeout,
+		MaxRecordSize:           cfg.maxRecordSize,
+		MaxBufferedRecords:      cfg.maxBufferedRecords,
+		OverflowPolicy:          cfg.overflowPolicy,
+		ProduceRequestTimeout:   cfg.produceTimeout,
+		RecordRetries:           cfg.produceRetries,
+		StopOnDataLoss:          cfg.stopOnDataLoss,
+		TargetedMetadataRefresh: cfg.targetedMetadataRefresh,
+		FailFastIfNoBroker:      cfg.failFastIfNoBroker,
+		MinInSyncReplicas:       cfg.minISR,
+		MinInSyncReplicasFail:   cfg.minISRFail,
+		Linger:                  cfg.linger,
+		RecordDeliveryTimeout:   cfg.recordTimeout,
+		ManualFlushing:          cfg.manualFlushing,
+
+		FetchMaxWait:           time.Duration(cfg.maxWait) * time.Millisecond,
+		FetchMinBytes:          cfg.minBytes,
+		FetchMaxBytes:          cfg.maxBytes,
+		FetchMaxPartitionBytes: cfg.maxPartBytes,
+		AdaptiveFetchMinBytes:  cfg.adaptiveFetchMinBytes,
+		AdaptiveFetchMaxBytes:  cfg.adaptiveFetchMaxBytes,
+		ResetOffset:            cfg.resetOffset,
+		ReadCommitted:          cfg.isolationLevel == 1,
+		KeepControlRecords:     cfg.keepControl,
+		Rack:                   cfg.rack,
+		MaxConcurrentFetches:   cfg.allowedConcurrentFetches,
+	}
+	if cfg.id != nil {
+		s.ClientID = *cfg.id
+	}
+	if cfg.socks5 != nil {
+		s.SOCKS5Proxy = cfg.socks5.proxyAddr
+	}
+	for key := int16(0); key <= kmsg.MaxKey; key++ {
+		if timeout := cfg.keyTimeoutOverhead(key); timeout != cfg.connTimeoutOverhead {
+			if s.RequestTimeouts == nil {
+				s.RequestTimeouts = make(map[int16]time.Duration)
+			}
+			s.RequestTimeouts[key] = timeout
+		}
+	}
+	for _, mechanism := range cfg.sasls {
+		s.SASLMechanisms = append(s.SASLMechanisms, mechanism.Name())
+	}
+	if cfg.txnID != nil {
+		s.TransactionalID = *cfg.txnID
+	}
+	return s
+}
+
+func copyVersions(vs *kversion.Versions) *kversion.Versions {
+	if vs == nil {
+		return nil
+	}
+	cp := new(kversion.Versions)
+	vs.EachMaxKeyVersion(cp.SetMaxKeyVersion)
+	return cp
+}
@@ -0,0 +1,180 @@
+package kgo
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"github.com/twmb/franz-go/pkg/sasl"
+)
+
+// snapshotExcluded are cfg fields that are deliberately not part of
+// ConfigSnapshot: functions and interfaces that have no plain value.
+var snapshotExcluded = map[string]bool{
+	"clientHost": true, // folded into id when validating
+
+	"dialFn":              true,
+	"onEmptyAPIVersions":  true,
+	"clock":               true,
+	"logger":              true,
+	"retryBackoff":        true,
+	"retryTimeout":        true,
+	"errorPolicy":         true,
+	"onTopicMetadataErr":  true,
+	"hooks":               true,
+	"newRequestScheduler": true,
+	"faultInjector":       true,
+	"frameCodec":          true,
+	"partitioner":         true,
+	"expectedPartitions":  true,
+	"onDataLoss":          true,
+	"onUnknownTopic":      true,
+	"onDropped":           true,
+	"onOffsetOutOfRange":  true,
+}
+
+type snapshotTestMechanism struct{}
+
+func (snapshotTestMechanism) Name() string { return "TEST" }
+func (snapshotTestMechanism) Authenticate(context.Context, string) (sasl.Session, []byte, error) {
+	return nil, nil, nil
+}
+
+// settable returns v such that it can be set even if unexported.
+func settable(v reflect.Value) reflect.Value {
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+// mutate changes v to a different value.
+func mutate(t *testing.T, name string, v reflect.Value) {
+	v = settable(v)
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(!v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(v.Int() + 1)
+	case reflect.String:
+		v.SetString(v.String() + "x")
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if !v.IsNil() {
+			p.Elem().Set(v.Elem())
+		}
+		mutate(t, name, p.Elem())
+		v.Set(p)
+	case reflect.Slice:
+		v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		e := reflect.New(v.Type().Elem()).Elem()
+		mutate(t, name, e)
+		v.SetMapIndex(reflect.Zero(v.Type().Key()), e)
+	case reflect.Struct:
+		mutate(t, name, v.Field(0))
+	case reflect.Func:
+		v.Set(reflect.MakeFunc(v.Type(), func(args []reflect.Value) []reflect.Value {
+			var rets []reflect.Value
+			for i := 0; i < v.Type().NumOut(); i++ {
+				rets = append(rets, reflect.Zero(v.Type().Out(i)))
+			}
+			return rets
+		}))
+	default:
+		t.Fatalf("cfg field %s: unable to mutate kind %v; add it to snapshotExcluded or to the special cases", name, v.Kind())
+	}
+}
+
+// TestConfigSnapshotComplete fails if a cfg field, and thus an option, is not
+// reflected in ConfigSnapshot.
+func TestConfigSnapshotComplete(t *testing.T) {
+	base := defaultCfg()
+	baseSnap := base.snapshot()
+
+	typ := reflect.TypeOf(base)
+	for name := range snapshotExcluded {
+		if _, ok := typ.FieldByName(name); !ok {
+			t.Errorf("excluded field %s no longer exists in cfg", name)
+		}
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		name := typ.Field(i).Name
+		if snapshotExcluded[name] {
+			continue
+		}
+		c := defaultCfg()
+		f := reflect.ValueOf(&c).Elem().Field(i)
+		switch name {
+		case "sasls":
+			c.sasls = append(c.sasls, snapshotTestMechanism{})
+		case "requestTimeout":
+			c.requestTimeout = func(int16) time.Duration { return time.Second }
+		default:
+			mutate(t, name, f)
+		}
+		if reflect.DeepEqual(c.snapshot(), baseSnap) {
+			t.Errorf("cfg field %s is not reflected in ConfigSnapshot; add it, or add it to snapshotExcluded if it has no plain value", name)
+		}
+	}
+}
+
+func TestOptsRuntime(t *testing.T) {
+	// TLS configured through a Dialer is detected once a connection is
+	// dialed; the handshake itself fails, since we close every connection.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	cl, err := NewClient(
+		SeedBrokers(ln.Addr().String()),
+		Dialer(func(ctx context.Context, network, host string) (net.Conn, error) {
+			conn, err := new(net.Dialer).DialContext(ctx, network, host)
+			if err != nil {
+				return nil, err
+			}
+			return tls.Client(conn, &tls.Config{InsecureSkipVerify: true}), nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if cl.Opts().TLS {
+		t.Error("TLS unexpectedly true before any dial")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	cl.Request(ctx, kmsg.NewPtrMetadataRequest())
+	if !cl.Opts().TLS {
+		t.Error("TLS not detected after dialing through a TLS Dialer")
+	}
+
+	cl.SetFetchMaxBytes(1 << 20)
+	cl.SetFetchPartitionMaxBytes(1 << 19)
+	cl.SetFetchMinBytes(10)
+	cl.SetFetchMaxWait(time.Second)
+	s := cl.Opts()
+	if s.FetchMaxBytes != 1<<20 || s.FetchMaxPartitionBytes != 1<<19 || s.FetchMinBytes != 10 || s.FetchMaxWait != time.Second {
+		t.Errorf("fetch options not reflected after SetFetch*: got max %d, partition max %d, min %d, wait %v",
+			s.FetchMaxBytes, s.FetchMaxPartitionBytes, s.FetchMinBytes, s.FetchMaxWait)
+	}
+}
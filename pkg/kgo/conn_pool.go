@@ -0,0 +1,274 @@
+package kgo
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// ConnPool is a pool of broker connections that can be shared across
+// multiple clients talking to the same cluster; see SharedConnPool.
+//
+// Each client using the pool still believes it has its own connections: the
+// pool hands every client dial one end of an in memory pipe and multiplexes
+// all pipes for the same broker address onto one real connection. Requests
+// written to a pipe have their correlation ID rewritten to an ID unique
+// across the real connection, and responses are routed back to the pipe
+// that issued the request with the original correlation ID restored. This
+// keeps correlation IDs namespaced per client while cutting the number of
+// connections a broker sees (and counts against max.connections) down to one
+// per pool, rather than one per client.
+//
+// A real connection is closed once every client connection multiplexed onto
+// it is closed, or if reading or writing it fails, in which case all client
+// connections on it are closed as well.
+//
+// Because one real connection serves every client, a client that stops
+// reading responses without closing its connection stalls responses for
+// all clients. Clients always read until they close their connections, so
+// this only matters if a client is stuck processing a response.
+type ConnPool struct {
+	dialFn func(context.Context, string, string) (net.Conn, error)
+
+	mu    sync.Mutex
+	conns map[string]*pooledConn
+
+	dialedTLS uint32 // atomic; 1 once any dialed connection is TLS, for Opts
+}
+
+// NewConnPool returns a new connection pool that dials brokers with dialFn,
+// or with a default net.Dialer if dialFn is nil. The dial function is used
+// in place of any Dialer set on the clients using the pool, so any TLS
+// configuration must be done here.
+func NewConnPool(dialFn func(ctx context.Context, network, host string) (net.Conn, error)) *ConnPool {
+	if dialFn == nil {
+		dialFn = new(net.Dialer).DialContext
+	}
+	return &ConnPool{
+		dialFn: dialFn,
+		conns:  make(map[string]*pooledConn),
+	}
+}
+
+// pooledConn is one real connection to a broker shared by many pipes.
+type pooledConn struct {
+	pool *ConnPool
+	addr string
+
+	dialed  chan struct{} // closed once the dial finishes
+	dialErr error
+	conn    net.Conn
+
+	wmu sync.Mutex // serializes writes to conn
+
+	mu      sync.Mutex
+	dead    bool
+	refs    int
+	nextID  int32
+	pending map[int32]pendingResp
+	pipes   map[net.Conn]struct{}
+}
+
+type pendingResp struct {
+	pipe   net.Conn
+	corrID int32
+}
+
+// dial returns a new client connection multiplexed onto the pool's real
+// connection for addr, dialing the real connection if necessary.
+func (p *ConnPool) dial(ctx context.Context, addr string) (net.Conn, error) {
+	for {
+		p.mu.Lock()
+		pc := p.conns[addr]
+		dialer := pc == nil
+		if dialer {
+			pc = &pooledConn{
+				pool:    p,
+				addr:    addr,
+				dialed:  make(chan struct{}),
+				pending: make(map[int32]pendingResp),
+				pipes:   make(map[net.Conn]struct{}),
+			}
+			p.conns[addr] = pc
+		}
+		p.mu.Unlock()
+
+		if dialer {
+			pc.conn, pc.dialErr = p.dialFn(ctx, "tcp", addr)
+			if pc.dialErr != nil {
+				p.remove(pc)
+			} else {
+				if isTLSConn(pc.conn) {
+					atomic.StoreUint32(&p.dialedTLS, 1)
+				}
+				go pc.readLoop()
+			}
+			close(pc.dialed)
+		} else {
+			select {
+			case <-pc.dialed:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if pc.dialErr != nil {
+			return nil, pc.dialErr
+		}
+
+		client, server := net.Pipe()
+		pc.mu.Lock()
+		if pc.dead {
+			// The real connection died between dialing and now;
+			// loop to dial a new one.
+			pc.mu.Unlock()
+			client.Close()
+			server.Close()
+			continue
+		}
+		pc.refs++
+		pc.pipes[server] = struct{}{}
+		pc.mu.Unlock()
+
+		go pc.writeLoop(server)
+		return client, nil
+	}
+}
+
+func (p *ConnPool) remove(pc *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conns[pc.addr] == pc {
+		delete(p.conns, pc.addr)
+	}
+}
+
+// kill closes the real connection and every pipe on it.
+func (pc *pooledConn) kill() {
+	pc.mu.Lock()
+	if pc.dead {
+		pc.mu.Unlock()
+		return
+	}
+	pc.dead = true
+	pipes := pc.pipes
+	pc.pipes = nil
+	pc.pending = nil
+	pc.mu.Unlock()
+
+	pc.pool.remove(pc)
+	pc.conn.Close()
+	for pipe := range pipes {
+		pipe.Close()
+	}
+}
+
+// release drops a pipe from the connection, killing the real connection if
+// no pipes remain.
+func (pc *pooledConn) release(pipe net.Conn) {
+	pipe.Close()
+	pc.mu.Lock()
+	if pc.dead {
+		pc.mu.Unlock()
+		return
+	}
+	delete(pc.pipes, pipe)
+	pc.refs--
+	last := pc.refs == 0
+	pc.mu.Unlock()
+	if last {
+		pc.kill()
+	}
+}
+
+// readFrame reads one size prefixed frame from r, returning the frame
+// including the size prefix.
+func readFrame(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := int32(binary.BigEndian.Uint32(size[:]))
+	if n < 4 {
+		return nil, errors.New("invalid frame size")
+	}
+	frame := make([]byte, 4+int(n))
+	copy(frame, size[:])
+	if _, err := io.ReadFull(r, frame[4:]); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// writeLoop forwards requests a client writes to its pipe onto the real
+// connection, rewriting each request's correlation ID.
+func (pc *pooledConn) writeLoop(pipe net.Conn) {
+	defer pc.release(pipe)
+	for {
+		frame, err := readFrame(pipe)
+		if err != nil {
+			return
+		}
+		// Request frames are size, key, version, correlation ID.
+		if len(frame) < 12 {
+			return
+		}
+		corrID := int32(binary.BigEndian.Uint32(frame[8:]))
+
+		pc.mu.Lock()
+		if pc.dead {
+			pc.mu.Unlock()
+			return
+		}
+		id := pc.nextID
+		pc.nextID++
+		pc.pending[id] = pendingResp{pipe, corrID}
+		pc.mu.Unlock()
+
+		binary.BigEndian.PutUint32(frame[8:], uint32(id))
+
+		pc.wmu.Lock()
+		_, err = pc.conn.Write(frame)
+		pc.wmu.Unlock()
+		if err != nil {
+			pc.kill()
+			return
+		}
+	}
+}
+
+// readLoop routes responses from the real connection back to the pipes that
+// issued the corresponding requests.
+func (pc *pooledConn) readLoop() {
+	defer pc.kill()
+	for {
+		frame, err := readFrame(pc.conn)
+		if err != nil {
+			return
+		}
+		// Response frames are size, correlation ID.
+		id := int32(binary.BigEndian.Uint32(frame[4:]))
+
+		pc.mu.Lock()
+		if pc.dead {
+			pc.mu.Unlock()
+			return
+		}
+		pending, ok := pc.pending[id]
+		delete(pc.pending, id)
+		pc.mu.Unlock()
+
+		if !ok {
+			return // the broker replied to something we did not send
+		}
+
+		binary.BigEndian.PutUint32(frame[4:], uint32(pending.corrID))
+
+		// If the client closed its pipe, the write fails and we drop
+		// the response.
+		pending.pipe.Write(frame)
+	}
+}
@@ -0,0 +1,94 @@
+package kgo
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestConnPoolMultiplexes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	var accepted int
+	var acceptedMu sync.Mutex
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			acceptedMu.Lock()
+			accepted++
+			acceptedMu.Unlock()
+			go func() {
+				defer conn.Close()
+				for {
+					req, err := readFrame(conn)
+					if err != nil {
+						return
+					}
+					// Reply with the correlation ID followed by
+					// the request key, version, and body.
+					resp := make([]byte, 8, len(req))
+					binary.BigEndian.PutUint32(resp[4:], binary.BigEndian.Uint32(req[8:]))
+					resp = append(resp, req[4:8]...)
+					resp = append(resp, req[12:]...)
+					binary.BigEndian.PutUint32(resp, uint32(len(resp)-4))
+					if _, err := conn.Write(resp); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	pool := NewConnPool(nil)
+	addr := ln.Addr().String()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		conn, err := pool.dial(context.Background(), addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(i int, conn net.Conn) {
+			defer wg.Done()
+			defer conn.Close()
+			for corrID := int32(0); corrID < 50; corrID++ {
+				req := make([]byte, 13)
+				binary.BigEndian.PutUint32(req, 9)
+				binary.BigEndian.PutUint32(req[8:], uint32(corrID))
+				req[12] = byte(i)
+				if _, err := conn.Write(req); err != nil {
+					t.Errorf("conn %d: write: %v", i, err)
+					return
+				}
+				resp, err := readFrame(conn)
+				if err != nil {
+					t.Errorf("conn %d: read: %v", i, err)
+					return
+				}
+				if got := int32(binary.BigEndian.Uint32(resp[4:])); got != corrID {
+					t.Errorf("conn %d: got correlation ID %d != exp %d", i, got, corrID)
+				}
+				if got := resp[len(resp)-1]; got != byte(i) {
+					t.Errorf("conn %d: got response for conn %d", i, got)
+				}
+			}
+		}(i, conn)
+	}
+	wg.Wait()
+
+	acceptedMu.Lock()
+	defer acceptedMu.Unlock()
+	if accepted != 1 {
+		t.Errorf("got %d real connections != exp 1", accepted)
+	}
+}
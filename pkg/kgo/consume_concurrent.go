@@ -0,0 +1,296 @@
+package kgo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ConsumeConcurrent joins group and consumes until ctx is done, calling
+// handler for every record with one goroutine per partition: records in a
+// partition are handled in order, while partitions are handled concurrently.
+//
+// Offsets are committed only for records handler has returned for. This
+// disables the group's normal autocommitting; instead, processed offsets are
+// committed every AutoCommitInterval (default 5s), on revoke, and once more
+// when ctx is done. When partitions are revoked, the revoked partitions'
+// goroutines are drained (all records already polled for them are handled)
+// and their offsets are committed before the rebalance continues. When
+// partitions are lost, their goroutines are drained but nothing is committed.
+// Any OnAssigned, OnRevoked, or OnLost passed in opts are called after this
+// function's own handling; the default revoke commit is not used.
+//
+// Because handling is asynchronous to polling, handler must not take long
+// relative to the group's RebalanceTimeout: a revoke waits for the revoked
+// partitions' pending records to be handled.
+//
+// This returns ctx.Err() once ctx is done, after all goroutines have been
+// drained and a final commit issued. The client remains in the group; use
+// LeaveGroup or Close to leave. Fetch errors are not passed to handler; hook
+// into FetchHook or the logger to observe them.
+func (cl *Client) ConsumeConcurrent(ctx context.Context, group string, handler func(*Record), opts ...GroupOpt) error {
+	cc := &concurrentConsumer{
+		cl:        cl,
+		handler:   handler,
+		assigned:  make(map[string]map[int32]bool),
+		workers:   make(map[string]map[int32]*partitionWorker),
+		processed: make(map[string]map[int32]EpochOffset),
+	}
+
+	commitInterval := 5 * time.Second
+	var userAssigned, userRevoked, userLost func(context.Context, map[string][]int32)
+
+	opts = append([]GroupOpt{groupOpt{func(g *groupConsumer) {
+		// Clear the default revoke commit so that we can tell below
+		// whether the user set their own.
+		g.onRevoked = nil
+		g.onLost = nil
+	}}}, opts...)
+	opts = append(opts, groupOpt{func(g *groupConsumer) {
+		userAssigned, userRevoked, userLost = g.onAssigned, g.onRevoked, g.onLost
+		if g.autocommitInterval > 0 {
+			commitInterval = g.autocommitInterval
+		}
+		g.autocommitDisable = true
+		g.onAssigned = func(ctx context.Context, assigned map[string][]int32) {
+			cc.assign(assigned)
+			if userAssigned != nil {
+				userAssigned(ctx, assigned)
+			}
+		}
+		g.onRevoked = func(ctx context.Context, revoked map[string][]int32) {
+			cc.drain(revoked)
+			cl.BlockingCommitOffsets(ctx, cc.takeProcessed(revoked), nil)
+			if userRevoked != nil {
+				userRevoked(ctx, revoked)
+			}
+		}
+		g.onLost = func(ctx context.Context, lost map[string][]int32) {
+			cc.drain(lost)
+			cc.takeProcessed(lost)
+			if userLost != nil {
+				userLost(ctx, lost)
+			}
+		}
+	}})
+	cl.AssignGroup(group, opts...)
+
+	commitTicker := cl.cfg.clock.NewTicker(commitInterval)
+	defer commitTicker.Stop()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-commitTicker.C():
+				cl.CommitOffsets(ctx, cc.snapshotProcessed(), nil)
+			}
+		}
+	}()
+
+	for ctx.Err() == nil && cl.ctx.Err() == nil {
+		cc.dispatch(cl.PollFetches(ctx))
+	}
+
+	cc.drain(nil)
+	cl.BlockingCommitOffsets(cl.ctx, cc.takeProcessed(nil), nil)
+	return ctx.Err()
+}
+
+type concurrentConsumer struct {
+	cl      *Client
+	handler func(*Record)
+
+	// mu guards assigned and workers. Sending to a worker can block on
+	// the worker handling records, so sends happen outside of mu: a
+	// blocked send never blocks a rebalance from draining.
+	mu       sync.Mutex
+	assigned map[string]map[int32]bool
+	workers  map[string]map[int32]*partitionWorker
+
+	processedMu sync.Mutex
+	processed   map[string]map[int32]EpochOffset
+}
+
+type partitionWorker struct {
+	recs chan []*Record
+	quit chan struct{} // closed when draining; recs is never closed
+	done chan struct{}
+}
+
+func (cc *concurrentConsumer) assign(assigned map[string][]int32) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for topic, partitions := range assigned {
+		ps := cc.assigned[topic]
+		if ps == nil {
+			ps = make(map[int32]bool)
+			cc.assigned[topic] = ps
+		}
+		for _, p := range partitions {
+			ps[p] = true
+		}
+	}
+}
+
+// dispatch sends polled records to their partitions' workers, dropping
+// records for partitions that were revoked after the poll or that are revoked
+// while waiting for their worker.
+func (cc *concurrentConsumer) dispatch(fetches Fetches) {
+	type send struct {
+		w    *partitionWorker
+		recs []*Record
+	}
+	var sends []send
+
+	cc.mu.Lock()
+	fetches.EachPartition(func(p FetchTopicPartition) {
+		partition := p.Partition.Partition
+		if len(p.Partition.Records) == 0 || !cc.assigned[p.Topic][partition] {
+			return
+		}
+		ws := cc.workers[p.Topic]
+		if ws == nil {
+			ws = make(map[int32]*partitionWorker)
+			cc.workers[p.Topic] = ws
+		}
+		w := ws[partition]
+		if w == nil {
+			w = &partitionWorker{
+				recs: make(chan []*Record, 1),
+				quit: make(chan struct{}),
+				done: make(chan struct{}),
+			}
+			ws[partition] = w
+			go cc.work(w)
+		}
+		sends = append(sends, send{w, p.Partition.Records})
+	})
+	cc.mu.Unlock()
+
+	for _, s := range sends {
+		select {
+		case s.w.recs <- s.recs:
+		case <-s.w.quit:
+		}
+	}
+}
+
+func (cc *concurrentConsumer) work(w *partitionWorker) {
+	defer close(w.done)
+	handle := func(recs []*Record) {
+		for _, r := range recs {
+			cc.handler(r)
+			cc.markProcessed(r)
+		}
+	}
+	for {
+		select {
+		case recs := <-w.recs:
+			handle(recs)
+		case <-w.quit:
+			// Handle anything sent before we were told to quit.
+			for {
+				select {
+				case recs := <-w.recs:
+					handle(recs)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// drain unassigns the given partitions, or all partitions if nil, and waits
+// for their workers to handle all records sent to them. A dispatch blocked
+// sending to a drained worker gives up on its records.
+func (cc *concurrentConsumer) drain(partitions map[string][]int32) {
+	var draining []*partitionWorker
+	stop := func(topic string, partition int32) {
+		delete(cc.assigned[topic], partition)
+		if w := cc.workers[topic][partition]; w != nil {
+			delete(cc.workers[topic], partition)
+			close(w.quit)
+			draining = append(draining, w)
+		}
+	}
+
+	cc.mu.Lock()
+	if partitions == nil {
+		for topic, ws := range cc.workers {
+			for partition := range ws {
+				stop(topic, partition)
+			}
+		}
+		cc.assigned = make(map[string]map[int32]bool)
+	} else {
+		for topic, ps := range partitions {
+			for _, partition := range ps {
+				stop(topic, partition)
+			}
+		}
+	}
+	cc.mu.Unlock()
+
+	for _, w := range draining {
+		<-w.done
+	}
+}
+
+func (cc *concurrentConsumer) markProcessed(r *Record) {
+	cc.processedMu.Lock()
+	defer cc.processedMu.Unlock()
+	ps := cc.processed[r.Topic]
+	if ps == nil {
+		ps = make(map[int32]EpochOffset)
+		cc.processed[r.Topic] = ps
+	}
+	ps[r.Partition] = EpochOffset{
+		Epoch:  r.LeaderEpoch,
+		Offset: r.Offset + 1,
+	}
+}
+
+func (cc *concurrentConsumer) snapshotProcessed() map[string]map[int32]EpochOffset {
+	cc.processedMu.Lock()
+	defer cc.processedMu.Unlock()
+	snapshot := make(map[string]map[int32]EpochOffset, len(cc.processed))
+	for topic, ps := range cc.processed {
+		sps := make(map[int32]EpochOffset, len(ps))
+		for p, eo := range ps {
+			sps[p] = eo
+		}
+		snapshot[topic] = sps
+	}
+	return snapshot
+}
+
+// takeProcessed removes and returns the processed offsets for the given
+// partitions, or all partitions if nil.
+func (cc *concurrentConsumer) takeProcessed(partitions map[string][]int32) map[string]map[int32]EpochOffset {
+	cc.processedMu.Lock()
+	defer cc.processedMu.Unlock()
+	if partitions == nil {
+		taken := cc.processed
+		cc.processed = make(map[string]map[int32]EpochOffset)
+		return taken
+	}
+	taken := make(map[string]map[int32]EpochOffset)
+	for topic, ps := range partitions {
+		for _, p := range ps {
+			eo, ok := cc.processed[topic][p]
+			if !ok {
+				continue
+			}
+			delete(cc.processed[topic], p)
+			tps := taken[topic]
+			if tps == nil {
+				tps = make(map[int32]EpochOffset)
+				taken[topic] = tps
+			}
+			tps[p] = eo
+		}
+	}
+	return taken
+}
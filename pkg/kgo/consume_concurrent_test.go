@@ -0,0 +1,107 @@
+package kgo
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestConcurrentConsumer(handler func(*Record)) *concurrentConsumer {
+	return &concurrentConsumer{
+		handler:   handler,
+		assigned:  make(map[string]map[int32]bool),
+		workers:   make(map[string]map[int32]*partitionWorker),
+		processed: make(map[string]map[int32]EpochOffset),
+	}
+}
+
+func fetchesFor(topic string, partition int32, offsets ...int64) Fetches {
+	var recs []*Record
+	for _, o := range offsets {
+		recs = append(recs, &Record{Topic: topic, Partition: partition, Offset: o})
+	}
+	return Fetches{{Topics: []FetchTopic{{
+		Topic:      topic,
+		Partitions: []FetchPartition{{Partition: partition, Records: recs}},
+	}}}}
+}
+
+func waitDone(t *testing.T, what string, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s did not finish", what)
+	}
+}
+
+func TestConcurrentConsumerDispatchDoesNotBlockRebalance(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	handling := make(chan struct{}, 10)
+	cc := newTestConcurrentConsumer(func(*Record) {
+		handling <- struct{}{}
+		<-release
+	})
+	cc.assign(map[string][]int32{"t": {0}})
+
+	// The first record blocks in the handler, the second fills the
+	// worker's buffer, and the third blocks dispatching.
+	cc.dispatch(fetchesFor("t", 0, 0))
+	<-handling
+	cc.dispatch(fetchesFor("t", 0, 1))
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		cc.dispatch(fetchesFor("t", 0, 2))
+	}()
+
+	// A rebalance can proceed while dispatch is blocked.
+	assigned := make(chan struct{})
+	go func() {
+		defer close(assigned)
+		cc.assign(map[string][]int32{"t": {1}})
+	}()
+	waitDone(t, "assign", assigned)
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		cc.drain(map[string][]int32{"t": {0}})
+	}()
+	waitDone(t, "blocked dispatch", dispatched)
+
+	// Draining waits for everything already sent to the worker.
+	select {
+	case <-drained:
+		t.Fatal("drain finished before the worker handled its records")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	waitDone(t, "drain", drained)
+
+	processed := cc.takeProcessed(nil)
+	if got := processed["t"][0].Offset; got != 2 {
+		t.Errorf("got processed offset %d != exp 2", got)
+	}
+}
+
+func TestConcurrentConsumerDispatchRevoked(t *testing.T) {
+	t.Parallel()
+
+	cc := newTestConcurrentConsumer(func(*Record) {})
+	cc.assign(map[string][]int32{"t": {0, 1}})
+	cc.drain(map[string][]int32{"t": {1}})
+
+	// Records for a partition revoked after the poll are dropped.
+	cc.dispatch(append(fetchesFor("t", 0, 5), fetchesFor("t", 1, 7)...))
+	cc.drain(nil)
+
+	processed := cc.takeProcessed(nil)
+	if got := processed["t"][0].Offset; got != 6 {
+		t.Errorf("got processed offset %d != exp 6", got)
+	}
+	if _, ok := processed["t"][1]; ok {
+		t.Error("records for a revoked partition were handled")
+	}
+}
@@ -78,6 +78,17 @@ func (o Offset) At(at int64) Offset {
 	return o
 }
 
+// OffsetReset is where ResetPartitionOffset resets a partition to.
+type OffsetReset int8
+
+const (
+	// OffsetResetEarliest resets a partition to its log start offset.
+	OffsetResetEarliest OffsetReset = iota
+	// OffsetResetLatest resets a partition to its high watermark, or last
+	// stable offset if reading committed.
+	OffsetResetLatest
+)
+
 type consumer struct {
 	cl *Client
 
@@ -123,6 +134,15 @@ type consumer struct {
 	// dead is set when the client closes; this being true means that any
 	// Assign does nothing (aside from unassigning everything prior).
 	dead bool
+
+	// maxBytes, maxPartBytes, minBytes, and maxWait are atomics that are
+	// initialized from the client configuration and can be changed with
+	// SetFetchMaxBytes, SetFetchPartitionMaxBytes, SetFetchMinBytes, and
+	// SetFetchMaxWait. They are read when building every fetch request.
+	maxBytes     int32
+	maxPartBytes int32
+	minBytes     int32
+	maxWait      int32
 }
 
 type usedCursors map[*cursor]struct{}
@@ -155,6 +175,129 @@ func (c *consumer) init(cl *Client) {
 	c.cl = cl
 	c.sourcesReadyCond = sync.NewCond(&c.sourcesReadyMu)
 	c.v.Store(consumerUnsetSentinel)
+	c.maxBytes = cl.cfg.maxBytes
+	c.maxPartBytes = cl.cfg.maxPartBytes
+	c.minBytes = cl.cfg.minBytes
+	c.maxWait = cl.cfg.maxWait
+	if min, max := cl.cfg.adaptiveFetchMinBytes, cl.cfg.adaptiveFetchMaxBytes; max > 0 {
+		if c.maxBytes < min {
+			c.maxBytes = min
+		} else if c.maxBytes > max {
+			c.maxBytes = max
+		}
+	}
+}
+
+// adaptFetchMaxBytes, if adaptive fetch sizing is enabled, grows the fetch max
+// bytes if a fetch response was nearly full for what was requested, and
+// shrinks it if the response was mostly empty.
+func (c *consumer) adaptFetchMaxBytes(requested int32, got int) {
+	min, max := c.cl.cfg.adaptiveFetchMinBytes, c.cl.cfg.adaptiveFetchMaxBytes
+	if max == 0 || requested <= 0 {
+		return
+	}
+	switch {
+	case int64(got) >= int64(requested)*9/10:
+		c.resizeFetchMaxBytes(requested, min, max, true)
+	case int64(got) < int64(requested)/4:
+		c.resizeFetchMaxBytes(requested, min, max, false)
+	}
+}
+
+// adaptFetchMaxBytesForPoll, if adaptive fetch sizing is enabled, shrinks the
+// fetch max bytes if a buffered fetch waited too long to be polled.
+func (c *consumer) adaptFetchMaxBytesForPoll(waited time.Duration) {
+	min, max := c.cl.cfg.adaptiveFetchMinBytes, c.cl.cfg.adaptiveFetchMaxBytes
+	if max == 0 || waited <= time.Duration(atomic.LoadInt32(&c.maxWait))*time.Millisecond {
+		return
+	}
+	c.resizeFetchMaxBytes(atomic.LoadInt32(&c.maxBytes), min, max, false)
+}
+
+// resizeFetchMaxBytes doubles or halves from, bounded to [min, max]. We only
+// swap if the fetch max bytes is still from, so that concurrent fetches all
+// seeing the same conditions only resize once.
+func (c *consumer) resizeFetchMaxBytes(from, min, max int32, grow bool) {
+	to := from / 2
+	if grow {
+		to = from * 2
+		if to < from { // overflow
+			to = max
+		}
+	}
+	if to < min {
+		to = min
+	} else if to > max {
+		to = max
+	}
+	if to != from && atomic.CompareAndSwapInt32(&c.maxBytes, from, to) {
+		c.cl.cfg.logger.Log(LogLevelDebug, "adapted fetch max bytes", "from", from, "to", to)
+	}
+}
+
+// SetFetchMaxBytes sets the maximum amount of bytes a broker will try to
+// send during a fetch, overriding the value set with FetchMaxBytes.
+//
+// This applies to all fetch requests built after this function returns; fetch
+// requests that are already in flight are unaffected. This allows tuning fetch
+// sizing at runtime, such as growing fetches while a consumer is behind and
+// shrinking them once it has caught up.
+//
+// Values less than one are ignored, and values larger than BrokerMaxReadBytes
+// are clamped to BrokerMaxReadBytes.
+func (cl *Client) SetFetchMaxBytes(b int32) {
+	if b < 1 {
+		return
+	}
+	if b > cl.cfg.maxBrokerReadBytes {
+		b = cl.cfg.maxBrokerReadBytes
+	}
+	atomic.StoreInt32(&cl.consumer.maxBytes, b)
+}
+
+// SetFetchPartitionMaxBytes sets the maximum amount of bytes that will be
+// consumed for a single partition in a fetch request, overriding the value
+// set with FetchMaxPartitionBytes.
+//
+// As with SetFetchMaxBytes, this applies to all fetch requests built after
+// this function returns. Values less than one are ignored. Just as with the
+// FetchMaxPartitionBytes option, the partition max is always clamped to the
+// fetch max bytes when building a request.
+func (cl *Client) SetFetchPartitionMaxBytes(b int32) {
+	if b < 1 {
+		return
+	}
+	atomic.StoreInt32(&cl.consumer.maxPartBytes, b)
+}
+
+// SetFetchMinBytes sets the minimum amount of bytes a broker will try to send
+// during a fetch, overriding the value set with FetchMinBytes.
+//
+// Together with SetFetchMaxWait, this allows switching a consumer between a
+// low latency mode (min bytes of 1 and a short max wait) and a high throughput
+// mode (large min bytes and a long max wait) at runtime. As with
+// SetFetchMaxBytes, this applies to all fetch requests built after this
+// function returns. Values less than one are ignored.
+func (cl *Client) SetFetchMinBytes(b int32) {
+	if b < 1 {
+		return
+	}
+	atomic.StoreInt32(&cl.consumer.minBytes, b)
+}
+
+// SetFetchMaxWait sets the maximum amount of time a broker will wait for a
+// fetch response to hit the minimum number of required bytes before
+// returning, overriding the value set with FetchMaxWait.
+//
+// As with SetFetchMaxBytes, this applies to all fetch requests built after
+// this function returns; a fetch that is already waiting in the broker is
+// unaffected. Values less than 10ms are raised to 10ms, matching the
+// FetchMaxWait validation.
+func (cl *Client) SetFetchMaxWait(wait time.Duration) {
+	if wait < 10*time.Millisecond {
+		wait = 10 * time.Millisecond
+	}
+	atomic.StoreInt32(&cl.consumer.maxWait, int32(wait.Milliseconds()))
 }
 
 func (c *consumer) loadKind() interface{} { return c.v.Load().(*consumerValue).v }
@@ -269,6 +412,20 @@ func (cl *Client) PollFetches(ctx context.Context) Fetches {
 // any partition has a fatal error and actually had no records, fake fetch will
 // be injected with the error.
 func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
+	return cl.pollRecords(ctx, maxPollRecords, true)
+}
+
+// PollFetchesNonBlocking returns all fetches that are immediately available,
+// without waiting. If no fetches are buffered, this returns empty fetches.
+//
+// This is meant for integrating the consumer into an event loop, where the
+// loop cannot block in PollFetches. As with PollFetches, it is important to
+// check all partition errors in the returned fetches.
+func (cl *Client) PollFetchesNonBlocking() Fetches {
+	return cl.pollRecords(context.Background(), 0, false)
+}
+
+func (cl *Client) pollRecords(ctx context.Context, maxPollRecords int, block bool) Fetches {
 	if maxPollRecords == 0 {
 		maxPollRecords = -1
 	}
@@ -335,7 +492,7 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 	}
 
 	fill()
-	if len(fetches) > 0 {
+	if len(fetches) > 0 || !block {
 		return fetches
 	}
 
@@ -370,6 +527,143 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 	return fetches
 }
 
+// CaughtUp returns whether every partition being consumed has been polled up
+// to the end of the partition as of the fetch the last poll for that
+// partition came from. The end is the high watermark, or the last stable
+// offset if reading committed.
+//
+// This is meant for consuming bounded or compacted topics in one shot jobs:
+// after each poll, a job can check CaughtUp and exit once it returns true,
+// rather than polling forever on a topic that is done being produced to.
+// Records produced after the fetches that were polled are not accounted for.
+//
+// This returns false if nothing is being consumed, if any partition is still
+// loading its offset, or if any partition's last fetch had an error.
+func (cl *Client) CaughtUp() bool {
+	c := &cl.consumer
+
+	if session := c.loadSession(); session != noConsumerSession {
+		session.listOrEpochMu.Lock()
+		loading := !session.listOrEpochLoadsWaiting.isEmpty() || !session.listOrEpochLoadsLoading.isEmpty()
+		session.listOrEpochMu.Unlock()
+		if loading {
+			return false
+		}
+	}
+
+	cl.sinksAndSourcesMu.Lock()
+	defer cl.sinksAndSourcesMu.Unlock()
+
+	var atEnd int
+	for _, sns := range cl.sinksAndSources {
+		s := sns.source
+		s.cursorsMu.Lock()
+		for _, cursor := range s.cursors {
+			switch atomic.LoadUint32(&cursor.endState) {
+			case cursorActive:
+				s.cursorsMu.Unlock()
+				return false
+			case cursorAtEnd:
+				atEnd++
+			}
+		}
+		s.cursorsMu.Unlock()
+	}
+	return atEnd > 0
+}
+
+// LagGauges returns the current lag of every partition being consumed: the
+// difference between the partition's high watermark (or last stable offset if
+// reading committed) as of the latest fetch and the offset the partition has
+// been polled to. Records that were fetched but are not yet polled are
+// included in the lag.
+//
+// Lag is updated on every fetch and every poll, so this can be called as
+// often as desired to read live gauges without issuing any requests.
+// Partitions that have not yet been fetched are not included. To be notified
+// of lag as it is updated, see FetchLagHook.
+func (cl *Client) LagGauges() map[string]map[int32]int64 {
+	cl.sinksAndSourcesMu.Lock()
+	defer cl.sinksAndSourcesMu.Unlock()
+
+	lags := make(map[string]map[int32]int64)
+	for _, sns := range cl.sinksAndSources {
+		s := sns.source
+		s.cursorsMu.Lock()
+		for _, cursor := range s.cursors {
+			lag, ok := cursor.lag()
+			if !ok {
+				continue
+			}
+			partitions := lags[cursor.topic]
+			if partitions == nil {
+				partitions = make(map[int32]int64)
+				lags[cursor.topic] = partitions
+			}
+			partitions[cursor.partition] = lag
+		}
+		s.cursorsMu.Unlock()
+	}
+	return lags
+}
+
+// ResetPartitionOffset resets a single partition being consumed to its
+// earliest or latest offset, without changing the offsets of any other
+// partition and without leaving or rejoining a group. This is useful to
+// recover a single partition whose data was deleted by retention.
+//
+// The partition's offset is listed anew before consuming resumes; fetches
+// already buffered for other partitions are discarded and refetched from the
+// same offsets. If the client is not currently consuming the partition, this
+// does nothing. For groups, this does not commit the reset offset; the offset
+// is committed as records are consumed.
+func (cl *Client) ResetPartitionOffset(topic string, partition int32, to OffsetReset) {
+	c := &cl.consumer
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var tps *topicsPartitions
+	switch kind := c.loadKind().(type) {
+	case *directConsumer:
+		tps = kind.tps
+	case *groupConsumer:
+		tps = kind.tps
+	default:
+		return
+	}
+
+	loadOffsets, _ := c.stopSession()
+	defer func() {
+		loadOffsets.loadWithSession(c.startNewSession(tps))
+	}()
+
+	var consuming bool
+	for usedCursor := range c.usingCursors {
+		if usedCursor.topic == topic && usedCursor.partition == partition {
+			usedCursor.unset()
+			delete(c.usingCursors, usedCursor)
+			consuming = true
+		}
+	}
+	loadOffsets.each(func(t string, p int32) {
+		if t == topic && p == partition {
+			consuming = true
+		}
+	})
+	if !consuming {
+		return
+	}
+
+	offset := NewOffset().AtStart()
+	if to == OffsetResetLatest {
+		offset = offset.AtEnd()
+	}
+	loadOffsets.addLoad(topic, partition, loadTypeList, offsetLoad{
+		replica: -1,
+		Offset:  offset,
+	})
+}
+
 // assignHow controls how assignPartitions operates.
 type assignHow int8
 
@@ -621,6 +915,12 @@ func (s *consumerSession) doOnMetadataUpdate() {
 type offsetLoad struct {
 	replica int32 // -1 means leader
 	Offset
+
+	// outOfRange is whether this load resolves an OffsetOutOfRange error
+	// with OnOffsetOutOfRange, in which case Offset lists the log start
+	// and consumed is the offset that was out of range.
+	outOfRange bool
+	consumed   int64
 }
 
 type offsetLoadMap map[string]map[int32]offsetLoad
@@ -1241,6 +1541,16 @@ func (l *loadedOffsets) addAll(as []loadedOffset) loadedOffsets {
 func (cl *Client) listOffsetsForBrokerLoad(ctx context.Context, broker *broker, load offsetLoadMap, tps *topicsPartitions, results chan<- loadedOffsets) {
 	loaded := loadedOffsets{loadType: loadTypeList}
 
+	ends, err := cl.listOutOfRangeEnds(ctx, broker, load)
+	if err != nil {
+		loaded.addAll(load.outOfRangeLoads().errToLoaded(err))
+		load = load.withoutOutOfRange()
+		if len(load) == 0 {
+			results <- loaded
+			return
+		}
+	}
+
 	kresp, err := broker.waitResp(ctx, load.buildListReq(cl.cfg.isolationLevel))
 	if err != nil {
 		results <- loaded.addAll(load.errToLoaded(err))
@@ -1295,6 +1605,26 @@ func (cl *Client) listOffsetsForBrokerLoad(ctx context.Context, broker *broker,
 				offset = 0
 			}
 
+			if loadPart.outOfRange {
+				end, ok := ends[topic][partition]
+				if !ok {
+					loaded.add(loadedOffset{
+						topic:     topic,
+						partition: partition,
+						err:       kerr.UnknownTopicOrPartition,
+						request:   loadPart,
+					})
+					continue
+				}
+				start := offset
+				offset = cl.cfg.onOffsetOutOfRange(topic, partition, loadPart.consumed, start, end)
+				if offset < start {
+					offset = start
+				} else if offset > end {
+					offset = end
+				}
+			}
+
 			loaded.add(loadedOffset{
 				topic:       topic,
 				partition:   partition,
@@ -1309,6 +1639,72 @@ func (cl *Client) listOffsetsForBrokerLoad(ctx context.Context, broker *broker,
 	results <- loaded.addAll(load.errToLoaded(kerr.UnknownTopicOrPartition))
 }
 
+// listOutOfRangeEnds lists the end offsets of all partitions in load that
+// are resolving an OffsetOutOfRange error with OnOffsetOutOfRange. Partitions
+// that fail to list are not in the returned map.
+func (cl *Client) listOutOfRangeEnds(ctx context.Context, broker *broker, load offsetLoadMap) (map[string]map[int32]int64, error) {
+	outOfRange := load.outOfRangeLoads()
+	if len(outOfRange) == 0 {
+		return nil, nil
+	}
+	for _, partitions := range outOfRange {
+		for partition, o := range partitions {
+			o.Offset = o.Offset.AtEnd()
+			partitions[partition] = o
+		}
+	}
+
+	kresp, err := broker.waitResp(ctx, outOfRange.buildListReq(cl.cfg.isolationLevel))
+	if err != nil {
+		return nil, err
+	}
+	ends := make(map[string]map[int32]int64)
+	for _, rTopic := range kresp.(*kmsg.ListOffsetsResponse).Topics {
+		for _, rPartition := range rTopic.Partitions {
+			if kerr.ErrorForCode(rPartition.ErrorCode) != nil {
+				continue
+			}
+			end := rPartition.Offset
+			if len(rPartition.OldStyleOffsets) > 0 {
+				end = rPartition.OldStyleOffsets[0]
+			}
+			if ends[rTopic.Topic] == nil {
+				ends[rTopic.Topic] = make(map[int32]int64)
+			}
+			ends[rTopic.Topic][rPartition.Partition] = end
+		}
+	}
+	return ends, nil
+}
+
+// outOfRangeLoads returns a copy of the loads that are resolving an
+// OffsetOutOfRange error with OnOffsetOutOfRange.
+func (o offsetLoadMap) outOfRangeLoads() offsetLoadMap {
+	return o.filter(func(load offsetLoad) bool { return load.outOfRange })
+}
+
+// withoutOutOfRange returns a copy of the loads that are not resolving an
+// OffsetOutOfRange error with OnOffsetOutOfRange.
+func (o offsetLoadMap) withoutOutOfRange() offsetLoadMap {
+	return o.filter(func(load offsetLoad) bool { return !load.outOfRange })
+}
+
+func (o offsetLoadMap) filter(keep func(offsetLoad) bool) offsetLoadMap {
+	filtered := make(offsetLoadMap)
+	for topic, partitions := range o {
+		for partition, load := range partitions {
+			if !keep(load) {
+				continue
+			}
+			if filtered[topic] == nil {
+				filtered[topic] = make(map[int32]offsetLoad)
+			}
+			filtered[topic][partition] = load
+		}
+	}
+	return filtered
+}
+
 func (cl *Client) loadEpochsForBrokerLoad(ctx context.Context, broker *broker, load offsetLoadMap, tps *topicsPartitions, results chan<- loadedOffsets) {
 	loaded := loadedOffsets{loadType: loadTypeEpoch}
 
@@ -42,6 +42,25 @@ func GroupTopicsRegex() GroupOpt {
 	return groupOpt{func(cfg *groupConsumer) { cfg.regexTopics = true }}
 }
 
+// ConsumeRegex adds a regular expression to match topics to consume against,
+// and sets all topics in GroupTopics to be parsed as regular expressions. This
+// can be used multiple times to add multiple expressions, and unlike
+// GroupTopics, it does not reset any previously added topics.
+//
+// Every metadata refresh evaluates all topics in the cluster against the
+// expressions. Newly created matching topics are added to the group's
+// subscription and deleted topics are removed, both of which trigger a
+// rebalance. Internal topics are never matched.
+func ConsumeRegex(pattern string) GroupOpt {
+	return groupOpt{func(cfg *groupConsumer) {
+		if cfg.topics == nil {
+			cfg.topics = make(map[string]struct{})
+		}
+		cfg.topics[pattern] = struct{}{}
+		cfg.regexTopics = true
+	}}
+}
+
 // Balancers sets the group balancers to use for dividing topic partitions
 // among group members, overriding the defaults.
 //
@@ -110,6 +129,42 @@ func RequireStableFetchOffsets() GroupOpt {
 	return groupOpt{func(cfg *groupConsumer) { cfg.requireStable = true }}
 }
 
+// OffsetStore loads and stores a group's offsets outside of Kafka, such as in
+// a database that is written to in the same transaction as the results of
+// processing records.
+type OffsetStore interface {
+	// LoadOffsets returns the offsets to begin consuming the given
+	// newly assigned partitions at. Partitions missing from the returned
+	// map begin at the client's ConsumeResetOffset. Returning an error
+	// fails the group's assignment as a failed OffsetFetch would,
+	// causing the group to be rejoined.
+	LoadOffsets(ctx context.Context, group string, partitions map[string][]int32) (map[string]map[int32]EpochOffset, error)
+
+	// StoreOffsets persists offsets for the group. The offsets are the
+	// next offsets to consume, as committed to Kafka in the normal case.
+	StoreOffsets(ctx context.Context, group string, offsets map[string]map[int32]EpochOffset) error
+}
+
+// GroupOffsetStore sets the group consumer to load and store offsets with
+// store rather than fetching them from and committing them to Kafka.
+//
+// Group membership is still used for partition assignment, and committing
+// works as usual (autocommitting, CommitOffsets, and so on), but commits are
+// sent to store.StoreOffsets. The commit callbacks receive a synthesized
+// OffsetCommitResponse with no errors if the store succeeds, or the store's
+// error. Since stored offsets are not tied to a group generation, committing
+// for partitions that have been revoked is not prevented by Kafka; commit
+// during OnRevoked (as the default does) to persist progress before a
+// partition moves to another member.
+//
+// This option cannot be used with a transactional client, such as with
+// AssignGroupTransactSession, which commits offsets in Kafka transactions. A
+// transactional client does not assign a group with this option, and
+// AssignGroupTransactSession returns nil.
+func GroupOffsetStore(store OffsetStore) GroupOpt {
+	return groupOpt{func(cfg *groupConsumer) { cfg.offsetStore = store }}
+}
+
 // OnAssigned sets the function to be called when a group is joined after
 // partitions are assigned before fetches for those partitions begin.
 //
@@ -160,6 +215,43 @@ func OnLost(onLost func(context.Context, map[string][]int32)) GroupOpt {
 	return groupOpt{func(cfg *groupConsumer) { cfg.onLost = onLost }}
 }
 
+// CommitRebalancePolicy is what to do with offsets that fail to commit with
+// REBALANCE_IN_PROGRESS, as returned from the function passed to
+// OnCommitRebalanceInProgress.
+type CommitRebalancePolicy int8
+
+const (
+	// CommitRebalanceFail fails the commit, leaving the
+	// REBALANCE_IN_PROGRESS error in the commit response for the caller
+	// to handle. This is the default behavior.
+	CommitRebalanceFail CommitRebalancePolicy = iota
+
+	// CommitRebalanceRetry waits for the rebalance to complete and then
+	// retries committing the failed offsets with the new generation, for
+	// partitions that are still assigned to this member after the
+	// rebalance. Offsets for partitions that were revoked are dropped,
+	// since committing them could rewind the new owner, and keep the
+	// REBALANCE_IN_PROGRESS error in the commit response.
+	CommitRebalanceRetry
+)
+
+// OnCommitRebalanceInProgress sets a function to be called when offsets fail
+// to commit because the group is rebalancing, overriding the default of
+// failing those offsets. The function is passed the topics and partitions
+// that failed and returns the policy to apply.
+//
+// With CommitRebalanceRetry, the commit does not complete (and
+// BlockingCommitOffsets does not return, nor is CommitOffsets' onDone called)
+// until the rebalance completes or the rebalance timeout passes, the commit's
+// context is canceled, or a new commit cancels this one. Because a rebalance
+// cannot complete while OnRevoked is running, a commit stops waiting when
+// OnRevoked begins, and commits issued while OnRevoked is running are not
+// retried; these keep their REBALANCE_IN_PROGRESS errors. This allows
+// OnRevoked to wait for in flight commits.
+func OnCommitRebalanceInProgress(fn func(map[string][]int32) CommitRebalancePolicy) GroupOpt {
+	return groupOpt{func(cfg *groupConsumer) { cfg.onCommitRebalance = fn }}
+}
+
 // DisableAutoCommit disable auto committing.
 func DisableAutoCommit() GroupOpt {
 	return groupOpt{func(cfg *groupConsumer) { cfg.autocommitDisable = true }}
@@ -230,11 +322,14 @@ type groupConsumer struct {
 	rebalanceTimeout  time.Duration
 	heartbeatInterval time.Duration
 	requireStable     bool
+	offsetStore       OffsetStore
 
 	onAssigned func(context.Context, map[string][]int32)
 	onRevoked  func(context.Context, map[string][]int32)
 	onLost     func(context.Context, map[string][]int32)
 
+	onCommitRebalance func(map[string][]int32) CommitRebalancePolicy
+
 	autocommitDisable  bool // true if autocommit was disabled or we are transactional
 	autocommitInterval time.Duration
 
@@ -324,7 +419,23 @@ type groupConsumer struct {
 	// autocommit does not cancel the user's manual commit.
 	blockAuto bool
 
+	// revoking is set under mu while OnRevoked runs, and retryCancel is
+	// set under mu while a commit waits for a rebalance to complete to
+	// retry. A rebalance cannot complete until OnRevoked returns, so
+	// commits do not wait to retry while revoking.
+	revoking    bool
+	retryCancel func()
+
 	dying bool // set when closing, read in findNewAssignments
+
+	// stableMu guards stableCh, which is closed once a group session has
+	// a stable assignment and is replaced when a new session begins;
+	// stableGen, the generation of the session that last became stable;
+	// and stableChange, which is closed and replaced on every transition.
+	stableMu     sync.Mutex
+	stableCh     chan struct{}
+	stableGen    int32
+	stableChange chan struct{}
 }
 
 // LeaveGroup leaves a group if in one. Calling the client's Close function
@@ -385,6 +496,9 @@ func (cl *Client) AssignGroup(group string, opts ...GroupOpt) {
 		using:    make(map[string]int),
 		rejoinCh: make(chan struct{}, 1),
 		reSeen:   make(map[string]struct{}),
+		stableCh: make(chan struct{}),
+
+		stableChange: make(chan struct{}),
 
 		sessionTimeout:    10000 * time.Millisecond,
 		rebalanceTimeout:  60000 * time.Millisecond,
@@ -404,6 +518,12 @@ func (cl *Client) AssignGroup(group string, opts ...GroupOpt) {
 	if len(group) == 0 || len(g.topics) == 0 || c.dead {
 		return
 	}
+	if g.offsetStore != nil && c.cl.cfg.txnID != nil {
+		// Transactional clients commit offsets to Kafka in their
+		// transactions, which would bypass the store.
+		cl.cfg.logger.Log(LogLevelError, "not assigning group: GroupOffsetStore cannot be used with a transactional client", "group", group)
+		return
+	}
 
 	defer c.storeGroup(g)
 	defer cl.triggerUpdateMetadata(true) // we definitely want to trigger a metadata update
@@ -427,6 +547,104 @@ func (cl *Client) AssignGroup(group string, opts ...GroupOpt) {
 	}
 }
 
+// WaitForGroupAssignment waits until the client's group session has a stable
+// assignment, or until the context is done, the group is left, or the client
+// is closed.
+//
+// An assignment is stable once the group has been joined and synced, the
+// OnAssigned callback (if any) has returned, and offsets have been fetched for
+// all assigned partitions; that is, once the client is able to begin fetching
+// assigned partitions. An empty assignment can be stable. If a rebalance
+// begins, the assignment is no longer stable until the rebalance completes.
+//
+// This returns an error if the client is not consuming as part of a group.
+// This is useful to delay declaring an application ready until it has joined
+// its group.
+func (cl *Client) WaitForGroupAssignment(ctx context.Context) error {
+	g, ok := cl.consumer.loadGroup()
+	if !ok {
+		return errNotGroup
+	}
+	g.stableMu.Lock()
+	stableCh := g.stableCh
+	g.stableMu.Unlock()
+
+	select {
+	case <-stableCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-g.ctx.Done():
+		return g.ctx.Err()
+	}
+}
+
+// setStable closes the current stable channel, if it is not already closed,
+// recording the generation of the session that is now stable.
+func (g *groupConsumer) setStable(generation int32) {
+	g.stableMu.Lock()
+	defer g.stableMu.Unlock()
+	select {
+	case <-g.stableCh:
+	default:
+		close(g.stableCh)
+		g.stableGen = generation
+		g.stableChanged()
+	}
+}
+
+// setUnstable replaces the stable channel if it was closed, so that new
+// waiters wait for the next session to become stable.
+func (g *groupConsumer) setUnstable() {
+	g.stableMu.Lock()
+	defer g.stableMu.Unlock()
+	select {
+	case <-g.stableCh:
+		g.stableCh = make(chan struct{})
+		g.stableChanged()
+	default:
+	}
+}
+
+// stableChanged wakes anything waiting in waitStableAfter; this must be
+// called with stableMu held.
+func (g *groupConsumer) stableChanged() {
+	close(g.stableChange)
+	g.stableChange = make(chan struct{})
+}
+
+// waitStableAfter waits until a session with a generation other than the
+// given generation is stable.
+//
+// Waiting on stableCh alone is not enough when reacting to a broker error
+// such as RebalanceInProgress: the broker can know of the rebalance before we
+// do, in which case stableCh is still the closed channel of the session that
+// is ending.
+func (g *groupConsumer) waitStableAfter(ctx context.Context, generation int32) error {
+	for {
+		g.stableMu.Lock()
+		var stable bool
+		select {
+		case <-g.stableCh:
+			stable = true
+		default:
+		}
+		gen, change := g.stableGen, g.stableChange
+		g.stableMu.Unlock()
+
+		if stable && gen != generation {
+			return nil
+		}
+		select {
+		case <-change:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-g.ctx.Done():
+			return g.ctx.Err()
+		}
+	}
+}
+
 // Manages the group consumer's join / sync / heartbeat / fetch offset flow.
 //
 // Once a group is assigned, we fire a metadata request for all topics the
@@ -628,9 +846,7 @@ func (g *groupConsumer) revoke(stage revokeStage, lost map[string][]int32, leavi
 		} else {
 			g.cl.cfg.logger.Log(LogLevelInfo, "cooperative consumer revoking prior assigned partitions because leaving group", "revoking", g.nowAssigned)
 		}
-		if g.onRevoked != nil {
-			g.onRevoked(g.ctx, g.nowAssigned)
-		}
+		g.callOnRevoked(g.nowAssigned)
 		g.nowAssigned = nil
 
 		// After nilling uncommitted here, nothing should recreate
@@ -690,9 +906,7 @@ func (g *groupConsumer) revoke(stage revokeStage, lost map[string][]int32, leavi
 		} else {
 			g.cl.cfg.logger.Log(LogLevelInfo, "cooperative consumer calling onRevoke", "lost", lost, "stage", stage)
 		}
-		if g.onRevoked != nil {
-			g.onRevoked(g.ctx, lost)
-		}
+		g.callOnRevoked(lost)
 	}
 
 	if len(lost) == 0 { // if we lost nothing, do nothing
@@ -728,6 +942,28 @@ func (g *groupConsumer) revoke(stage revokeStage, lost map[string][]int32, leavi
 
 }
 
+// callOnRevoked calls the user's onRevoked, if any. Any commit waiting to
+// retry after a rebalance stops waiting, and commits issued while revoking
+// do not wait, since the rebalance cannot complete until we return.
+func (g *groupConsumer) callOnRevoked(revoked map[string][]int32) {
+	if g.onRevoked == nil {
+		return
+	}
+	g.mu.Lock()
+	g.revoking = true
+	if g.retryCancel != nil {
+		g.retryCancel()
+	}
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		g.revoking = false
+		g.mu.Unlock()
+	}()
+	g.onRevoked(g.ctx, revoked)
+}
+
 // assignRevokeSession aids in sequencing prerevoke/assign/revoke.
 type assignRevokeSession struct {
 	prerevokeDone chan struct{}
@@ -826,12 +1062,14 @@ func (g *groupConsumer) setupAssignedAndHeartbeat() error {
 	// is specifically used for this function's return.
 	fetchDone := make(chan struct{})
 	defer func() { <-fetchDone }()
+	var fetchErr error
 	if len(added) > 0 {
 		go func() {
 			defer close(fetchDone)
 			defer close(fetchErrCh)
 			g.cl.cfg.logger.Log(LogLevelInfo, "fetching offsets for added partitions", "added", added)
-			fetchErrCh <- g.fetchOffsets(ctx, added)
+			fetchErr = g.fetchOffsets(ctx, added)
+			fetchErrCh <- fetchErr
 		}()
 	} else {
 		close(fetchDone)
@@ -855,6 +1093,21 @@ func (g *groupConsumer) setupAssignedAndHeartbeat() error {
 	s.assign(g, added)
 	defer func() { <-s.assignDone }()
 
+	// Once offsets are fetched and onAssigned is done, our assignment is
+	// stable. We wait for this before returning so that we cannot mark a
+	// later session stable.
+	generation := g.generation // only written in this goroutine, in joinAndSync
+	stableDone := make(chan struct{})
+	defer func() { <-stableDone }()
+	go func() {
+		defer close(stableDone)
+		<-fetchDone
+		<-s.assignDone
+		if fetchErr == nil {
+			g.setStable(generation)
+		}
+	}()
+
 	// Finally, we simply return whatever the heartbeat error is. This will
 	// be the fetch offset error if that function is what killed this.
 	return <-hbErrCh
@@ -1011,6 +1264,7 @@ func (g *groupConsumer) rejoin() {
 func (g *groupConsumer) joinAndSync() error {
 	g.cl.cfg.logger.Log(LogLevelInfo, "joining group")
 	g.leader.set(false)
+	g.setUnstable()
 
 start:
 	select {
@@ -1213,6 +1467,10 @@ func (g *groupConsumer) joinGroupProtocols() []kmsg.JoinGroupRequestProtocol {
 // fetchOffsets is issued once we join a group to see what the prior commits
 // were for the partitions we were assigned.
 func (g *groupConsumer) fetchOffsets(ctx context.Context, newAssigned map[string][]int32) error {
+	if g.offsetStore != nil {
+		return g.loadStoredOffsets(ctx, newAssigned)
+	}
+
 start:
 	req := kmsg.OffsetFetchRequest{
 		Group:         g.id,
@@ -1279,6 +1537,39 @@ start:
 		}
 	}
 
+	return g.assignFetchedOffsets(offsets)
+}
+
+// loadStoredOffsets loads offsets for newly assigned partitions from the
+// group's OffsetStore, rather than from Kafka, and assigns them.
+func (g *groupConsumer) loadStoredOffsets(ctx context.Context, newAssigned map[string][]int32) error {
+	loaded, err := g.offsetStore.LoadOffsets(ctx, g.id, newAssigned)
+	if err != nil {
+		g.cl.cfg.logger.Log(LogLevelError, "loading offsets from the offset store failed", "err", err)
+		return err
+	}
+
+	offsets := make(map[string]map[int32]Offset)
+	for topic, partitions := range newAssigned {
+		topicOffsets := make(map[int32]Offset)
+		offsets[topic] = topicOffsets
+		for _, partition := range partitions {
+			offset := g.cl.cfg.resetOffset
+			if eo, ok := loaded[topic][partition]; ok && eo.Offset >= 0 {
+				offset = Offset{
+					at:    eo.Offset,
+					epoch: eo.Epoch,
+				}
+			}
+			topicOffsets[partition] = offset
+		}
+	}
+	return g.assignFetchedOffsets(offsets)
+}
+
+// assignFetchedOffsets assigns newly fetched committed offsets, beginning
+// consuming the new partitions.
+func (g *groupConsumer) assignFetchedOffsets(offsets map[string]map[int32]Offset) error {
 	groupTopics := g.tps.load()
 	for fetchedTopic := range offsets {
 		if !groupTopics.hasTopic(fetchedTopic) {
@@ -1352,9 +1643,23 @@ func (g *groupConsumer) findNewAssignments() {
 	}
 
 	var numNewTopics int
+	var deleted []string
 	toChange := make(map[string]change, len(topics))
 	for topic, topicPartitions := range topics {
 		numPartitions := len(topicPartitions.load().partitions)
+
+		// If we are consuming via regex and a topic we matched was
+		// deleted, we drop it so that we no longer subscribe to it. We
+		// forget that we saw it so that it is matched again if it is
+		// recreated.
+		if g.regexTopics && topicPartitions.load().loadErr == kerr.UnknownTopicOrPartition {
+			if _, exists := g.using[topic]; exists {
+				deleted = append(deleted, topic)
+			}
+			delete(g.reSeen, topic)
+			continue
+		}
+
 		// If we are already using this topic, add that it changed if
 		// there are more partitions than we were using prior.
 		if used, exists := g.using[topic]; exists {
@@ -1393,7 +1698,7 @@ func (g *groupConsumer) findNewAssignments() {
 
 	}
 
-	if len(toChange) == 0 {
+	if len(toChange) == 0 && len(deleted) == 0 {
 		return
 	}
 
@@ -1408,6 +1713,13 @@ func (g *groupConsumer) findNewAssignments() {
 	for topic, change := range toChange {
 		g.using[topic] += change.delta
 	}
+	if len(deleted) > 0 {
+		g.cl.cfg.logger.Log(LogLevelInfo, "regex matched topics were deleted, removing them from the group subscription", "topics", deleted)
+		for _, topic := range deleted {
+			delete(g.using, topic)
+		}
+		numNewTopics++ // a changed subscription always needs a rejoin
+	}
 
 	if !wasManaging {
 		go g.manage()
@@ -2045,12 +2357,155 @@ func (g *groupConsumer) commit(
 			}
 		}
 
+		if g.offsetStore != nil {
+			if err := g.offsetStore.StoreOffsets(commitCtx, g.id, uncommitted); err != nil {
+				onDone(req, nil, err)
+				return
+			}
+			resp := storedCommitResponse(req)
+			g.updateCommitted(req, resp)
+			onDone(req, resp, nil)
+			return
+		}
+
 		resp, err := req.RequestWith(commitCtx, g.cl)
 		if err != nil {
 			onDone(req, nil, err)
 			return
 		}
 		g.updateCommitted(req, resp)
+		if g.onCommitRebalance != nil {
+			g.retryRebalancingCommit(commitCtx, req, resp)
+		}
 		onDone(req, resp, nil)
 	}()
 }
+
+// storedCommitResponse returns a successful response for a commit that was
+// persisted to an OffsetStore.
+func storedCommitResponse(req *kmsg.OffsetCommitRequest) *kmsg.OffsetCommitResponse {
+	resp := req.ResponseKind().(*kmsg.OffsetCommitResponse)
+	for _, t := range req.Topics {
+		rt := kmsg.OffsetCommitResponseTopic{Topic: t.Topic}
+		for _, p := range t.Partitions {
+			rt.Partitions = append(rt.Partitions, kmsg.OffsetCommitResponseTopicPartition{Partition: p.Partition})
+		}
+		resp.Topics = append(resp.Topics, rt)
+	}
+	return resp
+}
+
+// retryRebalancingCommit applies the user's CommitRebalancePolicy to any
+// partitions in resp that failed with RebalanceInProgress. If the policy is
+// to retry, this waits for the group to be stable, recommits the partitions
+// that are still assigned, and merges the retried results into resp.
+func (g *groupConsumer) retryRebalancingCommit(
+	ctx context.Context,
+	req *kmsg.OffsetCommitRequest,
+	resp *kmsg.OffsetCommitResponse,
+) {
+	failed := make(map[string][]int32)
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			if p.ErrorCode == kerr.RebalanceInProgress.Code {
+				failed[t.Topic] = append(failed[t.Topic], p.Partition)
+			}
+		}
+	}
+	if len(failed) == 0 || g.onCommitRebalance(failed) != CommitRebalanceRetry {
+		return
+	}
+
+	g.mu.Lock()
+	if g.revoking {
+		g.mu.Unlock()
+		g.cl.cfg.logger.Log(LogLevelInfo, "not retrying commit that failed with RebalanceInProgress while revoking", "group", g.id, "partitions", failed)
+		return
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, g.rebalanceTimeout)
+	defer cancel()
+	g.retryCancel = cancel
+	g.mu.Unlock()
+	defer func() {
+		g.mu.Lock()
+		g.retryCancel = nil
+		g.mu.Unlock()
+	}()
+
+	g.cl.cfg.logger.Log(LogLevelInfo, "commit failed with RebalanceInProgress, waiting for the rebalance to complete to retry", "group", g.id, "partitions", failed)
+	if err := g.waitStableAfter(waitCtx, req.Generation); err != nil {
+		g.cl.cfg.logger.Log(LogLevelInfo, "unable to retry commit after RebalanceInProgress", "group", g.id, "err", err)
+		return
+	}
+
+	// We only retry partitions that are still ours; revoked partitions
+	// were removed from uncommitted when revoking.
+	g.mu.Lock()
+	retry := &kmsg.OffsetCommitRequest{
+		Group:      g.id,
+		Generation: g.generation,
+		MemberID:   g.memberID,
+		InstanceID: g.instanceID,
+	}
+	for _, t := range req.Topics {
+		partitions := failed[t.Topic]
+		if len(partitions) == 0 {
+			continue
+		}
+		var reqTopic *kmsg.OffsetCommitRequestTopic
+		for _, p := range t.Partitions {
+			if _, ok := g.uncommitted[t.Topic][p.Partition]; !ok {
+				continue
+			}
+			var wasFailed bool
+			for _, failedPartition := range partitions {
+				if failedPartition == p.Partition {
+					wasFailed = true
+					break
+				}
+			}
+			if !wasFailed {
+				continue
+			}
+			if reqTopic == nil {
+				retry.Topics = append(retry.Topics, kmsg.OffsetCommitRequestTopic{Topic: t.Topic})
+				reqTopic = &retry.Topics[len(retry.Topics)-1]
+			}
+			p.Metadata = &retry.MemberID
+			reqTopic.Partitions = append(reqTopic.Partitions, p)
+		}
+	}
+	g.mu.Unlock()
+
+	if len(retry.Topics) == 0 {
+		return
+	}
+
+	retryResp, err := retry.RequestWith(ctx, g.cl)
+	if err != nil {
+		g.cl.cfg.logger.Log(LogLevelInfo, "retried commit after RebalanceInProgress failed", "group", g.id, "err", err)
+		return
+	}
+	g.updateCommitted(retry, retryResp)
+
+	// Merge the retried partition results into the original response so
+	// that the caller sees the final outcome for every partition.
+	retried := make(map[string]map[int32]int16)
+	for _, t := range retryResp.Topics {
+		for _, p := range t.Partitions {
+			if retried[t.Topic] == nil {
+				retried[t.Topic] = make(map[int32]int16)
+			}
+			retried[t.Topic][p.Partition] = p.ErrorCode
+		}
+	}
+	for i := range resp.Topics {
+		t := &resp.Topics[i]
+		for j := range t.Partitions {
+			p := &t.Partitions[j]
+			if code, ok := retried[t.Topic][p.Partition]; ok {
+				p.ErrorCode = code
+			}
+		}
+	}
+}
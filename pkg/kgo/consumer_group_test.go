@@ -0,0 +1,594 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// fakeGroup answers group coordinator requests on a fake cluster for a group
+// with a single member, which leads the group and is assigned whatever its
+// balancer plans. Offset fetches return no committed offsets, list offsets
+// return offset 0, and fetches return no records.
+type fakeGroup struct {
+	mu          sync.Mutex
+	generation  int32
+	rebalancing bool                       // heartbeats fail with RebalanceInProgress until the next join
+	fetched     map[string]map[int32]int64 // the latest fetch offset of each partition
+}
+
+func newFakeGroup(c *fakecluster.Cluster) *fakeGroup {
+	g := &fakeGroup{fetched: make(map[string]map[int32]int64)}
+	c.Control(11, g.join)
+	c.Control(14, g.sync)
+	c.Control(12, g.heartbeat)
+	c.Control(13, func(req kmsg.Request) (kmsg.Response, error) {
+		return req.ResponseKind(), nil
+	})
+	c.Control(9, func(req kmsg.Request) (kmsg.Response, error) {
+		resp := req.ResponseKind().(*kmsg.OffsetFetchResponse)
+		for _, rt := range req.(*kmsg.OffsetFetchRequest).Topics {
+			st := kmsg.NewOffsetFetchResponseTopic()
+			st.Topic = rt.Topic
+			for _, p := range rt.Partitions {
+				sp := kmsg.NewOffsetFetchResponseTopicPartition()
+				sp.Partition, sp.Offset = p, -1
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil
+	})
+	c.Control(2, func(req kmsg.Request) (kmsg.Response, error) {
+		resp := req.ResponseKind().(*kmsg.ListOffsetsResponse)
+		for _, rt := range req.(*kmsg.ListOffsetsRequest).Topics {
+			st := kmsg.NewListOffsetsResponseTopic()
+			st.Topic = rt.Topic
+			for _, rp := range rt.Partitions {
+				sp := kmsg.NewListOffsetsResponseTopicPartition()
+				sp.Partition = rp.Partition
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil
+	})
+	c.Control(1, g.fetch)
+	return g
+}
+
+func (g *fakeGroup) join(req kmsg.Request) (kmsg.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.generation++
+	g.rebalancing = false
+
+	join := req.(*kmsg.JoinGroupRequest)
+	resp := join.ResponseKind().(*kmsg.JoinGroupResponse)
+	resp.Generation = g.generation
+	resp.ProtocolType = kmsg.StringPtr(join.ProtocolType)
+	resp.Protocol = kmsg.StringPtr(join.Protocols[0].Name)
+	resp.LeaderID, resp.MemberID = "m", "m"
+	member := kmsg.NewJoinGroupResponseMember()
+	member.MemberID = "m"
+	member.ProtocolMetadata = join.Protocols[0].Metadata
+	resp.Members = append(resp.Members, member)
+	return resp, nil
+}
+
+func (g *fakeGroup) sync(req kmsg.Request) (kmsg.Response, error) {
+	sync := req.(*kmsg.SyncGroupRequest)
+	resp := sync.ResponseKind().(*kmsg.SyncGroupResponse)
+	resp.ProtocolType, resp.Protocol = sync.ProtocolType, sync.Protocol
+	for _, a := range sync.GroupAssignment {
+		if a.MemberID == "m" {
+			resp.MemberAssignment = a.MemberAssignment
+		}
+	}
+	return resp, nil
+}
+
+func (g *fakeGroup) heartbeat(req kmsg.Request) (kmsg.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	resp := req.ResponseKind().(*kmsg.HeartbeatResponse)
+	if g.rebalancing {
+		resp.ErrorCode = kerr.RebalanceInProgress.Code
+	}
+	return resp, nil
+}
+
+func (g *fakeGroup) fetch(req kmsg.Request) (kmsg.Response, error) {
+	time.Sleep(10 * time.Millisecond) // avoid spinning on empty fetches
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	resp := req.ResponseKind().(*kmsg.FetchResponse)
+	for _, rt := range req.(*kmsg.FetchRequest).Topics {
+		st := kmsg.NewFetchResponseTopic()
+		st.Topic = rt.Topic
+		for _, rp := range rt.Partitions {
+			if g.fetched[rt.Topic] == nil {
+				g.fetched[rt.Topic] = make(map[int32]int64)
+			}
+			g.fetched[rt.Topic][rp.Partition] = rp.FetchOffset
+			sp := kmsg.NewFetchResponseTopicPartition()
+			sp.Partition = rp.Partition
+			sp.HighWatermark, sp.LastStableOffset = rp.FetchOffset, rp.FetchOffset
+			st.Partitions = append(st.Partitions, sp)
+		}
+		resp.Topics = append(resp.Topics, st)
+	}
+	return resp, nil
+}
+
+// rebalance fails heartbeats with RebalanceInProgress until the member
+// rejoins.
+func (g *fakeGroup) rebalance() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rebalancing = true
+}
+
+// fetchedAt returns the latest offset the partition was fetched at.
+func (g *fakeGroup) fetchedAt(topic string, partition int32) (int64, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	offset, ok := g.fetched[topic][partition]
+	return offset, ok
+}
+
+// pollUntilDone polls the client until the returned function is called, so
+// that the client keeps fetching.
+func pollUntilDone(cl *Client) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ctx.Err() == nil {
+			cl.PollFetches(ctx)
+		}
+	}()
+	return func() { cancel(); <-done }
+}
+
+// waitFor waits up to 5s for fn to return true.
+func waitFor(t *testing.T, what string, fn func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// A commit can fail with RebalanceInProgress before we have noticed the
+// rebalance ourselves, at which point the stable channel is still closed from
+// the session that is ending. The retry must wait for the next session rather
+// than immediately recommitting with the old generation.
+func TestRetryRebalancingCommitWaitsForNewSession(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	var (
+		mu   sync.Mutex
+		gens []int32
+	)
+	c.Control(8, func(req kmsg.Request) (kmsg.Response, error) {
+		commit := req.(*kmsg.OffsetCommitRequest)
+		mu.Lock()
+		gens = append(gens, commit.Generation)
+		mu.Unlock()
+		resp := req.ResponseKind().(*kmsg.OffsetCommitResponse)
+		for _, t := range commit.Topics {
+			rt := kmsg.OffsetCommitResponseTopic{Topic: t.Topic}
+			for _, p := range t.Partitions {
+				rt.Partitions = append(rt.Partitions, kmsg.OffsetCommitResponseTopicPartition{Partition: p.Partition})
+			}
+			resp.Topics = append(resp.Topics, rt)
+		}
+		return resp, nil
+	})
+
+	cl, err := NewClient(SeedBrokers(c.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	g := &groupConsumer{
+		cl:               cl,
+		ctx:              context.Background(),
+		id:               "g",
+		rebalanceTimeout: 10 * time.Second,
+		stableCh:         make(chan struct{}),
+		stableChange:     make(chan struct{}),
+		generation:       1,
+		uncommitted: uncommitted{"t": {0: uncommit{
+			head: EpochOffset{-1, 10},
+		}}},
+		onCommitRebalance: func(map[string][]int32) CommitRebalancePolicy { return CommitRebalanceRetry },
+	}
+	g.setStable(1)
+
+	req := &kmsg.OffsetCommitRequest{
+		Group:      "g",
+		Generation: 1,
+		Topics: []kmsg.OffsetCommitRequestTopic{{
+			Topic:      "t",
+			Partitions: []kmsg.OffsetCommitRequestTopicPartition{{Partition: 0, Offset: 10}},
+		}},
+	}
+	resp := &kmsg.OffsetCommitResponse{
+		Topics: []kmsg.OffsetCommitResponseTopic{{
+			Topic:      "t",
+			Partitions: []kmsg.OffsetCommitResponseTopicPartition{{Partition: 0, ErrorCode: kerr.RebalanceInProgress.Code}},
+		}},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.retryRebalancingCommit(context.Background(), req, resp)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("retry returned while the stale session was still marked stable")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if n := c.NumReqs(8); n != 0 {
+		t.Fatalf("got %d commits before the rebalance completed, exp 0", n)
+	}
+
+	// The rebalance begins and completes with a new generation.
+	g.setUnstable()
+	g.mu.Lock()
+	g.generation = 2
+	g.mu.Unlock()
+	g.setStable(2)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("retry did not complete after the new session became stable")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(gens) != 1 || gens[0] != 2 {
+		t.Errorf("got retried commit generations %v != exp [2]", gens)
+	}
+	if code := resp.Topics[0].Partitions[0].ErrorCode; code != 0 {
+		t.Errorf("got merged error code %d != exp 0", code)
+	}
+}
+
+type testOffsetStore struct {
+	mu        sync.Mutex
+	failLoads int // how many loads fail before loads succeed
+	loads     int
+	offsets   map[string]map[int32]EpochOffset
+}
+
+func (s *testOffsetStore) LoadOffsets(_ context.Context, group string, partitions map[string][]int32) (map[string]map[int32]EpochOffset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loads++
+	if s.loads <= s.failLoads {
+		return nil, errors.New("load failed")
+	}
+	return s.offsets, nil
+}
+
+func (s *testOffsetStore) StoreOffsets(_ context.Context, group string, offsets map[string]map[int32]EpochOffset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offsets = offsets
+	return nil
+}
+
+func TestOffsetStoreLoadAndStore(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"t": 1})
+	defer c.Close()
+	fg := newFakeGroup(c)
+
+	store := &testOffsetStore{
+		failLoads: 1,
+		offsets:   map[string]map[int32]EpochOffset{"t": {0: {-1, 5}}},
+	}
+	cl, err := NewClient(SeedBrokers(c.Addr()), FetchMaxWait(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	cl.AssignGroup("g",
+		GroupTopics("t"),
+		GroupOffsetStore(store),
+		HeartbeatInterval(100*time.Millisecond),
+		DisableAutoCommit(),
+	)
+	defer pollUntilDone(cl)()
+
+	// The first load fails, which fails the session and rejoins; the
+	// second load succeeds and we begin consuming at the stored offset.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cl.WaitForGroupAssignment(ctx); err != nil {
+		t.Fatal(err)
+	}
+	store.mu.Lock()
+	loads := store.loads
+	store.mu.Unlock()
+	if loads < 2 {
+		t.Errorf("got %d loads, want at least 2", loads)
+	}
+	if n := c.NumReqs(11); n < 2 {
+		t.Errorf("got %d joins after a failed load, want at least 2", n)
+	}
+	waitFor(t, "fetch at the stored offset", func() bool {
+		offset, ok := fg.fetchedAt("t", 0)
+		return ok && offset == 5
+	})
+
+	done := make(chan error, 1)
+	commit := map[string]map[int32]EpochOffset{"t": {0: {-1, 9}}}
+	cl.CommitOffsets(ctx, commit, func(_ *kmsg.OffsetCommitRequest, _ *kmsg.OffsetCommitResponse, err error) {
+		done <- err
+	})
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	store.mu.Lock()
+	stored := store.offsets
+	store.mu.Unlock()
+	if !reflect.DeepEqual(stored, commit) {
+		t.Errorf("got stored offsets %v, want %v", stored, commit)
+	}
+	if n := c.NumReqs(9) + c.NumReqs(8); n != 0 {
+		t.Errorf("got %d offset fetch and commit requests, want 0", n)
+	}
+}
+
+func TestOffsetStoreTransactionalClient(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"t": 1})
+	defer c.Close()
+
+	cl, err := NewClient(SeedBrokers(c.Addr()), TransactionalID("txn"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	s := cl.AssignGroupTransactSession("g", GroupTopics("t"), GroupOffsetStore(new(testOffsetStore)))
+	if s != nil {
+		t.Error("got a transact session with an offset store, want nil")
+	}
+	if _, ok := cl.consumer.loadGroup(); ok {
+		t.Error("group was assigned with an offset store on a transactional client")
+	}
+}
+
+// An eager revoke that waits for an in flight commit must not deadlock with
+// the commit waiting to retry after the rebalance that the revoke is part of.
+func TestRetryRebalancingCommitStopsWhenRevoking(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	cl, err := NewClient(SeedBrokers(c.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	g := &groupConsumer{
+		c:                 &cl.consumer,
+		cl:                cl,
+		ctx:               context.Background(),
+		id:                "g",
+		rebalanceTimeout:  time.Minute,
+		stableCh:          make(chan struct{}),
+		stableChange:      make(chan struct{}),
+		generation:        1,
+		nowAssigned:       map[string][]int32{"t": {0}},
+		onCommitRebalance: func(map[string][]int32) CommitRebalancePolicy { return CommitRebalanceRetry },
+	}
+	g.setStable(1)
+
+	newResp := func() *kmsg.OffsetCommitResponse {
+		return &kmsg.OffsetCommitResponse{
+			Topics: []kmsg.OffsetCommitResponseTopic{{
+				Topic:      "t",
+				Partitions: []kmsg.OffsetCommitResponseTopicPartition{{Partition: 0, ErrorCode: kerr.RebalanceInProgress.Code}},
+			}},
+		}
+	}
+	req := &kmsg.OffsetCommitRequest{
+		Group:      "g",
+		Generation: 1,
+		Topics: []kmsg.OffsetCommitRequestTopic{{
+			Topic:      "t",
+			Partitions: []kmsg.OffsetCommitRequestTopicPartition{{Partition: 0, Offset: 10}},
+		}},
+	}
+
+	// An autocommit failed and is waiting to retry.
+	resp := newResp()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.retryRebalancingCommit(context.Background(), req, resp)
+	}()
+	waitFor(t, "the commit to wait to retry", func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.retryCancel != nil
+	})
+
+	// The rebalance begins; OnRevoked waits for the in flight commit and
+	// then issues its own commit, which also fails.
+	var revokedResp *kmsg.OffsetCommitResponse
+	g.onRevoked = func(context.Context, map[string][]int32) {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("in flight commit did not finish while revoking")
+		}
+		revokedResp = newResp()
+		g.retryRebalancingCommit(context.Background(), req, revokedResp)
+	}
+	g.setUnstable()
+	g.revoke(revokeThisSession, nil, false)
+
+	for _, r := range []*kmsg.OffsetCommitResponse{resp, revokedResp} {
+		if code := r.Topics[0].Partitions[0].ErrorCode; code != kerr.RebalanceInProgress.Code {
+			t.Errorf("got error code %d, want RebalanceInProgress", code)
+		}
+	}
+	if n := c.NumReqs(8); n != 0 {
+		t.Errorf("got %d retried commits, want 0", n)
+	}
+}
+
+func TestWaitForGroupAssignment(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"t": 2})
+	defer c.Close()
+	fg := newFakeGroup(c)
+
+	cl, err := NewClient(SeedBrokers(c.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if err := cl.WaitForGroupAssignment(context.Background()); err != errNotGroup {
+		t.Fatalf("got err %v before assigning a group, want %v", err, errNotGroup)
+	}
+
+	// The second session blocks in OnAssigned until released, which
+	// keeps it unstable.
+	var (
+		assigned    int32
+		release     = make(chan struct{})
+		releaseOnce sync.Once
+	)
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+	defer unblock()
+	cl.AssignGroup("g",
+		GroupTopics("t"),
+		Balancers(RangeBalancer()),
+		HeartbeatInterval(50*time.Millisecond),
+		DisableAutoCommit(),
+		OnAssigned(func(context.Context, map[string][]int32) {
+			if atomic.AddInt32(&assigned, 1) == 2 {
+				<-release
+			}
+		}),
+	)
+	g, _ := cl.consumer.loadGroup()
+	stableGen := func() int32 {
+		g.stableMu.Lock()
+		defer g.stableMu.Unlock()
+		return g.stableGen
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cl.WaitForGroupAssignment(ctx); err != nil {
+		t.Fatalf("group did not become stable after joining: %v", err)
+	}
+	firstGen := stableGen()
+
+	// A rebalance begins; until the new session's OnAssigned returns, the
+	// assignment is not stable.
+	fg.rebalance()
+	waitFor(t, "the rebalanced session to be assigned", func() bool { return atomic.LoadInt32(&assigned) == 2 })
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer shortCancel()
+	if err := cl.WaitForGroupAssignment(shortCtx); err != context.DeadlineExceeded {
+		t.Fatalf("got err %v while rebalancing, want %v", err, context.DeadlineExceeded)
+	}
+
+	unblock()
+	if err := cl.WaitForGroupAssignment(ctx); err != nil {
+		t.Fatalf("group did not become stable after rebalancing: %v", err)
+	}
+	if gen := stableGen(); gen <= firstGen {
+		t.Errorf("got stable generation %d after rebalancing, want more than %d", gen, firstGen)
+	}
+}
+
+func TestConsumeRegexDeletedTopic(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"foo-1": 1, "foo-2": 2, "bar": 1})
+	defer c.Close()
+	newFakeGroup(c)
+
+	cl, err := NewClient(
+		SeedBrokers(c.Addr()),
+		MetadataMinAge(10*time.Millisecond),
+		MetadataMaxAge(100*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	cl.AssignGroup("g",
+		ConsumeRegex("^foo-"),
+		HeartbeatInterval(50*time.Millisecond),
+		DisableAutoCommit(),
+	)
+	g, _ := cl.consumer.loadGroup()
+	using := func() map[string]int {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		dup := make(map[string]int, len(g.using))
+		for topic, partitions := range g.using {
+			dup[topic] = partitions
+		}
+		return dup
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cl.WaitForGroupAssignment(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := using(), map[string]int{"foo-1": 1, "foo-2": 2}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got subscription %v, want %v", got, exp)
+	}
+	joins := c.NumReqs(11)
+
+	// Once metadata shows foo-2 is gone, it is dropped from the
+	// subscription and the group is rejoined.
+	c.DeleteTopic("foo-2")
+	waitFor(t, "foo-2 to be dropped", func() bool {
+		return reflect.DeepEqual(using(), map[string]int{"foo-1": 1})
+	})
+	waitFor(t, "a rejoin", func() bool { return c.NumReqs(11) > joins })
+	if err := cl.WaitForGroupAssignment(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
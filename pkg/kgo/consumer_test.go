@@ -0,0 +1,127 @@
+package kgo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestOnOffsetOutOfRange(t *testing.T) {
+	t.Parallel()
+
+	const logStart, logEnd = 10, 20
+
+	for _, test := range []struct {
+		name     string
+		resetTo  int64
+		expFetch int64
+	}{
+		{"within bounds", logEnd - 3, logEnd - 3},
+		{"bounded to end", logEnd + 100, logEnd},
+		{"bounded to start", 0, logStart},
+	} {
+		c := fakecluster.New(t, map[string]int32{"t": 1})
+		defer c.Close()
+
+		fetched := make(chan int64, 100)
+		c.Control(1, func(req kmsg.Request) (kmsg.Response, error) {
+			resp := req.ResponseKind().(*kmsg.FetchResponse)
+			for _, rt := range req.(*kmsg.FetchRequest).Topics {
+				st := kmsg.NewFetchResponseTopic()
+				st.Topic = rt.Topic
+				for _, rp := range rt.Partitions {
+					sp := kmsg.NewFetchResponseTopicPartition()
+					sp.Partition = rp.Partition
+					sp.HighWatermark = logEnd
+					sp.LastStableOffset = logEnd
+					sp.LogStartOffset = logStart
+					if rp.FetchOffset < logStart || rp.FetchOffset > logEnd {
+						sp.ErrorCode = kerr.OffsetOutOfRange.Code
+					}
+					st.Partitions = append(st.Partitions, sp)
+					select {
+					case fetched <- rp.FetchOffset:
+					default:
+					}
+				}
+				resp.Topics = append(resp.Topics, st)
+			}
+			time.Sleep(5 * time.Millisecond) // avoid spinning on empty fetches
+			return resp, nil
+		})
+		c.Control(2, func(req kmsg.Request) (kmsg.Response, error) {
+			resp := req.ResponseKind().(*kmsg.ListOffsetsResponse)
+			for _, rt := range req.(*kmsg.ListOffsetsRequest).Topics {
+				st := kmsg.NewListOffsetsResponseTopic()
+				st.Topic = rt.Topic
+				for _, rp := range rt.Partitions {
+					sp := kmsg.NewListOffsetsResponseTopicPartition()
+					sp.Partition = rp.Partition
+					sp.Offset = logStart
+					if rp.Timestamp == -1 {
+						sp.Offset = logEnd
+					}
+					st.Partitions = append(st.Partitions, sp)
+				}
+				resp.Topics = append(resp.Topics, st)
+			}
+			return resp, nil
+		})
+
+		var (
+			mu   sync.Mutex
+			args [][5]interface{}
+		)
+		resetTo := test.resetTo
+		cl, err := NewClient(
+			SeedBrokers(c.Addr()),
+			OnOffsetOutOfRange(func(topic string, partition int32, committed, start, end int64) int64 {
+				mu.Lock()
+				defer mu.Unlock()
+				args = append(args, [5]interface{}{topic, partition, committed, start, end})
+				return resetTo
+			}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cl.Close()
+		cl.AssignPartitions(ConsumePartitions(map[string]map[int32]Offset{"t": {0: NewOffset().At(5)}}))
+
+		// Fetching continues only as fetches are polled.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			for ctx.Err() == nil {
+				cl.PollFetches(ctx)
+			}
+		}()
+
+		timeout := time.After(5 * time.Second)
+	wait:
+		for {
+			select {
+			case o := <-fetched:
+				if o == test.expFetch {
+					break wait
+				}
+				if o != 5 {
+					t.Fatalf("%s: got fetch offset %d != exp the out of range 5 or the reset %d", test.name, o, test.expFetch)
+				}
+			case <-timeout:
+				t.Fatalf("%s: never fetched the reset offset %d", test.name, test.expFetch)
+			}
+		}
+
+		mu.Lock()
+		if len(args) == 0 || args[0] != [5]interface{}{"t", int32(0), int64(5), int64(logStart), int64(logEnd)} {
+			t.Errorf("%s: got OnOffsetOutOfRange calls %v, exp the first with t, 0, 5, %d, %d", test.name, args, logStart, logEnd)
+		}
+		mu.Unlock()
+	}
+}
@@ -0,0 +1,135 @@
+package kgo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+)
+
+// DedupKeyHeader is the record header key that StampDedupKey uses to store a
+// record's dedup key, and that a Deduper checks.
+const DedupKeyHeader = "kgo-dedup-key"
+
+// StampDedupKey adds a DedupKeyHeader header to the record, replacing any
+// existing one. If key is nil, the dedup key is a SHA-256 hash of the record's
+// topic, key, and value, meaning that identical records produced to the same
+// topic are considered duplicates.
+//
+// Kafka's idempotent producer only deduplicates retries within one producer
+// ID, which changes when the producer restarts. A dedup key is stable across
+// restarts, allowing consumers to drop records an application produced twice
+// (for example, when an application replays its own input after crashing).
+func StampDedupKey(r *Record, key []byte) {
+	if key == nil {
+		h := sha256.New()
+		var lens [8]byte
+		binary.BigEndian.PutUint32(lens[:4], uint32(len(r.Topic)))
+		binary.BigEndian.PutUint32(lens[4:], uint32(len(r.Key)))
+		h.Write(lens[:])
+		h.Write([]byte(r.Topic))
+		h.Write(r.Key)
+		h.Write(r.Value)
+		key = h.Sum(nil)
+	}
+	for i := range r.Headers {
+		if r.Headers[i].Key == DedupKeyHeader {
+			r.Headers[i].Value = key
+			return
+		}
+	}
+	r.Headers = append(r.Headers, RecordHeader{Key: DedupKeyHeader, Value: key})
+}
+
+// DedupKey returns the record's DedupKeyHeader header value, and whether the
+// header exists.
+func DedupKey(r *Record) ([]byte, bool) {
+	for _, h := range r.Headers {
+		if h.Key == DedupKeyHeader {
+			return h.Value, true
+		}
+	}
+	return nil, false
+}
+
+// DedupStampHook is a ProduceRecordHook that stamps a payload hash dedup key
+// (see StampDedupKey) on every produced record that does not already have
+// one. Use it with WithHooks to stamp all records without changing produce
+// call sites.
+type DedupStampHook struct{}
+
+// OnProduceRecord implements ProduceRecordHook.
+func (DedupStampHook) OnProduceRecord(_ context.Context, r *Record) {
+	if _, ok := DedupKey(r); !ok {
+		StampDedupKey(r, nil)
+	}
+}
+
+// Deduper drops records whose dedup key (see StampDedupKey) was seen within
+// the last window keys. Records without a dedup key are never dropped.
+//
+// Keys are tracked per topic, since the same dedup key on two topics is two
+// different records. A Deduper is safe for concurrent use.
+type Deduper struct {
+	window int
+
+	mu     sync.Mutex
+	seen   map[dedupEntry]struct{}
+	ring   []dedupEntry
+	ringAt int
+}
+
+type dedupEntry struct {
+	topic string
+	key   string
+}
+
+// NewDeduper returns a Deduper that remembers the last window dedup keys.
+// A window less than one is treated as one.
+func NewDeduper(window int) *Deduper {
+	if window < 1 {
+		window = 1
+	}
+	return &Deduper{
+		window: window,
+		seen:   make(map[dedupEntry]struct{}, window),
+	}
+}
+
+// Seen returns whether the record's dedup key was already seen within the
+// window, remembering the key if not.
+func (d *Deduper) Seen(r *Record) bool {
+	key, ok := DedupKey(r)
+	if !ok {
+		return false
+	}
+	e := dedupEntry{r.Topic, string(key)}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.seen[e]; exists {
+		return true
+	}
+	if len(d.ring) < d.window {
+		d.ring = append(d.ring, e)
+	} else {
+		delete(d.seen, d.ring[d.ringAt])
+		d.ring[d.ringAt] = e
+		d.ringAt = (d.ringAt + 1) % d.window
+	}
+	d.seen[e] = struct{}{}
+	return false
+}
+
+// Filter returns the records that are not duplicates, in order. The input
+// slice is reused for the return.
+func (d *Deduper) Filter(rs []*Record) []*Record {
+	keep := rs[:0]
+	for _, r := range rs {
+		if !d.Seen(r) {
+			keep = append(keep, r)
+		}
+	}
+	return keep
+}
@@ -0,0 +1,116 @@
+package kgo
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestStampDedupKey(t *testing.T) {
+	t.Parallel()
+
+	r := &Record{Topic: "t", Key: []byte("k"), Value: []byte("v"), Headers: []RecordHeader{{Key: "other"}}}
+	if _, ok := DedupKey(r); ok {
+		t.Fatal("unexpected dedup key before stamping")
+	}
+
+	StampDedupKey(r, []byte("explicit"))
+	if key, ok := DedupKey(r); !ok || string(key) != "explicit" {
+		t.Errorf("got key %q (exists %v) != exp explicit", key, ok)
+	}
+
+	// Restamping replaces the existing header rather than adding one.
+	StampDedupKey(r, nil)
+	if len(r.Headers) != 2 {
+		t.Errorf("got %d headers != exp 2", len(r.Headers))
+	}
+	hashed, _ := DedupKey(r)
+	if len(hashed) != 32 {
+		t.Errorf("got hashed key length %d != exp 32", len(hashed))
+	}
+
+	same := &Record{Topic: "t", Key: []byte("k"), Value: []byte("v")}
+	StampDedupKey(same, nil)
+	if key, _ := DedupKey(same); !bytes.Equal(key, hashed) {
+		t.Error("identical records got different hashed keys")
+	}
+
+	// Lengths are hashed, so moving bytes between the topic, key, and
+	// value produces a different key.
+	for _, diff := range []*Record{
+		{Topic: "t", Key: []byte("kv")},
+		{Topic: "tk", Value: []byte("v")},
+		{Topic: "t", Value: []byte("kv")},
+		{Topic: "u", Key: []byte("k"), Value: []byte("v")},
+	} {
+		StampDedupKey(diff, nil)
+		if key, _ := DedupKey(diff); bytes.Equal(key, hashed) {
+			t.Errorf("record %+v got the same hashed key", diff)
+		}
+	}
+}
+
+func TestDedupStampHook(t *testing.T) {
+	t.Parallel()
+
+	var hook DedupStampHook
+
+	r := &Record{Topic: "t", Value: []byte("v")}
+	hook.OnProduceRecord(context.Background(), r)
+	if _, ok := DedupKey(r); !ok {
+		t.Error("hook did not stamp a dedup key")
+	}
+
+	stamped := &Record{Topic: "t", Value: []byte("v")}
+	StampDedupKey(stamped, []byte("mine"))
+	hook.OnProduceRecord(context.Background(), stamped)
+	if key, _ := DedupKey(stamped); string(key) != "mine" {
+		t.Errorf("hook replaced existing key with %q", key)
+	}
+}
+
+func TestDeduper(t *testing.T) {
+	t.Parallel()
+
+	rec := func(topic, key string) *Record {
+		r := &Record{Topic: topic}
+		if key != "" {
+			StampDedupKey(r, []byte(key))
+		}
+		return r
+	}
+
+	d := NewDeduper(2)
+	for i, test := range []struct {
+		r   *Record
+		exp bool
+	}{
+		{rec("t", "a"), false},
+		{rec("t", "a"), true},
+		{rec("u", "a"), false}, // keys are per topic
+		{rec("t", ""), false},  // no key is never a duplicate
+		{rec("t", ""), false},
+		{rec("t", "b"), false}, // evicts t/a
+		{rec("t", "a"), false},
+		{rec("t", "b"), true},
+	} {
+		if got := d.Seen(test.r); got != test.exp {
+			t.Errorf("#%d: got seen %v != exp %v", i, got, test.exp)
+		}
+	}
+
+	d = NewDeduper(0) // treated as one
+	if d.Seen(rec("t", "a")) || !d.Seen(rec("t", "a")) {
+		t.Error("window of zero did not remember the last key")
+	}
+	if d.Seen(rec("t", "b")) || d.Seen(rec("t", "a")) {
+		t.Error("window of zero remembered more than one key")
+	}
+
+	d = NewDeduper(10)
+	in := []*Record{rec("t", "a"), rec("t", "b"), rec("t", "a"), rec("t", ""), rec("t", "b")}
+	got := d.Filter(in)
+	if len(got) != 3 || got[0] != in[0] || got[1] != in[1] || got[2] != in[3] {
+		t.Errorf("got filtered %v != exp the first, second, and fourth records", got)
+	}
+}
@@ -0,0 +1,73 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInterleaveFamilies(t *testing.T) {
+	ips := []string{"::1", "::2", "::3", "10.0.0.1", "10.0.0.2"}
+	got := interleaveFamilies(ips)
+	exp := []string{"::1", "10.0.0.1", "::2", "10.0.0.2", "::3"}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v != exp %v", got, exp)
+	}
+}
+
+func TestDialStaggered(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	// The first address hangs until canceled, the second fails
+	// immediately, and the third connects: the third should be started
+	// right after the second fails, well before the hang would time out.
+	dialFn := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(addr)
+		switch host {
+		case "10.0.0.1":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case "10.0.0.2":
+			return nil, errors.New("refused")
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	conn, addr, err := dialStaggered(ctx, dialFn, []string{"10.0.0.1", "10.0.0.2", "127.0.0.1"}, port, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected dial err: %v", err)
+	}
+	defer conn.Close()
+	if exp := net.JoinHostPort("127.0.0.1", port); addr != exp {
+		t.Errorf("got dialed addr %s != exp %s", addr, exp)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("dial took %v, longer than expected", elapsed)
+	}
+
+	_, _, err = dialStaggered(ctx, dialFn, []string{"10.0.0.2"}, port, 50*time.Millisecond)
+	if err == nil {
+		t.Error("expected dial err when every address fails")
+	}
+}
@@ -0,0 +1,110 @@
+package kgo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestBrokerDrainRefusedConnection(t *testing.T) {
+	cl, err := NewClient(
+		SeedBrokers("127.0.0.1:1"), // nothing listens; dials are refused
+		BrokerDrainCooldown(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	// A refused connection is not a shutdown signal: nothing may have
+	// been listening to begin with.
+	b := cl.brokers[unknownSeedID(0)]
+	if _, err := b.loadConnection(context.Background(), 3); err == nil {
+		t.Fatal("expected dial err")
+	}
+	if b.isDraining() {
+		t.Error("broker draining after a refused connection")
+	}
+}
+
+func TestBrokerDrain(t *testing.T) {
+	c := fakecluster.New(t, nil) // no topics: metadata shows no leaders
+	defer c.Close()
+
+	cl, err := NewClient(
+		SeedBrokers(c.Addr()),
+		BrokerDrainCooldown(time.Hour),
+		RequestRetries(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	anyDraining := func() bool {
+		cl.brokersMu.RLock()
+		defer cl.brokersMu.RUnlock()
+		for _, b := range cl.brokers {
+			if b.isDraining() {
+				return true
+			}
+		}
+		return false
+	}
+
+	// BROKER_NOT_AVAILABLE is about some other broker, not the broker
+	// that replied.
+	c.Control(10, func(req kmsg.Request) (kmsg.Response, error) {
+		resp := req.ResponseKind().(*kmsg.FindCoordinatorResponse)
+		resp.ErrorCode = kerr.BrokerNotAvailable.Code
+		return resp, nil
+	})
+	cl.Request(ctx, kmsg.NewPtrFindCoordinatorRequest())
+	if anyDraining() {
+		t.Fatal("broker draining after replying BROKER_NOT_AVAILABLE")
+	}
+
+	// Closing a connection with a request in flight is what a broker
+	// does when shutting down.
+	c.Control(16, func(kmsg.Request) (kmsg.Response, error) {
+		return nil, fakecluster.ErrCloseConn
+	})
+	if _, err := cl.Broker(0).Request(ctx, kmsg.NewPtrListGroupsRequest()); err == nil {
+		t.Fatal("expected request err on the closed connection")
+	}
+	cl.brokersMu.RLock()
+	b := cl.brokers[0]
+	cl.brokersMu.RUnlock()
+	if !b.isDraining() {
+		t.Fatal("broker not draining after closing a connection with a request in flight")
+	}
+	if _, err := b.loadConnection(ctx, 16); err != errBrokerDraining {
+		t.Errorf("got load err %v != exp %v", err, errBrokerDraining)
+	}
+
+	// Metadata showing the broker leading a partition again clears the
+	// draining state.
+	c.Control(3, func(req kmsg.Request) (kmsg.Response, error) {
+		resp := c.Metadata(req.(*kmsg.MetadataRequest))
+		p := kmsg.NewMetadataResponseTopicPartition()
+		p.Leader = 0
+		mt := kmsg.NewMetadataResponseTopic()
+		mt.Topic = "t"
+		mt.Partitions = append(mt.Partitions, p)
+		resp.Topics = append(resp.Topics, mt)
+		return resp, nil
+	})
+	if _, err := cl.Request(ctx, kmsg.NewPtrMetadataRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if b.isDraining() {
+		t.Error("broker still draining after metadata showed it leading a partition")
+	}
+}
@@ -1,8 +1,12 @@
 package kgo
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 )
 
 type errDeadConn struct {
@@ -21,12 +25,7 @@ func isRetriableBrokerErr(err error) bool {
 	if errors.As(err, &tempErr) {
 		return tempErr.Temporary()
 	}
-	switch err {
-	case errChosenBrokerDead,
-		errCorrelationIDMismatch:
-		return true
-	}
-	return false
+	return err == errChosenBrokerDead || err == errBrokerDraining
 }
 
 var (
@@ -38,14 +37,12 @@ var (
 	// stopped due to a concurrent metadata response.
 	errChosenBrokerDead = errors.New("the internal broker struct chosen to issue this requesthas died--either the broker id is migrating or no longer exists")
 
-	errProducerIDLoadFail = errors.New("unable to initialize a producer ID due to request retry limits")
+	// A temporary error returned when a request must go to a broker that
+	// is draining (see BrokerDrainCooldown) and that has no connection
+	// open.
+	errBrokerDraining = errors.New("the broker chosen to issue this request is draining after appearing to shut down")
 
-	// A temporary error returned when Kafka replies with a different
-	// correlation ID than we were expecting for the request the client
-	// issued.
-	//
-	// If this error happens, the client closes the broker connection.
-	errCorrelationIDMismatch = errors.New("correlation ID mismatch")
+	errProducerIDLoadFail = errors.New("unable to initialize a producer ID due to request retry limits")
 
 	// Returned when using a kmsg.Request with a key larger than kmsg.MaxKey.
 	errUnknownRequestKey = errors.New("request key is unknown")
@@ -65,6 +62,9 @@ var (
 	// Returned when trying to produce a record outside of a transaction.
 	errNotInTransaction = errors.New("cannot produce record transactionally if not in a transaction")
 
+	// Returned when trying to produce a tombstone without a key.
+	errTombstoneNilKey = errors.New("cannot produce a tombstone with a nil key")
+
 	// Returned when issuing a request to a broker that the client does not
 	// know about.
 	errUnknownBroker = errors.New("unknown broker")
@@ -88,6 +88,19 @@ var (
 	// ErrAborting is returned for all buffered records while
 	// AbortBufferedRecords is being called.
 	ErrAborting = errors.New("client is aborting buffered records")
+
+	// ErrNoReachableBrokers is returned from Produce when the client is
+	// configured with FailFastIfNoBroker and the last dial to every broker
+	// failed.
+	ErrNoReachableBrokers = errors.New("fail fast is enabled and no brokers are reachable, cannot buffer records")
+
+	// ErrRecordDropped is passed to the promise of records that are
+	// dropped because of the ProduceOverflowPolicy.
+	ErrRecordDropped = errors.New("record was dropped because the maximum amount of records are buffered")
+
+	// ErrRecordPurged is passed to the promise of records that are failed
+	// because PurgeBufferedProduce is called.
+	ErrRecordPurged = errors.New("record was purged from the produce buffer before it was successfully produced")
 )
 
 // ErrDataLoss is returned for Kafka >=2.1.0 when data loss is detected and the
@@ -111,6 +124,200 @@ func (e *ErrDataLoss) Error() string {
 		e.Topic, e.Partition, e.ConsumedTo, e.ResetTo)
 }
 
+// ErrCorrelationIDMismatch is a temporary error returned when Kafka replies
+// with a different correlation ID than the client was expecting for the
+// request it issued. This can happen if something between the client and the
+// broker, such as a proxy, interleaves responses.
+//
+// If this error happens, the client closes the broker connection.
+type ErrCorrelationIDMismatch struct {
+	// Expected is the correlation ID of the request the client issued.
+	Expected int32
+	// Got is the correlation ID in the response the client read.
+	Got int32
+}
+
+func (e *ErrCorrelationIDMismatch) Error() string {
+	return fmt.Sprintf("correlation ID mismatch: expected %d, got %d", e.Expected, e.Got)
+}
+
+// Temporary returns true: requests that fail with a correlation ID mismatch
+// can be retried on a new connection.
+func (*ErrCorrelationIDMismatch) Temporary() bool { return true }
+
+// ErrBrokerCircuitOpen is returned for requests that need a new connection to
+// a broker whose circuit breaker is open; see BrokerCircuitBreaker.
+type ErrBrokerCircuitOpen struct {
+	// Broker is the ID of the broker the request was for.
+	Broker int32
+	// Until is when the breaker allows a probe dial to the broker.
+	Until time.Time
+}
+
+func (e *ErrBrokerCircuitOpen) Error() string {
+	return fmt.Sprintf("broker %d circuit breaker is open after repeated connection failures, next dial allowed at %s",
+		e.Broker, e.Until.Format(time.RFC3339Nano))
+}
+
+// ErrOffsetMismatch is returned from ProduceExpectOffset when Kafka assigns a
+// produced record an offset other than the expected offset.
+type ErrOffsetMismatch struct {
+	// Topic is the topic the record was produced to.
+	Topic string
+	// Partition is the partition the record was produced to.
+	Partition int32
+	// Expected is the offset the record was expected to be assigned.
+	Expected int64
+	// Got is the offset Kafka actually assigned to the record.
+	Got int64
+}
+
+func (e *ErrOffsetMismatch) Error() string {
+	return fmt.Sprintf("topic %s partition %d produced record was assigned offset %d,"+
+		" but offset %d was expected", e.Topic, e.Partition, e.Got, e.Expected)
+}
+
+// ErrRecordTooLarge is passed to the promise of records that encode larger
+// than the MaxRecordSize.
+type ErrRecordTooLarge struct {
+	// Topic is the topic the record was to be produced to.
+	Topic string
+	// Size is the encoded size of the record.
+	Size int32
+	// Max is the configured maximum.
+	Max int32
+}
+
+func (e *ErrRecordTooLarge) Error() string {
+	return fmt.Sprintf("topic %s record of encoded size %d is larger than the max record size %d",
+		e.Topic, e.Size, e.Max)
+}
+
+// ErrPartitionCountMismatch is passed to the promise of records whose topic
+// does not have the number of partitions expected by the function given to
+// PartitionCountAssertion.
+type ErrPartitionCountMismatch struct {
+	// Topic is the topic the record was to be produced to.
+	Topic string
+	// Expected is the partition count the partitioner assumes.
+	Expected int
+	// Actual is the topic's current partition count.
+	Actual int
+}
+
+func (e *ErrPartitionCountMismatch) Error() string {
+	return fmt.Sprintf("topic %s has %d partitions, but the partitioner expects %d",
+		e.Topic, e.Actual, e.Expected)
+}
+
+// ErrAddPartitionsToTxn is returned when a transactional producer cannot add
+// partitions to its transaction, and is passed to the promise of every
+// buffered record, failing the transaction before it is ended. Kafka replies
+// to AddPartitionsToTxn requests per partition; this error contains every
+// partition that failed for a reason other than a retriable error.
+//
+// This error unwraps to the error of the first failed partition, sorted by
+// topic and partition.
+type ErrAddPartitionsToTxn struct {
+	// Errors are the errors for every partition that could not be added to
+	// the transaction, keyed by topic then partition.
+	Errors map[string]map[int32]error
+}
+
+func (e *ErrAddPartitionsToTxn) sorted() []string {
+	topics := make([]string, 0, len(e.Errors))
+	for topic := range e.Errors {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+func (e *ErrAddPartitionsToTxn) sortedPartitions(topic string) []int32 {
+	partitions := make([]int32, 0, len(e.Errors[topic]))
+	for partition := range e.Errors[topic] {
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	return partitions
+}
+
+func (e *ErrAddPartitionsToTxn) Error() string {
+	var parts []string
+	for _, topic := range e.sorted() {
+		for _, partition := range e.sortedPartitions(topic) {
+			parts = append(parts, fmt.Sprintf("%s[%d]: %v", topic, partition, e.Errors[topic][partition]))
+		}
+	}
+	return fmt.Sprintf("unable to add %d partition(s) to the transaction: %s", len(parts), strings.Join(parts, "; "))
+}
+
+// Unwrap returns the error of the first failed partition.
+func (e *ErrAddPartitionsToTxn) Unwrap() error {
+	for _, topic := range e.sorted() {
+		for _, partition := range e.sortedPartitions(topic) {
+			return e.Errors[topic][partition]
+		}
+	}
+	return nil
+}
+
+// ErrThrottleExceedsDeadline is returned for requests that would be written
+// to a broker that is throttling the client for longer than the remaining
+// time until the request context's deadline. Rather than waiting out the
+// throttle only to hit the deadline, the request fails immediately without
+// being written.
+//
+// This error unwraps to context.DeadlineExceeded.
+type ErrThrottleExceedsDeadline struct {
+	// Broker is the ID of the throttling broker.
+	Broker int32
+	// Throttle is how much longer the broker is throttling the client.
+	Throttle time.Duration
+	// Remaining is how long was left until the request's deadline.
+	Remaining time.Duration
+}
+
+func (e *ErrThrottleExceedsDeadline) Error() string {
+	return fmt.Sprintf("broker %d is throttling for %v, exceeding the request's remaining deadline of %v",
+		e.Broker, e.Throttle, e.Remaining)
+}
+
+func (*ErrThrottleExceedsDeadline) Unwrap() error { return context.DeadlineExceeded }
+
+// errWriteRejected wraps an error returned from a BrokerWriteSizeHook.
+type errWriteRejected struct {
+	err error
+}
+
+func (e *errWriteRejected) Error() string {
+	return fmt.Sprintf("request rejected before writing: %v", e.err)
+}
+
+func (e *errWriteRejected) Unwrap() error { return e.err }
+
+// ErrUnderReplicated is passed to the promise of records that were produced
+// successfully to a partition whose in sync replica count was below the
+// minimum set with MinInSyncReplicas.
+//
+// The records were written: Kafka acknowledged them. This error only signals
+// that they may be less durable than desired.
+type ErrUnderReplicated struct {
+	// Topic is the topic the records were produced to.
+	Topic string
+	// Partition is the partition the records were produced to.
+	Partition int32
+	// InSync is the number of in sync replicas the client last knew of.
+	InSync int32
+	// Min is the configured minimum.
+	Min int32
+}
+
+func (e *ErrUnderReplicated) Error() string {
+	return fmt.Sprintf("topic %s partition %d records were produced with %d in sync replicas,"+
+		" less than the required minimum %d", e.Topic, e.Partition, e.InSync, e.Min)
+}
+
 type errUnknownController struct {
 	id int32
 }
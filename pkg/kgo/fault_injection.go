@@ -0,0 +1,94 @@
+package kgo
+
+import (
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// Fault is a fault to inject into a single request, as returned from a
+// FaultInjector. The zero value injects nothing.
+type Fault struct {
+	// Delay, if positive, delays writing the request. The delay blocks
+	// writing any other request to the same broker, similar to a slow
+	// network.
+	Delay time.Duration
+
+	// Err, if non-nil, fails the request with this error after any delay,
+	// without writing it.
+	Err error
+
+	// DropResponse, if true, writes the request and reads its response
+	// as normal, but discards the response: the request only finishes
+	// once its context is done (or the client is closed), failing with
+	// the context error. This simulates a broker that never replies,
+	// without killing the connection. Requests the client issues
+	// internally are usually issued with a context that is not canceled
+	// until the client is closed, so this should generally only be used
+	// on requests issued with a deadline.
+	DropResponse bool
+}
+
+// FaultInjector injects faults into requests for chaos testing.
+type FaultInjector interface {
+	// InjectFault is called for every request immediately before it is
+	// written to a broker, and returns the fault to inject, if any. This
+	// is called serially per broker, but concurrently across brokers.
+	InjectFault(meta BrokerMetadata, req kmsg.Request) Fault
+}
+
+// UnsafeFaultInjector sets the client to consult the given FaultInjector
+// before writing every request, allowing requests to be delayed, failed, or
+// have their responses dropped. This is meant strictly for resilience and
+// chaos testing without a fake broker.
+//
+// This option is deliberately named as unsafe: injected faults apply to every
+// request the client issues, including internal metadata, group, and
+// transactional requests, and can easily break a client. Never use this
+// option in production.
+func UnsafeFaultInjector(injector FaultInjector) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.faultInjector = injector }}
+}
+
+// injectFault consults the client's FaultInjector, if any, for pr, waiting
+// out any delay. This returns whether the request should still be written and
+// whether its response should be dropped; if the request should not be
+// written, its promise has been called.
+func (b *broker) injectFault(pr promisedReq) (write, dropResp bool) {
+	injector := b.cl.cfg.faultInjector
+	if injector == nil {
+		return true, false
+	}
+	fault := injector.InjectFault(b.meta, pr.req)
+	if fault.Delay > 0 {
+		timer := b.cl.cfg.clock.NewTimer(fault.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+		case <-pr.ctx.Done():
+			pr.promise(nil, pr.ctx.Err())
+			return false, false
+		case <-b.cl.ctx.Done():
+			pr.promise(nil, errClientClosing)
+			return false, false
+		}
+	}
+	if fault.Err != nil {
+		pr.promise(nil, fault.Err)
+		return false, false
+	}
+	return true, fault.DropResponse
+}
+
+// dropResponse finishes a request whose response was dropped by an injected
+// fault once the request's context is done or the client is closed.
+func (cxn *brokerCxn) dropResponse(pr promisedResp) {
+	go func() {
+		select {
+		case <-pr.ctx.Done():
+			pr.promise(nil, pr.ctx.Err())
+		case <-cxn.cl.ctx.Done():
+			pr.promise(nil, errClientClosing)
+		}
+	}()
+}
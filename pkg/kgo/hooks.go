@@ -1,8 +1,11 @@
 package kgo
 
 import (
+	"context"
 	"net"
 	"time"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
 )
 
 // Hook is a hook to be called when something happens in kgo.
@@ -34,6 +37,29 @@ type BrokerConnectHook interface {
 	OnConnect(meta BrokerMetadata, dialDur time.Duration, conn net.Conn, err error)
 }
 
+// BrokerConnectAddrHook is called after a connection to a broker is opened,
+// alongside BrokerConnectHook, with the address that was dialed.
+type BrokerConnectAddrHook interface {
+	// OnConnectAddr is passed the broker metadata, the dialed address, and
+	// any dial error.
+	//
+	// If the client resolves broker hostnames itself (see DNSCacheTTL and
+	// DialFallbackDelay), the address is the resolved ip and port that
+	// connected. Otherwise, or if every dial failed, the
+	// address is the broker's host and port.
+	OnConnectAddr(meta BrokerMetadata, addr string, err error)
+}
+
+// BrokerStuckRequestHook is called by the StuckRequestThreshold watchdog
+// when a request on a broker connection has been waiting for its response for
+// longer than the threshold. The hook is called at most once per request.
+type BrokerStuckRequestHook interface {
+	// OnStuckRequest is passed the broker metadata, the request key and
+	// correlation ID of the oldest request awaiting a response on a
+	// connection, and how long ago the request was written.
+	OnStuckRequest(meta BrokerMetadata, key int16, corrID int32, age time.Duration)
+}
+
 // BrokerDisconnectHook is called when a connection to a broker is closed.
 type BrokerDisconnectHook interface {
 	// OnDisconnect is passed the broker metadata and the connection that
@@ -41,6 +67,64 @@ type BrokerDisconnectHook interface {
 	OnDisconnect(meta BrokerMetadata, conn net.Conn)
 }
 
+// DisconnectReason is why the client closed a broker connection, as passed
+// to a BrokerDisconnectReasonHook.
+type DisconnectReason int8
+
+const (
+	// DisconnectIdle is a connection reaped for being idle longer than
+	// ConnIdleTimeout. This is healthy.
+	DisconnectIdle DisconnectReason = iota
+	// DisconnectReadError is a connection closed after failing to read
+	// a response, such as from a read timeout or the broker hanging up.
+	DisconnectReadError
+	// DisconnectWriteError is a connection closed after failing to write
+	// a request.
+	DisconnectWriteError
+	// DisconnectClientClose is a connection closed because the client is
+	// closing.
+	DisconnectClientClose
+	// DisconnectBrokerStopped is a connection closed because its broker
+	// was forcefully recycled, which happens when metadata indicates the
+	// broker changed its host, port, or rack, or left the cluster.
+	DisconnectBrokerStopped
+	// DisconnectInitError is a connection closed because initializing it
+	// failed (requesting api versions or authenticating).
+	DisconnectInitError
+	// DisconnectReauthError is a connection closed because
+	// reauthenticating it failed once its sasl lifetime expired.
+	DisconnectReauthError
+)
+
+func (r DisconnectReason) String() string {
+	switch r {
+	case DisconnectIdle:
+		return "idle"
+	case DisconnectReadError:
+		return "read_error"
+	case DisconnectWriteError:
+		return "write_error"
+	case DisconnectClientClose:
+		return "client_close"
+	case DisconnectBrokerStopped:
+		return "broker_stopped"
+	case DisconnectInitError:
+		return "init_error"
+	case DisconnectReauthError:
+		return "reauth_error"
+	}
+	return "unknown"
+}
+
+// BrokerDisconnectReasonHook is called when a connection to a broker is
+// closed, alongside BrokerDisconnectHook, with the reason the connection was
+// closed.
+type BrokerDisconnectReasonHook interface {
+	// OnDisconnectReason is passed the broker metadata, the connection
+	// that is closing, and why it is closing.
+	OnDisconnectReason(meta BrokerMetadata, conn net.Conn, reason DisconnectReason)
+}
+
 // BrokerWriteHook is called after a write to a broker.
 //
 // Kerberos SASL does not cause write hooks, since it directly writes to the
@@ -57,6 +141,27 @@ type BrokerWriteHook interface {
 	OnWrite(meta BrokerMetadata, key int16, bytesWritten int, writeWait, timeToWrite time.Duration, err error)
 }
 
+// BrokerWriteSizeHook is called before a request is written to a broker, once
+// the request is serialized, allowing the request to be rejected before it is
+// sent. This is useful for enforcing client side byte budgets, such as
+// per-tenant produce quotas, before the broker throttles the client.
+//
+// This hook is not called for requests the client issues while initializing
+// a connection (api versions and sasl).
+type BrokerWriteSizeHook interface {
+	// OnWriteSize is passed the broker metadata, the key for the request
+	// about to be written, and the serialized size of the request in
+	// bytes, including the four byte length prefix. Returning a non-nil
+	// error rejects the request: the request is not written and fails
+	// with an error wrapping the returned error, and the connection is
+	// kept open.
+	//
+	// If a produce request is rejected, every record buffered for the
+	// partitions in the request is failed, since the client cannot fail
+	// only some buffered batches without breaking idempotent sequencing.
+	OnWriteSize(meta BrokerMetadata, key int16, size int) error
+}
+
 // BrokerReadHook is called after a read from a broker.
 //
 // Kerberos SASL does not cause read hooks, since it directly reads from the
@@ -87,3 +192,152 @@ type BrokerThrottleHook interface {
 	// request until the throttle deadline has passed.
 	OnThrottle(meta BrokerMetadata, throttleInterval time.Duration, throttledAfterResponse bool)
 }
+
+// BrokerReauthHook is called after a connection reauthenticates because its
+// SASL session lifetime expired (KIP-368), which is common with short lived
+// OAUTHBEARER tokens.
+type BrokerReauthHook interface {
+	// OnReauth is passed the broker metadata, how long reauthenticating
+	// took, and any error. If reauthenticating fails, the request that
+	// triggered the reauth fails and the connection is closed.
+	OnReauth(meta BrokerMetadata, dur time.Duration, err error)
+}
+
+// BrokerRequestHook is called when a request is enqueued to be issued to a
+// broker. Unlike the write and read hooks, this hook covers the entire
+// lifetime of an individual request, which allows for tracing requests end
+// to end.
+//
+// Requests that are retried are enqueued again, and thus call this hook again.
+type BrokerRequestHook interface {
+	// OnBrokerRequest is passed the context the request was issued with,
+	// the broker metadata, and the request being issued. The returned
+	// function, if non-nil, is called once the request is finished (either
+	// successfully or not) just before the response is processed.
+	//
+	// The request version is not set until the request is being written,
+	// thus the request should not be inspected for its version here.
+	OnBrokerRequest(ctx context.Context, meta BrokerMetadata, req kmsg.Request) func(BrokerRequestResult)
+}
+
+// BrokerRequestResult contains details about a finished request, as passed to
+// the function returned from a BrokerRequestHook.
+type BrokerRequestResult struct {
+	// Version is the version the request was issued with, or -1 if the
+	// request failed before a version was chosen.
+	Version int16
+
+	// CorrelationID is the correlation ID the request was written with,
+	// or -1 if the request was not successfully written.
+	CorrelationID int32
+
+	// BytesWritten and BytesRead are the number of bytes written for the
+	// request and read for the response. These do not count tls overhead.
+	BytesWritten int
+	BytesRead    int
+
+	// Resp is the response to the request, if any.
+	Resp kmsg.Response
+
+	// Err is the error for the request, if any. Note that this is only
+	// the error from issuing the request, not any error code within the
+	// response.
+	Err error
+}
+
+// MetadataRefreshReason is why the client is issuing a metadata refresh, as
+// passed to a MetadataRequestHook.
+type MetadataRefreshReason int8
+
+const (
+	// MetadataRefreshPeriodic is a refresh due to MetadataMaxAge.
+	MetadataRefreshPeriodic MetadataRefreshReason = iota
+	// MetadataRefreshTriggered is a refresh triggered internally, for
+	// example due to a topic or partition error or a connection failure.
+	MetadataRefreshTriggered
+	// MetadataRefreshUrgent is a triggered refresh that bypasses
+	// MetadataMinAge because something is blocked on the refresh.
+	MetadataRefreshUrgent
+	// MetadataRefreshTargeted is a refresh of only topics that failed
+	// while producing; see TargetedMetadataRefresh.
+	MetadataRefreshTargeted
+	// MetadataRefreshRetry is an immediate retry of a failed or
+	// incomplete urgent refresh.
+	MetadataRefreshRetry
+)
+
+func (r MetadataRefreshReason) String() string {
+	switch r {
+	case MetadataRefreshPeriodic:
+		return "periodic"
+	case MetadataRefreshTriggered:
+		return "triggered"
+	case MetadataRefreshUrgent:
+		return "urgent"
+	case MetadataRefreshTargeted:
+		return "targeted"
+	case MetadataRefreshRetry:
+		return "retry"
+	}
+	return "unknown"
+}
+
+// MetadataRequestHook is called when the client's internal metadata loop
+// issues a metadata request to refresh the topics it is producing to or
+// consuming from. This is not called for metadata requests issued through
+// Request or for loading brokers only.
+type MetadataRequestHook interface {
+	// OnMetadataRequest is passed the topics being requested, which is
+	// nil if all topics are being requested, and why the refresh is
+	// occurring.
+	OnMetadataRequest(topics []string, reason MetadataRefreshReason)
+}
+
+// FetchHook is called after a partition in a fetch response is processed.
+type FetchHook interface {
+	// OnFetch is passed the topic and partition, the number of records
+	// kept from the fetch, the number of records that were filtered
+	// because they were part of aborted transactions, and any partition
+	// error.
+	//
+	// Records are only filtered for aborted transactions when consuming
+	// with ReadCommitted; this gives visibility into how much of a fetch
+	// is spent on records that are never returned.
+	OnFetch(topic string, partition int32, fetched, filtered int, err error)
+}
+
+// FetchLagHook is called after a partition in a fetch response is processed,
+// with the partition's lag: the difference between the partition's high
+// watermark (or last stable offset if reading committed) in the fetch and the
+// offset the partition has been polled to. This can be used to feed consumer
+// lag gauges without issuing separate requests; see also Client.LagGauges.
+//
+// Records that were fetched but are not yet polled are included in the lag.
+type FetchLagHook interface {
+	// OnFetchLag is passed the topic and partition and the partition's
+	// lag.
+	OnFetchLag(topic string, partition int32, lag int64)
+}
+
+// ProduceRequestSplitHook is called when a broker's ready batches do not all
+// fit in one produce request, and some are deferred to following requests.
+//
+// Requests are limited to ProduceRequestMaxBytes, or to a lowered limit if
+// ShrinkProduceRequestsOnDeadConn is used and a broker recently killed the
+// connection while the client was producing a request of many batches.
+type ProduceRequestSplitHook interface {
+	// OnProduceRequestSplit is passed the broker metadata, the number of
+	// batches in the request being sent, the number of ready batches
+	// deferred to following requests, and the request's size and size
+	// limit in bytes.
+	OnProduceRequestSplit(meta BrokerMetadata, batches, deferred int, bytes, limit int32)
+}
+
+// ProduceRecordHook is called when a record is passed to Produce, before the
+// record is partitioned and buffered.
+type ProduceRecordHook interface {
+	// OnProduceRecord is passed the context the record is being produced
+	// with and the record itself. The hook may modify the record, for
+	// example to add headers.
+	OnProduceRecord(ctx context.Context, r *Record)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/twmb/franz-go/pkg/kerr"
@@ -96,6 +97,37 @@ func (cl *Client) triggerUpdateMetadata(must bool) bool {
 	return true
 }
 
+// triggerUpdateMetadataTopics triggers a metadata update for only the given
+// topics. If a full update is triggered before the targeted update runs, the
+// full update is used instead.
+func (cl *Client) triggerUpdateMetadataTopics(topics ...string) {
+	cl.metaTopicsMu.Lock()
+	if cl.metaTopics == nil {
+		cl.metaTopics = make(map[string]struct{}, len(topics))
+	}
+	for _, topic := range topics {
+		cl.metaTopics[topic] = struct{}{}
+	}
+	cl.metaTopicsMu.Unlock()
+
+	select {
+	case cl.updateMetaTopicsCh <- struct{}{}:
+	default:
+	}
+}
+
+// takeMetaTopics returns and clears the topics pending a targeted update.
+func (cl *Client) takeMetaTopics() []string {
+	cl.metaTopicsMu.Lock()
+	defer cl.metaTopicsMu.Unlock()
+	topics := make([]string, 0, len(cl.metaTopics))
+	for topic := range cl.metaTopics {
+		topics = append(topics, topic)
+	}
+	cl.metaTopics = nil
+	return topics
+}
+
 func (cl *Client) triggerUpdateMetadataNow() {
 	select {
 	case cl.updateMetadataNowCh <- struct{}{}:
@@ -115,20 +147,27 @@ func (cl *Client) blockingMetadataFn(fn func()) {
 func (cl *Client) updateMetadataLoop() {
 	defer close(cl.metadone)
 	var consecutiveErrors int
-	var lastAt time.Time
+	var lastAt, lastStart time.Time
 
-	ticker := time.NewTicker(cl.cfg.metadataMaxAge)
+	ticker := cl.cfg.clock.NewTicker(cl.cfg.metadataMaxAge)
 	defer ticker.Stop()
 loop:
 	for {
-		var now bool
+		var now, targeted bool
+		var reason MetadataRefreshReason
 		select {
 		case <-cl.ctx.Done():
 			return
-		case <-ticker.C:
+		case <-ticker.C():
+			reason = MetadataRefreshPeriodic
 		case <-cl.updateMetadataCh:
+			reason = MetadataRefreshTriggered
 		case <-cl.updateMetadataNowCh:
 			now = true
+			reason = MetadataRefreshUrgent
+		case <-cl.updateMetaTopicsCh:
+			targeted = true
+			reason = MetadataRefreshTargeted
 		case fn := <-cl.blockingMetadataFnCh:
 			fn()
 			continue loop
@@ -137,9 +176,12 @@ loop:
 		var nowTries int
 	start:
 		nowTries++
+		if nowTries > 1 {
+			reason = MetadataRefreshRetry
+		}
 		if !now {
-			if wait := cl.cfg.metadataMinAge - time.Since(lastAt); wait > 0 {
-				timer := time.NewTimer(wait)
+			if wait := cl.cfg.metadataMinAge - cl.since(lastAt); wait > 0 {
+				timer := cl.cfg.clock.NewTimer(wait)
 			prewait:
 				select {
 				case <-cl.ctx.Done():
@@ -147,7 +189,9 @@ loop:
 					return
 				case <-cl.updateMetadataNowCh:
 					timer.Stop()
-				case <-timer.C:
+					targeted = false
+					reason = MetadataRefreshUrgent
+				case <-timer.C():
 				case fn := <-cl.blockingMetadataFnCh:
 					fn()
 					goto prewait
@@ -159,12 +203,38 @@ loop:
 			time.Sleep(10 * time.Millisecond)
 		}
 
-		// Drain any refires that occured during our waiting.
+		// The debounce applies to every refresh, including urgent
+		// refreshes that skip the min age wait above.
+		if wait := cl.cfg.metadataDebounce - cl.since(lastStart); wait > 0 {
+			timer := cl.cfg.clock.NewTimer(wait)
+		debounce:
+			select {
+			case <-cl.ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C():
+			case fn := <-cl.blockingMetadataFnCh:
+				fn()
+				goto debounce
+			}
+		}
+
+		// Drain any refires that occured during our waiting. Any full
+		// refresh trigger overrides a targeted refresh.
 	out:
 		for {
 			select {
 			case <-cl.updateMetadataCh:
+				if targeted {
+					targeted = false
+					reason = MetadataRefreshTriggered
+				}
 			case <-cl.updateMetadataNowCh:
+				if targeted {
+					targeted = false
+					reason = MetadataRefreshUrgent
+				}
+			case <-cl.updateMetaTopicsCh:
 			case fn := <-cl.blockingMetadataFnCh:
 				fn()
 			default:
@@ -172,27 +242,40 @@ loop:
 			}
 		}
 
-		again, err := cl.updateMetadata()
-		if again || err != nil {
-			if now && nowTries < 3 {
-				goto start
+		// Whether we are doing a full or targeted refresh, we take
+		// the pending targeted topics: a full refresh covers them.
+		metaTopics := cl.takeMetaTopics()
+		var again bool
+		var err error
+		lastStart = cl.cfg.clock.Now()
+		if targeted {
+			again, err = cl.updateMetadataTopics(metaTopics)
+			if again || err != nil {
+				cl.triggerUpdateMetadataTopics(metaTopics...)
+			}
+		} else {
+			again, err = cl.updateMetadata(reason)
+			if again || err != nil {
+				if now && nowTries < 3 {
+					goto start
+				}
+				cl.triggerUpdateMetadata(true)
 			}
-			cl.triggerUpdateMetadata(true)
 		}
 		if err == nil {
-			lastAt = time.Now()
+			lastAt = cl.cfg.clock.Now()
 			consecutiveErrors = 0
 			continue
 		}
 
 		consecutiveErrors++
-		after := time.NewTimer(cl.cfg.retryBackoff(consecutiveErrors))
+		after := cl.cfg.clock.NewTimer(cl.cfg.retryBackoff(consecutiveErrors))
 	backoff:
 		select {
 		case <-cl.ctx.Done():
 			after.Stop()
 			return
-		case <-after.C:
+		case <-after.C():
 		case fn := <-cl.blockingMetadataFnCh:
 			fn()
 			goto backoff
@@ -207,8 +290,28 @@ loop:
 // The producer and consumer use different topic maps and underlying
 // topicPartitionsData pointers, but we update those underlying pointers
 // equally.
-func (cl *Client) updateMetadata() (needsRetry bool, err error) {
+func (cl *Client) updateMetadata(reason MetadataRefreshReason) (needsRetry bool, err error) {
 	defer cl.metawait.signal()
+	return cl.updateMetadataFiltered(nil, reason)
+}
+
+// updateMetadataTopics is like updateMetadata, but only requests and updates
+// the given topics. This does not signal metadata waiters, since waiters may
+// be waiting on topics we did not update.
+func (cl *Client) updateMetadataTopics(topics []string) (needsRetry bool, err error) {
+	if len(topics) == 0 {
+		return false, nil
+	}
+	filter := make(map[string]struct{}, len(topics))
+	for _, topic := range topics {
+		filter[topic] = struct{}{}
+	}
+	return cl.updateMetadataFiltered(filter, MetadataRefreshTargeted)
+}
+
+// updateMetadataFiltered updates all topics, or only topics in filter if the
+// filter is non-nil.
+func (cl *Client) updateMetadataFiltered(filter map[string]struct{}, reason MetadataRefreshReason) (needsRetry bool, err error) {
 	defer cl.consumer.doOnMetadataUpdate()
 
 	var (
@@ -223,6 +326,9 @@ func (cl *Client) updateMetadata() (needsRetry bool, err error) {
 	case *directConsumer:
 		tpsConsumer, all = v.tps, v.regexTopics
 	}
+	if filter != nil {
+		all = false
+	}
 
 	if !all {
 		reqTopicsSet := make(map[string]struct{})
@@ -231,20 +337,40 @@ func (cl *Client) updateMetadata() (needsRetry bool, err error) {
 			tpsConsumer.load(),
 		} {
 			for topic := range m {
+				if _, ok := filter[topic]; filter != nil && !ok {
+					continue
+				}
 				reqTopicsSet[topic] = struct{}{}
 			}
 		}
+		if filter != nil && len(reqTopicsSet) == 0 {
+			return false, nil // none of the filtered topics are tracked anymore
+		}
 		reqTopics = make([]string, 0, len(reqTopicsSet))
 		for topic := range reqTopicsSet {
 			reqTopics = append(reqTopics, topic)
 		}
 	}
 
+	cl.cfg.hooks.each(func(h Hook) {
+		if h, ok := h.(MetadataRequestHook); ok {
+			h.OnMetadataRequest(reqTopics, reason)
+		}
+	})
+
 	latest, err := cl.fetchTopicMetadata(all, reqTopics)
 	if err != nil {
 		return true, err
 	}
 
+	if fn := cl.cfg.onTopicMetadataErr; fn != nil {
+		for topic, parts := range latest {
+			if parts.loadErr != nil {
+				fn(topic, parts.loadErr)
+			}
+		}
+	}
+
 	// If we are consuming with regex and fetched all topics, the metadata
 	// may have returned topics the consumer is not yet tracking. We ensure
 	// that we will store the topics at the end of our metadata update.
@@ -256,6 +382,24 @@ func (cl *Client) updateMetadata() (needsRetry bool, err error) {
 		}
 		tpsConsumerLoad = tpsConsumer.ensureTopics(allTopics)
 		defer tpsConsumer.storeData(tpsConsumerLoad)
+
+		// Topics we are tracking that are no longer in the cluster
+		// were deleted. We keep their stale partitions, as we do for
+		// any topic load error, but mark the topic as unknown so that
+		// regex group consumers can drop it from their subscription.
+		// We do not merge this like a normal load error, since that
+		// would retry the metadata request until the topic returns.
+		for topic, parts := range tpsConsumerLoad {
+			if _, exists := latest[topic]; exists {
+				continue
+			}
+			lv := *parts.load()
+			if lv.loadErr == kerr.UnknownTopicOrPartition {
+				continue
+			}
+			lv.loadErr = kerr.UnknownTopicOrPartition
+			parts.v.Store(&lv)
+		}
 	}
 
 	var (
@@ -362,12 +506,14 @@ func (cl *Client) fetchTopicMetadata(all bool, reqTopics []string) (map[string]*
 					leader:      partMeta.Leader,
 					leaderEpoch: leaderEpoch,
 				},
+				isr: int32(len(partMeta.ISR)),
 
 				records: &recBuf{
 					cl: cl,
 
 					topic:     topicMeta.Topic,
 					partition: partMeta.Partition,
+					isr:       int32(len(partMeta.ISR)),
 
 					maxRecordBatchBytes: cl.maxRecordBatchBytesForTopic(topicMeta.Topic),
 
@@ -380,6 +526,8 @@ func (cl *Client) fetchTopicMetadata(all bool, reqTopics []string) (map[string]*
 					partition:   partMeta.Partition,
 					keepControl: cl.cfg.keepControl,
 					cursorsIdx:  -1,
+					lagOffset:   -1,
+					lagEnd:      -1,
 
 					cursorOffset: cursorOffset{
 						offset:            -1, // required to not consume until needed
@@ -454,13 +602,19 @@ func (cl *Client) mergeTopicPartitions(
 	// but keep our stale partition information. For anything being
 	// produced, we bump the respective error or fail everything. There is
 	// nothing to be done in a consumer.
+	//
+	// We do not retry the metadata load if Kafka replied with a
+	// non-retriable error: a topic that is permanently erroring (say, we
+	// are not authorized to it) should not cause fast metadata refreshes
+	// for all other topics.
 	if r.loadErr != nil {
 		if isProduce {
 			for _, topicPartition := range lv.partitions {
 				topicPartition.records.bumpRepeatedLoadErr(lv.loadErr)
 			}
 		}
-		return true
+		ke, isKerr := r.loadErr.(*kerr.Error)
+		return !isKerr || ke.Retriable
 	}
 
 	// Before the atomic update, we keep the latest partitions / writable
@@ -560,6 +714,9 @@ func (cl *Client) mergeTopicPartitions(
 	// Anything left with a negative recBufsIdx / cursorsIdx is a new topic
 	// partition and must be added to the sink / source.
 	for _, newTP := range r.partitions {
+		if isProduce {
+			atomic.StoreInt32(&newTP.records.isr, newTP.isr)
+		}
 		if isProduce && newTP.records.recBufsIdx == -1 {
 			newTP.records.sink.addRecBuf(newTP.records)
 		} else if !isProduce && newTP.cursor.cursorsIdx == -1 {
@@ -0,0 +1,309 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// MultiClient wraps clients connected to different clusters, fanning produces
+// out to every cluster and merging consumed records from every cluster, with
+// each result tagged by the cluster it came from. This is meant for mirroring
+// and disaster recovery setups, where the same data must be written to or
+// read from several clusters.
+//
+// Each cluster is identified by a name chosen when creating the MultiClient.
+// The wrapped clients remain usable directly, and are configured as usual;
+// for example, a client that should consume must be configured with topics to
+// consume and optionally a group.
+type MultiClient struct {
+	clusters []string // sorted
+	clients  map[string]*Client
+}
+
+// NewMultiClient returns a MultiClient wrapping the given clients, keyed by
+// cluster name. This returns an error if there are no clients or if any client
+// is nil.
+func NewMultiClient(clients map[string]*Client) (*MultiClient, error) {
+	if len(clients) == 0 {
+		return nil, errors.New("invalid empty set of clients for a multi client")
+	}
+	m := &MultiClient{clients: make(map[string]*Client, len(clients))}
+	for cluster, cl := range clients {
+		if cl == nil {
+			return nil, fmt.Errorf("invalid nil client for cluster %q", cluster)
+		}
+		m.clusters = append(m.clusters, cluster)
+		m.clients[cluster] = cl
+	}
+	sort.Strings(m.clusters)
+	return m, nil
+}
+
+// Clusters returns the sorted names of the clusters this client wraps.
+func (m *MultiClient) Clusters() []string {
+	return append([]string(nil), m.clusters...)
+}
+
+// Client returns the client for the given cluster, or nil if the cluster is
+// unknown.
+func (m *MultiClient) Client(cluster string) *Client {
+	return m.clients[cluster]
+}
+
+// Subset returns a MultiClient wrapping only the given clusters, sharing the
+// underlying clients with m. This can be used to route produces to a subset
+// of clusters. This returns an error if any cluster is unknown.
+//
+// Because clients are shared, closing the subset closes the clients in m.
+func (m *MultiClient) Subset(clusters ...string) (*MultiClient, error) {
+	clients := make(map[string]*Client, len(clusters))
+	for _, cluster := range clusters {
+		cl, exists := m.clients[cluster]
+		if !exists {
+			return nil, fmt.Errorf("unknown cluster %q", cluster)
+		}
+		clients[cluster] = cl
+	}
+	return NewMultiClient(clients)
+}
+
+// each calls fn concurrently for every cluster and waits for all calls to
+// return.
+func (m *MultiClient) each(fn func(cluster string, cl *Client)) {
+	var wg sync.WaitGroup
+	for _, cluster := range m.clusters {
+		cluster, cl := cluster, m.clients[cluster]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(cluster, cl)
+		}()
+	}
+	wg.Wait()
+}
+
+// Produce produces a copy of r to every cluster, calling promise once per
+// cluster with that cluster's copy of the record. The input record itself is
+// not modified.
+//
+// If producing to a cluster fails immediately (see Client.Produce), promise
+// is called with the error for that cluster; producing to the other clusters
+// continues. Thus, promise is always called once per cluster.
+func (m *MultiClient) Produce(
+	ctx context.Context,
+	r *Record,
+	promise func(cluster string, r *Record, err error),
+) {
+	for _, cluster := range m.clusters {
+		cluster := cluster
+		cp := *r
+		clusterPromise := func(r *Record, err error) { promise(cluster, r, err) }
+		if err := m.clients[cluster].Produce(ctx, &cp, clusterPromise); err != nil {
+			clusterPromise(&cp, err)
+		}
+	}
+}
+
+// ClusterProduceResult is the result of producing a record to one cluster.
+type ClusterProduceResult struct {
+	// Cluster is the cluster the record was produced to.
+	Cluster string
+
+	ProduceResult
+}
+
+// ClusterProduceResults is a collection of per-cluster produce results.
+type ClusterProduceResults []ClusterProduceResult
+
+// FirstErr returns the first erroring result, if any.
+func (rs ClusterProduceResults) FirstErr() error {
+	for _, r := range rs {
+		if r.Err != nil {
+			return fmt.Errorf("cluster %s: %w", r.Cluster, r.Err)
+		}
+	}
+	return nil
+}
+
+// ProduceSync produces every record to every cluster and waits for all
+// produces to finish, returning one result per record per cluster.
+func (m *MultiClient) ProduceSync(ctx context.Context, rs ...*Record) ClusterProduceResults {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make(ClusterProduceResults, 0, len(rs)*len(m.clusters))
+		promise = func(cluster string, r *Record, err error) {
+			mu.Lock()
+			results = append(results, ClusterProduceResult{cluster, ProduceResult{Record: r, Err: err}})
+			mu.Unlock()
+			wg.Done()
+		}
+	)
+
+	wg.Add(len(rs) * len(m.clusters))
+	for _, r := range rs {
+		m.Produce(ctx, r, promise)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Flush flushes every client concurrently, returning the first error
+// encountered, if any.
+func (m *MultiClient) Flush(ctx context.Context) error {
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	m.each(func(cluster string, cl *Client) {
+		if err := cl.Flush(ctx); err != nil {
+			mu.Lock()
+			defer mu.Unlock()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("cluster %s: %w", cluster, err)
+			}
+		}
+	})
+	return firstErr
+}
+
+// ClusterFetches is fetches polled from one cluster.
+type ClusterFetches struct {
+	// Cluster is the cluster the fetches were polled from.
+	Cluster string
+	// Fetches are the polled fetches.
+	Fetches Fetches
+}
+
+// MultiFetches is fetches polled from multiple clusters.
+type MultiFetches []ClusterFetches
+
+// ClusterFetchError is a fetch error along with the cluster it occurred on.
+type ClusterFetchError struct {
+	// Cluster is the cluster the error occurred on.
+	Cluster string
+
+	FetchError
+}
+
+func (e ClusterFetchError) Error() string {
+	return fmt.Sprintf("cluster %s: %v", e.Cluster, e.FetchError)
+}
+
+// Errors returns all fetch errors across all clusters.
+func (fs MultiFetches) Errors() []ClusterFetchError {
+	var errs []ClusterFetchError
+	for _, f := range fs {
+		for _, err := range f.Fetches.Errors() {
+			errs = append(errs, ClusterFetchError{f.Cluster, err})
+		}
+	}
+	return errs
+}
+
+// EachRecord calls fn for every record across all clusters, along with the
+// cluster the record was consumed from. Records from the same cluster and
+// partition are visited in order.
+func (fs MultiFetches) EachRecord(fn func(cluster string, r *Record)) {
+	for _, f := range fs {
+		for iter := f.Fetches.RecordIter(); !iter.Done(); {
+			fn(f.Cluster, iter.Next())
+		}
+	}
+}
+
+// PollFetches waits for fetches to be available from any cluster, returning
+// as soon as any client has fetches. Once any client has fetches, every
+// other client is polled without waiting, so all fetches that are already
+// buffered are returned together. If the ctx quits, this function quits.
+//
+// As with Client.PollFetches, it is important to check all partition errors
+// in the returned fetches.
+func (m *MultiClient) PollFetches(ctx context.Context) MultiFetches {
+	pollCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	polled := make(chan ClusterFetches, len(m.clusters))
+	for _, cluster := range m.clusters {
+		cluster, cl := cluster, m.clients[cluster]
+		go func() { polled <- ClusterFetches{cluster, cl.PollFetches(pollCtx)} }()
+	}
+
+	// We must wait for every poll to return even after canceling: a poll
+	// may take fetches after our cancel, and those fetches must not be
+	// dropped.
+	var fetches MultiFetches
+	for range m.clusters {
+		f := <-polled
+		if len(f.Fetches) > 0 {
+			fetches = append(fetches, f)
+			cancel()
+		}
+	}
+	sort.Slice(fetches, func(i, j int) bool { return fetches[i].Cluster < fetches[j].Cluster })
+	return fetches
+}
+
+// UncommittedOffsets returns the latest uncommitted offsets of every client
+// consuming in a group, keyed by cluster. Clusters that are not consuming in
+// a group are omitted.
+func (m *MultiClient) UncommittedOffsets() map[string]map[string]map[int32]EpochOffset {
+	offsets := make(map[string]map[string]map[int32]EpochOffset)
+	for _, cluster := range m.clusters {
+		if uncommitted := m.clients[cluster].UncommittedOffsets(); uncommitted != nil {
+			offsets[cluster] = uncommitted
+		}
+	}
+	return offsets
+}
+
+// CommitUncommittedOffsets commits the latest uncommitted offsets of every
+// client consuming in a group, concurrently, and waits for all commits to
+// finish. This returns the first error encountered, including any partition
+// level commit error.
+//
+// See Client.BlockingCommitOffsets for more information about committing.
+func (m *MultiClient) CommitUncommittedOffsets(ctx context.Context) error {
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	m.each(func(cluster string, cl *Client) {
+		uncommitted := cl.UncommittedOffsets()
+		if len(uncommitted) == 0 {
+			return
+		}
+		cl.BlockingCommitOffsets(ctx, uncommitted, func(_ *kmsg.OffsetCommitRequest, resp *kmsg.OffsetCommitResponse, err error) {
+			if err == nil {
+			out:
+				for _, t := range resp.Topics {
+					for _, p := range t.Partitions {
+						if err = kerr.ErrorForCode(p.ErrorCode); err != nil {
+							break out
+						}
+					}
+				}
+			}
+			if err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("cluster %s: %w", cluster, err)
+				}
+			}
+		})
+	})
+	return firstErr
+}
+
+// Close closes every client concurrently.
+func (m *MultiClient) Close() {
+	m.each(func(_ string, cl *Client) { cl.Close() })
+}
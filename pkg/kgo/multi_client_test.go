@@ -0,0 +1,201 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+)
+
+func TestNewMultiClient(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewMultiClient(nil); err == nil {
+		t.Error("expected error for no clients")
+	}
+	if _, err := NewMultiClient(map[string]*Client{"a": nil}); err == nil {
+		t.Error("expected error for nil client")
+	}
+
+	a, _ := NewClient()
+	defer a.Close()
+	b, _ := NewClient()
+	defer b.Close()
+
+	m, err := NewMultiClient(map[string]*Client{"b": b, "a": a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := m.Clusters(), []string{"a", "b"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("got clusters %v, want %v", got, exp)
+	}
+	if m.Client("a") != a || m.Client("c") != nil {
+		t.Error("Client returned the wrong client")
+	}
+
+	sub, err := m.Subset("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := sub.Clusters(), []string{"b"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("got subset clusters %v, want %v", got, exp)
+	}
+	if _, err := m.Subset("c"); err == nil {
+		t.Error("expected error for unknown subset cluster")
+	}
+}
+
+func TestMultiClientProduceSync(t *testing.T) {
+	t.Parallel()
+
+	var clients = make(map[string]*Client)
+	for _, cluster := range []string{"a", "b"} {
+		c := fakecluster.New(t, map[string]int32{"t": 1})
+		defer c.Close()
+		c.Control(0, produceHandler(nil, nil))
+
+		cl, err := NewClient(SeedBrokers(c.Addr()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cl.Close()
+		clients[cluster] = cl
+	}
+
+	// A transactional client that is not in a transaction fails every
+	// produce immediately; the other clusters are still produced to.
+	txn, err := NewClient(TransactionalID("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Close()
+	clients["c"] = txn
+
+	m, err := NewMultiClient(clients)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	in := []*Record{{Topic: "t", Value: []byte("0")}, {Topic: "t", Value: []byte("1")}}
+	results := m.ProduceSync(ctx, in...)
+	if len(results) != 6 {
+		t.Fatalf("got %d results, want 6", len(results))
+	}
+
+	produced := make(map[string][]string)
+	for _, r := range results {
+		switch {
+		case r.Cluster == "c":
+			if !errors.Is(r.Err, errNotInTransaction) {
+				t.Errorf("cluster c: got err %v, want %v", r.Err, errNotInTransaction)
+			}
+		case r.Err != nil:
+			t.Errorf("cluster %s: unexpected err %v", r.Cluster, r.Err)
+		}
+		for _, orig := range in {
+			if r.Record == orig {
+				t.Errorf("cluster %s: promise was called with the input record rather than a copy", r.Cluster)
+			}
+		}
+		produced[r.Cluster] = append(produced[r.Cluster], string(r.Record.Value))
+	}
+	for cluster, values := range produced {
+		sort.Strings(values)
+		if exp := []string{"0", "1"}; !reflect.DeepEqual(values, exp) {
+			t.Errorf("cluster %s: got values %v, want %v", cluster, values, exp)
+		}
+	}
+	if err := results.FirstErr(); !errors.Is(err, errNotInTransaction) {
+		t.Errorf("got first err %v, want %v", err, errNotInTransaction)
+	}
+	for _, r := range in {
+		if r.Offset != 0 || r.Partition != 0 || !r.Timestamp.IsZero() {
+			t.Errorf("input record was modified: %+v", r)
+		}
+	}
+}
+
+func TestMultiClientPollFetchesKeepsAll(t *testing.T) {
+	t.Parallel()
+
+	errA, errB := errors.New("a"), errors.New("b")
+
+	a, _ := NewClient()
+	defer a.Close()
+	b, _ := NewClient()
+	defer b.Close()
+
+	m, err := NewMultiClient(map[string]*Client{"a": a, "b": b})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Whichever client is polled first cancels the other poll, but the
+	// other poll still takes its fetch after the cancel; that fetch must
+	// be returned rather than dropped.
+	for i := 0; i < 50; i++ {
+		a.consumer.addFakeReadyForDraining("t", 0, errA)
+		b.consumer.addFakeReadyForDraining("t", 1, errB)
+
+		fs := m.PollFetches(context.Background())
+		errs := fs.Errors()
+		if len(fs) != 2 || len(errs) != 2 {
+			t.Fatalf("iteration %d: got %d fetches with %d errors, want 2 and 2", i, len(fs), len(errs))
+		}
+		if errs[0].Cluster != "a" || !errors.Is(errs[0].Err, errA) ||
+			errs[1].Cluster != "b" || !errors.Is(errs[1].Err, errB) {
+			t.Fatalf("iteration %d: got errors %v", i, errs)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if fs := m.PollFetches(ctx); len(fs) != 0 {
+		t.Errorf("got %d fetches after draining, want 0", len(fs))
+	}
+}
+
+func TestMultiClientPollFetchesCanceled(t *testing.T) {
+	t.Parallel()
+
+	a, _ := NewClient()
+	defer a.Close()
+	b, _ := NewClient()
+	defer b.Close()
+
+	m, err := NewMultiClient(map[string]*Client{"a": a, "b": b})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if fs := m.PollFetches(ctx); len(fs) != 0 {
+		t.Errorf("got %d fetches, want 0", len(fs))
+	}
+}
+
+func TestMultiClientCommitUncommittedOffsetsNoGroup(t *testing.T) {
+	t.Parallel()
+
+	a, _ := NewClient()
+	defer a.Close()
+
+	m, err := NewMultiClient(map[string]*Client{"a": a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offsets := m.UncommittedOffsets(); len(offsets) != 0 {
+		t.Errorf("got uncommitted offsets %v, want none", offsets)
+	}
+	if err := m.CommitUncommittedOffsets(context.Background()); err != nil {
+		t.Errorf("unexpected commit err %v", err)
+	}
+}
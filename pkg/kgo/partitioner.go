@@ -142,6 +142,64 @@ func (p *stickyKeyTopicPartitioner) Partition(r *Record, n int) int {
 	return p.stickyTopicPartitioner.Partition(r, n)
 }
 
+// StickyKeylessPartitioner is like StickyKeyPartitioner, but keyless records
+// rotate through partitions in round-robin order rather than randomly.
+//
+// Keyless records stick to one partition until a record would start a new
+// batch on that partition (because the batch is full, or because the prior
+// batch was already sent after lingering), and then move to the next
+// partition. Unlike StickyKeyPartitioner, a keyed record starting a new batch
+// on its own partition does not move the keyless partition: the keyless
+// partition only moves once its own batch rolls over. Round-robin rotation
+// spreads keyless batches evenly across partitions even over short periods.
+//
+// Keyed records are hashed with overrideHasher, which if nil defaults to
+// hashing exactly how Kafka does (see StickyKeyPartitioner).
+func StickyKeylessPartitioner(overrideHasher PartitionerHasher) Partitioner {
+	if overrideHasher == nil {
+		overrideHasher = KafkaHasher(murmur2)
+	}
+	return &keylessPartitioner{overrideHasher}
+}
+
+type keylessPartitioner struct {
+	hasher PartitionerHasher
+}
+
+func (k *keylessPartitioner) ForTopic(string) TopicPartitioner {
+	return &stickyKeylessTopicPartitioner{
+		hasher: k.hasher,
+		onPart: -1,
+		next:   rand.New(rand.NewSource(time.Now().UnixNano())).Intn(1 << 30),
+	}
+}
+
+type stickyKeylessTopicPartitioner struct {
+	hasher    PartitionerHasher
+	onPart    int
+	next      int
+	lastKeyed bool // whether the last record partitioned had a key
+}
+
+func (p *stickyKeylessTopicPartitioner) OnNewBatch() {
+	if !p.lastKeyed {
+		p.onPart = -1
+	}
+}
+
+func (*stickyKeylessTopicPartitioner) RequiresConsistency(r *Record) bool { return r.Key != nil }
+func (p *stickyKeylessTopicPartitioner) Partition(r *Record, n int) int {
+	p.lastKeyed = r.Key != nil
+	if p.lastKeyed {
+		return p.hasher(r.Key, n)
+	}
+	if p.onPart == -1 || p.onPart >= n {
+		p.onPart = p.next % n
+		p.next = p.onPart + 1
+	}
+	return p.onPart
+}
+
 // Straight from the C++ code and from the Java code duplicating it.
 // https://github.com/apache/kafka/blob/d91a94e/clients/src/main/java/org/apache/kafka/common/utils/Utils.java#L383-L421
 // https://github.com/aappleby/smhasher/blob/61a0530f/src/MurmurHash2.cpp#L37-L86
@@ -33,6 +33,10 @@ type producer struct {
 	// recBuf could be created and records sent to while we are flushing.
 	flushing int32 // >0 if flushing, can Flush many times concurrently
 
+	// flushingPartitions is >0 while any FlushTopicPartitions is active,
+	// and signals recBufs to notify when they have no buffered records.
+	flushingPartitions int32
+
 	aborting uint32 // 1 means yes
 	workers  int32  // number of sinks draining / number of in flight produce requests
 
@@ -46,6 +50,9 @@ type producer struct {
 
 	txnMu sync.Mutex
 	inTxn bool
+
+	// rateLimiter paces produce requests if ProduceRateLimit is used.
+	rateLimiter *byteRateLimiter
 }
 
 type unknownTopicProduces struct {
@@ -94,6 +101,44 @@ type ProduceResult struct {
 	// Err is a potential produce error. If this is non-nil, the record was
 	// not produced successfully.
 	Err error
+
+	// Batch is the batch the record was produced in; see BatchInfo.
+	Batch BatchInfo
+}
+
+// BatchInfo describes the record batch a record was produced in, for
+// diagnosing batching behavior, such as whether large batches hurt tail
+// latency. See ProduceWithBatchInfo.
+type BatchInfo struct {
+	// ID identifies the batch; every batch created in the process has a
+	// unique, increasing ID. This is zero if the record failed before it
+	// was added to a batch, in which case the other fields are unset.
+	ID uint64
+
+	// Topic and Partition are what the batch was produced to.
+	Topic     string
+	Partition int32
+
+	// NumRecords is the number of records in the batch, including this
+	// record.
+	NumRecords int
+
+	// Bytes is the uncompressed size of the batch as it would be encoded,
+	// including the batch header.
+	Bytes int32
+
+	// Tries is the number of times the batch was sent in a produce request.
+	Tries int64
+
+	// BaseOffset is the offset of the first record in the batch, or -1 if
+	// the batch failed.
+	BaseOffset int64
+
+	// ErrMessage is the human readable message the broker returned with
+	// the last produce error for this batch, if any. The record's error
+	// itself is always the bare Kafka error so that it can be compared
+	// directly (err == kerr.X).
+	ErrMessage string
 }
 
 // ProduceResults is a collection of produce results.
@@ -119,9 +164,9 @@ func (cl *Client) ProduceSync(ctx context.Context, rs ...*Record) ProduceResults
 		wg      sync.WaitGroup
 		mu      sync.Mutex
 		results = make(ProduceResults, 0, len(rs))
-		promise = func(r *Record, err error) {
+		promise = func(r *Record, batch BatchInfo, err error) {
 			mu.Lock()
-			results = append(results, ProduceResult{r, err})
+			results = append(results, ProduceResult{Record: r, Err: err, Batch: batch})
 			mu.Unlock()
 			wg.Done()
 		}
@@ -129,8 +174,8 @@ func (cl *Client) ProduceSync(ctx context.Context, rs ...*Record) ProduceResults
 
 	wg.Add(len(rs))
 	for _, r := range rs {
-		if err := cl.Produce(ctx, r, promise); err != nil {
-			promise(r, err)
+		if err := cl.ProduceWithBatchInfo(ctx, r, promise); err != nil {
+			promise(r, BatchInfo{Partition: -1, BaseOffset: -1}, err)
 		}
 	}
 	wg.Wait()
@@ -138,6 +183,52 @@ func (cl *Client) ProduceSync(ctx context.Context, rs ...*Record) ProduceResults
 	return results
 }
 
+// ProduceExpectOffset synchronously produces a single record and returns the
+// offset Kafka assigned to it, returning an *ErrOffsetMismatch if that offset
+// is not expectOffset. This is meant for deterministically rebuilding a topic,
+// where offset continuity between the source and the rebuild matters.
+//
+// The record is partitioned with the client's partitioner as usual; on
+// return, the record's Partition and Offset fields are filled in. Because the
+// record is produced before its offset is known, a mismatch means the record
+// was still written; it is up to the caller to decide how to handle the
+// divergence.
+//
+// This requires acks to be required, since with NoAck the broker does not
+// reply with the offset it assigned.
+func (cl *Client) ProduceExpectOffset(ctx context.Context, r *Record, expectOffset int64) (int64, error) {
+	if cl.cfg.acks.val == 0 {
+		return -1, errors.New("cannot verify produced offsets when producing with no acks")
+	}
+	if err := cl.ProduceSync(ctx, r).FirstErr(); err != nil {
+		return -1, err
+	}
+	if r.Offset != expectOffset {
+		return r.Offset, &ErrOffsetMismatch{
+			Topic:     r.Topic,
+			Partition: r.Partition,
+			Expected:  expectOffset,
+			Got:       r.Offset,
+		}
+	}
+	return r.Offset, nil
+}
+
+// ProduceTombstone produces a tombstone for key to topic, calling promise as
+// Produce does. A tombstone is a record with a nil value; once compacted,
+// a tombstone deletes all prior records with the same key in a compacted
+// topic.
+//
+// This guarantees the value is nil rather than empty: an empty value is a
+// normal record, and compaction keeps it. The key must be non-nil, since
+// compaction ignores records without keys.
+func (cl *Client) ProduceTombstone(ctx context.Context, topic string, key []byte, promise func(*Record, error)) error {
+	if key == nil {
+		return errTombstoneNilKey
+	}
+	return cl.Produce(ctx, &Record{Topic: topic, Key: key}, promise)
+}
+
 // Produce sends a Kafka record to the topic in the record's Topic field,
 // calling promise with the record or an error when Kafka replies. For a
 // synchronous produce, see ProduceSync.
@@ -182,12 +273,37 @@ func (cl *Client) ProduceSync(ctx context.Context, rs ...*Record) ProduceResults
 // If the client is transactional and a transaction has not been begun, this
 // returns an error corresponding to not being in a transaction.
 //
-// Thus, there are only three possible errors: the non-transaction error, and
-// then either a context error or ErrMaxBuffered.
+// If the client is configured with FailFastIfNoBroker and no broker is
+// reachable, this returns ErrNoReachableBrokers.
+//
+// Thus, there are only four possible errors: the non-transaction error,
+// ErrNoReachableBrokers, and then either a context error or ErrMaxBuffered.
 func (cl *Client) Produce(
 	ctx context.Context,
 	r *Record,
 	promise func(*Record, error),
+) error {
+	return cl.produce(ctx, r, promise, nil)
+}
+
+// ProduceWithBatchInfo is exactly like Produce, but calls promise with the
+// BatchInfo of the batch the record was produced in. This can be used to
+// correlate a record's latency with its batch's size and number of records.
+func (cl *Client) ProduceWithBatchInfo(
+	ctx context.Context,
+	r *Record,
+	promise func(*Record, BatchInfo, error),
+) error {
+	return cl.produce(ctx, r, nil, promise)
+}
+
+// produce is Produce, calling batchPromise rather than promise if it is
+// non-nil.
+func (cl *Client) produce(
+	ctx context.Context,
+	r *Record,
+	promise func(*Record, error),
+	batchPromise func(*Record, BatchInfo, error),
 ) error {
 	p := &cl.producer
 
@@ -195,6 +311,10 @@ func (cl *Client) Produce(
 		return errNotInTransaction
 	}
 
+	if cl.cfg.failFastIfNoBroker && !cl.anyBrokerReachable() {
+		return ErrNoReachableBrokers
+	}
+
 	if atomic.AddInt64(&p.bufferedRecords, 1) > cl.cfg.maxBufferedRecords {
 		// If the client ctx cancels or the produce ctx cancels, we
 		// need to un-count our buffering of this record. As well, to
@@ -205,11 +325,29 @@ func (cl *Client) Produce(
 		// waitBuffer as normal.
 		drainBuffered := func() {
 			go func() { <-p.waitBuffer }()
-			cl.finishRecordPromise(promisedRec{ctx, noPromise, nil}, nil)
+			cl.finishRecordPromise(promisedRec{ctx, noPromise, nil, nil}, nil)
 		}
-		if cl.cfg.manualFlushing {
+		switch cl.cfg.overflowPolicy {
+		case OverflowBlock():
+			if cl.cfg.manualFlushing {
+				drainBuffered()
+				return ErrMaxBuffered
+			}
+		case OverflowError():
 			drainBuffered()
 			return ErrMaxBuffered
+		case OverflowDropNewest():
+			drainBuffered()
+			if promise == nil {
+				promise = noPromise
+			}
+			if cl.cfg.onDropped != nil {
+				cl.cfg.onDropped(r)
+			}
+			promisedRec{ctx, promise, r, batchPromise}.call(BatchInfo{Partition: -1, BaseOffset: -1}, ErrRecordDropped)
+			return nil
+		case OverflowDropOldest():
+			cl.dropOldestBatch()
 		}
 		select {
 		case <-p.waitBuffer:
@@ -225,17 +363,39 @@ func (cl *Client) Produce(
 	if promise == nil {
 		promise = noPromise
 	}
-	cl.partitionRecord(promisedRec{ctx, promise, r})
+	cl.cfg.hooks.each(func(h Hook) {
+		if h, ok := h.(ProduceRecordHook); ok {
+			h.OnProduceRecord(ctx, r)
+		}
+	})
+	// We check the size after hooks, since hooks can add headers.
+	if max := cl.cfg.maxRecordSize; max > 0 {
+		if size := new(recBatch).calculateRecordNumbers(r).wireLength(); size > max {
+			cl.finishRecordPromise(promisedRec{ctx, promise, r, batchPromise}, &ErrRecordTooLarge{
+				Topic: r.Topic,
+				Size:  size,
+				Max:   max,
+			})
+			return nil
+		}
+	}
+	cl.partitionRecord(promisedRec{ctx, promise, r, batchPromise})
 	return nil
 }
 
 func (cl *Client) finishRecordPromise(pr promisedRec, err error) {
+	cl.finishBatchedRecordPromise(pr, BatchInfo{Partition: -1, BaseOffset: -1}, err)
+}
+
+// finishBatchedRecordPromise finishes a record that was part of the given
+// batch.
+func (cl *Client) finishBatchedRecordPromise(pr promisedRec, batch BatchInfo, err error) {
 	p := &cl.producer
 
 	// We call the promise before finishing the record; this allows users
 	// of Flush to know that all buffered records are completely done
 	// before Flush returns.
-	pr.promise(pr.Record, err)
+	pr.call(batch, err)
 
 	buffered := atomic.AddInt64(&p.bufferedRecords, -1)
 	if buffered >= cl.cfg.maxBufferedRecords {
@@ -247,6 +407,65 @@ func (cl *Client) finishRecordPromise(pr promisedRec, err error) {
 	}
 }
 
+// dropOldestBatch fails the unsent batch with the oldest first record across
+// all partitions with ErrRecordDropped, returning whether a batch was dropped.
+func (cl *Client) dropOldestBatch() bool {
+	var (
+		oldest   *recBuf
+		oldestTs int64
+	)
+	for _, partitions := range cl.producer.topics.load() {
+		for _, partition := range partitions.load().partitions {
+			recBuf := partition.records
+			recBuf.mu.Lock()
+			if recBuf.batchDrainIdx < len(recBuf.batches) {
+				batch := recBuf.batches[recBuf.batchDrainIdx]
+				if batch.tries == 0 && len(batch.records) > 0 && (oldest == nil || batch.firstTimestamp < oldestTs) {
+					oldest, oldestTs = recBuf, batch.firstTimestamp
+				}
+			}
+			recBuf.mu.Unlock()
+		}
+	}
+	if oldest == nil {
+		return false
+	}
+
+	oldest.mu.Lock()
+
+	// The batch may have been drained between our scan and now.
+	idx := oldest.batchDrainIdx
+	if idx >= len(oldest.batches) || oldest.batches[idx].tries != 0 {
+		oldest.mu.Unlock()
+		return false
+	}
+	batch := oldest.batches[idx]
+	oldest.batches = append(oldest.batches[:idx], oldest.batches[idx+1:]...)
+	if len(oldest.batches) == idx {
+		oldest.lockedStopLinger()
+	}
+
+	// The batch has never been sent, so nothing can be concurrently
+	// reading its records; we still lock for consistency with
+	// failAllRecords.
+	batch.mu.Lock()
+	records := batch.records
+	batch.records = nil
+	batch.mu.Unlock()
+	oldest.mu.Unlock()
+
+	// We call onDropped and the promises after unlocking, so that they
+	// can take as long as they need without blocking the partition.
+	info := batch.info(len(records), -1)
+	for _, pnr := range records {
+		if cl.cfg.onDropped != nil {
+			cl.cfg.onDropped(pnr.Record)
+		}
+		oldest.finishRecordPromise(pnr.promisedRec, info, ErrRecordDropped)
+	}
+	return true
+}
+
 // partitionRecord loads the partitions for a topic and produce to them. If
 // the topic does not currently exist, the record is buffered in unknownTopics
 // for a metadata update to deal with.
@@ -266,6 +485,18 @@ func (cl *Client) doPartitionRecord(parts *topicPartitions, partsData *topicPart
 		return
 	}
 
+	if cl.cfg.expectedPartitions != nil {
+		expected := cl.cfg.expectedPartitions(pr.Topic)
+		if actual := len(partsData.partitions); expected > 0 && expected != actual {
+			cl.finishRecordPromise(pr, &ErrPartitionCountMismatch{
+				Topic:    pr.Topic,
+				Expected: expected,
+				Actual:   actual,
+			})
+			return
+		}
+	}
+
 	parts.partsMu.Lock()
 	defer parts.partsMu.Unlock()
 	if parts.partitioner == nil {
@@ -564,10 +795,22 @@ func (cl *Client) waitUnknownTopic(
 				cl.cfg.logger.Log(LogLevelInfo, "done waiting for unknown topic", "topic", topic)
 				return // metadata was successful!
 			}
-			cl.cfg.logger.Log(LogLevelInfo, "unknown topic wait failed, retrying wait", "topic", topic, "err", retriableErr)
-			tries++
-			if int64(tries) >= cl.cfg.retries {
-				err = fmt.Errorf("no partitions available after refreshing metadata %d times, last err: %w", tries, retriableErr)
+			action := UnknownTopicRetry()
+			if retriableErr == kerr.UnknownTopicOrPartition {
+				action = cl.cfg.unknownTopicAction(topic)
+			}
+			switch action {
+			case UnknownTopicFail():
+				cl.cfg.logger.Log(LogLevelInfo, "unknown topic wait failed, failing records as requested", "topic", topic, "err", retriableErr)
+				err = retriableErr
+			case UnknownTopicWait():
+				cl.cfg.logger.Log(LogLevelInfo, "unknown topic wait failed, waiting for the topic to be created", "topic", topic, "err", retriableErr)
+			default:
+				cl.cfg.logger.Log(LogLevelInfo, "unknown topic wait failed, retrying wait", "topic", topic, "err", retriableErr)
+				tries++
+				if int64(tries) >= cl.cfg.retries {
+					err = fmt.Errorf("no partitions available after refreshing metadata %d times, last err: %w", tries, retriableErr)
+				}
 			}
 		}
 	}
@@ -667,6 +910,130 @@ func (cl *Client) Flush(ctx context.Context) error {
 	}
 }
 
+// FlushTopicPartitions hangs waiting for all records buffered for the given
+// topics and partitions to be flushed, stopping any linger for those
+// partitions. Unlike Flush, this does not wait on records buffered for any
+// other partition, and other partitions are not unlingered.
+//
+// This is useful to quiesce specific partitions, such as before changing the
+// partition count of a topic, without blocking on unrelated partitions.
+// Producing to the given partitions while flushing is allowed, but this
+// function then also waits for the newly produced records.
+//
+// Records that are buffered for topics the client has not yet loaded have no
+// partition yet and are not waited on, nor are partitions that do not exist.
+// Draining the requested partitions produces to their brokers, and any
+// batches already buffered for other partitions on the same brokers may be
+// produced in the same requests, even if lingering or ManualFlushing.
+//
+// If the context finishes (Done), this returns the context's error.
+func (cl *Client) FlushTopicPartitions(ctx context.Context, topicPartitions map[string][]int32) error {
+	p := &cl.producer
+
+	atomic.AddInt32(&p.flushingPartitions, 1)
+	defer atomic.AddInt32(&p.flushingPartitions, -1)
+
+	var recBufs []*recBuf
+	topics := p.topics.load()
+	for topic, partitions := range topicPartitions {
+		parts, exists := topics[topic]
+		if !exists {
+			continue
+		}
+		partsData := parts.load()
+		for _, partition := range partitions {
+			if partition < 0 || int(partition) >= len(partsData.partitions) {
+				continue
+			}
+			recBufs = append(recBufs, partsData.partitions[partition].records)
+		}
+	}
+
+	cl.cfg.logger.Log(LogLevelInfo, "flushing partitions", "partitions", topicPartitions)
+	defer cl.cfg.logger.Log(LogLevelDebug, "flushed partitions", "partitions", topicPartitions)
+
+	// Similar to Flush, we block lingering from starting before we wake
+	// up anything that is lingering.
+	for _, recBuf := range recBufs {
+		atomic.AddInt32(&recBuf.flushing, 1)
+		defer atomic.AddInt32(&recBuf.flushing, -1)
+		recBuf.unlingerAndManuallyDrain()
+	}
+
+	allFlushed := func() bool {
+		for _, recBuf := range recBufs {
+			if atomic.LoadInt64(&recBuf.buffered) > 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	quit := false
+	done := make(chan struct{})
+	go func() {
+		p.notifyMu.Lock()
+		defer p.notifyMu.Unlock()
+		defer close(done)
+
+		for !quit && !allFlushed() {
+			p.notifyCond.Wait()
+		}
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.notifyMu.Lock()
+		quit = true
+		p.notifyMu.Unlock()
+		p.notifyCond.Broadcast()
+		return ctx.Err()
+	}
+}
+
+type noLingerKey struct{}
+
+// WithoutLinger returns a context that, when used to produce a record, opts
+// the record out of lingering: the record's batch is sent as soon as
+// possible, as if the client's linger were zero, rather than waiting for the
+// linger to elapse.
+//
+// This allows one client to produce latency critical records without linger
+// alongside bulk records that benefit from lingering. Other records that
+// land in the same batch as a no linger record are sent with it.
+func WithoutLinger(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noLingerKey{}, true)
+}
+
+func isNoLinger(ctx context.Context) bool {
+	noLinger, _ := ctx.Value(noLingerKey{}).(bool)
+	return noLinger
+}
+
+// FlushLinger stops any linger for the given topic and partition and
+// immediately triggers sending the partition's buffered records, rather than
+// waiting for the linger to elapse. This is useful when an application event
+// signals that records should be sent now, without waiting on everything that
+// Flush waits on.
+//
+// Unlike Flush, this does not wait for the records to be sent; new records
+// produced to the partition after this call linger as normal. If the topic or
+// partition is not yet known, or if the client is configured with
+// ManualFlushing, this does nothing.
+func (cl *Client) FlushLinger(topic string, partition int32) {
+	parts, exists := cl.producer.topics.load()[topic]
+	if !exists {
+		return
+	}
+	partsData := parts.load()
+	if partition < 0 || int(partition) >= len(partsData.partitions) {
+		return
+	}
+	partsData.partitions[partition].records.unlingerAndManuallyDrain()
+}
+
 // Bumps the tries for all buffered records in the client.
 //
 // This is called whenever there is a problematic error that would affect the
@@ -696,10 +1063,41 @@ func (cl *Client) bumpRepeatedLoadErr(err error) {
 	}
 }
 
+// PurgeBufferedProduce immediately fails every buffered record with
+// ErrRecordPurged without sending it, including records whose produce request
+// is in flight. This does not wait for in flight requests to finish or for
+// records to be flushed; it is meant for emergency shutdowns or other cases
+// where sending the buffered data would be harmful.
+//
+// Records in an in flight request may still be written by the broker even
+// though their promises are failed. Because of this, for idempotent,
+// non-transactional clients, the producer ID is reset so that the sequence
+// numbers of future produces do not conflict with the purged records.
+// Transactional clients should abort the current transaction after purging.
+//
+// Records produced concurrently with this function may not be purged.
+func (cl *Client) PurgeBufferedProduce() {
+	atomic.StoreUint32(&cl.producer.aborting, 1)
+	defer atomic.StoreUint32(&cl.producer.aborting, 0)
+
+	cl.cfg.logger.Log(LogLevelWarn, "purging buffered records")
+	cl.failBufferedRecords(ErrRecordPurged)
+
+	if cl.idempotent() && cl.cfg.txnID == nil {
+		// We load the ID directly rather than through producerID: if
+		// the ID is not loaded, there is nothing to reset, and we do
+		// not want to block initializing an ID here.
+		if id := cl.producer.id.Load().(*producerID); id.err == nil {
+			cl.failProducerID(id.id, id.epoch, errReloadProducerID)
+		}
+	}
+}
+
 // Clears all buffered records in the client with the given error.
 //
 // - closing client
 // - aborting transaction
+// - purging buffered records
 // - fatal AddPartitionsToTxn
 //
 // Because the error fails everything, we also empty our unknown topics and
@@ -707,12 +1105,17 @@ func (cl *Client) bumpRepeatedLoadErr(err error) {
 func (cl *Client) failBufferedRecords(err error) {
 	p := &cl.producer
 
+	// We fail each partition's records after unlocking it, so that
+	// promises do not run while the partition is locked.
 	for _, partitions := range p.topics.load() {
 		for _, partition := range partitions.load().partitions {
 			recBuf := partition.records
 			recBuf.mu.Lock()
-			recBuf.failAllRecords(err)
+			taken := recBuf.lockedTakeAllRecords()
 			recBuf.mu.Unlock()
+			for _, batch := range taken {
+				batch.fail(err)
+			}
 		}
 	}
 
@@ -740,3 +1143,35 @@ func (cl *Client) failBufferedRecords(err error) {
 		}
 	}()
 }
+
+// byteRateLimiter is a token bucket limiting bytes per second, allowing up to
+// one second of burst. A reservation larger than the bucket puts the bucket
+// into debt, which later reservations wait out.
+type byteRateLimiter struct {
+	mu          sync.Mutex
+	bytesPerSec int64
+	emptyAt     time.Time // when the bucket's debt is paid off
+	maxBurst    time.Duration
+}
+
+func newByteRateLimiter(bytesPerSec int64) *byteRateLimiter {
+	return &byteRateLimiter{
+		bytesPerSec: bytesPerSec,
+		maxBurst:    time.Second,
+	}
+}
+
+// reserve takes n bytes from the bucket, returning how long to wait before
+// the bytes may be sent.
+func (l *byteRateLimiter) reserve(now time.Time, n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if earliest := now.Add(-l.maxBurst); l.emptyAt.Before(earliest) {
+		l.emptyAt = earliest
+	}
+	l.emptyAt = l.emptyAt.Add(time.Duration(int64(n) * int64(time.Second) / l.bytesPerSec))
+	if wait := l.emptyAt.Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
@@ -0,0 +1,756 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestProduceErrorMessage(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"t": 1})
+	defer c.Close()
+
+	c.Control(0, func(req kmsg.Request) (kmsg.Response, error) {
+		resp := req.ResponseKind().(*kmsg.ProduceResponse)
+		for _, rt := range req.(*kmsg.ProduceRequest).Topics {
+			st := kmsg.NewProduceResponseTopic()
+			st.Topic = rt.Topic
+			for _, rp := range rt.Partitions {
+				sp := kmsg.NewProduceResponseTopicPartition()
+				sp.Partition = rp.Partition
+				sp.ErrorCode = kerr.InvalidRecord.Code
+				sp.ErrorMessage = kmsg.StringPtr("record is missing a key")
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil
+	})
+
+	cl, err := NewClient(SeedBrokers(c.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	rs := cl.ProduceSync(ctx, &Record{Topic: "t", Value: []byte("v")})
+	if len(rs) != 1 {
+		t.Fatalf("got %d results != exp 1", len(rs))
+	}
+
+	// The promise error must remain directly comparable to the Kafka
+	// error; the broker's message is exposed in the batch info.
+	r := rs[0]
+	if r.Err != kerr.InvalidRecord {
+		t.Errorf("got err %v != exp %v", r.Err, kerr.InvalidRecord)
+	}
+	if exp := "record is missing a key"; r.Batch.ErrMessage != exp {
+		t.Errorf("got batch err message %q != exp %q", r.Batch.ErrMessage, exp)
+	}
+}
+
+func TestProduceUnknownTopicWait(t *testing.T) {
+	t.Parallel()
+
+	for _, wait := range []bool{false, true} {
+		c := fakecluster.New(t, map[string]int32{"t": 1})
+		defer c.Close()
+
+		// The topic is unknown for the first few produce requests,
+		// well beyond the retry limit, and then is (re)created.
+		c.Control(0, func(req kmsg.Request) (kmsg.Response, error) {
+			resp := req.ResponseKind().(*kmsg.ProduceResponse)
+			for _, rt := range req.(*kmsg.ProduceRequest).Topics {
+				st := kmsg.NewProduceResponseTopic()
+				st.Topic = rt.Topic
+				for _, rp := range rt.Partitions {
+					sp := kmsg.NewProduceResponseTopicPartition()
+					sp.Partition = rp.Partition
+					if c.NumReqs(0) <= 4 {
+						sp.ErrorCode = kerr.UnknownTopicOrPartition.Code
+					}
+					st.Partitions = append(st.Partitions, sp)
+				}
+				resp.Topics = append(resp.Topics, st)
+			}
+			return resp, nil
+		})
+
+		opts := []Opt{
+			SeedBrokers(c.Addr()),
+			ProduceRetries(1),
+			RetryBackoff(func(int) time.Duration { return time.Millisecond }),
+			MetadataMinAge(10 * time.Millisecond),
+		}
+		if wait {
+			opts = append(opts, OnUnknownTopic(func(string) UnknownTopicAction { return UnknownTopicWait() }))
+		}
+		cl, err := NewClient(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cl.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err = cl.ProduceSync(ctx, &Record{Topic: "t", Value: []byte("v")}).FirstErr()
+		switch {
+		case wait && err != nil:
+			t.Errorf("wait: got err %v, expected the record to wait for the topic", err)
+		case !wait && err == nil:
+			t.Error("retry: expected the record to fail once retries were exhausted")
+		}
+	}
+}
+
+func TestProduceRequestLimitOnDeadConn(t *testing.T) {
+	t.Parallel()
+
+	for _, shrink := range []bool{false, true} {
+		opts := []Opt{SeedBrokers("127.0.0.1:1")}
+		if shrink {
+			opts = append(opts, ShrinkProduceRequestsOnDeadConn())
+		}
+		cl, err := NewClient(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cl.Close()
+
+		s := cl.newSink(0)
+		max := s.requestLimit()
+		req := &produceRequest{numBatches: 5, wireLength: max}
+
+		// A connection reset while producing many batches says
+		// nothing about the request size on its own.
+		s.maybeLowerRequestLimit(req, &errDeadConn{io.ErrUnexpectedEOF})
+		got := s.requestLimit()
+		switch {
+		case !shrink && got != max:
+			t.Errorf("default: got limit %d after a dead connection != exp unchanged %d", got, max)
+		case shrink && got != max/2:
+			t.Errorf("shrink: got limit %d after a dead connection != exp %d", got, max/2)
+		}
+
+		// Requests of a single batch cannot be split.
+		s = cl.newSink(1)
+		s.maybeLowerRequestLimit(&produceRequest{numBatches: 1, wireLength: max}, &errDeadConn{io.ErrUnexpectedEOF})
+		if got := s.requestLimit(); got != max {
+			t.Errorf("shrink %v: got limit %d after a single batch request != exp unchanged %d", shrink, got, max)
+		}
+
+		// Responses raise a lowered limit back to the max.
+		s = cl.newSink(2)
+		s.maybeLowerRequestLimit(req, &errDeadConn{io.ErrUnexpectedEOF})
+		s.maybeRaiseRequestLimit()
+		if got := s.requestLimit(); got != max {
+			t.Errorf("shrink %v: got limit %d after a response != exp %d", shrink, got, max)
+		}
+	}
+}
+
+// produceHandler returns a fake cluster produce handler that replies with the
+// error code errFor returns for each partition, and that sends the topics
+// and partitions of every request to produced, if non-nil.
+func produceHandler(errFor func(topic string, partition int32) int16, produced chan<- map[string][]int32) func(kmsg.Request) (kmsg.Response, error) {
+	return func(req kmsg.Request) (kmsg.Response, error) {
+		resp := req.ResponseKind().(*kmsg.ProduceResponse)
+		tps := make(map[string][]int32)
+		for _, rt := range req.(*kmsg.ProduceRequest).Topics {
+			st := kmsg.NewProduceResponseTopic()
+			st.Topic = rt.Topic
+			for _, rp := range rt.Partitions {
+				sp := kmsg.NewProduceResponseTopicPartition()
+				sp.Partition = rp.Partition
+				if errFor != nil {
+					sp.ErrorCode = errFor(rt.Topic, rp.Partition)
+				}
+				st.Partitions = append(st.Partitions, sp)
+				tps[rt.Topic] = append(tps[rt.Topic], rp.Partition)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		if produced != nil {
+			produced <- tps
+		}
+		return resp, nil
+	}
+}
+
+// waitBuffered waits for n records to be buffered in the given partition,
+// which requires the partition to be loaded.
+func waitBuffered(t *testing.T, cl *Client, topic string, partition int32, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if parts, ok := cl.producer.topics.load()[topic]; ok {
+			if ps := parts.load().partitions; int(partition) < len(ps) && atomic.LoadInt64(&ps[partition].records.buffered) == n {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%s/%d: %d records were never buffered", topic, partition, n)
+}
+
+// checkUnlocked errors if the given partition's records are locked for a
+// second, which is used to check that callbacks are not called with the
+// partition locked.
+func checkUnlocked(t *testing.T, cl *Client, topic string, partition int32) {
+	t.Helper()
+	recBuf := cl.producer.topics.load()[topic].load().partitions[partition].records
+	unlocked := make(chan struct{})
+	go func() {
+		recBuf.mu.Lock()
+		recBuf.mu.Unlock()
+		close(unlocked)
+	}()
+	select {
+	case <-unlocked:
+	case <-time.After(time.Second):
+		t.Errorf("%s/%d: callback was called with the partition locked", topic, partition)
+	}
+}
+
+func TestFlushTopicPartitions(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"t": 1})
+	defer c.Close()
+	c.Control(0, produceHandler(nil, nil))
+
+	cl, err := NewClient(
+		SeedBrokers(c.Addr()),
+		Linger(time.Minute),
+		OnUnknownTopic(func(string) UnknownTopicAction { return UnknownTopicWait() }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	results := make(chan string, 3)
+	promise := func(r *Record, err error) {
+		if err != nil && r.Topic == "t" {
+			t.Errorf("unexpected produce err: %v", err)
+		}
+		results <- r.Topic
+	}
+
+	// The record for the missing topic stays buffered, so a full Flush
+	// cannot finish.
+	cl.Produce(ctx, &Record{Topic: "t", Value: []byte("a")}, promise)
+	waitBuffered(t, cl, "t", 0, 1)
+	cl.Produce(ctx, &Record{Topic: "missing", Value: []byte("m")}, promise)
+	cl.Produce(ctx, &Record{Topic: "t", Value: []byte("b")}, promise)
+
+	// Flushing t/0 stops its linger and does not wait on the missing
+	// topic.
+	if err := cl.FlushTopicPartitions(ctx, map[string][]int32{"t": {0}}); err != nil {
+		t.Fatalf("unexpected flush err: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case topic := <-results:
+			if topic != "t" {
+				t.Errorf("got flushed record for topic %s != exp t", topic)
+			}
+		default:
+			t.Fatal("FlushTopicPartitions returned before its records finished")
+		}
+	}
+
+	// Flushing nonexistent partitions and unloaded topics returns
+	// immediately.
+	if err := cl.FlushTopicPartitions(ctx, map[string][]int32{"t": {5}, "missing": {0}}); err != nil {
+		t.Fatalf("unexpected flush err: %v", err)
+	}
+
+	fctx, fcancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer fcancel()
+	if err := cl.Flush(fctx); err != context.DeadlineExceeded {
+		t.Errorf("got full flush err %v != exp %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestFlushTopicPartitionsContext(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"t": 1})
+	defer c.Close()
+
+	// Produce requests are never replied to.
+	c.Control(0, func(kmsg.Request) (kmsg.Response, error) { return nil, nil })
+
+	cl, err := NewClient(SeedBrokers(c.Addr()), Linger(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	cl.Produce(context.Background(), &Record{Topic: "t", Value: []byte("a")}, nil)
+	waitBuffered(t, cl, "t", 0, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := cl.FlushTopicPartitions(ctx, map[string][]int32{"t": {0}}); err != context.DeadlineExceeded {
+		t.Errorf("got flush err %v != exp %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestTargetedMetadataRefresh(t *testing.T) {
+	t.Parallel()
+
+	for _, targeted := range []bool{false, true} {
+		c := fakecluster.New(t, map[string]int32{"a": 1, "b": 1})
+		defer c.Close()
+
+		var (
+			mu       sync.Mutex
+			metaReqs [][]string // topics in each metadata request, nil for all
+		)
+		c.Control(3, func(req kmsg.Request) (kmsg.Response, error) {
+			r := req.(*kmsg.MetadataRequest)
+			var topics []string
+			for _, rt := range r.Topics {
+				topics = append(topics, *rt.Topic)
+			}
+			sort.Strings(topics)
+			mu.Lock()
+			metaReqs = append(metaReqs, topics)
+			mu.Unlock()
+			return c.Metadata(r), nil
+		})
+		// The first produce to "a" fails with a partition error.
+		failedAt := -1
+		c.Control(0, produceHandler(func(topic string, _ int32) int16 {
+			mu.Lock()
+			defer mu.Unlock()
+			if topic == "a" && failedAt < 0 {
+				failedAt = len(metaReqs)
+				return kerr.NotLeaderForPartition.Code
+			}
+			return 0
+		}, nil))
+
+		opts := []Opt{
+			SeedBrokers(c.Addr()),
+			MetadataMinAge(10 * time.Millisecond),
+			RetryBackoff(func(int) time.Duration { return time.Millisecond }),
+		}
+		if targeted {
+			opts = append(opts, TargetedMetadataRefresh())
+		}
+		cl, err := NewClient(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cl.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := cl.ProduceSync(ctx, &Record{Topic: "b", Value: []byte("v")}).FirstErr(); err != nil {
+			t.Fatal(err)
+		}
+		if err := cl.ProduceSync(ctx, &Record{Topic: "a", Value: []byte("v")}).FirstErr(); err != nil {
+			t.Fatal(err)
+		}
+
+		mu.Lock()
+		after := metaReqs[failedAt:]
+		mu.Unlock()
+		var sawTargeted, sawFull bool
+		for _, topics := range after {
+			switch {
+			case len(topics) == 1 && topics[0] == "a":
+				sawTargeted = true
+			case len(topics) == 2:
+				sawFull = true
+			}
+		}
+		if targeted && (sawFull || !sawTargeted) {
+			t.Errorf("targeted: got metadata requests %v, expected only requests for a", after)
+		}
+		if !targeted && !sawFull {
+			t.Errorf("full: got metadata requests %v, expected a request for all topics", after)
+		}
+	}
+}
+
+func TestProduceOverflowPolicy(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name   string
+		policy OverflowPolicy
+
+		expProduceErr error
+		expFirstErr   error // the buffered record
+		expSecondErr  error // the overflowing record
+		expDropped    string
+	}{
+		{name: "error", policy: OverflowError(), expProduceErr: ErrMaxBuffered},
+		{name: "drop newest", policy: OverflowDropNewest(), expSecondErr: ErrRecordDropped, expDropped: "second"},
+		{name: "drop oldest", policy: OverflowDropOldest(), expFirstErr: ErrRecordDropped, expDropped: "first"},
+	} {
+		c := fakecluster.New(t, map[string]int32{"t": 1})
+		defer c.Close()
+		c.Control(0, produceHandler(nil, nil))
+
+		var (
+			mu      sync.Mutex
+			dropped []string
+			cl      *Client
+			err     error
+		)
+		cl, err = NewClient(
+			SeedBrokers(c.Addr()),
+			MaxBufferedRecords(1),
+			Linger(time.Minute),
+			ProduceOverflowPolicy(test.policy, func(r *Record) {
+				checkUnlocked(t, cl, "t", 0)
+				mu.Lock()
+				defer mu.Unlock()
+				dropped = append(dropped, string(r.Value))
+			}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cl.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		errs := make(map[string]chan error)
+		produce := func(v string) error {
+			errs[v] = make(chan error, 1)
+			return cl.Produce(ctx, &Record{Topic: "t", Value: []byte(v)}, func(_ *Record, err error) { errs[v] <- err })
+		}
+
+		if err := produce("first"); err != nil {
+			t.Fatalf("%s: unexpected first produce err: %v", test.name, err)
+		}
+		waitBuffered(t, cl, "t", 0, 1)
+
+		// The overflowing produce must not block.
+		if err := produce("second"); err != test.expProduceErr {
+			t.Errorf("%s: got produce err %v != exp %v", test.name, err, test.expProduceErr)
+		}
+		if err := cl.Flush(ctx); err != nil {
+			t.Fatalf("%s: unexpected flush err: %v", test.name, err)
+		}
+
+		if err := <-errs["first"]; err != test.expFirstErr {
+			t.Errorf("%s: got first record err %v != exp %v", test.name, err, test.expFirstErr)
+		}
+		if test.expProduceErr == nil {
+			if err := <-errs["second"]; err != test.expSecondErr {
+				t.Errorf("%s: got second record err %v != exp %v", test.name, err, test.expSecondErr)
+			}
+		}
+		mu.Lock()
+		if test.expDropped == "" && len(dropped) != 0 || test.expDropped != "" && !reflect.DeepEqual(dropped, []string{test.expDropped}) {
+			t.Errorf("%s: got dropped %v != exp %q", test.name, dropped, test.expDropped)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestMinInSyncReplicasFail(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"t": 1}) // one in sync replica
+	defer c.Close()
+
+	var (
+		mu   sync.Mutex
+		seqs []int32
+	)
+	handle := produceHandler(nil, nil)
+	c.Control(0, func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		for _, rt := range req.(*kmsg.ProduceRequest).Topics {
+			for _, rp := range rt.Partitions {
+				var b kmsg.RecordBatch
+				if err := b.ReadFrom(rp.Records); err != nil {
+					t.Errorf("unable to read produced batch: %v", err)
+				}
+				seqs = append(seqs, b.FirstSequence)
+			}
+		}
+		mu.Unlock()
+		return handle(req)
+	})
+
+	cl, err := NewClient(
+		SeedBrokers(c.Addr()),
+		MinInSyncReplicas(2, true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Kafka accepted each record, so each fails on its own and the next
+	// batch continues from the accepted sequence number.
+	for i := 0; i < 3; i++ {
+		err := cl.ProduceSync(ctx, &Record{Topic: "t", Value: []byte("v")}).FirstErr()
+		var under *ErrUnderReplicated
+		if !errors.As(err, &under) {
+			t.Fatalf("produce %d: got err %v, want *ErrUnderReplicated", i, err)
+		}
+		if under.InSync != 1 || under.Min != 2 {
+			t.Errorf("produce %d: got in sync %d min %d, want 1 and 2", i, under.InSync, under.Min)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if exp := []int32{0, 1, 2}; !reflect.DeepEqual(seqs, exp) {
+		t.Errorf("got produced sequences %v, want %v", seqs, exp)
+	}
+}
+
+func TestPurgeBufferedProduce(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"t": 1})
+	defer c.Close()
+
+	var (
+		mu       sync.Mutex
+		produced [][2]int32 // producer epoch, first sequence
+	)
+	handle := produceHandler(nil, nil)
+	c.Control(0, func(req kmsg.Request) (kmsg.Response, error) {
+		mu.Lock()
+		for _, rt := range req.(*kmsg.ProduceRequest).Topics {
+			for _, rp := range rt.Partitions {
+				var b kmsg.RecordBatch
+				if err := b.ReadFrom(rp.Records); err != nil {
+					t.Errorf("unable to read produced batch: %v", err)
+				}
+				produced = append(produced, [2]int32{int32(b.ProducerEpoch), b.FirstSequence})
+			}
+		}
+		mu.Unlock()
+		return handle(req)
+	})
+
+	cl, err := NewClient(
+		SeedBrokers(c.Addr()),
+		ManualFlushing(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	produce := func(v string) <-chan error {
+		errc := make(chan error, 1)
+		if err := cl.Produce(ctx, &Record{Topic: "t", Value: []byte(v)}, func(_ *Record, err error) {
+			if err == ErrRecordPurged {
+				checkUnlocked(t, cl, "t", 0)
+			}
+			errc <- err
+		}); err != nil {
+			t.Fatalf("unable to produce %s: %v", v, err)
+		}
+		return errc
+	}
+
+	first := produce("first")
+	if err := cl.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-first; err != nil {
+		t.Fatalf("unexpected first record err: %v", err)
+	}
+
+	purged := []<-chan error{produce("second"), produce("third")}
+	waitBuffered(t, cl, "t", 0, 2)
+	cl.PurgeBufferedProduce()
+	for i, errc := range purged {
+		if err := <-errc; err != ErrRecordPurged {
+			t.Errorf("purged record %d: got err %v != exp %v", i, err, ErrRecordPurged)
+		}
+	}
+	if n := atomic.LoadInt64(&cl.producer.bufferedRecords); n != 0 {
+		t.Errorf("got %d buffered records after purging, want 0", n)
+	}
+
+	// The purged records may have been written, so the producer ID is
+	// reset: the epoch is bumped and sequence numbers start over.
+	last := produce("last")
+	if err := cl.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-last; err != nil {
+		t.Fatalf("unexpected last record err: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if exp := [][2]int32{{0, 0}, {1, 0}}; !reflect.DeepEqual(produced, exp) {
+		t.Errorf("got produced epochs and sequences %v, want %v", produced, exp)
+	}
+}
+
+func TestRequireProduceOrderingConfig(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name   string
+		opts   []Opt
+		exp    int
+		expErr bool
+	}{
+		{"idempotent is unaffected", []Opt{RequireProduceOrdering()}, 0, false},
+		{"non-idempotent defaults to one", []Opt{RequireProduceOrdering(), DisableIdempotentWrite()}, 1, false},
+		{"non-idempotent keeps one", []Opt{RequireProduceOrdering(), DisableIdempotentWrite(), MaxInflightProducePerPartition(1)}, 1, false},
+		{"non-idempotent rejects more than one", []Opt{RequireProduceOrdering(), DisableIdempotentWrite(), MaxInflightProducePerPartition(2)}, 0, true},
+		{"negative is no limit", []Opt{MaxInflightProducePerPartition(-1)}, 0, false},
+	} {
+		cl, err := NewClient(test.opts...)
+		if test.expErr {
+			if err == nil {
+				cl.Close()
+				t.Errorf("%s: expected error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected err: %v", test.name, err)
+			continue
+		}
+		if got := cl.cfg.maxInflightPerPartition; got != test.exp {
+			t.Errorf("%s: got max in flight %d != exp %d", test.name, got, test.exp)
+		}
+		cl.Close()
+	}
+}
+
+func TestMaxInflightProducePerPartition(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name     string
+		opts     []Opt
+		expDrain int // batches sent while the first is blocked
+	}{
+		{"no limit", nil, 2},
+		{"limit one", []Opt{MaxInflightProducePerPartition(1)}, 1},
+		{"ordered", []Opt{RequireProduceOrdering()}, 1},
+	} {
+		c := fakecluster.New(t, map[string]int32{"t": 1})
+		defer c.Close()
+
+		var (
+			mu      sync.Mutex
+			values  []string
+			blocked = make(chan struct{})
+			release = make(chan struct{})
+		)
+		handle := produceHandler(nil, nil)
+		c.Control(0, func(req kmsg.Request) (kmsg.Response, error) {
+			mu.Lock()
+			for _, rt := range req.(*kmsg.ProduceRequest).Topics {
+				for _, rp := range rt.Partitions {
+					var b kmsg.RecordBatch
+					if err := b.ReadFrom(rp.Records); err != nil {
+						t.Errorf("unable to read produced batch: %v", err)
+					}
+					// Each batch is one record, whose one
+					// byte value is followed by no headers.
+					values = append(values, string(b.Records[len(b.Records)-2]))
+				}
+			}
+			block := len(values) == 2
+			mu.Unlock()
+			if block {
+				close(blocked)
+				<-release
+			}
+			return handle(req)
+		})
+
+		cl, err := NewClient(append([]Opt{
+			SeedBrokers(c.Addr()),
+			DisableIdempotentWrite(),
+			BatchCompression(NoCompression()),
+		}, test.opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cl.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		// The first produce grows the broker's in flight limit past
+		// one, so that only the partition limit holds batches back.
+		if err := cl.ProduceSync(ctx, &Record{Topic: "t", Value: []byte("0")}).FirstErr(); err != nil {
+			t.Fatalf("%s: unexpected first produce err: %v", test.name, err)
+		}
+
+		errs := make(chan error, 2)
+		produce := func(v string) {
+			if err := cl.Produce(ctx, &Record{Topic: "t", Value: []byte(v)}, func(_ *Record, err error) { errs <- err }); err != nil {
+				t.Fatalf("%s: unable to produce %s: %v", test.name, v, err)
+			}
+		}
+		produce("1")
+		<-blocked
+		produce("2")
+
+		recBuf := cl.producer.topics.load()["t"].load().partitions[0].records
+		drained := func() (int, int) {
+			recBuf.mu.Lock()
+			defer recBuf.mu.Unlock()
+			return recBuf.batchDrainIdx, len(recBuf.batches)
+		}
+		waitFor(t, "the second batch to be buffered", func() bool {
+			idx, batches := drained()
+			return batches == 2 && idx == test.expDrain
+		})
+		time.Sleep(50 * time.Millisecond)
+		if idx, _ := drained(); idx != test.expDrain {
+			t.Errorf("%s: got %d batches sent while blocked != exp %d", test.name, idx, test.expDrain)
+		}
+
+		close(release)
+		for i := 0; i < 2; i++ {
+			if err := <-errs; err != nil {
+				t.Errorf("%s: unexpected produce err: %v", test.name, err)
+			}
+		}
+		mu.Lock()
+		if exp := []string{"0", "1", "2"}; !reflect.DeepEqual(values, exp) {
+			t.Errorf("%s: got produced values %v != exp %v", test.name, values, exp)
+		}
+		mu.Unlock()
+	}
+}
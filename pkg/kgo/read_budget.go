@@ -0,0 +1,87 @@
+package kgo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// readBudgetMinBytes is the smallest response size that is counted against
+// MaxConcurrentReadBytes. Smaller responses, such as ApiVersions or SASL
+// responses, are read without waiting on the budget.
+const readBudgetMinBytes = 64 << 10
+
+// readBudget bounds the total bytes of large response buffers that are
+// allocated at once across all broker connections.
+type readBudget struct {
+	mu    sync.Mutex
+	max   int64
+	used  int64
+	freed chan struct{} // closed and replaced whenever bytes are released
+}
+
+func newReadBudget(max int64) *readBudget {
+	return &readBudget{
+		max:   max,
+		freed: make(chan struct{}),
+	}
+}
+
+// acquire waits until n bytes are available, returning the number of bytes
+// acquired. A response larger than the entire budget acquires the entire
+// budget, so that it can still be read once nothing else is being read.
+func (r *readBudget) acquire(ctx, clientCtx context.Context, n int64) (int64, error) {
+	if n > r.max {
+		n = r.max
+	}
+	for {
+		r.mu.Lock()
+		if r.used+n <= r.max {
+			r.used += n
+			r.mu.Unlock()
+			return n, nil
+		}
+		freed := r.freed
+		r.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-clientCtx.Done():
+			return 0, errClientClosing
+		}
+	}
+}
+
+func (r *readBudget) release(n int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.used -= n
+	close(r.freed)
+	r.freed = make(chan struct{})
+}
+
+// acquireReadBudget, called before allocating a buffer for a response of the
+// given size, waits for the size to be available in the client's read budget.
+// The acquired bytes are held by the connection until releaseReadBudget.
+func (cxn *brokerCxn) acquireReadBudget(ctx context.Context, size int32) error {
+	budget := cxn.cl.readBudget
+	if budget == nil || size < readBudgetMinBytes {
+		return nil
+	}
+	n, err := budget.acquire(ctx, cxn.cl.ctx, int64(size))
+	if err != nil {
+		return err
+	}
+	atomic.AddInt64(&cxn.readBudgetHeld, n)
+	return nil
+}
+
+// releaseReadBudget releases any read budget held by the connection, once the
+// buffer of the last read response is no longer needed.
+func (cxn *brokerCxn) releaseReadBudget() {
+	if n := atomic.SwapInt64(&cxn.readBudgetHeld, 0); n > 0 {
+		cxn.cl.readBudget.release(n)
+	}
+}
@@ -0,0 +1,45 @@
+package kgo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReadBudget(t *testing.T) {
+	r := newReadBudget(100)
+	ctx := context.Background()
+
+	if n, err := r.acquire(ctx, ctx, 60); n != 60 || err != nil {
+		t.Fatalf("got %d, %v != exp 60, nil", n, err)
+	}
+
+	// A second acquire exceeding the budget waits for a release.
+	acquired := make(chan int64)
+	go func() {
+		n, _ := r.acquire(ctx, ctx, 60)
+		acquired <- n
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired beyond the budget")
+	case <-time.After(20 * time.Millisecond):
+	}
+	r.release(60)
+	if n := <-acquired; n != 60 {
+		t.Fatalf("got %d != exp 60", n)
+	}
+
+	// Waiting respects the context.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := r.acquire(cctx, ctx, 60); err != context.Canceled {
+		t.Fatalf("got %v != exp context.Canceled", err)
+	}
+
+	// Responses larger than the budget acquire the whole budget.
+	r.release(60)
+	if n, err := r.acquire(ctx, ctx, 1000); n != 100 || err != nil {
+		t.Fatalf("got %d, %v != exp 100, nil", n, err)
+	}
+}
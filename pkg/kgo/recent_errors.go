@@ -0,0 +1,77 @@
+package kgo
+
+import (
+	"sync"
+	"time"
+)
+
+// ErrorEvent is a request or produce error, as returned from RecentErrors.
+type ErrorEvent struct {
+	// Time is when the error occurred.
+	Time time.Time
+
+	// Broker is the node ID of the broker the request was issued to.
+	Broker int32
+
+	// Key is the key of the request that failed; produce errors are for
+	// the produce key, 0.
+	Key int16
+
+	// Topic and Partition are the topic and partition of a produce error,
+	// or an empty topic and -1 for request errors.
+	Topic     string
+	Partition int32
+
+	// Err is the error. For produce errors, this is the partition's error
+	// from the produce response, which may have been retried
+	// successfully.
+	Err error
+}
+
+// recentErrors is a fixed size ring buffer of the latest errors.
+type recentErrors struct {
+	mu     sync.Mutex
+	events []ErrorEvent
+	next   int
+	full   bool
+}
+
+func newRecentErrors(n int) *recentErrors {
+	return &recentErrors{events: make([]ErrorEvent, n)}
+}
+
+func (r *recentErrors) add(e ErrorEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[r.next] = e
+	r.next++
+	if r.next == len(r.events) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// RecentErrors returns the latest request and produce errors, oldest first,
+// up to the limit set with RecentErrorsLimit. This returns nil if the limit
+// is not set.
+//
+// Errors are recorded even if the client retries the failed request or
+// produce successfully, which makes this useful for a quick diagnostic
+// snapshot during an incident, when transient errors have long scrolled past
+// in logs. Request errors are errors issuing a request or reading its
+// response, not error codes within responses; produce errors are the error
+// codes for each partition in produce responses.
+func (cl *Client) RecentErrors() []ErrorEvent {
+	r := cl.recentErrs
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []ErrorEvent
+	if r.full {
+		events = append(events, r.events[r.next:]...)
+	}
+	return append(events, r.events[:r.next]...)
+}
@@ -0,0 +1,26 @@
+package kgo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRecentErrors(t *testing.T) {
+	cl := &Client{recentErrs: newRecentErrors(3)}
+	for i := int32(0); i < 5; i++ {
+		cl.recentErrs.add(ErrorEvent{Broker: i, Err: errors.New("err")})
+	}
+	events := cl.RecentErrors()
+	if len(events) != 3 {
+		t.Fatalf("got %d events != exp 3", len(events))
+	}
+	for i, e := range events {
+		if exp := int32(i + 2); e.Broker != exp {
+			t.Errorf("event %d: got broker %d != exp %d", i, e.Broker, exp)
+		}
+	}
+
+	if events := (&Client{}).RecentErrors(); events != nil {
+		t.Errorf("got %v != exp nil without a limit", events)
+	}
+}
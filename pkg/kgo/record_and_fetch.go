@@ -1,6 +1,9 @@
 package kgo
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // RecordHeader contains extra information that can be sent with Records.
 type RecordHeader struct {
@@ -129,6 +132,49 @@ type Record struct {
 	Offset int64
 }
 
+// IsTombstone returns whether the record is a tombstone: a record with a nil
+// value, which compaction uses to delete all prior records with the same key.
+// A record with an empty, non-nil value is not a tombstone.
+func (r *Record) IsTombstone() bool {
+	return r.Value == nil
+}
+
+// ControlRecordType is the type of a control record, which is a transaction
+// marker written by the transaction coordinator when a transaction ends.
+type ControlRecordType int16
+
+const (
+	// ControlAbort marks the end of an aborted transaction.
+	ControlAbort ControlRecordType = 0
+	// ControlCommit marks the end of a committed transaction.
+	ControlCommit ControlRecordType = 1
+)
+
+func (t ControlRecordType) String() string {
+	switch t {
+	case ControlAbort:
+		return "ABORT"
+	case ControlCommit:
+		return "COMMIT"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ControlType returns the type of a control record, and whether the record is
+// a control record with a valid key. Control records are only returned from
+// fetches if KeepControlRecords is used.
+//
+// The transaction the marker ends is identified by the record's ProducerID
+// and ProducerEpoch.
+func (r *Record) ControlType() (ControlRecordType, bool) {
+	// A control record key is an int16 version and int16 type.
+	if !r.Attrs.IsControl() || len(r.Key) < 4 {
+		return 0, false
+	}
+	return ControlRecordType(int16(r.Key[2])<<8 | int16(r.Key[3])), true
+}
+
 // FetchPartition is a response for a partition in a fetched topic from a
 // broker.
 type FetchPartition struct {
@@ -182,6 +228,13 @@ type FetchError struct {
 	Err       error
 }
 
+func (e FetchError) Error() string {
+	return fmt.Sprintf("fetch error on topic %s partition %d: %v", e.Topic, e.Partition, e.Err)
+}
+
+// Unwrap returns the underlying fetch error.
+func (e FetchError) Unwrap() error { return e.Err }
+
 // Errors returns all errors in a fetch with the topic and partition that
 // errored.
 func (fs Fetches) Errors() []FetchError {
@@ -0,0 +1,47 @@
+//go:build go1.23
+// +build go1.23
+
+package kgo
+
+import (
+	"context"
+	"iter"
+)
+
+// Records returns an iterator over consumed records, yielding records one at
+// a time as they are polled:
+//
+//	for r, err := range cl.Records(ctx) {
+//	        if err != nil {
+//	                // handle the partition error
+//	                continue
+//	        }
+//	        // process r
+//	}
+//
+// This is a convenience layer over PollFetches. Fetch errors are yielded as a
+// FetchError with a nil record; most errors are informational and consuming
+// continues after them. Iteration stops when ctx is canceled, when the client
+// is closed, or when the loop body breaks.
+//
+// Records are polled only as the loop asks for them. The client buffers at
+// most one fetch per broker, so while the loop body is busy, brokers with
+// buffered fetches are not fetched from again: a slow loop body naturally
+// pauses fetching rather than buffering unbounded records.
+func (cl *Client) Records(ctx context.Context) iter.Seq2[*Record, error] {
+	return func(yield func(*Record, error) bool) {
+		for ctx.Err() == nil && cl.ctx.Err() == nil {
+			fetches := cl.PollFetches(ctx)
+			for _, fe := range fetches.Errors() {
+				if !yield(nil, fe) {
+					return
+				}
+			}
+			for it := fetches.RecordIter(); !it.Done(); {
+				if !yield(it.Next(), nil) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package kgo
+
+import (
+	"context"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// QueuedRequest is a request queued to be written to a broker, as passed to
+// a RequestScheduler.
+type QueuedRequest struct {
+	// Ctx is the context the request was issued with.
+	Ctx context.Context
+	// Request is the request to be written. This must not be modified.
+	Request kmsg.Request
+	// Enqueued is when the request was queued.
+	Enqueued time.Time
+
+	pr promisedReq
+}
+
+// RequestScheduler orders the requests queued to be written to a single
+// broker. By default, requests are written in the order they are issued,
+// meaning a large produce request can delay every request queued behind it.
+// A scheduler can reorder or weight queued requests, such as fairly
+// interleaving requests of different kinds.
+//
+// A scheduler is only used from one goroutine and does not need to be safe
+// for concurrent use. Every pushed request must eventually be popped: a
+// request that is never popped is never written and its caller hangs until
+// its context is canceled.
+//
+// A scheduler must not reorder produce requests (key 0) relative to each
+// other. Idempotent and transactional produce requests to a broker carry
+// sequence numbers that the broker requires in order; reordering them causes
+// OUT_OF_ORDER_SEQUENCE_NUMBER errors and can fail the producer. Produce
+// requests can be freely interleaved with requests of other keys.
+type RequestScheduler interface {
+	// Push queues a request.
+	Push(QueuedRequest)
+	// Pop removes and returns the next request to write. This is only
+	// called if Len is positive.
+	Pop() QueuedRequest
+	// Len returns the number of queued requests.
+	Len() int
+}
+
+// BrokerRequestScheduler sets the client to use a RequestScheduler, created
+// per broker with newScheduler, to order requests written to each broker,
+// overriding the default of writing requests in the order they are issued.
+//
+// Requests are handed to the scheduler as they are issued, and the next
+// request is popped whenever the broker is ready to write. One request is
+// popped ahead of the write in progress, so a scheduler can reorder all but
+// the next request to be written.
+//
+// See FairRequestScheduler for a scheduler that round robins requests of
+// different kinds.
+func BrokerRequestScheduler(newScheduler func(BrokerMetadata) RequestScheduler) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.newRequestScheduler = newScheduler }}
+}
+
+// FairRequestScheduler returns a RequestScheduler that round robins between
+// request keys: produce, fetch, metadata, commits, and so on each take turns,
+// and requests of the same key are written in the order they were issued.
+// This keeps a backlog of large produce requests from delaying unrelated
+// requests, such as heartbeats or offset commits, issued to the same broker.
+//
+// This can be passed directly to BrokerRequestScheduler.
+func FairRequestScheduler(BrokerMetadata) RequestScheduler {
+	return new(fairRequestScheduler)
+}
+
+type fairRequestScheduler struct {
+	queues map[int16][]QueuedRequest
+	keys   []int16 // keys with queued requests, in round robin order
+	n      int
+}
+
+func (s *fairRequestScheduler) Push(q QueuedRequest) {
+	if s.queues == nil {
+		s.queues = make(map[int16][]QueuedRequest)
+	}
+	key := q.Request.Key()
+	if len(s.queues[key]) == 0 {
+		s.keys = append(s.keys, key)
+	}
+	s.queues[key] = append(s.queues[key], q)
+	s.n++
+}
+
+func (s *fairRequestScheduler) Pop() QueuedRequest {
+	key := s.keys[0]
+	queue := s.queues[key]
+	q := queue[0]
+	queue[0] = QueuedRequest{}
+	queue = queue[1:]
+	s.queues[key] = queue
+
+	// Rotate the key to the back if it still has requests, giving every
+	// other key a turn first.
+	s.keys = s.keys[1:]
+	if len(queue) > 0 {
+		s.keys = append(s.keys, key)
+	}
+	s.n--
+	return q
+}
+
+func (s *fairRequestScheduler) Len() int { return s.n }
+
+// scheduleReqs pumps requests from b.reqs through the scheduler, returning
+// the channel of scheduled requests to write. The returned channel is closed
+// once b.reqs is closed and the scheduler is drained.
+func (b *broker) scheduleReqs(s RequestScheduler) <-chan promisedReq {
+	out := make(chan promisedReq)
+	go func() {
+		defer close(out)
+		var (
+			in      = b.reqs
+			pending *promisedReq
+		)
+		for {
+			if pending == nil && s.Len() > 0 {
+				q := s.Pop()
+				pending = &q.pr
+			}
+			if pending == nil && in == nil {
+				return
+			}
+
+			var (
+				send chan<- promisedReq
+				next promisedReq
+			)
+			if pending != nil {
+				send, next = out, *pending
+			}
+			select {
+			case pr, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+				s.Push(QueuedRequest{
+					Ctx:      pr.ctx,
+					Request:  pr.req,
+					Enqueued: pr.enqueue,
+					pr:       pr,
+				})
+			case send <- next:
+				pending = nil
+			}
+		}
+	}()
+	return out
+}
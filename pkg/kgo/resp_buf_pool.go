@@ -0,0 +1,89 @@
+package kgo
+
+import (
+	"math/bits"
+	"reflect"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// respBufMinClass is the smallest size class of pooled response buffers,
+// 1KiB; smaller responses use 1KiB buffers.
+const respBufMinClass = 10
+
+// respBufPool is a size classed pool of response buffers, used if the client
+// is configured with PooledResponseBuffers. Class i holds buffers of capacity
+// 1<<i.
+type respBufPool struct {
+	classes [32]sync.Pool
+	safe    [kmsg.MaxKey + 1]bool
+}
+
+func newRespBufPool() *respBufPool {
+	p := new(respBufPool)
+	for key := range p.safe {
+		if resp := kmsg.ResponseForKey(int16(key)); resp != nil {
+			p.safe[key] = !retainsBytes(reflect.TypeOf(resp), make(map[reflect.Type]bool))
+		}
+	}
+	return p
+}
+
+// retainsBytes returns whether t contains a byte slice anywhere. Decoding a
+// response aliases byte slices into the response buffer, whereas strings and
+// numbers are copied, so only responses without byte slices are safe to
+// decode from a buffer that is reused.
+func retainsBytes(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Ptr:
+		return retainsBytes(t.Elem(), seen)
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8 || retainsBytes(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if retainsBytes(t.Field(i).Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// get returns a buffer of length size, drawn from the pool. Sizes smaller
+// than the minimum class, including zero, use a minimum class buffer.
+func (p *respBufPool) get(size int32) []byte {
+	class := respBufMinClass
+	if size > 1<<respBufMinClass {
+		class = bits.Len32(uint32(size - 1))
+	}
+	if buf, ok := p.classes[class].Get().(*[]byte); ok {
+		return (*buf)[:size]
+	}
+	return make([]byte, size, 1<<uint(class))
+}
+
+// put returns a buffer drawn from get to the pool.
+func (p *respBufPool) put(buf []byte) {
+	class := bits.Len32(uint32(cap(buf))) - 1
+	p.classes[class].Put(&buf)
+}
+
+// poolsResp returns whether the response for the given key is read into a
+// pooled buffer.
+func (cl *Client) poolsResp(key int16) bool {
+	return cl.respBufs != nil && key >= 0 && int(key) < len(cl.respBufs.safe) && cl.respBufs.safe[key]
+}
+
+// releaseRespBuf returns the connection's last pooled response buffer to the
+// pool, once the response has been decoded.
+func (cxn *brokerCxn) releaseRespBuf() {
+	if cxn.respBuf != nil {
+		cxn.cl.respBufs.put(cxn.respBuf)
+		cxn.respBuf = nil
+	}
+}
@@ -0,0 +1,84 @@
+package kgo
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func BenchmarkReadConn(b *testing.B) {
+	const size = 64 << 10
+	frame := make([]byte, 4+size)
+	binary.BigEndian.PutUint32(frame, size)
+
+	for _, pooled := range []bool{false, true} {
+		name := "unpooled"
+		if pooled {
+			name = "pooled"
+		}
+		b.Run(name, func(b *testing.B) {
+			cl := &Client{cfg: defaultCfg(), ctx: context.Background(), respBufs: newRespBufPool()}
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+			go func() {
+				for {
+					if _, err := server.Write(frame); err != nil {
+						return
+					}
+				}
+			}()
+			cxn := &brokerCxn{cl: cl, b: &broker{cl: cl}, conn: client}
+
+			b.ReportAllocs()
+			b.SetBytes(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, _, err, _, _ := cxn.readConn(context.Background(), 0, time.Now(), pooled); err != nil {
+					b.Fatal(err)
+				}
+				cxn.releaseRespBuf()
+			}
+		})
+	}
+}
+
+func TestRespBufPoolGet(t *testing.T) {
+	p := newRespBufPool()
+	for _, test := range []struct {
+		size   int32
+		expCap int
+	}{
+		{0, 1 << respBufMinClass},
+		{1, 1 << respBufMinClass},
+		{1 << respBufMinClass, 1 << respBufMinClass},
+		{1<<respBufMinClass + 1, 1 << (respBufMinClass + 1)},
+		{1 << 20, 1 << 20},
+	} {
+		buf := p.get(test.size)
+		if len(buf) != int(test.size) || cap(buf) != test.expCap {
+			t.Errorf("get(%d): got len %d cap %d, want len %d cap %d", test.size, len(buf), cap(buf), test.size, test.expCap)
+		}
+		p.put(buf)
+	}
+}
+
+func TestReadConnPooledEmptyFrame(t *testing.T) {
+	cl := &Client{cfg: defaultCfg(), ctx: context.Background(), respBufs: newRespBufPool()}
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go server.Write(make([]byte, 4)) // a zero length frame
+
+	cxn := &brokerCxn{cl: cl, b: &broker{cl: cl}, conn: client}
+	_, buf, err, _, _ := cxn.readConn(context.Background(), 0, time.Now(), true)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(buf) != 0 {
+		t.Errorf("got %d bytes, want 0", len(buf))
+	}
+	cxn.releaseRespBuf()
+}
@@ -3,6 +3,7 @@ package kgo
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"hash/crc32"
 	"strings"
@@ -51,6 +52,13 @@ type sink struct {
 	// occurs, the backoff is not cleared.
 	consecutiveFailures uint32
 
+	// reqLimit, if positive, is a lowered produce request size limit. If
+	// a broker kills the connection for a produce request of many batches,
+	// the request may have exceeded the broker's socket.request.max.bytes,
+	// so we halve our limit to split the batches across more requests.
+	// Every successful response doubles the limit back up.
+	reqLimit int32 // atomic
+
 	recBufsMu    sync.Mutex // guards the following
 	recBufs      []*recBuf  // contains all partition records for batch building
 	recBufsStart int        // incremented every req to avoid large batch starvation
@@ -90,7 +98,7 @@ func (s *sink) createReq(id int64, epoch int16) (*produceRequest, *kmsg.AddParti
 		compressor: s.cl.compressor,
 
 		wireLength:      s.cl.baseProduceRequestLength(), // start length with no topics
-		wireLengthLimit: s.cl.cfg.maxBrokerWriteBytes,
+		wireLengthLimit: s.requestLimit(),
 	}
 	txnBuilder := txnReqBuilder{
 		txnID: req.txnID,
@@ -114,6 +122,13 @@ func (s *sink) createReq(id int64, epoch int16) (*produceRequest, *kmsg.AddParti
 			continue
 		}
 
+		if max := s.cl.cfg.maxInflightPerPartition; max > 0 && recBuf.batchDrainIdx >= max {
+			// This partition has its max batches in flight; when
+			// one finishes, finishBatch triggers a drain.
+			recBuf.mu.Unlock()
+			continue
+		}
+
 		batch := recBuf.batches[recBuf.batchDrainIdx]
 		if added := req.tryAddBatch(atomic.LoadInt32(&s.produceVersion), recBuf, batch); !added {
 			recBuf.mu.Unlock()
@@ -137,6 +152,59 @@ func (s *sink) createReq(id int64, epoch int16) (*produceRequest, *kmsg.AddParti
 	return req, txnBuilder.req, moreToDrain
 }
 
+// onRequestSplit calls any ProduceRequestSplitHook for a request that could
+// not fit every ready batch.
+func (s *sink) onRequestSplit(req *produceRequest) {
+	meta := BrokerMetadata{NodeID: s.nodeID}
+	s.cl.brokersMu.RLock()
+	if b := s.cl.brokers[s.nodeID]; b != nil {
+		meta = b.meta
+	}
+	s.cl.brokersMu.RUnlock()
+
+	s.cl.cfg.hooks.each(func(h Hook) {
+		if h, ok := h.(ProduceRequestSplitHook); ok {
+			h.OnProduceRequestSplit(meta, req.numBatches, req.splitBatches, req.wireLength, req.wireLengthLimit)
+		}
+	})
+}
+
+// requestLimit returns the size limit for the next produce request.
+func (s *sink) requestLimit() int32 {
+	if limit := atomic.LoadInt32(&s.reqLimit); limit > 0 {
+		return limit
+	}
+	return s.cl.maxProduceRequestBytes()
+}
+
+// maybeLowerRequestLimit halves the request size limit if shrinking is
+// opted into and req had many batches and died with its connection, in case
+// the request was larger than the broker allows.
+func (s *sink) maybeLowerRequestLimit(req *produceRequest, err error) {
+	if !s.cl.cfg.shrinkProduceReqs || req.numBatches < 2 || !errors.As(err, new(*errDeadConn)) {
+		return
+	}
+	limit := req.wireLength / 2
+	atomic.StoreInt32(&s.reqLimit, limit)
+	s.cl.cfg.logger.Log(LogLevelInfo, "connection died while producing a request of many batches, lowering the produce request size limit to split batches across more requests",
+		"broker", s.nodeID, "request_bytes", req.wireLength, "new_limit", limit, "err", err)
+}
+
+// maybeRaiseRequestLimit doubles a lowered request size limit after a
+// successful response, until the limit is back to the configured limit.
+func (s *sink) maybeRaiseRequestLimit() {
+	limit := atomic.LoadInt32(&s.reqLimit)
+	if limit <= 0 {
+		return
+	}
+	if limit > s.cl.maxProduceRequestBytes()/2 {
+		limit = 0
+	} else {
+		limit *= 2
+	}
+	atomic.StoreInt32(&s.reqLimit, limit)
+}
+
 type txnReqBuilder struct {
 	txnID       *string
 	req         *kmsg.AddPartitionsToTxnRequest
@@ -173,7 +241,8 @@ func (t *txnReqBuilder) add(rb *recBuf) {
 }
 
 func (s *sink) maybeDrain() {
-	if s.cl.cfg.manualFlushing && atomic.LoadInt32(&s.cl.producer.flushing) == 0 {
+	p := &s.cl.producer
+	if s.cl.cfg.manualFlushing && atomic.LoadInt32(&p.flushing) == 0 && atomic.LoadInt32(&p.flushingPartitions) == 0 {
 		return
 	}
 	if s.drainState.maybeBegin() {
@@ -194,11 +263,11 @@ func (s *sink) maybeBackoff() {
 	s.cl.triggerUpdateMetadata(false) // as good a time as any
 
 	tries := int(atomic.AddUint32(&s.consecutiveFailures, 1))
-	after := time.NewTimer(s.cl.cfg.retryBackoff(tries))
+	after := s.cl.cfg.clock.NewTimer(s.cl.cfg.retryBackoff(tries))
 	defer after.Stop()
 
 	select {
-	case <-after.C:
+	case <-after.C():
 	case <-s.cl.ctx.Done():
 	}
 }
@@ -311,6 +380,9 @@ func (s *sink) produce(sem <-chan struct{}) bool {
 	if len(req.batches) == 0 { // everything was failing or lingering
 		return moreToDrain
 	}
+	if req.splitBatches > 0 {
+		s.onRequestSplit(req)
+	}
 
 	if txnReq != nil {
 		// txnReq can fail from:
@@ -329,7 +401,14 @@ func (s *sink) produce(sem <-chan struct{}) bool {
 				s.cl.cfg.logger.Log(LogLevelWarn, "unable to AddPartitionsToTxn due to retriable broker err, bumping client's buffered record load errors by 1 and retrying", "err", err)
 				return moreToDrain || len(req.batches) > 0
 			default:
-				s.cl.failProducerID(id, epoch, err)
+				// The producer ID keeps the underlying partition
+				// error so that EndTransaction can detect errors it
+				// can recover from.
+				idErr := err
+				if addErr, ok := err.(*ErrAddPartitionsToTxn); ok {
+					idErr = addErr.Unwrap()
+				}
+				s.cl.failProducerID(id, epoch, idErr)
 				s.cl.cfg.logger.Log(LogLevelError, "fatal AddPartitionsToTxn error, failing all buffered records (it is possible the client can recover after EndTransaction)", "broker", s.nodeID, "err", err)
 				s.cl.failBufferedRecords(err)
 			}
@@ -343,6 +422,17 @@ func (s *sink) produce(sem <-chan struct{}) bool {
 
 	req.backoffSeq = s.backoffSeq // safe to read outside mu since we are in drain loop
 
+	if limiter := s.cl.producer.rateLimiter; limiter != nil {
+		if wait := limiter.reserve(s.cl.cfg.clock.Now(), int(req.wireLength)); wait > 0 {
+			after := s.cl.cfg.clock.NewTimer(wait)
+			select {
+			case <-after.C():
+			case <-s.cl.ctx.Done(): // the request fails on issue below
+				after.Stop()
+			}
+		}
+	}
+
 	// Add that we are working and then check if we are aborting: this
 	// order ensures that we will do not produce after aborting is set.
 	p := &s.cl.producer
@@ -430,6 +520,10 @@ func (s *sink) issueTxnReq(
 		return err
 	}
 
+	// We check every partition before failing so that the returned error
+	// covers every partition that could not be added, rather than only the
+	// first.
+	var failed map[string]map[int32]error
 	for _, topic := range resp.Topics {
 		topicBatches, ok := req.batches[topic.Topic]
 		if !ok {
@@ -438,11 +532,26 @@ func (s *sink) issueTxnReq(
 		}
 		for _, partition := range topic.Partitions {
 			if err := kerr.ErrorForCode(partition.ErrorCode); err != nil {
+				// Concurrent transactions means the prior
+				// transaction is still ending; the whole request
+				// is retried in doWithConcurrentTransactions.
+				if err == kerr.ConcurrentTransactions {
+					return err
+				}
+
 				// OperationNotAttempted is set for all partitions that are authorized
 				// if any partition is unauthorized _or_ does not exist. We simply remove
 				// unattempted partitions and treat them as retriable.
 				if !kerr.IsRetriable(err) && err != kerr.OperationNotAttempted {
-					return err // auth err, etc.
+					s.cl.cfg.logger.Log(LogLevelError, "unable to add partition to transaction", "broker", s.nodeID, "topic", topic.Topic, "partition", partition.Partition, "err", err)
+					if failed == nil {
+						failed = make(map[string]map[int32]error)
+					}
+					if failed[topic.Topic] == nil {
+						failed[topic.Topic] = make(map[int32]error)
+					}
+					failed[topic.Topic][partition.Partition] = err // auth err, etc.
+					continue
 				}
 
 				batch, ok := topicBatches[partition.Partition]
@@ -470,6 +579,9 @@ func (s *sink) issueTxnReq(
 			}
 		}
 	}
+	if failed != nil {
+		return &ErrAddPartitionsToTxn{Errors: failed}
+	}
 	return nil
 }
 
@@ -520,11 +632,26 @@ func (s *sink) handleReqClientErr(req *produceRequest, err error) {
 	case err == errClientClosing:
 		s.cl.failBufferedRecords(errClientClosing)
 
+	case errors.As(err, new(*errWriteRejected)):
+		// The request was never written, so failing every record
+		// buffered in these partitions is safe: failAllRecords resets
+		// the sequence numbers for the next produce.
+		for _, partitions := range req.batches {
+			for _, batch := range partitions {
+				batch.owner.mu.Lock()
+				if batch.isOwnersFirstBatch() {
+					batch.owner.failAllRecords(err)
+				}
+				batch.owner.mu.Unlock()
+			}
+		}
+
 	default:
 		s.cl.cfg.logger.Log(LogLevelWarn, "random error while producing, requeueing unattempted request", "broker", s.nodeID, "err", err)
 		fallthrough
 
 	case isRetriableBrokerErr(err):
+		s.maybeLowerRequestLimit(req, err)
 		s.requeueUnattemptedReq(req, err)
 	}
 }
@@ -536,6 +663,7 @@ func (s *sink) handleReqResp(req *produceRequest, resp kmsg.Response, err error)
 	}
 	s.firstRespCheck(req.version)
 	atomic.StoreUint32(&s.consecutiveFailures, 0)
+	s.maybeRaiseRequestLimit()
 
 	var b *bytes.Buffer
 	debug := s.cl.cfg.logger.Level() >= LogLevelDebug
@@ -616,6 +744,7 @@ func (s *sink) handleReqResp(req *produceRequest, resp kmsg.Response, err error)
 				req.producerEpoch,
 				rPartition.BaseOffset,
 				rPartition.ErrorCode,
+				rPartition.ErrorMessage,
 			)
 			if retry {
 				reqRetry.addSeqBatch(topic, partition, batch)
@@ -652,6 +781,7 @@ func (s *sink) handleReqRespBatch(
 	producerEpoch int16,
 	baseOffset int64,
 	errorCode int16,
+	errorMessage *string,
 ) (retry bool) {
 	batch.owner.mu.Lock()
 	defer batch.owner.mu.Unlock()
@@ -690,10 +820,63 @@ func (s *sink) handleReqRespBatch(
 	batch.canFailFromLoadErrs = true
 
 	err := kerr.ErrorForCode(errorCode)
+	if err != nil && errorMessage != nil {
+		batch.errMessage = *errorMessage
+	}
+	if recent := s.cl.recentErrs; recent != nil && err != nil {
+		recent.add(ErrorEvent{
+			Time:      s.cl.cfg.clock.Now(),
+			Broker:    s.nodeID,
+			Topic:     topic,
+			Partition: partition,
+			Err:       kerr.WithMessage(err, errorMessage),
+		})
+	}
+	unknownTopicAction := UnknownTopicRetry()
+	if err == kerr.UnknownTopicOrPartition {
+		unknownTopicAction = s.cl.cfg.unknownTopicAction(topic)
+	}
+	batch.unknownTopicWait = unknownTopicAction == UnknownTopicWait()
+	errorAction := s.cl.cfg.errorAction(0, errorCode) // 0 is the produce key
 	switch {
+	case errorAction == ErrorFail():
+		s.cl.cfg.logger.Log(LogLevelInfo, "batch errored, failing as requested by the error policy",
+			"broker", s.nodeID,
+			"topic", topic,
+			"partition", partition,
+			"err", err,
+		)
+		s.cl.finishBatch(batch.recBatch, producerID, producerEpoch, partition, baseOffset, err)
+		if debug {
+			fmt.Fprintf(b, "err@%d,%d(%s)}, ", baseOffset, nrec, err)
+		}
+		return false
+
+	case (errorAction == ErrorRetry() || errorAction == ErrorRefreshMetadata()) &&
+		batch.tries < s.cl.cfg.produceRetries:
+
+		// Retried batches always trigger a metadata update, so both
+		// actions are the same here.
+		if debug {
+			fmt.Fprintf(b, "retrying@%d,%d(%s)}, ", baseOffset, nrec, err)
+		}
+		return true
+
+	case unknownTopicAction == UnknownTopicFail():
+		s.cl.cfg.logger.Log(LogLevelInfo, "batch produced to unknown topic, failing as requested",
+			"broker", s.nodeID,
+			"topic", topic,
+			"partition", partition,
+		)
+		s.cl.finishBatch(batch.recBatch, producerID, producerEpoch, partition, baseOffset, err)
+		if debug {
+			fmt.Fprintf(b, "err@%d,%d(%s)}, ", baseOffset, nrec, err)
+		}
+		return false
+
 	case kerr.IsRetriable(err) &&
 		err != kerr.CorruptMessage &&
-		batch.tries < s.cl.cfg.produceRetries:
+		(batch.tries < s.cl.cfg.produceRetries || unknownTopicAction == UnknownTopicWait()):
 
 		if debug {
 			fmt.Fprintf(b, "retrying@%d,%d(%s)}, ", baseOffset, nrec, err)
@@ -837,18 +1020,50 @@ func (cl *Client) finishBatch(batch *recBatch, producerID int64, producerEpoch i
 		return
 	}
 
+	// Kafka accepted the batch, but if the partition is under
+	// replicated and we are configured to fail, we fail only this
+	// batch's records. The batch still counts as produced below so that
+	// our sequence numbers keep matching what the broker has.
+	var recErr error
+	if min := cl.cfg.minISR; min > 0 {
+		if isr := atomic.LoadInt32(&recBuf.isr); isr < min {
+			underErr := &ErrUnderReplicated{
+				Topic:     recBuf.topic,
+				Partition: partition,
+				InSync:    isr,
+				Min:       min,
+			}
+			cl.cfg.logger.Log(LogLevelWarn, "produced batch to an under replicated partition",
+				"topic", recBuf.topic,
+				"partition", partition,
+				"in_sync", isr,
+				"min", min,
+			)
+			if cl.cfg.minISRFail {
+				recErr = underErr
+			}
+		}
+	}
+
 	// We know the batch made it to Kafka successfully without error.
 	// We remove this batch and finish all records appropriately.
 	recBuf.batch0Seq += int32(len(recBuf.batches[0].records))
 	recBuf.batches[0] = nil
 	recBuf.batches = recBuf.batches[1:]
 	recBuf.batchDrainIdx--
+	if cl.cfg.maxInflightPerPartition > 0 && len(recBuf.batches) > recBuf.batchDrainIdx {
+		// We may have skipped draining this partition because it had
+		// its max batches in flight.
+		recBuf.sink.maybeDrain()
+	}
 
 	batch.mu.Lock()
 	records, attrs := batch.records, batch.attrs
 	batch.records = nil
 	batch.mu.Unlock()
 
+	info := batch.info(len(records), baseOffset)
+
 	for i, pnr := range records {
 		pnr.Offset = baseOffset + int64(i)
 		pnr.Partition = partition
@@ -862,7 +1077,7 @@ func (cl *Client) finishBatch(batch *recBatch, producerID int64, producerEpoch i
 		// attrs to our own RecordAttrs.
 		pnr.Attrs = RecordAttrs{uint8(attrs)}
 
-		cl.finishRecordPromise(pnr.promisedRec, err)
+		recBuf.finishRecordPromise(pnr.promisedRec, info, recErr)
 	}
 }
 
@@ -875,10 +1090,12 @@ func (s *sink) handleRetryBatches(
 	canFail bool, // if records can fail if they are at limits
 ) {
 	var needsMetaUpdate bool
+	var metaTopics []string
 	retry.tryResetFailingBatchesWith(&s.cl.cfg, canFail, func(batch seqRecBatch) {
 		if updateMeta {
 			batch.owner.failing = true
 			needsMetaUpdate = true
+			metaTopics = append(metaTopics, batch.owner.topic)
 		}
 	})
 
@@ -889,7 +1106,11 @@ func (s *sink) handleRetryBatches(
 	// If we do want to metadata update, we only do so if any batch was the
 	// first batch in its buf / not concurrently failed.
 	if needsMetaUpdate {
-		s.cl.triggerUpdateMetadata(true)
+		if s.cl.cfg.targetedMetadataRefresh {
+			s.cl.triggerUpdateMetadataTopics(metaTopics...)
+		} else {
+			s.cl.triggerUpdateMetadata(true)
+		}
 	} else if !updateMeta {
 		s.maybeTriggerBackoff(backoffSeq)
 		s.maybeDrain()
@@ -950,6 +1171,17 @@ type recBuf struct {
 	// all buffered records are flushed (if the API is used correctly).
 	addedToTxn bool
 
+	// buffered is the number of records currently buffered in this
+	// recBuf, and flushing is >0 while the partition is being flushed
+	// with FlushTopicPartitions. Both are atomics and are not guarded by
+	// the mu below.
+	buffered int64
+	flushing int32
+
+	// isr is an atomic of the number of in sync replicas for this
+	// partition as of the latest metadata update, for MinInSyncReplicas.
+	isr int32
+
 	mu sync.Mutex // guards r/w access to all fields below
 
 	// sink is who is currently draining us. This can be modified
@@ -1054,11 +1286,20 @@ func (recBuf *recBuf) bufferRecord(pr promisedRec, abortOnNewBatch bool) bool {
 
 		recBuf.batches = append(recBuf.batches, newBatch)
 	}
+	atomic.AddInt64(&recBuf.buffered, 1)
+
+	noLinger := isNoLinger(pr.ctx)
+	if noLinger {
+		recBuf.batches[len(recBuf.batches)-1].noLinger = true
+	}
 
 	if recBuf.cl.cfg.linger == 0 {
 		if onDrainBatch {
 			recBuf.sink.maybeDrain()
 		}
+	} else if noLinger {
+		recBuf.lockedStopLinger()
+		recBuf.sink.maybeDrain()
 	} else {
 		// With linger, if this is a new batch but not the first, we
 		// stop lingering and begin draining. The drain loop will
@@ -1090,9 +1331,14 @@ func (recBuf *recBuf) tryStopLingerForDraining() bool {
 	return moreToDrain
 }
 
-// Begins a linger timer unless the producer is being flushed.
+// Begins a linger timer unless the producer or this partition is being
+// flushed, or the batch that would linger has a record that opted out of
+// lingering.
 func (recBuf *recBuf) lockedMaybeStartLinger() bool {
-	if atomic.LoadInt32(&recBuf.cl.producer.flushing) == 1 {
+	if atomic.LoadInt32(&recBuf.cl.producer.flushing) == 1 || atomic.LoadInt32(&recBuf.flushing) > 0 {
+		return false
+	}
+	if recBuf.batchDrainIdx < len(recBuf.batches) && recBuf.batches[recBuf.batchDrainIdx].noLinger {
 		return false
 	}
 	recBuf.lingering = time.AfterFunc(recBuf.cl.cfg.linger, recBuf.sink.maybeDrain)
@@ -1129,6 +1375,7 @@ func (recBuf *recBuf) bumpRepeatedLoadErr(err error) {
 	recBuf.cl.cfg.logger.Log(LogLevelWarn, "produce partition load error, unable to produce on this partition", "broker", recBuf.sink.nodeID, "topic", recBuf.topic, "partition", recBuf.partition, "err", err)
 	batch0 := recBuf.batches[0]
 	batch0.tries++
+	batch0.unknownTopicWait = err == kerr.UnknownTopicOrPartition && recBuf.cl.cfg.unknownTopicAction(recBuf.topic) == UnknownTopicWait()
 	failErr := batch0.maybeFailErr(&recBuf.cl.cfg)
 	if (!recBuf.cl.idempotent() || batch0.canFailFromLoadErrs) && (!kerr.IsRetriable(err) || failErr != nil) {
 		recBuf.failAllRecords(err)
@@ -1154,8 +1401,9 @@ func (recBuf *recBuf) failAllRecords(err error) {
 		// modifications to this batch because the recBuf is already
 		// locked.
 		batch.mu.Lock()
+		info := batch.info(len(batch.records), -1)
 		for _, pnr := range batch.records {
-			recBuf.cl.finishRecordPromise(pnr.promisedRec, err)
+			recBuf.finishRecordPromise(pnr.promisedRec, info, err)
 		}
 		batch.records = nil
 		batch.mu.Unlock()
@@ -1164,6 +1412,52 @@ func (recBuf *recBuf) failAllRecords(err error) {
 	recBuf.batches = nil
 }
 
+// takenBatch is the records of a batch removed from a recBuf, to be failed
+// once the recBuf is unlocked.
+type takenBatch struct {
+	recBuf  *recBuf
+	info    BatchInfo
+	records []promisedNumberedRecord
+}
+
+// lockedTakeAllRecords is like failAllRecords, but returns the removed
+// records rather than failing them, so that promises can be called after the
+// recBuf is unlocked.
+func (recBuf *recBuf) lockedTakeAllRecords() []takenBatch {
+	recBuf.lockedStopLinger()
+	taken := make([]takenBatch, 0, len(recBuf.batches))
+	for _, batch := range recBuf.batches {
+		// As in failAllRecords, we guard against a concurrent
+		// produceRequest's write.
+		batch.mu.Lock()
+		taken = append(taken, takenBatch{recBuf, batch.info(len(batch.records), -1), batch.records})
+		batch.records = nil
+		batch.mu.Unlock()
+	}
+	recBuf.resetBatchDrainIdx()
+	recBuf.batches = nil
+	return taken
+}
+
+// fail finishes every record in the taken batch with the given error.
+func (b takenBatch) fail(err error) {
+	for _, pnr := range b.records {
+		b.recBuf.finishRecordPromise(pnr.promisedRec, b.info, err)
+	}
+}
+
+// finishRecordPromise finishes a record that was buffered in this recBuf,
+// notifying any partition flush once the recBuf has no more buffered records.
+func (recBuf *recBuf) finishRecordPromise(pr promisedRec, batch BatchInfo, err error) {
+	p := &recBuf.cl.producer
+	recBuf.cl.finishBatchedRecordPromise(pr, batch, err)
+	if atomic.AddInt64(&recBuf.buffered, -1) == 0 && atomic.LoadInt32(&p.flushingPartitions) > 0 {
+		p.notifyMu.Lock()
+		p.notifyMu.Unlock()
+		p.notifyCond.Broadcast()
+	}
+}
+
 // clearFailing clears a buffer's failing state if it is failing.
 //
 // This is called when a buffer is added to a sink (to clear a failing state
@@ -1190,6 +1484,19 @@ type promisedRec struct {
 	ctx     context.Context
 	promise func(*Record, error)
 	*Record
+
+	// batchPromise, if non-nil, is called rather than promise; see
+	// ProduceWithBatchInfo.
+	batchPromise func(*Record, BatchInfo, error)
+}
+
+// call calls the record's promise.
+func (pr promisedRec) call(batch BatchInfo, err error) {
+	if pr.batchPromise != nil {
+		pr.batchPromise(pr.Record, batch, err)
+	} else {
+		pr.promise(pr.Record, err)
+	}
 }
 
 // promisedNumberedRecord ties a promised record to its calculated numbers.
@@ -1218,8 +1525,36 @@ type recBatch struct {
 	attrs          int16 // updated during apending; read and converted to RecordAttrs on success
 	firstTimestamp int64 // since unix epoch, in millis
 
+	noLinger bool // if any record was produced with WithoutLinger
+
 	mu      sync.Mutex // guards appendTo's reading of records against failAllRecords emptying it
 	records []promisedNumberedRecord
+
+	id uint64 // from batchIDs, for BatchInfo
+
+	errMessage string // the broker's message for the last produce error, for BatchInfo
+
+	// unknownTopicWait is whether the last error for this batch was an
+	// unknown topic that OnUnknownTopic asked to wait on, in which case
+	// the batch does not fail from reaching the retry limit.
+	unknownTopicWait bool
+}
+
+// batchIDs is incremented to assign every record batch a unique ID.
+var batchIDs uint64
+
+// info returns the BatchInfo of the batch, which has nrec records.
+func (b *recBatch) info(nrec int, baseOffset int64) BatchInfo {
+	return BatchInfo{
+		ID:         b.id,
+		Topic:      b.owner.topic,
+		Partition:  b.owner.partition,
+		NumRecords: nrec,
+		Bytes:      b.wireLength,
+		Tries:      b.tries,
+		BaseOffset: baseOffset,
+		ErrMessage: b.errMessage,
+	}
 }
 
 // Returns an error if the batch should fail.
@@ -1234,7 +1569,7 @@ func (b *recBatch) maybeFailErr(cfg *cfg) error {
 	}
 	if b.isTimedOut(cfg.recordTimeout) {
 		return errRecordTimeout
-	} else if b.tries >= cfg.produceRetries {
+	} else if b.tries >= cfg.produceRetries && !b.unknownTopicWait {
 		return errRecordRetries
 	}
 	return nil
@@ -1284,6 +1619,7 @@ func (recBuf *recBuf) newRecordBatch() *recBatch {
 		owner:      recBuf,
 		records:    make([]promisedNumberedRecord, 0, 10),
 		wireLength: recordBatchOverhead,
+		id:         atomic.AddUint64(&batchIDs, 1),
 
 		canFailFromLoadErrs: true, // until we send this batch, we can fail it
 	}
@@ -1334,6 +1670,9 @@ type produceRequest struct {
 	// we use the proper flexible numbers when calculating.
 	wireLength      int32
 	wireLengthLimit int32
+
+	numBatches   int // number of batches added
+	splitBatches int // number of ready batches that did not fit
 }
 
 func (r *produceRequest) tryAddBatch(produceVersion int32, recBuf *recBuf, batch *recBatch) bool {
@@ -1359,7 +1698,11 @@ func (r *produceRequest) tryAddBatch(produceVersion int32, recBuf *recBuf, batch
 	// non-flexible, we have 200mil partitions to add before we have to
 	// worry about hitting 5 bytes vs. the non-flexible 4. We do not worry.
 
-	if r.wireLength+batchWireLength > r.wireLengthLimit {
+	// The first batch always fits: batches are sized to fit alone in a
+	// request of the configured limit, and the limit may be lowered below
+	// that if the broker rejects large requests.
+	if r.numBatches > 0 && r.wireLength+batchWireLength > r.wireLengthLimit {
+		r.splitBatches++
 		return false
 	}
 
@@ -1379,6 +1722,7 @@ func (r *produceRequest) tryAddBatch(produceVersion int32, recBuf *recBuf, batch
 
 	batch.tries++
 	batch.canFailFromLoadErrs = false
+	r.numBatches++
 	r.wireLength += batchWireLength
 	r.batches.addBatch(
 		recBuf.topic,
@@ -1505,7 +1849,7 @@ func (cl *Client) maxRecordBatchBytesForTopic(topic string) int32 {
 		4 + // partition int32 encoding length
 		4 // int32 record bytes array length
 
-	wireLengthLimit := cl.cfg.maxBrokerWriteBytes
+	wireLengthLimit := cl.maxProduceRequestBytes()
 
 	recordBatchLimit := wireLengthLimit - minOnePartitionBatchLength
 	if cfgLimit := cl.cfg.maxRecordBatchBytes; cfgLimit < recordBatchLimit {
@@ -1514,6 +1858,14 @@ func (cl *Client) maxRecordBatchBytesForTopic(topic string) int32 {
 	return recordBatchLimit
 }
 
+// maxProduceRequestBytes returns the size limit of a produce request.
+func (cl *Client) maxProduceRequestBytes() int32 {
+	if limit := cl.cfg.maxProduceReqBytes; limit > 0 {
+		return limit
+	}
+	return cl.cfg.maxBrokerWriteBytes
+}
+
 func messageSet0Length(r *Record) int32 {
 	const length = 4 + // array len
 		8 + // offset
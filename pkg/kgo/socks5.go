@@ -0,0 +1,224 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// ProxyAuth is the username and password to authenticate to a SOCKS5 proxy
+// with (RFC 1929).
+type ProxyAuth struct {
+	User     string
+	Password string
+}
+
+// DialSOCKS5 dials brokers through the SOCKS5 proxy at proxyAddr (RFC 1928),
+// authenticating with auth if it is non-nil. The proxy is dialed with the
+// client's dial function (by default, a net.Dialer, or whatever is set with
+// Dialer, regardless of option order), and the broker's host is sent to the
+// proxy unresolved, so that the proxy resolves it.
+//
+// The proxy handshake is bounded by the dial context and thus by the
+// DialTimeout, and it is included in the dial duration passed to
+// BrokerConnectHook. To use TLS through the proxy, use TLSConfigFn: the TLS
+// handshake is performed with the broker over the proxied connection.
+func DialSOCKS5(proxyAddr string, auth *ProxyAuth) Opt {
+	return clientOpt{func(cfg *cfg) {
+		cfg.socks5 = &socks5Dialer{
+			proxyAddr: proxyAddr,
+			auth:      auth,
+		}
+	}}
+}
+
+// wrapSOCKS5 wraps the final dial function with the SOCKS5 dialer, if
+// DialSOCKS5 was used. This is done once all options are applied so that a
+// Dialer option anywhere in the options is used to dial the proxy.
+func (cfg *cfg) wrapSOCKS5() {
+	if cfg.socks5 == nil {
+		return
+	}
+	d := *cfg.socks5
+	d.forward = cfg.dialFn
+	cfg.dialFn = d.DialContext
+}
+
+type socks5Dialer struct {
+	proxyAddr string
+	auth      *ProxyAuth
+	forward   func(context.Context, string, string) (net.Conn, error)
+}
+
+// DialContext dials the proxy and asks it to connect to addr, returning the
+// proxied connection.
+func (d *socks5Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.forward(ctx, network, d.proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to dial socks5 proxy %s: %w", d.proxyAddr, err)
+	}
+
+	// As with TLS handshakes, we bound the handshake by the context's
+	// deadline and interrupt it if the context is canceled.
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	err = d.handshake(conn, addr)
+	close(done)
+	<-exited
+	if err != nil {
+		conn.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		return nil, fmt.Errorf("unable to connect to %s through socks5 proxy %s: %w", addr, d.proxyAddr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (d *socks5Dialer) handshake(conn net.Conn, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q: %w", portStr, err)
+	}
+
+	// Greeting: we offer no auth, and username/password if we have it.
+	methods := []byte{0x00}
+	if d.auth != nil {
+		methods = append(methods, 0x02)
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 {
+		return fmt.Errorf("unexpected socks version %d", reply[0])
+	}
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if d.auth == nil {
+			return errors.New("proxy chose username/password auth, which was not offered")
+		}
+		if err := d.authenticate(conn); err != nil {
+			return err
+		}
+	case 0xff:
+		return errors.New("proxy accepted none of the offered auth methods")
+	default:
+		return fmt.Errorf("proxy chose unsupported auth method %d", reply[1])
+	}
+
+	// Connect request.
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("host %q is too long for socks5", host)
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, 0x01)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, 0x04)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	var resp [4]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return err
+	}
+	if resp[1] != 0x00 {
+		return fmt.Errorf("proxy failed to connect: %s", socks5ReplyText(resp[1]))
+	}
+
+	// Skip the bound address and port, which we have no use for.
+	var skip int
+	switch resp[3] {
+	case 0x01:
+		skip = net.IPv4len
+	case 0x04:
+		skip = net.IPv6len
+	case 0x03:
+		var l [1]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return err
+		}
+		skip = int(l[0])
+	default:
+		return fmt.Errorf("unknown bound address type %d", resp[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
+
+// authenticate performs username/password authentication (RFC 1929).
+func (d *socks5Dialer) authenticate(conn net.Conn) error {
+	user, pass := d.auth.User, d.auth.Password
+	if len(user) == 0 || len(user) > 255 || len(pass) > 255 {
+		return errors.New("invalid socks5 username or password length")
+	}
+	req := []byte{0x01, byte(len(user))}
+	req = append(req, user...)
+	req = append(req, byte(len(pass)))
+	req = append(req, pass...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[1] != 0x00 {
+		return errors.New("proxy rejected the username and password")
+	}
+	return nil
+}
+
+func socks5ReplyText(code byte) string {
+	switch code {
+	case 0x01:
+		return "general failure"
+	case 0x02:
+		return "connection not allowed by ruleset"
+	case 0x03:
+		return "network unreachable"
+	case 0x04:
+		return "host unreachable"
+	case 0x05:
+		return "connection refused"
+	case 0x06:
+		return "ttl expired"
+	case 0x07:
+		return "command not supported"
+	case 0x08:
+		return "address type not supported"
+	}
+	return "unknown reply code " + strconv.Itoa(int(code))
+}
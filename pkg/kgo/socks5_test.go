@@ -0,0 +1,103 @@
+package kgo
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDialSOCKS5(t *testing.T) {
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	go func() {
+		conn, err := target.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("hi"))
+	}()
+
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	gotAddr := make(chan string, 1)
+	go func() {
+		conn, err := proxy.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		read := func(n int) []byte {
+			b := make([]byte, n)
+			io.ReadFull(conn, b)
+			return b
+		}
+
+		greeting := read(2)
+		read(int(greeting[1]))
+		conn.Write([]byte{0x05, 0x02})
+
+		auth := read(2)
+		user := string(read(int(auth[1])))
+		pass := string(read(int(read(1)[0])))
+		if user != "user" || pass != "pass" {
+			conn.Write([]byte{0x01, 0x01})
+			return
+		}
+		conn.Write([]byte{0x01, 0x00})
+
+		req := read(5) // version, cmd, rsv, atyp, host length
+		host := string(read(int(req[4])))
+		port := read(2)
+		gotAddr <- net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1])))
+
+		upstream, err := net.Dial("tcp", target.Addr().String())
+		if err != nil {
+			conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer upstream.Close()
+		conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 127, 0, 0, 1, 0, 0})
+		io.Copy(conn, upstream)
+	}()
+
+	// A Dialer option after DialSOCKS5 must still be used to dial the
+	// proxy rather than replace the proxy dialer.
+	var forwarded []string
+	cfg := defaultCfg()
+	DialSOCKS5(proxy.Addr().String(), &ProxyAuth{User: "user", Password: "pass"}).apply(&cfg)
+	Dialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		forwarded = append(forwarded, addr)
+		return new(net.Dialer).DialContext(ctx, network, addr)
+	}).apply(&cfg)
+	cfg.wrapSOCKS5()
+
+	_, port, _ := net.SplitHostPort(target.Addr().String())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := cfg.dialFn(ctx, "tcp", net.JoinHostPort("localhost", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if len(forwarded) != 1 || forwarded[0] != proxy.Addr().String() {
+		t.Errorf("forward dialer dialed %v != exp only the proxy %s", forwarded, proxy.Addr())
+	}
+	if got, exp := <-gotAddr, net.JoinHostPort("localhost", port); got != exp {
+		t.Errorf("proxy got connect to %s != exp %s", got, exp)
+	}
+	b := make([]byte, 2)
+	if _, err := io.ReadFull(conn, b); err != nil || string(b) != "hi" {
+		t.Errorf("got %q, %v != exp \"hi\", nil", b, err)
+	}
+}
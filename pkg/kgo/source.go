@@ -38,6 +38,9 @@ type source struct {
 
 	session fetchSession // supports fetch sessions as per KIP-227
 
+	sessionInfoMu sync.Mutex
+	sessionInfo   FetchSessionInfo // snapshot of session after the last fetch
+
 	cursorsMu    sync.Mutex
 	cursors      []*cursor // contains all partitions being consumed on this source
 	cursorsStart int       // incremented every fetch req to ensure all partitions are fetched
@@ -120,6 +123,20 @@ type cursor struct {
 	// request or when the source is stopped.
 	useState uint32
 
+	// endState is an atomic tracking whether this cursor has been polled
+	// to the end of its partition, for CaughtUp: cursorInactive if the
+	// cursor is not being consumed, cursorActive if it is, and
+	// cursorAtEnd if the last poll returned everything up to the high
+	// watermark (or last stable offset) of the fetch it came from.
+	endState uint32
+
+	// lagOffset and lagEnd are atomics for LagGauges: the offset the
+	// cursor has been polled to (or -1 if the cursor is not being
+	// consumed), and the end offset from the latest fetch (or -1 if
+	// unknown). The lag is the difference.
+	lagOffset int64
+	lagEnd    int64
+
 	topicPartitionData // updated in metadata when session is stopped
 
 	// cursorOffset is our epoch/offset that we are consuming. When a fetch
@@ -157,6 +174,7 @@ func (c *cursor) use() *cursorOffsetNext {
 		cursorOffset:       c.cursorOffset,
 		from:               c,
 		currentLeaderEpoch: c.leaderEpoch,
+		endOffset:          -1,
 	}
 }
 
@@ -190,6 +208,42 @@ func (c *cursor) allowUsable() {
 // after.
 func (c *cursor) setOffset(o cursorOffset) {
 	c.cursorOffset = o
+	state := cursorInactive
+	if o.offset >= 0 {
+		state = cursorActive
+	} else {
+		atomic.StoreInt64(&c.lagEnd, -1)
+	}
+	atomic.StoreUint32(&c.endState, state)
+	atomic.StoreInt64(&c.lagOffset, o.offset)
+}
+
+// lag returns the cursor's lag as of its latest fetch and poll, or false if
+// the cursor is not being consumed or has not yet been fetched.
+func (c *cursor) lag() (int64, bool) {
+	offset, end := atomic.LoadInt64(&c.lagOffset), atomic.LoadInt64(&c.lagEnd)
+	if offset < 0 || end < 0 {
+		return 0, false
+	}
+	if lag := end - offset; lag > 0 {
+		return lag, true
+	}
+	return 0, true
+}
+
+const (
+	cursorInactive uint32 = iota
+	cursorActive
+	cursorAtEnd
+)
+
+// setPolledOffset sets the cursor's offset after a poll returns records up to
+// o, marking the cursor at the end if o is at the end offset of the fetch.
+func (c *cursor) setPolledOffset(o *cursorOffsetNext) {
+	c.setOffset(o.cursorOffset)
+	if o.endOffset >= 0 && o.offset >= o.endOffset {
+		atomic.StoreUint32(&c.endState, cursorAtEnd)
+	}
 }
 
 // cursorOffsetNext is updated while processing a fetch response.
@@ -211,6 +265,14 @@ type cursorOffsetNext struct {
 	// Basically, any field read in AppendTo needs to be copied into
 	// cursorOffsetNext.
 	currentLeaderEpoch int32
+
+	// endOffset is the high watermark (or last stable offset if reading
+	// committed) from the fetch response, or -1 if the partition errored.
+	endOffset int64
+
+	// filtered is the number of records dropped from the fetch response
+	// because they were in aborted transactions, for FetchHook.
+	filtered int
 }
 
 type cursorOffsetPreferred struct {
@@ -276,13 +338,15 @@ type bufferedFetch struct {
 
 	doneFetch   chan<- struct{} // when unbuffered, we send down this
 	usedOffsets usedOffsets     // what the offsets will be next if this fetch is used
+	bufferedAt  time.Time       // used for adaptive fetch sizing
 }
 
 // takeBuffered drains a buffered fetch and updates offsets.
 func (s *source) takeBuffered() Fetch {
+	s.cl.consumer.adaptFetchMaxBytesForPoll(time.Since(s.buffered.bufferedAt))
 	return s.takeBufferedFn(func(usedOffsets usedOffsets) {
 		usedOffsets.finishUsingAllWith(func(o *cursorOffsetNext) {
-			o.from.setOffset(o.cursorOffset)
+			o.from.setPolledOffset(o)
 		})
 	})
 }
@@ -336,7 +400,7 @@ func (s *source) takeNBuffered(n int) (Fetch, int, bool) {
 			if len(p.Records) == 0 {
 				t.Partitions = t.Partitions[1:]
 
-				pCursor.from.setOffset(pCursor.cursorOffset)
+				pCursor.from.setPolledOffset(pCursor)
 				pCursor.from.allowUsable()
 				delete(tCursors, p.Partition)
 				if len(tCursors) == 0 {
@@ -375,11 +439,19 @@ func (s *source) takeBufferedFn(offsetFn func(usedOffsets)) Fetch {
 
 // createReq actually creates a fetch request.
 func (s *source) createReq() *fetchRequest {
+	// We clamp maxPartBytes to maxBytes for the same reason as in our
+	// config validation; either may have been changed at runtime.
+	maxBytes := atomic.LoadInt32(&s.cl.consumer.maxBytes)
+	maxPartBytes := atomic.LoadInt32(&s.cl.consumer.maxPartBytes)
+	if maxPartBytes > maxBytes {
+		maxPartBytes = maxBytes
+	}
+
 	req := &fetchRequest{
-		maxWait:        s.cl.cfg.maxWait,
-		minBytes:       s.cl.cfg.minBytes,
-		maxBytes:       s.cl.cfg.maxBytes,
-		maxPartBytes:   s.cl.cfg.maxPartBytes,
+		maxWait:        atomic.LoadInt32(&s.cl.consumer.maxWait),
+		minBytes:       atomic.LoadInt32(&s.cl.consumer.minBytes),
+		maxBytes:       maxBytes,
+		maxPartBytes:   maxPartBytes,
 		rack:           s.cl.cfg.rack,
 		isolationLevel: s.cl.cfg.isolationLevel,
 
@@ -550,10 +622,10 @@ func (s *source) fetch(consumerSession *consumerSession, doneFetch chan<- struct
 
 		s.cl.triggerUpdateMetadata(false) // as good a time as any
 		s.consecutiveFailures++
-		after := time.NewTimer(s.cl.cfg.retryBackoff(s.consecutiveFailures))
+		after := s.cl.cfg.clock.NewTimer(s.cl.cfg.retryBackoff(s.consecutiveFailures))
 		defer after.Stop()
 		select {
-		case <-after.C:
+		case <-after.C():
 		case <-ctx.Done():
 		}
 		return
@@ -562,6 +634,14 @@ func (s *source) fetch(consumerSession *consumerSession, doneFetch chan<- struct
 
 	resp := kresp.(*kmsg.FetchResponse)
 
+	var respBytes int
+	for i := range resp.Topics {
+		for j := range resp.Topics[i].Partitions {
+			respBytes += len(resp.Topics[i].Partitions[j].RecordBatches)
+		}
+	}
+	s.cl.consumer.adaptFetchMaxBytes(req.maxBytes, respBytes)
+
 	var (
 		fetch         Fetch
 		reloadOffsets listOrEpochLoads
@@ -611,6 +691,7 @@ func (s *source) fetch(consumerSession *consumerSession, doneFetch chan<- struct
 
 	// The session on the request was updated; we keep those updates.
 	s.session = req.session
+	defer s.storeSessionInfo(req)
 
 	// handleReqResp only parses the body of the response, not the top
 	// level error code.
@@ -662,6 +743,7 @@ func (s *source) fetch(consumerSession *consumerSession, doneFetch chan<- struct
 			fetch:       fetch,
 			doneFetch:   doneFetch,
 			usedOffsets: req.usedOffsets,
+			bufferedAt:  time.Now(),
 		}
 		s.sem = make(chan struct{})
 		s.cl.consumer.addSourceReadyForDraining(s)
@@ -723,6 +805,27 @@ func (s *source) handleReqResp(req *fetchRequest, resp *kmsg.FetchResponse) (Fet
 			fp := &fetchTopic.Partitions[len(fetchTopic.Partitions)-1]
 			updateMeta = updateMeta || fp.Err != nil
 
+			s.cl.cfg.hooks.each(func(h Hook) {
+				if h, ok := h.(FetchHook); ok {
+					h.OnFetch(topic, partition, len(fp.Records), partOffset.filtered, fp.Err)
+				}
+			})
+
+			if fp.Err == nil {
+				partOffset.endOffset = fp.HighWatermark
+				if req.isolationLevel == 1 {
+					partOffset.endOffset = fp.LastStableOffset
+				}
+				atomic.StoreInt64(&partOffset.from.lagEnd, partOffset.endOffset)
+				if lag, ok := partOffset.from.lag(); ok {
+					s.cl.cfg.hooks.each(func(h Hook) {
+						if h, ok := h.(FetchLagHook); ok {
+							h.OnFetchLag(topic, partition, lag)
+						}
+					})
+				}
+			}
+
 			switch fp.Err {
 			default:
 				// - bad auth
@@ -765,16 +868,20 @@ func (s *source) handleReqResp(req *fetchRequest, resp *kmsg.FetchResponse) (Fet
 				// we stay in a cycle of validating the leader epoch
 				// until the follower has caught up.
 
+				reset := offsetLoad{
+					replica: -1,
+					Offset:  s.cl.cfg.resetOffset,
+				}
+				if s.cl.cfg.onOffsetOutOfRange != nil {
+					reset.Offset = NewOffset().AtStart()
+					reset.outOfRange = true
+					reset.consumed = partOffset.offset
+				}
 				if s.nodeID == partOffset.from.leader { // non KIP-392 case
-					reloadOffsets.addLoad(topic, partition, loadTypeList, offsetLoad{
-						replica: -1,
-						Offset:  s.cl.cfg.resetOffset,
-					})
+					reloadOffsets.addLoad(topic, partition, loadTypeList, reset)
 				} else if partOffset.offset < fp.LogStartOffset { // KIP-392 case 3
-					reloadOffsets.addLoad(topic, partition, loadTypeList, offsetLoad{
-						replica: s.nodeID,
-						Offset:  s.cl.cfg.resetOffset,
-					})
+					reset.replica = s.nodeID
+					reloadOffsets.addLoad(topic, partition, loadTypeList, reset)
 				} else { // partOffset.offset > fp.HighWatermark, KIP-392 case 4
 					reloadOffsets.addLoad(topic, partition, loadTypeEpoch, offsetLoad{
 						replica: -1,
@@ -1252,6 +1359,8 @@ func (o *cursorOffsetNext) maybeKeepRecord(fp *FetchPartition, record *Record, a
 	// We only keep control records if specifically requested.
 	if record.Attrs.IsControl() {
 		abort = !o.from.keepControl
+	} else if abort {
+		o.filtered++
 	}
 	if !abort {
 		fp.Records = append(fp.Records, record)
@@ -1365,6 +1474,8 @@ type fetchRequest struct {
 	numOffsets  int
 	usedOffsets usedOffsets
 
+	numSent int // partitions actually written, set in AppendTo
+
 	// Session is a copy of the source session at the time a request is
 	// built. If the source is reset, the session it has is reset at the
 	// field level only. Our view of the original session is still valid.
@@ -1401,6 +1512,7 @@ func (f *fetchRequest) AppendTo(dst []byte) []byte {
 		SessionEpoch:   f.session.epoch,
 		Rack:           f.rack,
 	}
+	f.numSent = 0
 
 	for topic, partitions := range f.usedOffsets {
 
@@ -1429,6 +1541,7 @@ func (f *fetchRequest) AppendTo(dst []byte) []byte {
 					LogStartOffset:     -1,
 					PartitionMaxBytes:  f.maxPartBytes,
 				})
+				f.numSent++
 			}
 		}
 	}
@@ -1442,6 +1555,72 @@ func (f *fetchRequest) ResponseKind() kmsg.Response {
 	return &kmsg.FetchResponse{Version: f.version}
 }
 
+// FetchSessionInfo is a snapshot of the fetch session (KIP-227) the client
+// has with a broker, as returned from Client.FetchSessionInfo.
+type FetchSessionInfo struct {
+	// NodeID is the broker this session is with.
+	NodeID int32
+
+	// ID is the session ID the broker assigned, or 0 if no session has
+	// been established.
+	ID int32
+
+	// Epoch is the epoch the next fetch request will use. An epoch of 0
+	// means the next request will create a new session (unregistering any
+	// prior session), and an epoch of -1 means sessions are not in use.
+	Epoch int32
+
+	// Partitions is the number of partitions in the session, that is,
+	// partitions the broker knows of and that do not need to be resent.
+	Partitions int
+
+	// LastSent is the number of partitions that were written in the last
+	// fetch request. With a healthy session, this is only partitions whose
+	// offset or epoch changed; if it is routinely equal to Partitions, the
+	// session is not saving anything.
+	LastSent int
+
+	// Killed is true if the client stopped using sessions with this
+	// broker, either because the broker is too old or because the broker
+	// is out of session slots.
+	Killed bool
+}
+
+// FetchSessionInfo returns a snapshot of the fetch session with the given
+// broker as of the last fetch response from it. If the client has not
+// fetched from the broker, this returns the zero value with only NodeID set.
+func (cl *Client) FetchSessionInfo(nodeID int32) FetchSessionInfo {
+	cl.sinksAndSourcesMu.Lock()
+	sns, exists := cl.sinksAndSources[nodeID]
+	cl.sinksAndSourcesMu.Unlock()
+	if !exists {
+		return FetchSessionInfo{NodeID: nodeID}
+	}
+	s := sns.source
+	s.sessionInfoMu.Lock()
+	defer s.sessionInfoMu.Unlock()
+	info := s.sessionInfo
+	info.NodeID = nodeID
+	return info
+}
+
+func (s *source) storeSessionInfo(req *fetchRequest) {
+	var partitions int
+	for _, t := range s.session.used {
+		partitions += len(t)
+	}
+	s.sessionInfoMu.Lock()
+	defer s.sessionInfoMu.Unlock()
+	s.sessionInfo = FetchSessionInfo{
+		NodeID:     s.nodeID,
+		ID:         s.session.id,
+		Epoch:      s.session.epoch,
+		Partitions: partitions,
+		LastSent:   req.numSent,
+		Killed:     s.session.killed,
+	}
+}
+
 // fetchSessions, introduced in KIP-227, allow us to send less information back
 // and forth to a Kafka broker. Rather than relying on forgotten topics to
 // remove partitions from a session, we just simply reset the session.
@@ -182,6 +182,11 @@ type topicPartition struct {
 	// whether the data changed (leader or leader epoch, etc.).
 	topicPartitionData
 
+	// isr is the number of in sync replicas in the metadata response that
+	// created this topicPartition. This is not part of topicPartitionData
+	// because changes to it do not require migrating records or cursors.
+	isr int32
+
 	// If we do not have a load error, we copy the records and cursor
 	// pointers from the old after updating any necessary fields in them
 	// (see migrate functions below).
@@ -58,6 +58,9 @@ type GroupTransactSession struct {
 // rebalance timeout, but this is just one request with no cpu logic. With a
 // proper rebalance timeout, this single request will not fail and the commit
 // will succeed properly.
+//
+// This returns nil if the group is not assigned, such as if the options
+// include GroupOffsetStore, which cannot be used with transactions.
 func (cl *Client) AssignGroupTransactSession(group string, opts ...GroupOpt) *GroupTransactSession {
 	cl.AssignGroup(group, opts...)
 
@@ -519,7 +522,9 @@ func (cl *Client) EndTransaction(ctx context.Context, commit TransactionEndTry)
 
 // If a transaction is begun too quickly after finishing an old transaction,
 // Kafka may still be finalizing its commit / abort and will return a
-// concurrent transactions error. We handle that by retrying for a bit.
+// concurrent transactions error. We handle that by retrying for a bit. Any
+// other error from fn, or the concurrent transactions error once we stop
+// retrying, is returned.
 func (cl *Client) doWithConcurrentTransactions(name string, fn func() error) error {
 	start := time.Now()
 	tries := 0
@@ -540,7 +545,7 @@ start:
 		}
 		goto start
 	}
-	return nil
+	return err
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -3,6 +3,7 @@ package kgo
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
@@ -10,6 +11,10 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
 )
 
 // This test is identical to TestGroupETL but based around transactions.
@@ -255,3 +260,84 @@ func (c *testConsumer) transact(txnsBeforeQuit int) {
 	}
 
 }
+
+func TestAddPartitionsToTxnErrReturned(t *testing.T) {
+	c := fakecluster.New(t, map[string]int32{"foo": 1})
+	defer c.Close()
+
+	var tries int32
+	c.Control(24, func(kreq kmsg.Request) (kmsg.Response, error) {
+		req := kreq.(*kmsg.AddPartitionsToTxnRequest)
+		resp := req.ResponseKind().(*kmsg.AddPartitionsToTxnResponse)
+		code := kerr.TopicAuthorizationFailed.Code
+		if atomic.AddInt32(&tries, 1) == 1 {
+			code = kerr.ConcurrentTransactions.Code // retried
+		}
+		for _, rt := range req.Topics {
+			st := kmsg.NewAddPartitionsToTxnResponseTopic()
+			st.Topic = rt.Topic
+			for _, p := range rt.Partitions {
+				sp := kmsg.NewAddPartitionsToTxnResponseTopicPartition()
+				sp.Partition, sp.ErrorCode = p, code
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil
+	})
+
+	cl, err := NewClient(
+		SeedBrokers(c.Addr()),
+		TransactionalID("txn"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	if err := cl.BeginTransaction(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = cl.ProduceSync(ctx, &Record{Topic: "foo"}).FirstErr()
+	var addErr *ErrAddPartitionsToTxn
+	if !errors.As(err, &addErr) || addErr.Errors["foo"][0] != kerr.TopicAuthorizationFailed {
+		t.Errorf("got err %v != exp ErrAddPartitionsToTxn with TOPIC_AUTHORIZATION_FAILED", err)
+	}
+	if got := atomic.LoadInt32(&tries); got < 2 {
+		t.Errorf("got %d AddPartitionsToTxn tries, expected CONCURRENT_TRANSACTIONS to be retried", got)
+	}
+}
+
+func TestAddOffsetsToTxnErrReturned(t *testing.T) {
+	c := fakecluster.New(t, nil)
+	defer c.Close()
+
+	var tries int32
+	c.Control(25, func(kreq kmsg.Request) (kmsg.Response, error) {
+		resp := kreq.ResponseKind().(*kmsg.AddOffsetsToTxnResponse)
+		switch atomic.AddInt32(&tries, 1) {
+		case 1:
+			resp.ErrorCode = kerr.ConcurrentTransactions.Code // retried
+		case 2:
+			resp.ErrorCode = kerr.GroupAuthorizationFailed.Code
+		}
+		return resp, nil
+	})
+
+	cl, err := NewClient(SeedBrokers(c.Addr()), TransactionalID("txn"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cl.addOffsetsToTxn(ctx, "group"); err != kerr.GroupAuthorizationFailed {
+		t.Errorf("got err %v != exp GROUP_AUTHORIZATION_FAILED", err)
+	}
+	if err := cl.addOffsetsToTxn(ctx, "group"); err != nil {
+		t.Errorf("got unexpected err %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+//go:build go1.18
+// +build go1.18
+
+package kgo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// TypedSerde configures how a TypedClient converts between the bytes of a
+// record's key or value and a Go type.
+type TypedSerde[T any] struct {
+	// Deserialize decodes consumed bytes. If nil, consumed records are
+	// left with the zero value of T. Note that a nil slice means the key
+	// or value was null.
+	Deserialize func([]byte) (T, error)
+
+	// Serialize encodes v for a record being produced. The record has its
+	// Topic set and any headers set by serializing prior fields (the key
+	// is serialized before the value); Serialize can add headers, such as
+	// a schema ID. If nil, produced records have a null key or value.
+	Serialize func(r *Record, v T) ([]byte, error)
+}
+
+// TypedRecord is a consumed record with its key and value deserialized.
+type TypedRecord[K, V any] struct {
+	// Record is the underlying consumed record, including the raw key
+	// and value bytes.
+	Record *Record
+
+	// Key is the deserialized key.
+	Key K
+	// Value is the deserialized value.
+	Value V
+
+	// Err is the first error from deserializing the key or value, if
+	// any. A record that failed to deserialize is still returned so that
+	// the error can be handled per record; Key or Value may be partially
+	// decoded or the zero value.
+	Err error
+}
+
+// TypedFetches is the result of polling a TypedClient: the raw fetches, and
+// every record in the fetches deserialized, in order.
+//
+// The embedded Fetches should still be checked for errors.
+type TypedFetches[K, V any] struct {
+	Fetches
+
+	// Records contains every record in the fetches, deserialized.
+	Records []TypedRecord[K, V]
+}
+
+// TypedClient wraps a Client to deserialize consumed keys and values into K
+// and V, and to serialize produced keys and values from K and V, avoiding
+// every application reimplementing the same serde loop.
+//
+// A TypedClient adds no state to the Client it wraps: it is safe to use the
+// wrapped client directly, and to create many typed clients over one client.
+type TypedClient[K, V any] struct {
+	cl  *Client
+	key TypedSerde[K]
+	val TypedSerde[V]
+}
+
+// NewTypedClient returns a TypedClient that uses key and value to convert
+// keys and values of records for cl.
+func NewTypedClient[K, V any](cl *Client, key TypedSerde[K], value TypedSerde[V]) *TypedClient[K, V] {
+	return &TypedClient[K, V]{
+		cl:  cl,
+		key: key,
+		val: value,
+	}
+}
+
+// Client returns the wrapped client.
+func (t *TypedClient[K, V]) Client() *Client {
+	return t.cl
+}
+
+// PollFetches calls PollFetches on the wrapped client and deserializes every
+// polled record. Deserialization errors are set per record and do not stop
+// deserializing other records.
+func (t *TypedClient[K, V]) PollFetches(ctx context.Context) TypedFetches[K, V] {
+	return t.PollRecords(ctx, 0)
+}
+
+// PollRecords calls PollRecords on the wrapped client and deserializes every
+// polled record, as PollFetches does.
+func (t *TypedClient[K, V]) PollRecords(ctx context.Context, maxPollRecords int) TypedFetches[K, V] {
+	fetches := t.cl.PollRecords(ctx, maxPollRecords)
+	tf := TypedFetches[K, V]{Fetches: fetches}
+	for it := fetches.RecordIter(); !it.Done(); {
+		tf.Records = append(tf.Records, t.Deserialize(it.Next()))
+	}
+	return tf
+}
+
+// Deserialize deserializes a single record's key and value.
+func (t *TypedClient[K, V]) Deserialize(r *Record) TypedRecord[K, V] {
+	tr := TypedRecord[K, V]{Record: r}
+	if t.key.Deserialize != nil {
+		tr.Key, tr.Err = t.key.Deserialize(r.Key)
+	}
+	if t.val.Deserialize != nil {
+		var err error
+		tr.Value, err = t.val.Deserialize(r.Value)
+		if tr.Err == nil {
+			tr.Err = err
+		}
+	}
+	return tr
+}
+
+// Serialize returns a new record for topic with the key and value serialized.
+func (t *TypedClient[K, V]) Serialize(topic string, key K, value V) (*Record, error) {
+	r := &Record{Topic: topic}
+	var err error
+	if t.key.Serialize != nil {
+		if r.Key, err = t.key.Serialize(r, key); err != nil {
+			return nil, err
+		}
+	}
+	if t.val.Serialize != nil {
+		if r.Value, err = t.val.Serialize(r, value); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Produce serializes key and value into a record for topic and produces it
+// with the wrapped client, as Client.Produce does. Serialization errors are
+// returned immediately, before anything is buffered, and the promise is not
+// called.
+func (t *TypedClient[K, V]) Produce(
+	ctx context.Context,
+	topic string,
+	key K,
+	value V,
+	promise func(*Record, error),
+) error {
+	r, err := t.Serialize(topic, key, value)
+	if err != nil {
+		return err
+	}
+	return t.cl.Produce(ctx, r, promise)
+}
+
+// ProduceSync serializes key and value into a record for topic and produces
+// it synchronously, returning the produced record (with its partition and
+// offset set) or the serialization or produce error.
+func (t *TypedClient[K, V]) ProduceSync(ctx context.Context, topic string, key K, value V) (*Record, error) {
+	r, err := t.Serialize(topic, key, value)
+	if err != nil {
+		return nil, err
+	}
+	return r, t.cl.ProduceSync(ctx, r).FirstErr()
+}
+
+// TypedResponseShard is a ResponseShard with the request and response typed,
+// as returned from TypedRequestSharded.
+type TypedResponseShard[Req kmsg.Request, Resp kmsg.Response] struct {
+	// Meta contains the broker that this request was issued to, or an
+	// unknown (node ID -1) metadata if the request could not be issued.
+	Meta BrokerMetadata
+
+	// Req is the piece of the original request that was issued to this
+	// broker.
+	Req Req
+
+	// Resp is the response received from the broker, if any. This is the
+	// zero value (nil) if Err is non-nil.
+	Resp Resp
+
+	// Err, if non-nil, is the error that prevented a response from being
+	// received or the request from being issued.
+	Err error
+
+	// Attempts is the number of times this request was tried; see
+	// ResponseShard.Attempts.
+	Attempts int
+}
+
+// TypedRequestSharded calls RequestSharded on cl and returns the shards with
+// their requests and responses typed, avoiding type asserting every shard:
+//
+//	shards := kgo.TypedRequestSharded[*kmsg.ListOffsetsRequest, *kmsg.ListOffsetsResponse](ctx, cl, req)
+//
+// Resp must be the type of req.ResponseKind(); if it is not, every shard that
+// received a response has a non-nil Err and a nil Resp.
+func TypedRequestSharded[Req kmsg.Request, Resp kmsg.Response](
+	ctx context.Context,
+	cl *Client,
+	req Req,
+) []TypedResponseShard[Req, Resp] {
+	shards := cl.RequestSharded(ctx, req)
+	typed := make([]TypedResponseShard[Req, Resp], 0, len(shards))
+	for _, shard := range shards {
+		t := TypedResponseShard[Req, Resp]{
+			Meta:     shard.Meta,
+			Err:      shard.Err,
+			Attempts: shard.Attempts,
+		}
+		if shard.Req != nil {
+			t.Req, _ = shard.Req.(Req)
+		}
+		if shard.Resp != nil {
+			var ok bool
+			if t.Resp, ok = shard.Resp.(Resp); !ok && t.Err == nil {
+				t.Err = fmt.Errorf("response type %T is not the requested %T", shard.Resp, t.Resp)
+			}
+		}
+		typed = append(typed, t)
+	}
+	return typed
+}
@@ -0,0 +1,89 @@
+//go:build go1.18
+// +build go1.18
+
+package kgo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/internal/fakecluster"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestTypedRequestSharded(t *testing.T) {
+	t.Parallel()
+
+	c := fakecluster.New(t, map[string]int32{"t": 2})
+	defer c.Close()
+
+	c.Control(2, func(req kmsg.Request) (kmsg.Response, error) {
+		resp := req.ResponseKind().(*kmsg.ListOffsetsResponse)
+		for _, rt := range req.(*kmsg.ListOffsetsRequest).Topics {
+			st := kmsg.NewListOffsetsResponseTopic()
+			st.Topic = rt.Topic
+			for _, rp := range rt.Partitions {
+				sp := kmsg.NewListOffsetsResponseTopicPartition()
+				sp.Partition = rp.Partition
+				sp.Offset = 10
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil
+	})
+
+	cl, err := NewClient(SeedBrokers(c.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req := kmsg.NewPtrListOffsetsRequest()
+	rt := kmsg.NewListOffsetsRequestTopic()
+	rt.Topic = "t"
+	for _, p := range []int32{0, 1} {
+		rp := kmsg.NewListOffsetsRequestTopicPartition()
+		rp.Partition = p
+		rt.Partitions = append(rt.Partitions, rp)
+	}
+	req.Topics = append(req.Topics, rt)
+
+	shards := TypedRequestSharded[*kmsg.ListOffsetsRequest, *kmsg.ListOffsetsResponse](ctx, cl, req)
+	if len(shards) == 0 {
+		t.Fatal("no shards")
+	}
+	var partitions int
+	for _, shard := range shards {
+		if shard.Err != nil {
+			t.Fatalf("unexpected shard err: %v", shard.Err)
+		}
+		if shard.Req == nil || shard.Resp == nil {
+			t.Fatalf("shard missing typed request or response: %+v", shard)
+		}
+		for _, st := range shard.Resp.Topics {
+			for _, sp := range st.Partitions {
+				if sp.Offset != 10 {
+					t.Errorf("got offset %d != exp 10", sp.Offset)
+				}
+				partitions++
+			}
+		}
+	}
+	if partitions != 2 {
+		t.Errorf("got %d partitions != exp 2", partitions)
+	}
+
+	// A response type that does not match the request is an error on
+	// every shard, rather than a panic.
+	mismatched := TypedRequestSharded[*kmsg.ListOffsetsRequest, *kmsg.MetadataResponse](ctx, cl, req)
+	for _, shard := range mismatched {
+		if shard.Err == nil || shard.Resp != nil {
+			t.Errorf("got err %v, resp %v; expected a type mismatch err and nil resp", shard.Err, shard.Resp)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package kinternal decodes records from Kafka's internal topics.
+//
+// Kafka stores group commits and group metadata in the __consumer_offsets
+// topic, and transactional state in the __transaction_state topic. The admin
+// APIs expose most of this state, but reading the topics directly can help
+// when debugging: the topics show every state transition, including
+// transitions for groups and transactions that no longer exist.
+//
+// This package only decodes the record formats that the kmsg package defines
+// (OffsetCommitKey / OffsetCommitValue, GroupMetadataKey /
+// GroupMetadataValue, and TxnMetadataKey / TxnMetadataValue). Record versions
+// newer than what kmsg knows of return an error rather than a partially
+// decoded record.
+package kinternal
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// The internal topic names.
+const (
+	ConsumerOffsetsTopic  = "__consumer_offsets"
+	TransactionStateTopic = "__transaction_state"
+)
+
+// ConsumerOffsetsRecord is a decoded __consumer_offsets record. Exactly one of
+// OffsetCommitKey or GroupMetadataKey is non-nil.
+//
+// A nil value for a non-nil key means the record is a tombstone: the offset
+// commit expired or was deleted, or the group was deleted.
+type ConsumerOffsetsRecord struct {
+	OffsetCommitKey   *kmsg.OffsetCommitKey
+	OffsetCommitValue *kmsg.OffsetCommitValue
+
+	GroupMetadataKey   *kmsg.GroupMetadataKey
+	GroupMetadataValue *kmsg.GroupMetadataValue
+}
+
+// TransactionStateRecord is a decoded __transaction_state record. A nil Value
+// means the record is a tombstone for an expired transactional ID.
+type TransactionStateRecord struct {
+	Key   kmsg.TxnMetadataKey
+	Value *kmsg.TxnMetadataValue
+}
+
+// errShort is returned for keys or values too short to contain a version.
+var errShort = errors.New("record too short to contain a version")
+
+func version(b []byte) (int16, error) {
+	if len(b) < 2 {
+		return 0, errShort
+	}
+	return int16(b[0])<<8 | int16(b[1]), nil
+}
+
+// checkVersion returns an error if b's version is larger than max.
+func checkVersion(what string, b []byte, max int16) error {
+	v, err := version(b)
+	if err != nil {
+		return fmt.Errorf("%s: %w", what, err)
+	}
+	if v < 0 || v > max {
+		return fmt.Errorf("%s: unknown version %d", what, v)
+	}
+	return nil
+}
+
+// DecodeConsumerOffsets decodes the key and value of a record consumed from
+// the __consumer_offsets topic.
+func DecodeConsumerOffsets(key, value []byte) (ConsumerOffsetsRecord, error) {
+	var r ConsumerOffsetsRecord
+	v, err := version(key)
+	if err != nil {
+		return r, fmt.Errorf("key: %w", err)
+	}
+	switch v {
+	case 0, 1:
+		r.OffsetCommitKey = new(kmsg.OffsetCommitKey)
+		if err := r.OffsetCommitKey.ReadFrom(key); err != nil {
+			return r, fmt.Errorf("offset commit key: %w", err)
+		}
+		if value == nil {
+			return r, nil
+		}
+		if err := checkVersion("offset commit value", value, 3); err != nil {
+			return r, err
+		}
+		r.OffsetCommitValue = new(kmsg.OffsetCommitValue)
+		if err := r.OffsetCommitValue.ReadFrom(value); err != nil {
+			return r, fmt.Errorf("offset commit value: %w", err)
+		}
+
+	case 2:
+		r.GroupMetadataKey = new(kmsg.GroupMetadataKey)
+		if err := r.GroupMetadataKey.ReadFrom(key); err != nil {
+			return r, fmt.Errorf("group metadata key: %w", err)
+		}
+		if value == nil {
+			return r, nil
+		}
+		if err := checkVersion("group metadata value", value, 3); err != nil {
+			return r, err
+		}
+		r.GroupMetadataValue = new(kmsg.GroupMetadataValue)
+		if err := r.GroupMetadataValue.ReadFrom(value); err != nil {
+			return r, fmt.Errorf("group metadata value: %w", err)
+		}
+
+	default:
+		return r, fmt.Errorf("key: unknown version %d", v)
+	}
+	return r, nil
+}
+
+// DecodeTransactionState decodes the key and value of a record consumed from
+// the __transaction_state topic.
+func DecodeTransactionState(key, value []byte) (TransactionStateRecord, error) {
+	var r TransactionStateRecord
+	if err := checkVersion("txn metadata key", key, 0); err != nil {
+		return r, err
+	}
+	if err := r.Key.ReadFrom(key); err != nil {
+		return r, fmt.Errorf("txn metadata key: %w", err)
+	}
+	if value == nil {
+		return r, nil
+	}
+	if err := checkVersion("txn metadata value", value, 0); err != nil {
+		return r, err
+	}
+	r.Value = new(kmsg.TxnMetadataValue)
+	if err := r.Value.ReadFrom(value); err != nil {
+		return r, fmt.Errorf("txn metadata value: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,122 @@
+package kinternal
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestDecodeConsumerOffsets(t *testing.T) {
+	commitKey := kmsg.OffsetCommitKey{Version: 1, Group: "g", Topic: "t", Partition: 3}
+	commitValue := kmsg.OffsetCommitValue{Version: 3, Offset: 10, LeaderEpoch: 2, Metadata: "m", CommitTimestamp: 100}
+	groupKey := kmsg.GroupMetadataKey{Version: 2, Group: "g"}
+	groupValue := kmsg.GroupMetadataValue{Version: 3, ProtocolType: "consumer", Generation: 4, Protocol: kmsg.StringPtr("range"), Leader: kmsg.StringPtr("m")}
+
+	{
+		r, err := DecodeConsumerOffsets(commitKey.AppendTo(nil), commitValue.AppendTo(nil))
+		if err != nil {
+			t.Fatalf("offset commit: unexpected err: %v", err)
+		}
+		if !reflect.DeepEqual(r.OffsetCommitKey, &commitKey) || !reflect.DeepEqual(r.OffsetCommitValue, &commitValue) {
+			t.Errorf("offset commit: got %+v %+v != exp %+v %+v", r.OffsetCommitKey, r.OffsetCommitValue, commitKey, commitValue)
+		}
+		if r.GroupMetadataKey != nil || r.GroupMetadataValue != nil {
+			t.Error("offset commit: unexpected group metadata")
+		}
+	}
+
+	{
+		r, err := DecodeConsumerOffsets(commitKey.AppendTo(nil), nil)
+		if err != nil {
+			t.Fatalf("offset commit tombstone: unexpected err: %v", err)
+		}
+		if r.OffsetCommitKey == nil || r.OffsetCommitValue != nil {
+			t.Errorf("offset commit tombstone: got %+v", r)
+		}
+	}
+
+	{
+		r, err := DecodeConsumerOffsets(groupKey.AppendTo(nil), groupValue.AppendTo(nil))
+		if err != nil {
+			t.Fatalf("group metadata: unexpected err: %v", err)
+		}
+		if !reflect.DeepEqual(r.GroupMetadataKey, &groupKey) || !reflect.DeepEqual(r.GroupMetadataValue, &groupValue) {
+			t.Errorf("group metadata: got %+v %+v != exp %+v %+v", r.GroupMetadataKey, r.GroupMetadataValue, groupKey, groupValue)
+		}
+		if r.OffsetCommitKey != nil || r.OffsetCommitValue != nil {
+			t.Error("group metadata: unexpected offset commit")
+		}
+	}
+
+	{
+		r, err := DecodeConsumerOffsets(groupKey.AppendTo(nil), nil)
+		if err != nil {
+			t.Fatalf("group metadata tombstone: unexpected err: %v", err)
+		}
+		if r.GroupMetadataKey == nil || r.GroupMetadataValue != nil {
+			t.Errorf("group metadata tombstone: got %+v", r)
+		}
+	}
+
+	newerValue := commitValue
+	newerValue.Version = 4
+	for _, test := range []struct {
+		name       string
+		key, value []byte
+	}{
+		{"short key", []byte{0}, nil},
+		{"unknown key version", []byte{0, 3}, nil},
+		{"short value", commitKey.AppendTo(nil), []byte{0}},
+		{"unknown value version", commitKey.AppendTo(nil), newerValue.AppendTo(nil)},
+		{"truncated key", commitKey.AppendTo(nil)[:4], nil},
+		{"truncated value", commitKey.AppendTo(nil), commitValue.AppendTo(nil)[:4]},
+	} {
+		if _, err := DecodeConsumerOffsets(test.key, test.value); err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+}
+
+func TestDecodeTransactionState(t *testing.T) {
+	key := kmsg.TxnMetadataKey{TransactionalID: "txn"}
+	value := kmsg.TxnMetadataValue{ProducerID: 1, ProducerEpoch: 2, TimeoutMillis: 60000, State: kmsg.TransactionStateOngoing}
+
+	{
+		r, err := DecodeTransactionState(key.AppendTo(nil), value.AppendTo(nil))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if !reflect.DeepEqual(r.Key, key) || !reflect.DeepEqual(r.Value, &value) {
+			t.Errorf("got %+v %+v != exp %+v %+v", r.Key, r.Value, key, value)
+		}
+	}
+
+	{
+		r, err := DecodeTransactionState(key.AppendTo(nil), nil)
+		if err != nil {
+			t.Fatalf("tombstone: unexpected err: %v", err)
+		}
+		if r.Key.TransactionalID != "txn" || r.Value != nil {
+			t.Errorf("tombstone: got %+v", r)
+		}
+	}
+
+	newerKey := key
+	newerKey.Version = 1
+	newerValue := value
+	newerValue.Version = 1
+	for _, test := range []struct {
+		name       string
+		key, value []byte
+	}{
+		{"short key", nil, nil},
+		{"unknown key version", newerKey.AppendTo(nil), nil},
+		{"unknown value version", key.AppendTo(nil), newerValue.AppendTo(nil)},
+		{"truncated value", key.AppendTo(nil), value.AppendTo(nil)[:4]},
+	} {
+		if _, err := DecodeTransactionState(test.key, test.value); err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Package sr provides a Confluent Schema Registry client and serde that
+// encodes and decodes payloads in the registry's wire format.
+//
+// The wire format prefixes every payload with a magic zero byte and the big
+// endian 4 byte ID of the schema the payload was encoded with. Protobuf
+// payloads additionally have the indexes of the message type within the
+// schema file after the ID.
+//
+// This package does not implement Avro, Protobuf, or JSON encoding itself:
+// a Serde is given a Codec that wraps the encoding library of your choice.
+// The Serde handles registering and looking up schemas, caching schema IDs,
+// subject naming, and the wire format.
+package sr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// SchemaType is the type of a schema.
+type SchemaType int
+
+const (
+	TypeAvro SchemaType = iota
+	TypeProtobuf
+	TypeJSON
+)
+
+func (t SchemaType) String() string {
+	switch t {
+	case TypeAvro:
+		return "AVRO"
+	case TypeProtobuf:
+		return "PROTOBUF"
+	case TypeJSON:
+		return "JSON"
+	default:
+		return ""
+	}
+}
+
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *SchemaType) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	switch s {
+	case "", "AVRO": // the registry omits the type for Avro
+		*t = TypeAvro
+	case "PROTOBUF":
+		*t = TypeProtobuf
+	case "JSON":
+		*t = TypeJSON
+	default:
+		return fmt.Errorf("unknown schema type %q", s)
+	}
+	return nil
+}
+
+// SchemaReference is a reference from one schema to another schema
+// registered under a subject and version.
+type SchemaReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// Schema is a schema and its type.
+type Schema struct {
+	// Schema is the actual schema text.
+	Schema string `json:"schema"`
+	// Type is the type of the schema.
+	Type SchemaType `json:"schemaType"`
+	// References are any references to other schemas.
+	References []SchemaReference `json:"references,omitempty"`
+}
+
+// ResponseError is the error the registry returns for failed requests.
+type ResponseError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int `json:"-"`
+	// ErrorCode is the registry specific error code.
+	ErrorCode int `json:"error_code"`
+	// Message is the registry's error message.
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("schema registry error (status %d, code %d): %s", e.StatusCode, e.ErrorCode, e.Message)
+}
+
+// Opt is an option to configure a registry client.
+type Opt func(*Client)
+
+// HTTPClient sets the http client to use for requests, overriding the
+// default http.DefaultClient.
+func HTTPClient(httpcl *http.Client) Opt {
+	return func(cl *Client) { cl.httpcl = httpcl }
+}
+
+// BasicAuth sets basic authentication to use for requests.
+func BasicAuth(user, pass string) Opt {
+	return func(cl *Client) { cl.user, cl.pass = user, pass }
+}
+
+// Client is a schema registry client that caches schemas and schema IDs.
+//
+// Schemas are immutable once registered, so the cache is never invalidated.
+type Client struct {
+	url    string
+	httpcl *http.Client
+	user   string
+	pass   string
+
+	mu      sync.Mutex
+	byID    map[int]Schema
+	idByKey map[subjectSchema]int
+}
+
+type subjectSchema struct {
+	subject string
+	schema  string
+	typ     SchemaType
+}
+
+// NewClient returns a registry client for the registry at url.
+func NewClient(url string, opts ...Opt) *Client {
+	cl := &Client{
+		url:     strings.TrimSuffix(url, "/"),
+		httpcl:  http.DefaultClient,
+		byID:    make(map[int]Schema),
+		idByKey: make(map[subjectSchema]int),
+	}
+	for _, opt := range opts {
+		opt(cl)
+	}
+	return cl
+}
+
+func (cl *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("unable to encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, cl.url+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	}
+	if cl.user != "" || cl.pass != "" {
+		req.SetBasicAuth(cl.user, cl.pass)
+	}
+
+	resp, err := cl.httpcl.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		e := &ResponseError{StatusCode: resp.StatusCode}
+		if err := json.Unmarshal(b, e); err != nil || e.Message == "" {
+			e.Message = string(b)
+		}
+		return e
+	}
+	if out != nil {
+		if err := json.Unmarshal(b, out); err != nil {
+			return fmt.Errorf("unable to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// SchemaByID returns the schema for the given ID.
+func (cl *Client) SchemaByID(ctx context.Context, id int) (Schema, error) {
+	cl.mu.Lock()
+	s, ok := cl.byID[id]
+	cl.mu.Unlock()
+	if ok {
+		return s, nil
+	}
+
+	if err := cl.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &s); err != nil {
+		return Schema{}, err
+	}
+
+	cl.mu.Lock()
+	cl.byID[id] = s
+	cl.mu.Unlock()
+	return s, nil
+}
+
+// Register registers a schema under subject, returning the schema's ID. If
+// the schema is already registered under the subject, this returns the
+// existing ID.
+func (cl *Client) Register(ctx context.Context, subject string, s Schema) (int, error) {
+	return cl.idFor(ctx, subject, s, true)
+}
+
+// LookupID returns the ID of a schema already registered under subject.
+func (cl *Client) LookupID(ctx context.Context, subject string, s Schema) (int, error) {
+	return cl.idFor(ctx, subject, s, false)
+}
+
+func (cl *Client) idFor(ctx context.Context, subject string, s Schema, register bool) (int, error) {
+	key := subjectSchema{subject, s.Schema, s.Type}
+	cl.mu.Lock()
+	id, ok := cl.idByKey[key]
+	cl.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	path := "/subjects/" + url.PathEscape(subject)
+	if register {
+		path += "/versions"
+	}
+	var resp struct {
+		ID int `json:"id"`
+	}
+	if err := cl.do(ctx, http.MethodPost, path, s, &resp); err != nil {
+		return 0, err
+	}
+
+	cl.mu.Lock()
+	cl.idByKey[key] = resp.ID
+	cl.byID[resp.ID] = s
+	cl.mu.Unlock()
+	return resp.ID, nil
+}
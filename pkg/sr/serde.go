@@ -0,0 +1,203 @@
+package sr
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrNotRegistryEncoded is returned when decoding a payload that does not
+// start with the registry's magic byte and schema ID.
+var ErrNotRegistryEncoded = errors.New("payload is not schema registry encoded")
+
+// AppendHeader appends the wire format header for the schema id to b. For
+// Protobuf schemas, index is the path of indexes to the message type within
+// the schema file (for example, []int{0} for the first message, or
+// []int{1, 0} for the first nested message in the second message); index is
+// ignored for other schema types.
+func AppendHeader(b []byte, id int, typ SchemaType, index []int) []byte {
+	b = append(b, 0, byte(id>>24), byte(id>>16), byte(id>>8), byte(id))
+	if typ != TypeProtobuf {
+		return b
+	}
+	// The common case of the first message is encoded as a single zero,
+	// rather than a length of one followed by a zero index.
+	if len(index) == 1 && index[0] == 0 {
+		return append(b, 0)
+	}
+	var buf [binary.MaxVarintLen64]byte
+	b = append(b, buf[:binary.PutVarint(buf[:], int64(len(index)))]...)
+	for _, i := range index {
+		b = append(b, buf[:binary.PutVarint(buf[:], int64(i))]...)
+	}
+	return b
+}
+
+// DecodeHeader decodes the wire format header from b, returning the schema
+// ID, the Protobuf message index if typ is TypeProtobuf, and the remaining
+// payload.
+func DecodeHeader(b []byte, typ SchemaType) (id int, index []int, payload []byte, err error) {
+	if len(b) < 5 || b[0] != 0 {
+		return 0, nil, nil, ErrNotRegistryEncoded
+	}
+	id = int(binary.BigEndian.Uint32(b[1:5]))
+	b = b[5:]
+	if typ != TypeProtobuf {
+		return id, nil, b, nil
+	}
+
+	n, read := binary.Varint(b)
+	if read <= 0 || n < 0 || n > int64(len(b)) {
+		return 0, nil, nil, errors.New("invalid protobuf message index length")
+	}
+	b = b[read:]
+	if n == 0 {
+		return id, []int{0}, b, nil
+	}
+	index = make([]int, 0, n)
+	for ; n > 0; n-- {
+		i, read := binary.Varint(b)
+		if read <= 0 {
+			return 0, nil, nil, errors.New("invalid protobuf message index")
+		}
+		b = b[read:]
+		index = append(index, int(i))
+	}
+	return id, index, b, nil
+}
+
+// SubjectNameStrategy returns the subject to register a schema under for a
+// topic. The record name is the fully qualified name of the record type being
+// encoded, as configured with SerdeRecordName.
+type SubjectNameStrategy func(topic string, isKey bool, recordName string) string
+
+// TopicNameStrategy is the registry's default strategy, using "<topic>-key"
+// or "<topic>-value" as the subject.
+func TopicNameStrategy(topic string, isKey bool, _ string) string {
+	if isKey {
+		return topic + "-key"
+	}
+	return topic + "-value"
+}
+
+// RecordNameStrategy uses the record name as the subject, allowing one
+// schema to be used across many topics.
+func RecordNameStrategy(_ string, _ bool, recordName string) string {
+	return recordName
+}
+
+// TopicRecordNameStrategy uses "<topic>-<record name>" as the subject,
+// allowing many record types in one topic.
+func TopicRecordNameStrategy(topic string, _ bool, recordName string) string {
+	return topic + "-" + recordName
+}
+
+// Codec encodes and decodes values with a schema, wrapping an Avro,
+// Protobuf, or JSON library. The payloads do not include the wire format
+// header.
+type Codec struct {
+	// Encode encodes v with the schema it is being registered with.
+	Encode func(s Schema, v interface{}) ([]byte, error)
+	// Decode decodes payload into v, with the schema the payload was
+	// written with (which may differ from the Serde's schema if the
+	// schema evolved).
+	Decode func(s Schema, payload []byte, v interface{}) error
+}
+
+// SerdeOpt is an option to configure a Serde.
+type SerdeOpt func(*Serde)
+
+// SerdeKey configures the Serde to encode keys rather than values, which only
+// changes subject names for the TopicNameStrategy.
+func SerdeKey() SerdeOpt {
+	return func(s *Serde) { s.isKey = true }
+}
+
+// SerdeSubjectNameStrategy sets the subject naming strategy, overriding the
+// default TopicNameStrategy.
+func SerdeSubjectNameStrategy(strategy SubjectNameStrategy) SerdeOpt {
+	return func(s *Serde) { s.strategy = strategy }
+}
+
+// SerdeRecordName sets the fully qualified record name passed to the subject
+// naming strategy.
+func SerdeRecordName(name string) SerdeOpt {
+	return func(s *Serde) { s.recordName = name }
+}
+
+// SerdeProtobufIndex sets the path of indexes to the encoded message type
+// within a Protobuf schema file, overriding the default of the first message.
+func SerdeProtobufIndex(index ...int) SerdeOpt {
+	return func(s *Serde) { s.index = index }
+}
+
+// SerdeNoAutoRegister looks up schema IDs rather than registering schemas
+// when encoding, causing encoding to fail if the schema is not registered.
+func SerdeNoAutoRegister() SerdeOpt {
+	return func(s *Serde) { s.noRegister = true }
+}
+
+// Serde encodes values with a schema into the registry wire format, and
+// decodes wire format payloads.
+type Serde struct {
+	cl     *Client
+	schema Schema
+	codec  Codec
+
+	isKey      bool
+	strategy   SubjectNameStrategy
+	recordName string
+	index      []int
+	noRegister bool
+}
+
+// NewSerde returns a Serde that encodes and decodes with schema and codec,
+// using cl to register and look up schemas.
+func NewSerde(cl *Client, schema Schema, codec Codec, opts ...SerdeOpt) *Serde {
+	s := &Serde{
+		cl:       cl,
+		schema:   schema,
+		codec:    codec,
+		strategy: TopicNameStrategy,
+		index:    []int{0},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Subject returns the subject the serde registers its schema under for topic.
+func (s *Serde) Subject(topic string) string {
+	return s.strategy(topic, s.isKey, s.recordName)
+}
+
+// Encode encodes v for topic, registering the schema (or looking up its ID
+// if auto registering is disabled) on the first encode for the topic's
+// subject.
+func (s *Serde) Encode(ctx context.Context, topic string, v interface{}) ([]byte, error) {
+	id, err := s.cl.idFor(ctx, s.Subject(topic), s.schema, !s.noRegister)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load schema id: %w", err)
+	}
+	payload, err := s.codec.Encode(s.schema, v)
+	if err != nil {
+		return nil, err
+	}
+	return append(AppendHeader(nil, id, s.schema.Type, s.index), payload...), nil
+}
+
+// Decode decodes a wire format payload into v, loading the schema the
+// payload was written with from the registry.
+func (s *Serde) Decode(ctx context.Context, b []byte, v interface{}) error {
+	id, _, payload, err := DecodeHeader(b, s.schema.Type)
+	if err != nil {
+		return err
+	}
+	schema, err := s.cl.SchemaByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("unable to load schema %d: %w", id, err)
+	}
+	return s.codec.Decode(schema, payload, v)
+}
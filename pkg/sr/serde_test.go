@@ -0,0 +1,88 @@
+package sr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		typ   SchemaType
+		index []int
+		exp   []int
+	}{
+		{TypeAvro, nil, nil},
+		{TypeProtobuf, []int{0}, []int{0}},
+		{TypeProtobuf, []int{1, 0, 2}, []int{1, 0, 2}},
+	} {
+		b := AppendHeader(nil, 300, test.typ, test.index)
+		b = append(b, "payload"...)
+		id, index, payload, err := DecodeHeader(b, test.typ)
+		if err != nil {
+			t.Errorf("%v %v: unexpected err: %v", test.typ, test.index, err)
+			continue
+		}
+		if id != 300 || !reflect.DeepEqual(index, test.exp) || string(payload) != "payload" {
+			t.Errorf("%v %v: got id %d, index %v, payload %q", test.typ, test.index, id, index, payload)
+		}
+	}
+}
+
+func TestSerdeRoundTrip(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		subjects  = make(map[string]int)
+		registers int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/subjects/t-value/versions":
+			registers++
+			subjects["t-value"] = 7
+			w.Write([]byte(`{"id":7}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/schemas/ids/7":
+			w.Write([]byte(`{"schema":"{}","schemaType":"JSON"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40401,"message":"not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	codec := Codec{
+		Encode: func(_ Schema, v interface{}) ([]byte, error) { return json.Marshal(v) },
+		Decode: func(_ Schema, b []byte, v interface{}) error { return json.Unmarshal(b, v) },
+	}
+	serde := NewSerde(NewClient(srv.URL), Schema{Schema: "{}", Type: TypeJSON}, codec)
+
+	type value struct{ A int }
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		b, err := serde.Encode(ctx, "t", value{A: i})
+		if err != nil {
+			t.Fatalf("unable to encode: %v", err)
+		}
+		var got value
+		if err := serde.Decode(ctx, b, &got); err != nil {
+			t.Fatalf("unable to decode: %v", err)
+		}
+		if got.A != i {
+			t.Errorf("got %d != exp %d", got.A, i)
+		}
+	}
+	if registers != 1 {
+		t.Errorf("got %d registers != exp 1", registers)
+	}
+
+	_, err := NewClient(srv.URL).LookupID(ctx, "missing", Schema{})
+	if re, ok := err.(*ResponseError); !ok || re.ErrorCode != 40401 {
+		t.Errorf("got err %v, exp registry 40401 error", err)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build go1.18
+// +build go1.18
+
+package sr
+
+import (
+	"context"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// NewTypedSerde returns a kgo.TypedSerde that encodes and decodes T with s,
+// for use with a kgo.TypedClient. Null keys or values (such as tombstones)
+// decode to the zero value of T.
+//
+// The TypedSerde functions do not take a context; registry requests made
+// while serializing or deserializing use context.Background, and are only
+// made the first time a schema or schema ID is needed.
+func NewTypedSerde[T any](s *Serde) kgo.TypedSerde[T] {
+	return kgo.TypedSerde[T]{
+		Deserialize: func(b []byte) (T, error) {
+			var v T
+			if b == nil {
+				return v, nil
+			}
+			err := s.Decode(context.Background(), b, &v)
+			return v, err
+		},
+		Serialize: func(r *kgo.Record, v T) ([]byte, error) {
+			return s.Encode(context.Background(), r.Topic, v)
+		},
+	}
+}
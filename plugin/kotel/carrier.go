@@ -0,0 +1,54 @@
+package kotel
+
+import (
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+var _ propagation.TextMapCarrier = RecordCarrier{}
+
+// RecordCarrier injects and extracts traces from a kgo.Record's headers.
+//
+// This type implements the otel propagation.TextMapCarrier interface.
+type RecordCarrier struct {
+	record *kgo.Record
+}
+
+// NewRecordCarrier creates a new RecordCarrier.
+func NewRecordCarrier(record *kgo.Record) RecordCarrier {
+	return RecordCarrier{record: record}
+}
+
+// Get retrieves a single value for a given key if it exists.
+func (c RecordCarrier) Get(key string) string {
+	for _, h := range c.record.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set sets a header, replacing any existing header with the same key.
+func (c RecordCarrier) Set(key, val string) {
+	for i, h := range c.record.Headers {
+		if h.Key == key {
+			c.record.Headers[i].Value = []byte(val)
+			return
+		}
+	}
+	c.record.Headers = append(c.record.Headers, kgo.RecordHeader{
+		Key:   key,
+		Value: []byte(val),
+	})
+}
+
+// Keys returns a slice of all header keys.
+func (c RecordCarrier) Keys() []string {
+	out := make([]string, len(c.record.Headers))
+	for i, h := range c.record.Headers {
+		out[i] = h.Key
+	}
+	return out
+}
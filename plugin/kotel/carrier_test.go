@@ -0,0 +1,34 @@
+package kotel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestRecordCarrierRoundTrip(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	tracer := NewTracer(TracerPropagator(propagation.TraceContext{}))
+	r := &kgo.Record{Headers: []kgo.RecordHeader{{Key: "foo", Value: []byte("bar")}}}
+	tracer.OnProduceRecord(ctx, r)
+	tracer.OnProduceRecord(ctx, r) // injecting twice must not duplicate headers
+
+	if len(r.Headers) != 2 {
+		t.Fatalf("got %d headers, exp 2", len(r.Headers))
+	}
+
+	extracted := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), NewRecordCarrier(r)))
+	if extracted.TraceID() != sc.TraceID() || extracted.SpanID() != sc.SpanID() {
+		t.Errorf("got extracted span context %v != exp %v", extracted, sc)
+	}
+}
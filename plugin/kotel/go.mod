@@ -0,0 +1,20 @@
+module github.com/twmb/franz-go/plugin/kotel
+
+go 1.18
+
+require (
+	github.com/twmb/franz-go v0.6.14
+	go.opentelemetry.io/otel v1.11.1
+	go.opentelemetry.io/otel/trace v1.11.1
+)
+
+require (
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/golang/snappy v0.0.3 // indirect
+	github.com/klauspost/compress v1.12.2 // indirect
+	github.com/pierrec/lz4/v4 v4.1.6 // indirect
+	github.com/twmb/go-rbtree v1.0.0 // indirect
+)
+
+replace github.com/twmb/franz-go => ../../
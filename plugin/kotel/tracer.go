@@ -0,0 +1,152 @@
+package kotel
+
+import (
+	"context"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+const instrumentationName = "github.com/twmb/franz-go/plugin/kotel"
+
+var ( // interface checks to ensure we implement the hooks properly
+	_ kgo.BrokerRequestHook = new(Tracer)
+	_ kgo.ProduceRecordHook = new(Tracer)
+)
+
+// Tracer creates a span per Kafka request issued by a client and injects
+// trace context into the headers of produced records.
+type Tracer struct {
+	tracerProvider trace.TracerProvider
+	propagators    propagation.TextMapPropagator
+	tracer         trace.Tracer
+}
+
+// TracerOpt interface used for setting optional config properties.
+type TracerOpt interface {
+	apply(*Tracer)
+}
+
+type tracerOpt struct{ fn func(*Tracer) }
+
+func (o tracerOpt) apply(t *Tracer) { o.fn(t) }
+
+// TracerProvider takes a trace.TracerProvider and applies it to the Tracer,
+// overriding the default of the global tracer provider.
+func TracerProvider(provider trace.TracerProvider) TracerOpt {
+	return tracerOpt{func(t *Tracer) { t.tracerProvider = provider }}
+}
+
+// TracerPropagator takes a propagation.TextMapPropagator and applies it to the
+// Tracer, overriding the default of the global text map propagator.
+func TracerPropagator(propagator propagation.TextMapPropagator) TracerOpt {
+	return tracerOpt{func(t *Tracer) { t.propagators = propagator }}
+}
+
+// NewTracer returns a Tracer, used as a hook in a kgo client:
+//
+//     tracer := kotel.NewTracer()
+//     cl, err := kgo.NewClient(
+//             kgo.WithHooks(tracer),
+//             // ...other opts
+//     )
+//
+// A span is started when a request is enqueued to be issued to a broker and
+// is ended once the request's response is received or the request fails. The
+// span is a child of any span in the context the request was issued with.
+//
+// Records produced with a context containing a span have that span's context
+// injected into their headers, allowing consumers to continue the trace; see
+// WithProcessSpan.
+func NewTracer(opts ...TracerOpt) *Tracer {
+	t := &Tracer{}
+	for _, opt := range opts {
+		opt.apply(t)
+	}
+	if t.tracerProvider == nil {
+		t.tracerProvider = otel.GetTracerProvider()
+	}
+	if t.propagators == nil {
+		t.propagators = otel.GetTextMapPropagator()
+	}
+	t.tracer = t.tracerProvider.Tracer(instrumentationName)
+	return t
+}
+
+// OnBrokerRequest implements the kgo.BrokerRequestHook interface, starting a
+// span for the request and returning a function to end it.
+func (t *Tracer) OnBrokerRequest(ctx context.Context, meta kgo.BrokerMetadata, req kmsg.Request) func(kgo.BrokerRequestResult) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	name := kmsg.NameForKey(req.Key())
+	_, span := t.tracer.Start(ctx, "kafka "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.kafka.request", name),
+			attribute.Int("messaging.kafka.request_key", int(req.Key())),
+			attribute.Int("messaging.kafka.broker_id", int(meta.NodeID)),
+			attribute.String("net.peer.name", meta.Host),
+			attribute.String("net.peer.port", strconv.Itoa(int(meta.Port))),
+		),
+	)
+	return func(r kgo.BrokerRequestResult) {
+		span.SetAttributes(
+			attribute.Int("messaging.kafka.request_version", int(r.Version)),
+			attribute.Int("messaging.kafka.correlation_id", int(r.CorrelationID)),
+			attribute.Int("messaging.kafka.bytes_written", r.BytesWritten),
+			attribute.Int("messaging.kafka.bytes_read", r.BytesRead),
+		)
+		if r.Err != nil {
+			span.RecordError(r.Err)
+			span.SetStatus(codes.Error, r.Err.Error())
+		}
+		span.End()
+	}
+}
+
+// OnProduceRecord implements the kgo.ProduceRecordHook interface, injecting
+// the trace context of the produce context into the record's headers.
+func (t *Tracer) OnProduceRecord(ctx context.Context, r *kgo.Record) {
+	if ctx == nil {
+		return
+	}
+	t.propagators.Inject(ctx, NewRecordCarrier(r))
+}
+
+// WithProcessSpan extracts any trace context injected into the headers of a
+// consumed record and starts a consumer span continuing that trace, returning
+// the span and a context containing it.
+//
+// This is meant to be used while processing consumed records, so that each
+// record's processing continues the trace of whoever produced the record:
+//
+//     for _, record := range fetches.Records() {
+//             ctx, span := tracer.WithProcessSpan(record)
+//             process(ctx, record)
+//             span.End()
+//     }
+//
+// If the record has no trace context, the returned span starts a new trace.
+// The caller is responsible for ending the span.
+func (t *Tracer) WithProcessSpan(r *kgo.Record) (context.Context, trace.Span) {
+	ctx := t.propagators.Extract(context.Background(), NewRecordCarrier(r))
+	return t.tracer.Start(ctx, r.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination", r.Topic),
+			attribute.String("messaging.operation", "process"),
+			attribute.Int("messaging.kafka.partition", int(r.Partition)),
+			attribute.Int64("messaging.kafka.message_offset", r.Offset),
+		),
+	)
+}
@@ -0,0 +1,341 @@
+// Package kprom provides prometheus plug-in metrics for a kgo client.
+//
+// This package tracks the following metrics under the following names,
+// all metrics being counter vecs:
+//
+//	#{ns}_connects_total{node_id="#{node}"}
+//	#{ns}_connect_errors_total{node_id="#{node}"}
+//	#{ns}_disconnects_total{node_id="#{node}"}
+//	#{ns}_write_errors_total{node_id="#{node}"}
+//	#{ns}_write_bytes_total{node_id="#{node}"}
+//	#{ns}_read_errors_total{node_id="#{node}"}
+//	#{ns}_read_bytes_total{node_id="#{node}"}
+//	#{ns}_throttles_total{node_id="#{node}"}
+//
+// The following gauge vec is tracked:
+//
+//	#{ns}_open_connections{node_id="#{node}"}
+//
+// The following histogram vecs are tracked, in seconds:
+//
+//	#{ns}_write_wait_seconds{node_id="#{node}",request="#{key}"}
+//	#{ns}_write_seconds{node_id="#{node}",request="#{key}"}
+//	#{ns}_read_wait_seconds{node_id="#{node}",request="#{key}"}
+//	#{ns}_read_seconds{node_id="#{node}",request="#{key}"}
+//	#{ns}_throttle_seconds{node_id="#{node}"}
+//
+// The read and write histograms are labeled with the name of the request
+// being issued (e.g. "Produce", "Fetch"), which allows tracking request
+// latency by key. The write wait is the time a request spent waiting to be
+// written, and the read wait is the time between a request being written
+// and its response beginning to be read.
+//
+// This can be used in a client like so:
+//
+//	m := kprom.NewMetrics()
+//	cl, err := kgo.NewClient(
+//	        kgo.WithHooks(m),
+//	        // ...other opts
+//	)
+//
+// By default, metrics are installed under the a new prometheus registry, but
+// this can be overridden with the Registry option.
+//
+// Note that seed brokers use broker IDs starting at math.MinInt32.
+package kprom
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+var ( // interface checks to ensure we implement the hooks properly
+	_ kgo.BrokerConnectHook    = new(Metrics)
+	_ kgo.BrokerDisconnectHook = new(Metrics)
+	_ kgo.BrokerWriteHook      = new(Metrics)
+	_ kgo.BrokerReadHook       = new(Metrics)
+	_ kgo.BrokerThrottleHook   = new(Metrics)
+)
+
+// Metrics provides prometheus metrics to a given registry.
+type Metrics struct {
+	cfg cfg
+
+	connects    *prometheus.CounterVec
+	connectErrs *prometheus.CounterVec
+	disconnects *prometheus.CounterVec
+	openConns   *prometheus.GaugeVec
+
+	writeErrs    *prometheus.CounterVec
+	writeBytes   *prometheus.CounterVec
+	writeWaits   *prometheus.HistogramVec
+	writeTimings *prometheus.HistogramVec
+
+	readErrs    *prometheus.CounterVec
+	readBytes   *prometheus.CounterVec
+	readWaits   *prometheus.HistogramVec
+	readTimings *prometheus.HistogramVec
+
+	throttles     *prometheus.CounterVec
+	throttleTimes *prometheus.HistogramVec
+}
+
+// Registry returns the prometheus registry that metrics were added to.
+//
+// This is useful if you want the Metrics type to create its own registry for
+// you to add additional metrics to.
+func (m *Metrics) Registry() prometheus.Registerer {
+	return m.cfg.reg
+}
+
+// Handler returns an http.Handler providing prometheus metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.cfg.gatherer, m.cfg.handlerOpts)
+}
+
+type cfg struct {
+	namespace string
+
+	reg      prometheus.Registerer
+	gatherer prometheus.Gatherer
+
+	handlerOpts  promhttp.HandlerOpts
+	goCollectors bool
+}
+
+// Opt applies options to further tune how prometheus metrics are gathered or
+// which metrics to use.
+type Opt interface {
+	apply(*cfg)
+}
+
+type opt struct{ fn func(*cfg) }
+
+func (o opt) apply(c *cfg) { o.fn(c) }
+
+// Namespace sets the namespace for all metrics, overriding the default "kgo".
+func Namespace(namespace string) Opt {
+	return opt{func(c *cfg) { c.namespace = namespace }}
+}
+
+// Registry sets the registerer and gatherer to add metrics to, rather than a
+// new registry. Use this option if you want to configure both Gatherer and
+// Registerer with the same object.
+func Registry(rg prometheus.Registerer) Opt {
+	return opt{func(c *cfg) {
+		c.reg = rg
+		if gatherer, ok := rg.(prometheus.Gatherer); ok {
+			c.gatherer = gatherer
+		}
+	}}
+}
+
+// Registerer sets the registerer to add register to, rather than a new
+// registry.
+func Registerer(reg prometheus.Registerer) Opt {
+	return opt{func(c *cfg) { c.reg = reg }}
+}
+
+// Gatherer sets the gatherer to add gather to, rather than a new registry.
+func Gatherer(gatherer prometheus.Gatherer) Opt {
+	return opt{func(c *cfg) { c.gatherer = gatherer }}
+}
+
+// GoCollectors adds the prometheus.NewProcessCollector and
+// prometheus.NewGoCollector collectors to the Metric's registry.
+func GoCollectors() Opt {
+	return opt{func(c *cfg) { c.goCollectors = true }}
+}
+
+// HandlerOpts sets handler options to use if you wish you use the
+// Metrics.Handler function.
+//
+// This is only useful if you both (a) do not want to provide your own registry
+// and (b) want to override the default handler options.
+func HandlerOpts(opts promhttp.HandlerOpts) Opt {
+	return opt{func(c *cfg) { c.handlerOpts = opts }}
+}
+
+// NewMetrics returns a new Metrics that adds prometheus metrics to the
+// registry under the given options.
+func NewMetrics(opts ...Opt) *Metrics {
+	reg := prometheus.NewRegistry()
+	c := cfg{
+		namespace: "kgo",
+		reg:       reg,
+		gatherer:  reg,
+	}
+	for _, opt := range opts {
+		opt.apply(&c)
+	}
+
+	if c.goCollectors {
+		c.reg.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+		c.reg.MustRegister(prometheus.NewGoCollector())
+	}
+
+	factory := promauto.With(c.reg)
+	namespace := c.namespace
+	buckets := prometheus.ExponentialBuckets(0.0001, 1.5, 20)
+
+	return &Metrics{
+		cfg: c,
+
+		// connects and disconnects
+
+		connects: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "connects_total",
+			Help:      "Total number of connections opened, by broker",
+		}, []string{"node_id"}),
+
+		connectErrs: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "connect_errors_total",
+			Help:      "Total number of connection errors, by broker",
+		}, []string{"node_id"}),
+
+		disconnects: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "disconnects_total",
+			Help:      "Total number of connections closed, by broker",
+		}, []string{"node_id"}),
+
+		openConns: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "open_connections",
+			Help:      "Number of connections currently open, by broker",
+		}, []string{"node_id"}),
+
+		// write
+
+		writeErrs: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "write_errors_total",
+			Help:      "Total number of write errors, by broker",
+		}, []string{"node_id"}),
+
+		writeBytes: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "write_bytes_total",
+			Help:      "Total number of bytes written, by broker",
+		}, []string{"node_id"}),
+
+		writeWaits: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "write_wait_seconds",
+			Help:      "Time spent waiting to write to Kafka, in seconds by broker and request",
+			Buckets:   buckets,
+		}, []string{"node_id", "request"}),
+
+		writeTimings: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "write_seconds",
+			Help:      "Time spent writing to Kafka, in seconds by broker and request",
+			Buckets:   buckets,
+		}, []string{"node_id", "request"}),
+
+		// read
+
+		readErrs: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "read_errors_total",
+			Help:      "Total number of read errors, by broker",
+		}, []string{"node_id"}),
+
+		readBytes: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "read_bytes_total",
+			Help:      "Total number of bytes read, by broker",
+		}, []string{"node_id"}),
+
+		readWaits: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "read_wait_seconds",
+			Help:      "Time spent waiting to read from Kafka, in seconds by broker and request",
+			Buckets:   buckets,
+		}, []string{"node_id", "request"}),
+
+		readTimings: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "read_seconds",
+			Help:      "Time spent reading from Kafka, in seconds by broker and request",
+			Buckets:   buckets,
+		}, []string{"node_id", "request"}),
+
+		// throttles
+
+		throttles: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "throttles_total",
+			Help:      "Total number of throttled responses, by broker",
+		}, []string{"node_id"}),
+
+		throttleTimes: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "throttle_seconds",
+			Help:      "Time Kafka throttled requests, in seconds by broker",
+			Buckets:   buckets,
+		}, []string{"node_id"}),
+	}
+}
+
+// OnConnect implements the kgo.BrokerConnectHook interface.
+func (m *Metrics) OnConnect(meta kgo.BrokerMetadata, _ time.Duration, _ net.Conn, err error) {
+	node := strconv.Itoa(int(meta.NodeID))
+	if err != nil {
+		m.connectErrs.WithLabelValues(node).Inc()
+		return
+	}
+	m.connects.WithLabelValues(node).Inc()
+	m.openConns.WithLabelValues(node).Inc()
+}
+
+// OnDisconnect implements the kgo.BrokerDisconnectHook interface.
+func (m *Metrics) OnDisconnect(meta kgo.BrokerMetadata, _ net.Conn) {
+	node := strconv.Itoa(int(meta.NodeID))
+	m.disconnects.WithLabelValues(node).Inc()
+	m.openConns.WithLabelValues(node).Dec()
+}
+
+// OnWrite implements the kgo.BrokerWriteHook interface.
+func (m *Metrics) OnWrite(meta kgo.BrokerMetadata, key int16, bytesWritten int, writeWait, timeToWrite time.Duration, err error) {
+	node := strconv.Itoa(int(meta.NodeID))
+	if err != nil {
+		m.writeErrs.WithLabelValues(node).Inc()
+		return
+	}
+	request := kmsg.NameForKey(key)
+	m.writeBytes.WithLabelValues(node).Add(float64(bytesWritten))
+	m.writeWaits.WithLabelValues(node, request).Observe(writeWait.Seconds())
+	m.writeTimings.WithLabelValues(node, request).Observe(timeToWrite.Seconds())
+}
+
+// OnRead implements the kgo.BrokerReadHook interface.
+func (m *Metrics) OnRead(meta kgo.BrokerMetadata, key int16, bytesRead int, readWait, timeToRead time.Duration, err error) {
+	node := strconv.Itoa(int(meta.NodeID))
+	if err != nil {
+		m.readErrs.WithLabelValues(node).Inc()
+		return
+	}
+	request := kmsg.NameForKey(key)
+	m.readBytes.WithLabelValues(node).Add(float64(bytesRead))
+	m.readWaits.WithLabelValues(node, request).Observe(readWait.Seconds())
+	m.readTimings.WithLabelValues(node, request).Observe(timeToRead.Seconds())
+}
+
+// OnThrottle implements the kgo.BrokerThrottleHook interface.
+func (m *Metrics) OnThrottle(meta kgo.BrokerMetadata, throttleInterval time.Duration, _ bool) {
+	node := strconv.Itoa(int(meta.NodeID))
+	m.throttles.WithLabelValues(node).Inc()
+	m.throttleTimes.WithLabelValues(node).Observe(throttleInterval.Seconds())
+}
@@ -0,0 +1,114 @@
+package kprom
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	meta := kgo.BrokerMetadata{NodeID: 1}
+	errFail := errors.New("fail")
+
+	m.OnConnect(meta, time.Millisecond, nil, nil)
+	m.OnConnect(meta, time.Millisecond, nil, nil)
+	m.OnConnect(meta, time.Millisecond, nil, errFail)
+	m.OnDisconnect(meta, nil)
+
+	m.OnWrite(meta, 0, 100, time.Millisecond, time.Millisecond, nil)
+	m.OnWrite(meta, 1, 50, time.Millisecond, time.Millisecond, nil)
+	m.OnWrite(meta, 0, 0, 0, 0, errFail)
+
+	m.OnRead(meta, 1, 200, time.Millisecond, time.Millisecond, nil)
+	m.OnRead(meta, 1, 0, 0, 0, errFail)
+
+	m.OnThrottle(meta, time.Second, false)
+
+	for _, test := range []struct {
+		name string
+		c    prometheus.Collector
+		exp  float64
+	}{
+		{"connects", m.connects.WithLabelValues("1"), 2},
+		{"connect errors", m.connectErrs.WithLabelValues("1"), 1},
+		{"disconnects", m.disconnects.WithLabelValues("1"), 1},
+		{"open connections", m.openConns.WithLabelValues("1"), 1},
+		{"write errors", m.writeErrs.WithLabelValues("1"), 1},
+		{"write bytes", m.writeBytes.WithLabelValues("1"), 150},
+		{"read errors", m.readErrs.WithLabelValues("1"), 1},
+		{"read bytes", m.readBytes.WithLabelValues("1"), 200},
+		{"throttles", m.throttles.WithLabelValues("1"), 1},
+	} {
+		if got := testutil.ToFloat64(test.c); got != test.exp {
+			t.Errorf("%s: got %v != exp %v", test.name, got, test.exp)
+		}
+	}
+
+	// Histograms are labeled by request name, and errors are not
+	// observed.
+	for _, test := range []struct {
+		name string
+		c    prometheus.Collector
+		exp  int
+	}{
+		{"write waits", m.writeWaits, 2},
+		{"write timings", m.writeTimings, 2},
+		{"read waits", m.readWaits, 1},
+		{"read timings", m.readTimings, 1},
+		{"throttle times", m.throttleTimes, 1},
+	} {
+		if got := testutil.CollectAndCount(test.c); got != test.exp {
+			t.Errorf("%s: got %d series != exp %d", test.name, got, test.exp)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := NewMetrics(Namespace("ns"))
+	m.OnWrite(kgo.BrokerMetadata{NodeID: 2}, 0, 10, time.Millisecond, time.Millisecond, nil)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{
+		`ns_write_bytes_total{node_id="2"} 10`,
+		`ns_write_seconds_count{node_id="2",request="Produce"} 1`,
+	} {
+		if !strings.Contains(string(body), exp) {
+			t.Errorf("handler output missing %q:\n%s", exp, body)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewMetrics(Registry(reg))
+	if m.Registry() != reg {
+		t.Error("Registry did not return the registry option")
+	}
+	m.OnThrottle(kgo.BrokerMetadata{NodeID: 3}, time.Second, true)
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, mf := range mfs {
+		found = found || mf.GetName() == "kgo_throttles_total"
+	}
+	if !found {
+		t.Error("throttles were not registered to the given registry")
+	}
+}